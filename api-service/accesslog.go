@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// AccessLogEntry is one line of the access log
+type AccessLogEntry struct {
+	Time       string  `json:"time"`
+	Method     string  `json:"method"`
+	Path       string  `json:"path"`
+	Status     int     `json:"status"`
+	Bytes      int64   `json:"bytes"`
+	DurationMs float64 `json:"durationMs"`
+	ClientIP   string  `json:"clientIp"`
+	RequestId  string  `json:"requestId"`
+}
+
+// accessLogWriter writes access log lines to ACCESS_LOG_FILE, or stdout when it is unset. The file
+// is opened in append mode and can be reopened after logrotate has moved it away.
+type accessLogWriter struct {
+	mu   sync.Mutex
+	path string
+	out  io.Writer
+	file *os.File
+}
+
+// accessLog is the process-wide access log used by routes
+var accessLog = &accessLogWriter{out: os.Stdout}
+
+// open switches the log to path, or to stdout when path is empty
+func (a *accessLogWriter) open(path string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.path = path
+	return a.reopenLocked()
+}
+
+// reopen reopens the configured file, e.g. on SIGHUP after rotation
+func (a *accessLogWriter) reopen() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.reopenLocked()
+}
+
+// reopenLocked opens a.path and closes the previous file; a.mu must be held. On error the previous
+// file stays in use so no lines are lost.
+func (a *accessLogWriter) reopenLocked() error {
+	if a.path == "" {
+		a.closeLocked()
+		a.out = os.Stdout
+		return nil
+	}
+	f, err := os.OpenFile(a.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	a.closeLocked()
+	a.file, a.out = f, f
+	return nil
+}
+
+// closeLocked closes the current file, if any; a.mu must be held
+func (a *accessLogWriter) closeLocked() {
+	if a.file != nil {
+		a.file.Close()
+		a.file = nil
+	}
+}
+
+// write appends entry as a single JSON line
+func (a *accessLogWriter) write(entry AccessLogEntry) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("Error encoding access log entry: %v", err)
+		return
+	}
+	line = append(line, '\n')
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.out.Write(line); err != nil {
+		log.Printf("Error writing access log: %v", err)
+	}
+}
+
+// reopenOnSIGHUP reopens a each time the process receives SIGHUP; it never returns
+func reopenOnSIGHUP(a *accessLogWriter) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		if err := a.reopen(); err != nil {
+			log.Printf("Error reopening access log: %v", err)
+			continue
+		}
+		log.Println("Reopened access log")
+	}
+}
+
+// byteCounter wraps a ResponseWriter to count the body bytes written
+type byteCounter struct {
+	http.ResponseWriter
+	bytes int64
+}
+
+func (c *byteCounter) Write(b []byte) (int, error) {
+	n, err := c.ResponseWriter.Write(b)
+	c.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying ResponseWriter
+func (c *byteCounter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// accessLogMiddleware writes one access log line for every completed request, unsampled
+func accessLogMiddleware(next http.Handler, out *accessLogWriter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		counter := &byteCounter{ResponseWriter: w}
+		rec := &statusRecorder{ResponseWriter: counter}
+		next.ServeHTTP(rec, r)
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		out.write(AccessLogEntry{
+			Time:       start.UTC().Format(time.RFC3339Nano),
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Status:     status,
+			Bytes:      counter.bytes,
+			DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+			ClientIP:   clientIP(r),
+			RequestId:  requestIDFrom(r.Context()),
+		})
+	})
+}
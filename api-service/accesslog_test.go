@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readAccessLog returns the entries written to path
+func readAccessLog(t *testing.T, path string) []AccessLogEntry {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading access log: %v", err)
+	}
+	var entries []AccessLogEntry
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry AccessLogEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("access log line %q is not JSON: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// TestAccessLogMiddleware_Fields tests that a completed request writes one line with all fields
+func TestAccessLogMiddleware_Fields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	out := &accessLogWriter{}
+	if err := out.open(path); err != nil {
+		t.Fatal(err)
+	}
+	defer out.open("")
+
+	handler := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	}), requestIDMiddleware, func(next http.Handler) http.Handler { return accessLogMiddleware(next, out) })
+
+	req := httptest.NewRequest(http.MethodPost, "/order", nil)
+	req.RemoteAddr = "203.0.113.9:5555"
+	req.Header.Set("X-Request-Id", "req-123")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if got := rr.Header().Get("X-Request-Id"); got != "req-123" {
+		t.Errorf("X-Request-Id = %q, want req-123", got)
+	}
+	entries := readAccessLog(t, path)
+	if len(entries) != 1 {
+		t.Fatalf("got %d access log lines, want 1", len(entries))
+	}
+	got := entries[0]
+	if got.Method != http.MethodPost || got.Path != "/order" || got.Status != http.StatusCreated || got.Bytes != 5 ||
+		got.ClientIP != "203.0.113.9" || got.RequestId != "req-123" || got.Time == "" || got.DurationMs < 0 {
+		t.Errorf("access log entry = %+v", got)
+	}
+}
+
+// TestAccessLogWriter_Reopen tests that reopening after a rotation writes to a fresh file
+func TestAccessLogWriter_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+	out := &accessLogWriter{}
+	if err := out.open(path); err != nil {
+		t.Fatal(err)
+	}
+	defer out.open("")
+
+	out.write(AccessLogEntry{Path: "/before"})
+	if err := os.Rename(path, filepath.Join(dir, "access.log.1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.reopen(); err != nil {
+		t.Fatal(err)
+	}
+	out.write(AccessLogEntry{Path: "/after"})
+
+	if entries := readAccessLog(t, path); len(entries) != 1 || entries[0].Path != "/after" {
+		t.Errorf("entries after reopen = %+v, want only /after", entries)
+	}
+	if entries := readAccessLog(t, filepath.Join(dir, "access.log.1")); len(entries) != 1 || entries[0].Path != "/before" {
+		t.Errorf("rotated entries = %+v, want only /before", entries)
+	}
+}
+
+// TestRequestIDMiddleware_GeneratesID tests that a missing or unusable X-Request-Id is replaced
+func TestRequestIDMiddleware_GeneratesID(t *testing.T) {
+	for _, incoming := range []string{"", "has spaces", strings.Repeat("x", maxRequestIDLength+1)} {
+		var seen string
+		handler := requestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = requestIDFrom(r.Context())
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/products", nil)
+		req.Header.Set("X-Request-Id", incoming)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if seen == "" || seen == incoming || rr.Header().Get("X-Request-Id") != seen {
+			t.Errorf("incoming %q: context id %q, header %q", incoming, seen, rr.Header().Get("X-Request-Id"))
+		}
+	}
+}
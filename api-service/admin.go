@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"log"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// requireAdmin only lets requests through whose bearer token is a login token for the admin role
+// or, as a break-glass path that works without any user store, ADMIN_TOKEN itself. Other callers get
+// 403. Admin endpoints are disabled entirely when ADMIN_TOKEN is not set and bearer tokens are not
+// accepted (see bearerSecret).
+func requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		adminToken := os.Getenv("ADMIN_TOKEN")
+		secret, tokensAccepted := bearerSecret()
+		if adminToken == "" && !tokensAccepted {
+			log.Printf("Rejected admin request to %s: ADMIN_TOKEN is not set", r.URL.Path)
+			writeError(w, r, http.StatusForbidden, "Admin endpoints are disabled")
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			writeError(w, r, http.StatusUnauthorized, "Admin token required")
+			return
+		}
+		if adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if tokensAccepted {
+			if id, err := parseToken(token, secret, clock.Now()); err == nil {
+				if id.Role != "admin" {
+					log.Printf("Rejected admin request to %s from %s: role %q is not admin", r.URL.Path, id.Username, id.Role)
+					writeError(w, r, http.StatusForbidden, "Admin role required")
+					return
+				}
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, id)))
+				return
+			}
+		}
+		log.Printf("Rejected admin request to %s: invalid token", r.URL.Path)
+		writeError(w, r, http.StatusForbidden, "Invalid admin token")
+	})
+}
+
+// adminPort is the port of the separate admin listener from ADMIN_PORT; empty serves admin endpoints
+// on the public port
+func adminPort() string {
+	return os.Getenv("ADMIN_PORT")
+}
+
+// maintenanceMode is set via POST /admin/maintenance; while enabled, orders are refused
+// and /products serves the cached catalog without refetching
+var maintenanceMode atomic.Bool
+
+// MaintenanceRequest toggles maintenance mode
+type MaintenanceRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// MaintenanceResponse reports the current maintenance mode
+type MaintenanceResponse struct {
+	Maintenance bool `json:"maintenance"`
+}
+
+// maintenanceHandler reports (GET) or sets (POST) maintenance mode
+func maintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req MaintenanceRequest
+		if err := decodeJSON(r, &req, "Invalid maintenance request body"); err != nil {
+			writeRequestError(w, r, err)
+			return
+		}
+		maintenanceMode.Store(req.Enabled)
+		log.Printf("Maintenance mode set to %v", req.Enabled)
+	default:
+		methodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, MaintenanceResponse{Maintenance: maintenanceMode.Load()})
+}
+
+// rejectDuringMaintenance responds 503 with a Retry-After when maintenance mode is on and reports whether it did
+func rejectDuringMaintenance(w http.ResponseWriter, r *http.Request) bool {
+	if !maintenanceMode.Load() {
+		return false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(envInt("MAINTENANCE_RETRY_AFTER", 300)))
+	writeError(w, r, http.StatusServiceUnavailable, "maintenance")
+	return true
+}
+
+// ProductsRefreshResponse reports the catalog fetched by POST /admin/products/refresh
+type ProductsRefreshResponse struct {
+	Products int            `json:"products"`          // Number of products in the refetched catalog, summed over tenants
+	Tenants  map[string]int `json:"tenants,omitempty"` // With TENANT_UPSTREAMS_FILE, the number for each tenant
+}
+
+// productsRefreshHandler drops the cached catalogs and refetches the default one from the Dotnet
+// service, so upstream catalog changes show up without waiting for PRODUCTS_CACHE_TTL. With
+// TENANT_UPSTREAMS_FILE set each tenant's default catalog is refetched from its own service instead.
+func productsRefreshHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	productsCache.invalidate()
+	invalidateLocalizedCaches()
+	ttl := envDuration("PRODUCTS_CACHE_TTL", defaultProductsCacheTTL)
+	if path := os.Getenv("TENANT_UPSTREAMS_FILE"); path != "" {
+		refreshTenantProducts(w, r, path, ttl)
+		return
+	}
+	entry, err := productsCache.get(r.Context(), ttl)
+	if err != nil {
+		log.Printf("Error refetching products after invalidation: %v", err)
+		writeUpstreamError(w, r, err)
+		return
+	}
+	log.Printf("Products cache refreshed by admin: %d products", len(entry.Products))
+	writeJSON(w, r, http.StatusOK, ProductsRefreshResponse{Products: len(entry.Products)})
+}
+
+// refreshTenantProducts refetches every tenant's default catalog for productsRefreshHandler. All
+// tenants are tried; the first failure is what the response reports.
+func refreshTenantProducts(w http.ResponseWriter, r *http.Request, path string, ttl time.Duration) {
+	tenants, err := tenantUpstreams.get(path)
+	if err != nil {
+		log.Printf("Error loading tenant upstreams: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	resp := ProductsRefreshResponse{Tenants: make(map[string]int, len(tenants))}
+	var firstErr error
+	for _, id := range slices.Sorted(maps.Keys(tenants)) {
+		ctx := context.WithValue(r.Context(), tenantKey{}, tenantRoute{id: id, instances: tenants[id]})
+		entry, err := productCacheFor(id, "").get(ctx, ttl)
+		if err != nil {
+			log.Printf("Error refetching products of tenant %s after invalidation: %v", id, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		resp.Tenants[id] = len(entry.Products)
+		resp.Products += len(entry.Products)
+	}
+	if firstErr != nil {
+		writeUpstreamError(w, r, firstErr)
+		return
+	}
+	log.Printf("Products caches of %d tenants refreshed by admin: %d products", len(tenants), resp.Products)
+	writeJSON(w, r, http.StatusOK, resp)
+}
+
+// configHandler serves the effective configuration with the same redaction as the startup log,
+// so settings can be checked without shelling into the container
+func configHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, loadConfig())
+}
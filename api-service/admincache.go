@@ -0,0 +1,188 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// CacheEntry describes one cached entry listed by GET /admin/cache
+type CacheEntry struct {
+	Key              string `json:"key"`
+	Store            string `json:"store"`                // "process" for this replica's catalogs, else "memory" or "redis"
+	AgeSeconds       *int64 `json:"ageSeconds,omitempty"` // Omitted when the store doesn't record it, as Redis doesn't
+	ExpiresInSeconds int64  `json:"expiresInSeconds"`     // Negative for a catalog past PRODUCTS_CACHE_TTL still served as stale
+}
+
+// CacheEntriesResponse lists the cached entries
+type CacheEntriesResponse struct {
+	Entries []CacheEntry `json:"entries"`
+}
+
+// CacheDeleteResponse reports how many entries a DELETE removed
+type CacheDeleteResponse struct {
+	Deleted int `json:"deleted"`
+}
+
+// namedStore is a Store as shown in the cache listing
+type namedStore struct {
+	name  string
+	store Store
+}
+
+// productCaches returns the default catalog cache followed by the tenants' and localized ones, by
+// tenant and then language
+func productCaches() []*productCache {
+	localizedCaches.mu.Lock()
+	keys := make([]catalogKey, 0, len(localizedCaches.byKey))
+	for key := range localizedCaches.byKey {
+		keys = append(keys, key)
+	}
+	localizedCaches.mu.Unlock()
+	slices.SortFunc(keys, func(a, b catalogKey) int {
+		return cmp.Or(strings.Compare(a.tenant, b.tenant), strings.Compare(a.lang, b.lang))
+	})
+	caches := []*productCache{productsCache}
+	for _, key := range keys {
+		caches = append(caches, productCacheFor(key.tenant, key.lang))
+	}
+	return caches
+}
+
+// cacheStores returns the Stores holding cached state: the shared one, if any, and this process's
+// order nonces, which are the fallback while the shared one fails
+func cacheStores() []namedStore {
+	var stores []namedStore
+	if sharedStore != nil {
+		stores = append(stores, namedStore{"redis", sharedStore})
+	}
+	local := orderNonces.store
+	if orderNonces.fallback != nil {
+		local = orderNonces.fallback
+	}
+	if local != sharedStore {
+		stores = append(stores, namedStore{"memory", local})
+	}
+	return stores
+}
+
+// listCacheEntries lists this process's catalogs and the contents of every cache Store. The catalogs
+// are listed under their shared store keys, so the same key may appear for "process" and "redis".
+func listCacheEntries(ctx context.Context, now time.Time) ([]CacheEntry, error) {
+	ttl := envDuration("PRODUCTS_CACHE_TTL", defaultProductsCacheTTL)
+	entries := []CacheEntry{}
+	for _, c := range productCaches() {
+		if entry := c.cached(); entry != nil {
+			age := now.Sub(entry.FetchedAt)
+			ageSeconds := int64(age.Seconds())
+			entries = append(entries, CacheEntry{Key: c.sharedKey(), Store: "process", AgeSeconds: &ageSeconds, ExpiresInSeconds: int64((ttl - age).Seconds())})
+		}
+	}
+	for _, st := range cacheStores() {
+		list, err := st.store.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range list {
+			entry := CacheEntry{Key: e.Key, Store: st.name, ExpiresInSeconds: int64(e.ExpiresAt.Sub(now).Seconds())}
+			if !e.WrittenAt.IsZero() {
+				ageSeconds := int64(now.Sub(e.WrittenAt).Seconds())
+				entry.AgeSeconds = &ageSeconds
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// deleteCacheEntry removes key wherever it is cached and returns the number of places it was found.
+// A catalog is invalidated rather than just deleted, which also drops its shared copy, so the next
+// request refetches it; a deleted order nonce can be used again.
+func deleteCacheEntry(ctx context.Context, key string) (int, error) {
+	deleted := 0
+	for _, c := range productCaches() {
+		if c.sharedKey() == key && c.cached() != nil {
+			c.invalidate()
+			deleted++
+		}
+	}
+	for _, st := range cacheStores() {
+		_, ok, err := st.store.Get(ctx, key)
+		if err == nil && ok {
+			err = st.store.Delete(ctx, key)
+			deleted++
+		}
+		if err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// cacheHandler lists the cached entries (GET) or deletes every key starting with ?prefix= (DELETE),
+// for clearing stale entries more selectively than POST /admin/products/refresh
+func cacheHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		entries, err := listCacheEntries(r.Context(), time.Now())
+		if err != nil {
+			writeUpstreamError(w, r, &upstreamError{Status: http.StatusBadGateway, Message: "Failed to list the shared cache", Err: err})
+			return
+		}
+		writeJSON(w, r, http.StatusOK, CacheEntriesResponse{Entries: entries})
+	case http.MethodDelete:
+		prefix := r.URL.Query().Get("prefix")
+		if prefix == "" {
+			writeError(w, r, http.StatusBadRequest, "prefix is required; use DELETE /admin/cache/{key} for a single entry")
+			return
+		}
+		entries, err := listCacheEntries(r.Context(), time.Now())
+		if err != nil {
+			writeUpstreamError(w, r, &upstreamError{Status: http.StatusBadGateway, Message: "Failed to list the shared cache", Err: err})
+			return
+		}
+		var keys []string
+		for _, e := range entries {
+			if strings.HasPrefix(e.Key, prefix) && !slices.Contains(keys, e.Key) {
+				keys = append(keys, e.Key)
+			}
+		}
+		total := 0
+		for _, key := range keys {
+			deleted, err := deleteCacheEntry(r.Context(), key)
+			total += deleted
+			if err != nil {
+				writeUpstreamError(w, r, &upstreamError{Status: http.StatusBadGateway, Message: "Failed to delete from the shared cache", Err: err})
+				return
+			}
+		}
+		log.Printf("Cache entries with prefix %q deleted by admin: %d", prefix, total)
+		writeJSON(w, r, http.StatusOK, CacheDeleteResponse{Deleted: total})
+	default:
+		methodNotAllowed(w, r, http.MethodGet, http.MethodDelete)
+	}
+}
+
+// cacheEntryHandler deletes one cached entry by key, answering 404 if it isn't cached anywhere
+func cacheEntryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		methodNotAllowed(w, r, http.MethodDelete)
+		return
+	}
+	key := r.PathValue("key")
+	deleted, err := deleteCacheEntry(r.Context(), key)
+	if err != nil {
+		writeUpstreamError(w, r, &upstreamError{Status: http.StatusBadGateway, Message: "Failed to delete from the shared cache", Err: err})
+		return
+	}
+	if deleted == 0 {
+		writeError(w, r, http.StatusNotFound, "No cache entry "+key)
+		return
+	}
+	log.Printf("Cache entry %q deleted by admin", key)
+	writeJSON(w, r, http.StatusOK, CacheDeleteResponse{Deleted: deleted})
+}
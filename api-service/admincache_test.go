@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// useCacheEntries fills the default catalog cache and a fresh nonce store with nonces n1, n2 and other
+func useCacheEntries(t *testing.T) {
+	t.Helper()
+	newProductsUpstream(t, func() []Product { return []Product{{Id: "p1"}} })
+	if _, err := productsCache.get(context.Background(), time.Minute); err != nil {
+		t.Fatalf("filling the products cache: %v", err)
+	}
+	orig := orderNonces
+	orderNonces = newNonceStore(newMemoryStore(nil))
+	t.Cleanup(func() { orderNonces = orig })
+	for _, nonce := range []string{"n1", "n2", "other"} {
+		claimNonce(t, nonce, time.Minute)
+	}
+}
+
+// cacheRequest sends an admin request to the /admin/cache routes
+func cacheRequest(method, target string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	registerAdminRoutes(mux)
+	req := httptest.NewRequest(method, target, nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	return rr
+}
+
+// listedKeys lists the cache through GET /admin/cache, by key
+func listedKeys(t *testing.T) map[string]CacheEntry {
+	t.Helper()
+	rr := cacheRequest(http.MethodGet, "/admin/cache")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var resp CacheEntriesResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding listing: %v", err)
+	}
+	keys := make(map[string]CacheEntry)
+	for _, e := range resp.Entries {
+		keys[e.Key] = e
+	}
+	return keys
+}
+
+// TestCacheHandler_List tests that the catalog and nonces are listed with their ages, for admins only
+func TestCacheHandler_List(t *testing.T) {
+	withAdminToken(t, "secret")
+	useCacheEntries(t)
+
+	keys := listedKeys(t)
+	catalog, ok := keys["products:catalog"]
+	if !ok || catalog.Store != "process" || catalog.AgeSeconds == nil || *catalog.AgeSeconds != 0 {
+		t.Errorf("catalog entry = %+v, want a fresh process entry", catalog)
+	}
+	if catalog.ExpiresInSeconds <= 0 {
+		t.Errorf("catalog expires in %ds, want it still fresh", catalog.ExpiresInSeconds)
+	}
+	nonce, ok := keys[nonceKey("", "n1")]
+	if !ok || nonce.Store != "memory" || nonce.AgeSeconds == nil || nonce.ExpiresInSeconds > 60 {
+		t.Errorf("nonce entry = %+v, want a memory entry expiring within its window", nonce)
+	}
+	if len(keys) != 4 {
+		t.Errorf("listed %d keys, want the catalog and 3 nonces: %v", len(keys), keys)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/cache", nil)
+	if rr := adminRequest(cacheHandler, req, "wrong"); rr.Code != http.StatusForbidden {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusForbidden)
+	}
+}
+
+// TestCacheEntryHandler_Delete tests deleting one key, a missing key and a prefix
+func TestCacheEntryHandler_Delete(t *testing.T) {
+	withAdminToken(t, "secret")
+	useCacheEntries(t)
+
+	if rr := cacheRequest(http.MethodDelete, "/admin/cache/"+nonceKey("", "n1")); rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if !claimNonce(t, "n1", time.Minute) {
+		t.Errorf("deleted nonce could not be claimed again")
+	}
+	if rr := cacheRequest(http.MethodDelete, "/admin/cache/products:catalog"); rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if productsCache.cached() != nil {
+		t.Errorf("catalog still cached after delete")
+	}
+	if rr := cacheRequest(http.MethodDelete, "/admin/cache/products:catalog"); rr.Code != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+
+	rr := cacheRequest(http.MethodDelete, "/admin/cache?prefix="+nonceKey("", "n"))
+	var resp CacheDeleteResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if rr.Code != http.StatusOK || resp.Deleted != 2 {
+		t.Errorf("prefix delete = %v, %+v, want n1 and n2 deleted", rr.Code, resp)
+	}
+	if keys := listedKeys(t); len(keys) != 1 {
+		t.Errorf("keys left after prefix delete = %v, want only the other nonce", keys)
+	}
+	if rr := cacheRequest(http.MethodDelete, "/admin/cache"); rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
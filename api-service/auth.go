@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// errInvalidCredentials is returned by an Authenticator when the credentials don't match
+var errInvalidCredentials = errors.New("invalid credentials")
+
+// Credentials are what a client presents to log in
+type Credentials struct {
+	Username string
+	Passkey  string
+}
+
+// Identity is the authenticated caller
+type Identity struct {
+	Username string
+	Role     string
+}
+
+// Authenticator verifies login credentials. Implementations return errInvalidCredentials for a
+// wrong username or passkey and other errors only for backend failures.
+type Authenticator interface {
+	Authenticate(ctx context.Context, creds Credentials) (Identity, error)
+}
+
+// authenticator is the backend used by authHandler, chosen at startup from AUTH_BACKEND
+var authenticator Authenticator = PasskeyAuthenticator{}
+
+// loginFailSleep waits out LOGIN_FAIL_DELAY before a failed login is answered; swapped out in tests
+var loginFailSleep = sleepContext
+
+// newAuthenticatorFromEnv builds the Authenticator selected by AUTH_BACKEND ("passkey" or "users-file")
+func newAuthenticatorFromEnv() (Authenticator, error) {
+	switch backend := os.Getenv("AUTH_BACKEND"); backend {
+	case "", "passkey":
+		if os.Getenv("AUTH_PASSKEY") == "" {
+			if strictConfig() {
+				return nil, errors.New("STRICT_CONFIG=true requires AUTH_PASSKEY")
+			}
+			log.Println("WARNING: AUTH_PASSKEY is not set, so logins accept the development default '12345'. " +
+				"Set AUTH_PASSKEY, or STRICT_CONFIG=true to refuse to start without it.")
+		}
+		return PasskeyAuthenticator{}, nil
+	case "users-file":
+		path := os.Getenv("USERS_FILE")
+		if path == "" {
+			return nil, errors.New("AUTH_BACKEND=users-file requires USERS_FILE")
+		}
+		return newUsersFileAuthenticator(path)
+	default:
+		return nil, fmt.Errorf("unknown AUTH_BACKEND %q", backend)
+	}
+}
+
+// strictConfig reports whether STRICT_CONFIG=true forbids falling back to development defaults
+func strictConfig() bool {
+	strict, _ := strconv.ParseBool(os.Getenv("STRICT_CONFIG"))
+	return strict
+}
+
+// PasskeyAuthenticator accepts a single shared passkey. When Passkey is empty the AUTH_PASSKEY
+// environment variable is used, falling back to '12345' for development.
+type PasskeyAuthenticator struct {
+	Passkey string
+}
+
+func (a PasskeyAuthenticator) Authenticate(ctx context.Context, creds Credentials) (Identity, error) {
+	configuredPasskey := a.Passkey
+	if configuredPasskey == "" {
+		configuredPasskey = os.Getenv("AUTH_PASSKEY")
+	}
+	if configuredPasskey == "" {
+		configuredPasskey = "12345" // Fallback for development if not set; warned about at startup
+	}
+
+	if subtle.ConstantTimeCompare([]byte(creds.Passkey), []byte(configuredPasskey)) != 1 {
+		return Identity{}, errInvalidCredentials
+	}
+	return Identity{Username: creds.Username, Role: "customer"}, nil
+}
+
+// userRecord is one entry in USERS_FILE
+type userRecord struct {
+	PasskeyHash string `json:"passkeyHash"` // bcrypt hash of the user's passkey
+	Role        string `json:"role"`
+}
+
+// UsersFileAuthenticator checks usernames and bcrypt-hashed passkeys loaded from a JSON file like
+// {"alice": {"passkeyHash": "$2a$10$...", "role": "admin"}}
+type UsersFileAuthenticator struct {
+	users map[string]userRecord
+}
+
+// dummyHash is compared against for unknown users so lookups take the same time as wrong passkeys
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+
+// newUsersFileAuthenticator loads users from path
+func newUsersFileAuthenticator(path string) (*UsersFileAuthenticator, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var users map[string]userRecord
+	if err := json.Unmarshal(data, &users); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	for name, user := range users {
+		if _, err := bcrypt.Cost([]byte(user.PasskeyHash)); err != nil {
+			return nil, fmt.Errorf("user %q: invalid passkeyHash: %w", name, err)
+		}
+	}
+	return &UsersFileAuthenticator{users: users}, nil
+}
+
+func (a *UsersFileAuthenticator) Authenticate(ctx context.Context, creds Credentials) (Identity, error) {
+	user, ok := a.users[creds.Username]
+	hash := []byte(user.PasskeyHash)
+	if !ok {
+		hash = dummyHash
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(creds.Passkey)); err != nil || !ok {
+		return Identity{}, errInvalidCredentials
+	}
+	role := user.Role
+	if role == "" {
+		role = "customer"
+	}
+	return Identity{Username: creds.Username, Role: role}, nil
+}
+
+// identityKey is the request context key for the Identity set by requireAuth
+type identityKey struct{}
+
+// identityFrom returns the caller authenticated by requireAuth, if any
+func identityFrom(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
+
+// authRequired reports whether AUTH_REQUIRED=true puts the shop endpoints behind requireAuth
+func authRequired() bool {
+	required, _ := strconv.ParseBool(os.Getenv("AUTH_REQUIRED"))
+	return required
+}
+
+// requireAuth only lets requests through that carry a login token ("Authorization: Bearer <token>")
+// or, for service clients such as cron jobs, an "X-API-Key" listed in the comma-separated API_KEYS.
+// A bearer token takes precedence: when one is present the API key is not consulted, so a request
+// with an expired token fails rather than silently running as the service. Bearer tokens are only
+// accepted with AUTH_ISSUE_TOKENS=true and a JWT_SECRET. Preflight requests are passed through so the
+// wrapped handler can answer them.
+func requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var id Identity
+		if authz := r.Header.Get("Authorization"); authz != "" {
+			token, ok := strings.CutPrefix(authz, "Bearer ")
+			if !ok {
+				writeError(w, r, http.StatusUnauthorized, "Bearer token required")
+				return
+			}
+			secret, ok := bearerSecret()
+			if !ok {
+				log.Printf("Rejected request to %s: bearer tokens need AUTH_ISSUE_TOKENS and JWT_SECRET", r.URL.Path)
+				writeError(w, r, http.StatusUnauthorized, "Invalid or expired token")
+				return
+			}
+			var err error
+			if id, err = parseToken(token, secret, clock.Now()); err != nil {
+				log.Printf("Rejected request to %s: invalid bearer token", r.URL.Path)
+				writeError(w, r, http.StatusUnauthorized, "Invalid or expired token")
+				return
+			}
+		} else if key := r.Header.Get("X-API-Key"); key != "" {
+			if !validAPIKey(key, os.Getenv("API_KEYS")) {
+				log.Printf("Rejected request to %s: invalid API key", r.URL.Path)
+				writeError(w, r, http.StatusUnauthorized, "Invalid API key")
+				return
+			}
+			id = Identity{Username: "service", Role: "service"}
+		} else {
+			writeError(w, r, http.StatusUnauthorized, "Authentication required")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, id)))
+	})
+}
+
+// validAPIKey reports whether key is one of the comma-separated keys. Every configured key is
+// compared in constant time so the response time doesn't reveal how close a guess was.
+func validAPIKey(key, keys string) bool {
+	match := 0
+	for _, k := range strings.Split(keys, ",") {
+		k = strings.TrimSpace(k)
+		if k == "" {
+			continue
+		}
+		match |= subtle.ConstantTimeCompare([]byte(key), []byte(k))
+	}
+	return match == 1
+}
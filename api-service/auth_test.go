@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// TestPasskeyAuthenticator tests the shared-passkey backend
+func TestPasskeyAuthenticator(t *testing.T) {
+	a := PasskeyAuthenticator{Passkey: "secret"}
+
+	if _, err := a.Authenticate(context.Background(), Credentials{Passkey: "secret"}); err != nil {
+		t.Errorf("valid passkey returned error: %v", err)
+	}
+	if _, err := a.Authenticate(context.Background(), Credentials{Passkey: "wrong"}); !errors.Is(err, errInvalidCredentials) {
+		t.Errorf("wrong passkey returned %v, want errInvalidCredentials", err)
+	}
+}
+
+// TestPasskeyAuthenticator_FromEnv tests that the zero value reads AUTH_PASSKEY
+func TestPasskeyAuthenticator_FromEnv(t *testing.T) {
+	os.Setenv("AUTH_PASSKEY", "fromenv")
+	defer os.Unsetenv("AUTH_PASSKEY")
+
+	if _, err := (PasskeyAuthenticator{}).Authenticate(context.Background(), Credentials{Passkey: "fromenv"}); err != nil {
+		t.Errorf("AUTH_PASSKEY passkey returned error: %v", err)
+	}
+}
+
+// writeUsersFile writes a users file with bcrypt-hashed passkeys and returns its path
+func writeUsersFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "users.json")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("Could not write users file: %v", err)
+	}
+	return path
+}
+
+// TestUsersFileAuthenticator tests username/passkey verification and roles from the users file
+func TestUsersFileAuthenticator(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("alicepass"), bcrypt.MinCost)
+	a, err := newUsersFileAuthenticator(writeUsersFile(t, `{"alice": {"passkeyHash": "`+string(hash)+`", "role": "admin"}}`))
+	if err != nil {
+		t.Fatalf("newUsersFileAuthenticator returned error: %v", err)
+	}
+
+	identity, err := a.Authenticate(context.Background(), Credentials{Username: "alice", Passkey: "alicepass"})
+	if err != nil {
+		t.Fatalf("valid credentials returned error: %v", err)
+	}
+	if identity.Username != "alice" || identity.Role != "admin" {
+		t.Errorf("got identity %+v, want alice/admin", identity)
+	}
+
+	for _, creds := range []Credentials{
+		{Username: "alice", Passkey: "wrong"},
+		{Username: "bob", Passkey: "alicepass"},
+		{Passkey: "alicepass"},
+	} {
+		if _, err := a.Authenticate(context.Background(), creds); !errors.Is(err, errInvalidCredentials) {
+			t.Errorf("Authenticate(%+v) returned %v, want errInvalidCredentials", creds, err)
+		}
+	}
+}
+
+// TestUsersFileAuthenticator_InvalidFile tests that malformed users files are rejected at startup
+func TestUsersFileAuthenticator_InvalidFile(t *testing.T) {
+	for _, contents := range []string{`not json`, `{"alice": {"passkeyHash": "plaintext"}}`} {
+		if _, err := newUsersFileAuthenticator(writeUsersFile(t, contents)); err == nil {
+			t.Errorf("newUsersFileAuthenticator(%q) succeeded, want error", contents)
+		}
+	}
+}
+
+// TestNewAuthenticatorFromEnv tests backend selection by AUTH_BACKEND
+func TestNewAuthenticatorFromEnv(t *testing.T) {
+	defer os.Unsetenv("AUTH_BACKEND")
+	defer os.Unsetenv("USERS_FILE")
+
+	if a, err := newAuthenticatorFromEnv(); err != nil {
+		t.Errorf("default backend returned error: %v", err)
+	} else if _, ok := a.(PasskeyAuthenticator); !ok {
+		t.Errorf("default backend is %T, want PasskeyAuthenticator", a)
+	}
+
+	os.Setenv("AUTH_BACKEND", "users-file")
+	if _, err := newAuthenticatorFromEnv(); err == nil {
+		t.Error("users-file backend without USERS_FILE should fail")
+	}
+	os.Setenv("USERS_FILE", writeUsersFile(t, `{}`))
+	if a, err := newAuthenticatorFromEnv(); err != nil {
+		t.Errorf("users-file backend returned error: %v", err)
+	} else if _, ok := a.(*UsersFileAuthenticator); !ok {
+		t.Errorf("users-file backend is %T, want *UsersFileAuthenticator", a)
+	}
+
+	os.Setenv("AUTH_BACKEND", "ldap")
+	if _, err := newAuthenticatorFromEnv(); err == nil {
+		t.Error("unknown backend should fail")
+	}
+}
+
+// TestNewAuthenticatorFromEnv_StrictConfig tests that STRICT_CONFIG refuses to start without AUTH_PASSKEY
+func TestNewAuthenticatorFromEnv_StrictConfig(t *testing.T) {
+	os.Setenv("STRICT_CONFIG", "true")
+	defer os.Unsetenv("STRICT_CONFIG")
+
+	if _, err := newAuthenticatorFromEnv(); err == nil {
+		t.Error("strict mode without AUTH_PASSKEY should fail")
+	}
+
+	os.Setenv("AUTH_PASSKEY", "configured")
+	defer os.Unsetenv("AUTH_PASSKEY")
+	if _, err := newAuthenticatorFromEnv(); err != nil {
+		t.Errorf("strict mode with AUTH_PASSKEY returned error: %v", err)
+	}
+}
+
+// TestNewAuthenticatorFromEnv_DefaultPasskeyWarning tests that the dev default is warned about once at startup, not per login
+func TestNewAuthenticatorFromEnv_DefaultPasskeyWarning(t *testing.T) {
+	logs := captureLog(t)
+
+	a, err := newAuthenticatorFromEnv()
+	if err != nil {
+		t.Fatalf("non-strict mode returned error: %v", err)
+	}
+	if !strings.Contains(logs.String(), "WARNING: AUTH_PASSKEY is not set") {
+		t.Errorf("expected a startup warning, got %q", logs.String())
+	}
+
+	logs.Reset()
+	if _, err := a.Authenticate(context.Background(), Credentials{Passkey: "12345"}); err != nil {
+		t.Errorf("dev default passkey returned error: %v", err)
+	}
+	if logs.Len() != 0 {
+		t.Errorf("login logged %q, want nothing", logs.String())
+	}
+}
+
+// stubLoginFailSleep records requested login failure delays instead of waiting
+func stubLoginFailSleep(t *testing.T) *[]time.Duration {
+	t.Helper()
+	var delays []time.Duration
+	orig := loginFailSleep
+	loginFailSleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return ctx.Err()
+	}
+	t.Cleanup(func() { loginFailSleep = orig })
+	return &delays
+}
+
+// TestAuthHandler_FailureDelay tests that only failed logins are delayed by LOGIN_FAIL_DELAY
+func TestAuthHandler_FailureDelay(t *testing.T) {
+	delays := stubLoginFailSleep(t)
+	useFakeLockoutClock(t)
+	os.Setenv("AUTH_PASSKEY", "testpasskey")
+	defer os.Unsetenv("AUTH_PASSKEY")
+	os.Setenv("LOGIN_FAIL_DELAY", "500ms")
+	defer os.Unsetenv("LOGIN_FAIL_DELAY")
+
+	authHandler(httptest.NewRecorder(), postJSON(t, "/auth", LoginRequest{Passkey: "testpasskey"}))
+	if len(*delays) != 0 {
+		t.Errorf("successful login was delayed: %v", *delays)
+	}
+
+	rr := httptest.NewRecorder()
+	authHandler(rr, postJSON(t, "/auth", LoginRequest{Passkey: "wrong"}))
+	if len(*delays) != 1 || (*delays)[0] != 500*time.Millisecond {
+		t.Errorf("failed login delays = %v, want [500ms]", *delays)
+	}
+	if !strings.Contains(rr.Body.String(), "Invalid passkey") {
+		t.Errorf("failed login response = %q", rr.Body.String())
+	}
+}
+
+// TestAuthHandler_NoFailureDelayByDefault tests that failed logins are not delayed unless configured
+func TestAuthHandler_NoFailureDelayByDefault(t *testing.T) {
+	delays := stubLoginFailSleep(t)
+	useFakeLockoutClock(t)
+
+	authHandler(httptest.NewRecorder(), postJSON(t, "/auth", LoginRequest{Passkey: "wrong"}))
+	if len(*delays) != 0 {
+		t.Errorf("failed login was delayed without LOGIN_FAIL_DELAY: %v", *delays)
+	}
+}
+
+// TestSleepContext_Canceled tests that the delay ends as soon as the client goes away
+func TestSleepContext_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	if err := sleepContext(ctx, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Errorf("sleepContext returned %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("sleepContext took %s after cancellation", elapsed)
+	}
+}
+
+// authRequest sends req through requireAuth and returns the response and the identity the handler saw
+func authRequest(req *http.Request) (*httptest.ResponseRecorder, Identity) {
+	var seen Identity
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = identityFrom(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+	rr := httptest.NewRecorder()
+	requireAuth(handler).ServeHTTP(rr, req)
+	return rr, seen
+}
+
+// TestRequireAuth_APIKey tests that listed API keys authenticate as the service role
+func TestRequireAuth_APIKey(t *testing.T) {
+	os.Setenv("API_KEYS", "cron-key, report-key")
+	defer os.Unsetenv("API_KEYS")
+
+	for _, key := range []string{"cron-key", "report-key"} {
+		req := httptest.NewRequest(http.MethodGet, "/products", nil)
+		req.Header.Set("X-API-Key", key)
+		rr, id := authRequest(req)
+		if rr.Code != http.StatusOK {
+			t.Errorf("API key %q: got %v want %v", key, rr.Code, http.StatusOK)
+		}
+		if id.Role != "service" {
+			t.Errorf("API key %q authenticated as role %q, want service", key, id.Role)
+		}
+	}
+
+	for _, key := range []string{"wrong", "cron-key,report-key", " "} {
+		req := httptest.NewRequest(http.MethodGet, "/products", nil)
+		req.Header.Set("X-API-Key", key)
+		if rr, _ := authRequest(req); rr.Code != http.StatusUnauthorized {
+			t.Errorf("API key %q: got %v want %v", key, rr.Code, http.StatusUnauthorized)
+		}
+	}
+
+	if rr, _ := authRequest(httptest.NewRequest(http.MethodGet, "/products", nil)); rr.Code != http.StatusUnauthorized {
+		t.Errorf("request without credentials: got %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+	if rr, _ := authRequest(httptest.NewRequest(http.MethodOptions, "/products", nil)); rr.Code != http.StatusOK {
+		t.Errorf("preflight request: got %v want %v", rr.Code, http.StatusOK)
+	}
+}
+
+// TestRequireAuth_APIKeysUnset tests that no API key is accepted when API_KEYS is empty
+func TestRequireAuth_APIKeysUnset(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/products", nil)
+	req.Header.Set("X-API-Key", "anything")
+	if rr, _ := authRequest(req); rr.Code != http.StatusUnauthorized {
+		t.Errorf("API key without API_KEYS: got %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+}
+
+// TestRequireAuth_BearerPrecedence tests that a bearer token is used over an API key on the same request
+func TestRequireAuth_BearerPrecedence(t *testing.T) {
+	os.Setenv("API_KEYS", "cron-key")
+	defer os.Unsetenv("API_KEYS")
+	os.Setenv("AUTH_ISSUE_TOKENS", "true")
+	defer os.Unsetenv("AUTH_ISSUE_TOKENS")
+	os.Setenv("JWT_SECRET", "jwtsecret")
+	defer os.Unsetenv("JWT_SECRET")
+
+	token, _ := issueToken(Identity{Username: "alice", Role: "customer"}, []byte("jwtsecret"), time.Hour, time.Now())
+	req := httptest.NewRequest(http.MethodGet, "/products", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("X-API-Key", "cron-key")
+	rr, id := authRequest(req)
+	if rr.Code != http.StatusOK || id.Username != "alice" || id.Role != "customer" {
+		t.Errorf("bearer and API key: got %v as %+v, want 200 as alice/customer", rr.Code, id)
+	}
+
+	// An invalid token is not rescued by a valid API key
+	req = httptest.NewRequest(http.MethodGet, "/products", nil)
+	req.Header.Set("Authorization", "Bearer not-a-token")
+	req.Header.Set("X-API-Key", "cron-key")
+	if rr, _ := authRequest(req); rr.Code != http.StatusUnauthorized {
+		t.Errorf("invalid bearer with valid API key: got %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+}
+
+// TestRequireAuth_DevSecretToken tests that a token forged with the development secret is refused
+// when JWT_SECRET is unset, as in an AUTH_REQUIRED deployment with only API_KEYS configured
+func TestRequireAuth_DevSecretToken(t *testing.T) {
+	os.Setenv("API_KEYS", "cron-key")
+	defer os.Unsetenv("API_KEYS")
+	forged, _ := issueToken(Identity{Username: "mallory", Role: "admin"}, []byte(devJWTSecret), time.Hour, time.Now())
+
+	for _, issueTokens := range []string{"false", "true"} {
+		os.Setenv("AUTH_ISSUE_TOKENS", issueTokens)
+		req := httptest.NewRequest(http.MethodGet, "/products", nil)
+		req.Header.Set("Authorization", "Bearer "+forged)
+		if rr, id := authRequest(req); rr.Code != http.StatusUnauthorized {
+			t.Errorf("AUTH_ISSUE_TOKENS=%s: dev-secret token got %v as %+v, want %v", issueTokens, rr.Code, id, http.StatusUnauthorized)
+		}
+	}
+	os.Unsetenv("AUTH_ISSUE_TOKENS")
+
+	req := httptest.NewRequest(http.MethodGet, "/products", nil)
+	req.Header.Set("X-API-Key", "cron-key")
+	if rr, _ := authRequest(req); rr.Code != http.StatusOK {
+		t.Errorf("API key: got %v want %v", rr.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import "log"
+
+// orderBreakdown computes the breakdown for an order's items, taxing the discounted subtotal at
+// taxRate (e.g. 0.08 for 8%). Each amount is rounded to cents before it is used in the next.
+func orderBreakdown(items []OrderItemRequest, discount, taxRate float64) OrderBreakdown {
+	subtotal := itemsSubtotal(items)
+	discount = roundMoney(discount)
+	tax := roundMoney((subtotal - discount) * taxRate)
+	return OrderBreakdown{
+		Subtotal: subtotal,
+		Discount: discount,
+		Tax:      tax,
+		Total:    roundMoney(subtotal - discount + tax),
+	}
+}
+
+// taxRate reads TAX_RATE as a fraction between 0 and 1, defaulting to no tax
+func taxRate() float64 {
+	rate := envFloat("TAX_RATE", 0)
+	if rate < 0 || rate > 1 {
+		log.Printf("Invalid TAX_RATE value %g: must be between 0 and 1. Using default '0'.", rate)
+		return 0
+	}
+	return rate
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// TestOrderBreakdown tests subtotal, discount, tax and total with cent rounding
+func TestOrderBreakdown(t *testing.T) {
+	items := []OrderItemRequest{{Id: "p1", Quantity: 3, Price: 19.99}, {Id: "p2", Quantity: 1, Price: 5.01}}
+	tests := []struct {
+		name     string
+		discount float64
+		taxRate  float64
+		want     OrderBreakdown
+	}{
+		{"zero tax", 0, 0, OrderBreakdown{Subtotal: 64.98, Discount: 0, Tax: 0, Total: 64.98}},
+		{"tax only", 0, 0.0825, OrderBreakdown{Subtotal: 64.98, Discount: 0, Tax: 5.36, Total: 70.34}},
+		{"discount applied", 6.5, 0, OrderBreakdown{Subtotal: 64.98, Discount: 6.5, Tax: 0, Total: 58.48}},
+		{"discount then tax", 6.5, 0.1, OrderBreakdown{Subtotal: 64.98, Discount: 6.5, Tax: 5.85, Total: 64.33}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := orderBreakdown(items, tt.discount, tt.taxRate); got != tt.want {
+				t.Errorf("orderBreakdown = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestTaxRate tests that TAX_RATE outside 0–1 falls back to no tax
+func TestTaxRate(t *testing.T) {
+	for value, want := range map[string]float64{"": 0, "0.07": 0.07, "7": 0, "-0.1": 0, "abc": 0} {
+		os.Setenv("TAX_RATE", value)
+		if got := taxRate(); got != want {
+			t.Errorf("TAX_RATE=%q: taxRate() = %v, want %v", value, got, want)
+		}
+	}
+	os.Unsetenv("TAX_RATE")
+}
+
+// TestOrderHandler_Breakdown tests that placed orders report their breakdown without changing the forwarded total
+func TestOrderHandler_Breakdown(t *testing.T) {
+	writeCouponsFile(t, `{"SAVE10": 10}`)
+	dotnet := newFakeDotnet(t, nil)
+	os.Setenv("TAX_RATE", "0.08")
+	defer os.Unsetenv("TAX_RATE")
+
+	order := PlaceOrderRequest{
+		Items:           []OrderItemRequest{{Id: "prod1", Quantity: 2, Price: 50}},
+		DeliveryAddress: "1 Main St",
+		TotalAmount:     pricePtr(100),
+		CouponCode:      "SAVE10",
+	}
+	rr := httptest.NewRecorder()
+	orderHandler(rr, postJSON(t, "/order", order))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v (%s)", rr.Code, http.StatusOK, rr.Body.String())
+	}
+
+	var resp PlaceOrderResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	want := OrderBreakdown{Subtotal: 100, Discount: 10, Tax: 7.2, Total: 97.2}
+	if resp.Breakdown == nil || *resp.Breakdown != want {
+		t.Errorf("response breakdown = %+v, want %+v", resp.Breakdown, want)
+	}
+	if got := dotnet.lastOrder(t).TotalAmount; got == nil || *got != 90 {
+		t.Errorf("forwarded total = %v, want 90", got)
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// defaultProductsCacheTTL is how long a fetched catalog is reused when PRODUCTS_CACHE_TTL is not set
+const defaultProductsCacheTTL = 30 * time.Second
+
+// defaultProductsStaleMax is how long past expiry a cached catalog may be served while the Dotnet
+// service is failing, when PRODUCTS_STALE_MAX is not set
+const defaultProductsStaleMax = 10 * time.Minute
+
+// catalogEntry is a single cached copy of the product catalog
+type catalogEntry struct {
+	Products  []Product
+	Body      []byte    // JSON encoding of Products, as sent to clients
+	ETag      string    // Strong validator derived from Body
+	FetchedAt time.Time // When the catalog was fetched from the Dotnet service
+
+	// LastModified is when the catalog contents last changed; refetching an identical catalog keeps it
+	LastModified time.Time
+
+	ContentLanguage string // Content-Language of a localized catalog, as reported by the Dotnet service
+
+	// DeltaSince is the since of the next delta refresh from this catalog (see deltaWatermark)
+	DeltaSince time.Time
+}
+
+// productCache holds the most recently fetched catalog
+type productCache struct {
+	mu    sync.Mutex
+	entry *catalogEntry
+	group singleflight.Group // Deduplicates concurrent refreshes
+
+	// generation counts invalidations, so a fetch that started before one doesn't store its result
+	generation int
+
+	// previous is the last fetched catalog, which the next fetch is checked against for price
+	// changes; unlike entry it survives invalidate
+	previous []Product
+
+	lang   string // Language requested from the Dotnet service; empty for the default catalog
+	tenant string // Tenant whose Dotnet service the catalog comes from; empty without tenants
+
+	// When the last full (not delta) fetch started
+	lastFullRefresh time.Time
+}
+
+// productsCache is the process-wide cache of the default catalog, used without tenants
+var productsCache = &productCache{}
+
+// get returns the cached catalog, refetching it from the Dotnet service when missing or older than ttl
+func (c *productCache) get(ctx context.Context, ttl time.Duration) (*catalogEntry, error) {
+	entry, _, err := c.lookup(ctx, ttl, 0)
+	return entry, err
+}
+
+// lookup is like get, but when the refetch fails it falls back to the expired catalog for up to
+// staleMax past its expiry. The bool result reports whether the returned entry is such a stale fallback.
+func (c *productCache) lookup(ctx context.Context, ttl, staleMax time.Duration) (*catalogEntry, bool, error) {
+	c.mu.Lock()
+	cached := c.entry
+	generation := c.generation
+	c.mu.Unlock()
+
+	if cached != nil && time.Since(cached.FetchedAt) < ttl {
+		productsCacheHits.Inc()
+		return cached, false, nil
+	}
+	productsCacheMisses.Inc()
+
+	// Concurrent misses share a single upstream fetch instead of each hitting the Dotnet service.
+	// The fetch keeps the first caller's deadline but not its cancellation, since one client leaving
+	// must not fail the others; each caller stops waiting when its own context ends. With a shared
+	// store, a catalog another replica fetched recently is used instead.
+	ch := c.group.DoChan("catalog", func() (interface{}, error) {
+		// The catalog is shared, so it is never fetched with the headers one client asked to forward
+		fetchCtx, cancel := detachContext(withoutForwardedHeaders(ctx))
+		defer cancel()
+		if entry := c.loadShared(fetchCtx, ttl, generation); entry != nil {
+			return entry, nil
+		}
+		return c.refresh(fetchCtx, generation)
+	})
+	var v interface{}
+	var err error
+	select {
+	case res := <-ch:
+		v, err = res.Val, res.Err
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		if cached != nil && time.Since(cached.FetchedAt) < ttl+staleMax {
+			log.Printf("Serving stale products fetched at %s: %v", cached.FetchedAt.Format(time.RFC3339), err)
+			productsCacheStale.Inc()
+			return cached, true, nil
+		}
+		return nil, false, err
+	}
+	return v.(*catalogEntry), false, nil
+}
+
+// detachContext returns a context with ctx's values and deadline that is not canceled with ctx
+func detachContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	detached := context.WithoutCancel(ctx)
+	if deadline, ok := ctx.Deadline(); ok {
+		return context.WithDeadline(detached, deadline)
+	}
+	return detached, func() {}
+}
+
+// refresh fetches the catalog from the Dotnet service and stores it in the cache, unless the cache
+// has been invalidated since generation, in which case the catalog is only returned to the caller
+func (c *productCache) refresh(ctx context.Context, generation int) (*catalogEntry, error) {
+	started := time.Now()
+	products, contentLanguage, asOf, full, err := c.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := newCatalogEntry(products)
+	if err != nil {
+		return nil, err
+	}
+	if maxProducts := envInt("MAX_PRODUCTS_RETURNED", 0); maxProducts > 0 && len(products) > maxProducts {
+		log.Printf("WARNING: the Dotnet service returned %d products, more than MAX_PRODUCTS_RETURNED=%d, so /products responses are truncated", len(products), maxProducts)
+	}
+	entry.ContentLanguage = contentLanguage
+	entry.DeltaSince = deltaWatermark(asOf, started)
+
+	c.mu.Lock()
+	if c.generation != generation {
+		c.mu.Unlock()
+		return entry, nil
+	}
+	if c.entry != nil && c.entry.ETag == entry.ETag {
+		entry.LastModified = c.entry.LastModified
+	}
+	c.entry = entry
+	if full {
+		c.lastFullRefresh = started
+	}
+	previous := c.previous
+	c.previous = products
+	c.mu.Unlock()
+	c.saveShared(ctx, entry)
+
+	// Localized catalogs carry the same prices, so only the default one announces changes
+	if previous != nil && c.lang == "" {
+		notifyPriceChanges(c.tenant, priceChanges(previous, products))
+	}
+	return entry, nil
+}
+
+// fetch gets the catalog from the Dotnet service, merging in only the changes since the last refresh
+// when delta refresh applies and fetching it in full otherwise. full reports which one happened, and
+// asOf is the delta's watermark, zero when the Dotnet service sent none.
+func (c *productCache) fetch(ctx context.Context) (products []Product, contentLanguage string, asOf time.Time, full bool, err error) {
+	c.mu.Lock()
+	base, lastFull := c.entry, c.lastFullRefresh
+	c.mu.Unlock()
+
+	// Localized catalogs are always fetched in full; the delta endpoint takes no language
+	delta, ok := dotnet.(DeltaCatalogClient)
+	if ok && c.lang == "" && base != nil && deltaRefreshEnabled() &&
+		time.Since(lastFull) < envDuration("PRODUCTS_FULL_REFRESH_INTERVAL", defaultFullRefreshInterval) {
+		changes, err := delta.GetChangedProducts(ctx, base.DeltaSince)
+		if err == nil {
+			return mergeProductDelta(base.Products, changes), "", changes.AsOf, false, nil
+		}
+		log.Printf("Delta refresh of products failed, fetching the full catalog: %v", err)
+	}
+
+	if localized, ok := dotnet.(LocalizedCatalogClient); ok && c.lang != "" {
+		products, contentLanguage, err = localized.GetLocalizedProducts(ctx, c.lang)
+	} else {
+		products, err = dotnet.GetProducts(ctx)
+	}
+	return products, contentLanguage, time.Time{}, true, err
+}
+
+// invalidate drops the cached catalog, along with its ETag and Last-Modified, so the next get
+// refetches it, also from the shared store. A fetch already in flight is not joined by later
+// callers, since it may predate the change.
+func (c *productCache) invalidate() {
+	c.group.Forget("catalog")
+	c.mu.Lock()
+	c.entry = nil
+	c.generation++
+	c.mu.Unlock()
+	c.deleteShared()
+}
+
+// cached returns the cached catalog regardless of age, or nil if nothing has been fetched
+func (c *productCache) cached() *catalogEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entry
+}
+
+// newCatalogEntry serializes products once and derives the ETag from the serialized bytes
+func newCatalogEntry(products []Product) (*catalogEntry, error) {
+	body, err := json.Marshal(products)
+	if err != nil {
+		return nil, &upstreamError{Status: http.StatusInternalServerError, Message: "Failed to encode products", Err: err}
+	}
+	body = append(body, '\n')
+	sum := sha256.Sum256(body)
+	now := time.Now()
+	return &catalogEntry{
+		Products:     products,
+		Body:         body,
+		ETag:         `"` + hex.EncodeToString(sum[:16]) + `"`,
+		FetchedAt:    now,
+		LastModified: now,
+	}, nil
+}
+
+// etagMatches reports whether an If-None-Match header value matches etag, using weak comparison
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// notModifiedSince reports whether an If-Modified-Since header value is at or after lastModified.
+// Malformed dates are ignored, as required by RFC 7232.
+func notModifiedSince(ifModifiedSince string, lastModified time.Time) bool {
+	if ifModifiedSince == "" {
+		return false
+	}
+	since, err := http.ParseTime(ifModifiedSince)
+	if err != nil {
+		return false
+	}
+	// HTTP dates have one-second resolution
+	return !lastModified.Truncate(time.Second).After(since)
+}
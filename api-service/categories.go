@@ -0,0 +1,74 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// uncategorized is the category reported for products without one
+const uncategorized = "Uncategorized"
+
+// CategoryCount is one entry in the GET /categories response
+type CategoryCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// categoryName returns the normalized category of a product, falling back to "Uncategorized"
+func categoryName(p Product) string {
+	if name := strings.TrimSpace(p.Category); name != "" {
+		return name
+	}
+	return uncategorized
+}
+
+// countCategories groups products by category (case-insensitively) and returns the counts sorted by name
+func countCategories(products []Product) []CategoryCount {
+	byKey := make(map[string]*CategoryCount)
+	for _, p := range products {
+		name := categoryName(p)
+		key := strings.ToLower(name)
+		if c, ok := byKey[key]; ok {
+			c.Count++
+			continue
+		}
+		byKey[key] = &CategoryCount{Name: name, Count: 1}
+	}
+
+	categories := make([]CategoryCount, 0, len(byKey))
+	for _, c := range byKey {
+		categories = append(categories, *c)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		return strings.ToLower(categories[i].Name) < strings.ToLower(categories[j].Name)
+	})
+	return categories
+}
+
+// categoriesHandler responds with the distinct product categories and how many products each holds
+func categoriesHandler(w http.ResponseWriter, r *http.Request) {
+	// Set CORS headers for any origin
+	applyCORS(w, r, "GET, OPTIONS", "Content-Type, Authorization, X-API-Key, X-Tenant-ID")
+
+	// Handle preflight OPTIONS request
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet, http.MethodOptions)
+		return
+	}
+
+	entry, err := tenantProductCache(r.Context()).get(r.Context(), envDuration("PRODUCTS_CACHE_TTL", defaultProductsCacheTTL))
+	if err != nil {
+		log.Printf("An error occured loading products for categories: %v", err)
+		writeUpstreamError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, countCategories(entry.Products))
+}
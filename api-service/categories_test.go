@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// TestCategoriesHandler tests distinct sorted categories with duplicates and blanks
+func TestCategoriesHandler(t *testing.T) {
+	newFakeDotnet(t, []Product{
+		{Id: "p1", Name: "Go Book", Category: "Books"},
+		{Id: "p2", Name: "Headphones", Category: "Electronics"},
+		{Id: "p3", Name: "Rust Book", Category: "books"},
+		{Id: "p4", Name: "Mystery Box"},
+		{Id: "p5", Name: "Gift Card", Category: "  "},
+		{Id: "p6", Name: "Atlas", Category: " Books "},
+	})
+
+	rr := httptest.NewRecorder()
+	categoriesHandler(rr, httptest.NewRequest(http.MethodGet, "/categories", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var got []CategoryCount
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	want := []CategoryCount{
+		{Name: "Books", Count: 3},
+		{Name: "Electronics", Count: 1},
+		{Name: "Uncategorized", Count: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("handler returned %+v, want %+v", got, want)
+	}
+}
+
+// TestCategoriesHandler_EmptyCatalog tests that an empty catalog yields an empty array
+func TestCategoriesHandler_EmptyCatalog(t *testing.T) {
+	newFakeDotnet(t, []Product{})
+
+	rr := httptest.NewRecorder()
+	categoriesHandler(rr, httptest.NewRequest(http.MethodGet, "/categories", nil))
+	if body := rr.Body.String(); body != "[]\n" {
+		t.Errorf("handler returned %q, want %q", body, "[]\n")
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Chaos defaults when CHAOS_DELAY / CHAOS_PATHS are not set
+const (
+	defaultChaosDelay = 2 * time.Second
+	defaultChaosPaths = "/products,/order"
+)
+
+// chaosConfig controls fault injection for resilience testing of the frontend
+type chaosConfig struct {
+	Enabled   bool
+	ErrorRate float64 // Fraction of matching requests answered with a synthetic 500
+	DelayRate float64 // Fraction of the remaining requests delayed by Delay before being served
+	Delay     time.Duration
+	Paths     map[string]bool
+	Seed      int64
+}
+
+// chaosConfigFromEnv reads the CHAOS_* variables. Chaos is off unless CHAOS_ENABLED=true, and is
+// refused under STRICT_CONFIG so production configuration can't turn it on by accident.
+func chaosConfigFromEnv() (chaosConfig, error) {
+	enabled, _ := strconv.ParseBool(os.Getenv("CHAOS_ENABLED"))
+	if !enabled {
+		return chaosConfig{}, nil
+	}
+	if strictConfig() {
+		return chaosConfig{}, errors.New("CHAOS_ENABLED=true is not allowed with STRICT_CONFIG=true")
+	}
+	cfg := chaosConfig{
+		Enabled:   true,
+		ErrorRate: envFloat("CHAOS_ERROR_RATE", 0),
+		DelayRate: envFloat("CHAOS_DELAY_RATE", 0),
+		Delay:     envDuration("CHAOS_DELAY", defaultChaosDelay),
+		Paths:     make(map[string]bool),
+		Seed:      int64(envInt("CHAOS_SEED", int(time.Now().UnixNano()))),
+	}
+	paths := os.Getenv("CHAOS_PATHS")
+	if paths == "" {
+		paths = defaultChaosPaths
+	}
+	for _, p := range strings.Split(paths, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			cfg.Paths[p] = true
+		}
+	}
+	return cfg, nil
+}
+
+// chaosInjector decides which requests get faults; safe for concurrent use
+type chaosInjector struct {
+	mu  sync.Mutex
+	cfg chaosConfig
+	rng *rand.Rand
+}
+
+// newChaosInjector returns an injector whose decisions are reproducible for a given cfg.Seed
+func newChaosInjector(cfg chaosConfig) *chaosInjector {
+	return &chaosInjector{cfg: cfg, rng: rand.New(rand.NewSource(cfg.Seed))}
+}
+
+// decide reports whether the next matching request should fail or, failing that, be delayed
+func (c *chaosInjector) decide() (fail, delay bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rng.Float64() < c.cfg.ErrorRate {
+		return true, false
+	}
+	return false, c.rng.Float64() < c.cfg.DelayRate
+}
+
+// middleware injects synthetic 500s and delays into requests for the configured paths.
+// Injected responses carry an X-Chaos-Injected header so they are easy to tell apart. When next is
+// the mux, an injected 500 is given the pattern of the route it stands in for, so metrics count it
+// under that route rather than as unmatched.
+func (c *chaosInjector) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !c.cfg.Paths[r.URL.Path] || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		fail, delay := c.decide()
+		switch {
+		case fail:
+			log.Printf("CHAOS: injecting 500 for %s %s", r.Method, r.URL.Path)
+			if mux, ok := next.(*http.ServeMux); ok {
+				_, r.Pattern = mux.Handler(r)
+			}
+			w.Header().Set("X-Chaos-Injected", "error")
+			writeError(w, r, http.StatusInternalServerError, "Internal server error (injected by chaos mode)")
+			return
+		case delay:
+			log.Printf("CHAOS: delaying %s %s by %s", r.Method, r.URL.Path, c.cfg.Delay)
+			w.Header().Set("X-Chaos-Injected", "delay")
+			if err := sleepContext(r.Context(), c.cfg.Delay); err != nil {
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// TestChaosConfigFromEnv tests that chaos stays off by default and is refused under STRICT_CONFIG
+func TestChaosConfigFromEnv(t *testing.T) {
+	if cfg, err := chaosConfigFromEnv(); err != nil || cfg.Enabled {
+		t.Errorf("chaos without CHAOS_ENABLED = %+v, %v; want disabled", cfg, err)
+	}
+
+	os.Setenv("CHAOS_ENABLED", "true")
+	defer os.Unsetenv("CHAOS_ENABLED")
+	cfg, err := chaosConfigFromEnv()
+	if err != nil || !cfg.Enabled || !cfg.Paths["/products"] || !cfg.Paths["/order"] || len(cfg.Paths) != 2 {
+		t.Errorf("chaos with CHAOS_ENABLED = %+v, %v; want enabled for /products and /order", cfg, err)
+	}
+
+	os.Setenv("STRICT_CONFIG", "true")
+	defer os.Unsetenv("STRICT_CONFIG")
+	if _, err := chaosConfigFromEnv(); err == nil {
+		t.Error("chaos under STRICT_CONFIG returned no error")
+	}
+}
+
+// TestChaosInjector_Rates tests that faults are injected at roughly the configured rates
+func TestChaosInjector_Rates(t *testing.T) {
+	c := newChaosInjector(chaosConfig{ErrorRate: 0.2, DelayRate: 0.5, Seed: 42})
+	const n = 10000
+	var fails, delays int
+	for i := 0; i < n; i++ {
+		fail, delay := c.decide()
+		if fail && delay {
+			t.Fatal("decide returned both fail and delay")
+		}
+		if fail {
+			fails++
+		}
+		if delay {
+			delays++
+		}
+	}
+	// Delays apply to the 80% of requests that weren't failed
+	if fails < 1800 || fails > 2200 {
+		t.Errorf("failed %d of %d requests, want about 2000", fails, n)
+	}
+	if delays < 3700 || delays > 4300 {
+		t.Errorf("delayed %d of %d requests, want about 4000", delays, n)
+	}
+
+	never := newChaosInjector(chaosConfig{Seed: 42})
+	always := newChaosInjector(chaosConfig{ErrorRate: 1, Seed: 42})
+	for i := 0; i < 100; i++ {
+		if fail, delay := never.decide(); fail || delay {
+			t.Fatal("zero rates injected a fault")
+		}
+		if fail, _ := always.decide(); !fail {
+			t.Fatal("error rate 1 let a request through")
+		}
+	}
+}
+
+// TestChaosInjector_Seeded tests that the same seed reproduces the same faults
+func TestChaosInjector_Seeded(t *testing.T) {
+	cfg := chaosConfig{ErrorRate: 0.3, DelayRate: 0.3, Seed: 7}
+	a, b := newChaosInjector(cfg), newChaosInjector(cfg)
+	for i := 0; i < 1000; i++ {
+		failA, delayA := a.decide()
+		failB, delayB := b.decide()
+		if failA != failB || delayA != delayB {
+			t.Fatalf("decision %d differs between injectors with the same seed", i)
+		}
+	}
+}
+
+// TestChaosInjector_Middleware tests injected errors and delays, and that other paths are untouched
+func TestChaosInjector_Middleware(t *testing.T) {
+	paths := map[string]bool{"/products": true}
+	failing := newChaosInjector(chaosConfig{ErrorRate: 1, Paths: paths}).middleware(statusHandler(http.StatusOK))
+
+	rr := httptest.NewRecorder()
+	failing.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/products", nil))
+	if status := rr.Code; status != http.StatusInternalServerError {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
+	}
+	if got := rr.Header().Get("X-Chaos-Injected"); got != "error" {
+		t.Errorf("X-Chaos-Injected = %q, want error", got)
+	}
+
+	rr = httptest.NewRecorder()
+	failing.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/categories", nil))
+	if status := rr.Code; status != http.StatusOK || rr.Header().Get("X-Chaos-Injected") != "" {
+		t.Errorf("unlisted path got status %v with chaos header %q", status, rr.Header().Get("X-Chaos-Injected"))
+	}
+
+	delaying := newChaosInjector(chaosConfig{DelayRate: 1, Delay: 20 * time.Millisecond, Paths: paths}).middleware(statusHandler(http.StatusOK))
+	rr = httptest.NewRecorder()
+	start := time.Now()
+	delaying.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/products", nil))
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("delayed request took %s, want at least 20ms", elapsed)
+	}
+	if status := rr.Code; status != http.StatusOK || rr.Header().Get("X-Chaos-Injected") != "delay" {
+		t.Errorf("delayed request got status %v with chaos header %q", status, rr.Header().Get("X-Chaos-Injected"))
+	}
+}
+
+// TestChaosInjector_MetricsPattern tests that injected 500s are counted under the route they replace
+func TestChaosInjector_MetricsPattern(t *testing.T) {
+	m := newHTTPMetrics(prometheus.NewRegistry())
+	mux := http.NewServeMux()
+	mux.Handle("/products", statusHandler(http.StatusOK))
+	handler := m.middleware(newChaosInjector(chaosConfig{ErrorRate: 1, Paths: map[string]bool{"/products": true}}).middleware(mux))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/products", nil))
+	if got := testutil.ToFloat64(m.requests.WithLabelValues("/products", "500")); got != 1 {
+		t.Errorf("http_requests_total{handler=/products,code=500} = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.requests.WithLabelValues("unmatched", "500")); got != 0 {
+		t.Errorf("http_requests_total{handler=unmatched,code=500} = %v, want 0", got)
+	}
+}
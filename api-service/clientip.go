@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+// parseTrustedProxies parses a comma-separated list of CIDRs or bare IPs, as in TRUSTED_PROXIES
+func parseTrustedProxies(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// trustedProxies returns the proxies listed in TRUSTED_PROXIES. Invalid lists are refused at
+// startup, so an error here only leaves forwarded headers untrusted.
+func trustedProxies() []netip.Prefix {
+	prefixes, _ := parseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	return prefixes
+}
+
+// clientIP returns the address of the client that sent r. X-Forwarded-For is only believed when the
+// connection comes from a trusted proxy: the header is walked right to left, skipping trusted hops,
+// and the first untrusted address is the client. Entries left of that were supplied by the client
+// and are ignored, so they can't be used to spoof another address.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	trusted := trustedProxies()
+	peer, err := netip.ParseAddr(host)
+	if err != nil || !isTrustedProxy(peer, trusted) {
+		return host
+	}
+
+	// Several X-Forwarded-For headers are equivalent to one joined with commas
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	client := peer.Unmap()
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break // Garbage can't be a client address; the last hop we trust is the best we know
+		}
+		client = hop.Unmap()
+		if !isTrustedProxy(client, trusted) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// isTrustedProxy reports whether addr is in one of the trusted prefixes
+func isTrustedProxy(addr netip.Addr, trusted []netip.Prefix) bool {
+	addr = addr.Unmap()
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// TestParseTrustedProxies tests CIDR and bare-IP entries and rejection of invalid ones
+func TestParseTrustedProxies(t *testing.T) {
+	prefixes, err := parseTrustedProxies(" 10.0.0.0/8, 192.0.2.10 ,2001:db8::/32,::ffff:198.51.100.0/120,")
+	if err != nil {
+		t.Fatalf("parseTrustedProxies returned error: %v", err)
+	}
+	want := []string{"10.0.0.0/8", "192.0.2.10/32", "2001:db8::/32", "198.51.100.0/24"}
+	if len(prefixes) != len(want) {
+		t.Fatalf("parseTrustedProxies = %v, want %v", prefixes, want)
+	}
+	for i, p := range prefixes {
+		if p.String() != want[i] {
+			t.Errorf("prefix %d = %s, want %s", i, p, want[i])
+		}
+	}
+
+	for _, list := range []string{"10.0.0.0/33", "not-an-ip", "10.0.0.0/8,proxy.internal"} {
+		if _, err := parseTrustedProxies(list); err == nil {
+			t.Errorf("parseTrustedProxies(%q) returned no error", list)
+		}
+	}
+}
+
+// TestClientIP tests walking X-Forwarded-For through trusted hops
+func TestClientIP(t *testing.T) {
+	os.Setenv("TRUSTED_PROXIES", "10.0.0.0/8,192.0.2.10")
+	defer os.Unsetenv("TRUSTED_PROXIES")
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        []string
+		want       string
+	}{
+		{"no header", "203.0.113.7:5123", nil, "203.0.113.7"},
+		{"untrusted peer can't spoof", "203.0.113.7:5123", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"one trusted proxy", "10.0.0.2:443", []string{"203.0.113.7"}, "203.0.113.7"},
+		{"multiple trusted hops", "10.0.0.2:443", []string{"203.0.113.7, 192.0.2.10, 10.1.2.3"}, "203.0.113.7"},
+		{"client-supplied prefix ignored", "10.0.0.2:443", []string{"1.1.1.1, 203.0.113.7, 10.1.2.3"}, "203.0.113.7"},
+		{"split across headers", "10.0.0.2:443", []string{"1.1.1.1, 203.0.113.7", "10.1.2.3"}, "203.0.113.7"},
+		{"all hops trusted", "10.0.0.2:443", []string{"10.9.9.9, 10.1.2.3"}, "10.9.9.9"},
+		{"garbage stops the walk", "10.0.0.2:443", []string{"203.0.113.7, unknown, 10.1.2.3"}, "10.1.2.3"},
+		{"trusted peer without header", "10.0.0.2:443", nil, "10.0.0.2"},
+		{"ipv6 client", "10.0.0.2:443", []string{"2001:db8::1"}, "2001:db8::1"},
+		{"ipv4-mapped peer", "[::ffff:10.0.0.2]:443", []string{"203.0.113.7"}, "203.0.113.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if got := clientIP(r); got != tt.want {
+				t.Errorf("clientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestClientIP_NoTrustedProxies tests that X-Forwarded-For is ignored unless TRUSTED_PROXIES is set
+func TestClientIP_NoTrustedProxies(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.0.0.2:443"
+	r.Header.Set("X-Forwarded-For", "203.0.113.7")
+	if got := clientIP(r); got != "10.0.0.2" {
+		t.Errorf("clientIP = %q, want the peer address 10.0.0.2", got)
+	}
+}
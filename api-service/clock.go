@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Clock is the source of time for time-dependent features, so tests can control it rather than wait
+type Clock interface {
+	Now() time.Time
+	// Sleep waits for d or until ctx is done, returning ctx.Err() in the latter case
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+// realClock is the wall clock
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) Sleep(ctx context.Context, d time.Duration) error { return sleepContext(ctx, d) }
+
+// clock is the process-wide clock used for issuing and checking login tokens; swapped in tests
+var clock Clock = realClock{}
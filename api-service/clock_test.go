@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock that only moves when told to; Sleep advances it instantly instead of waiting
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	slept []time.Duration
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.slept = append(c.slept, d)
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+	return nil
+}
+
+// useFakeClock swaps the process-wide clock for a fake starting at now
+func useFakeClock(t *testing.T, now time.Time) *fakeClock {
+	t.Helper()
+	c := newFakeClock(now)
+	orig := clock
+	clock = c
+	t.Cleanup(func() { clock = orig })
+	return c
+}
+
+// TestFakeClock tests that sleeping advances the fake clock without waiting and honors cancellation
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := newFakeClock(start)
+
+	if err := c.Sleep(context.Background(), time.Hour); err != nil {
+		t.Fatalf("Sleep returned %v", err)
+	}
+	c.Advance(time.Minute)
+	if got := c.Now().Sub(start); got != time.Hour+time.Minute {
+		t.Errorf("clock moved %s, want 1h1m", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.Sleep(ctx, time.Hour); err != context.Canceled {
+		t.Errorf("Sleep on a canceled context = %v, want context.Canceled", err)
+	}
+	if len(c.slept) != 1 {
+		t.Errorf("slept %v, want only the first sleep recorded", c.slept)
+	}
+}
+
+// TestTokenExpiry_FakeClock tests that a login token is accepted until JWT_TTL passes on the clock
+func TestTokenExpiry_FakeClock(t *testing.T) {
+	c := useFakeClock(t, time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC))
+	useFakeLockoutClock(t)
+	os.Setenv("AUTH_ISSUE_TOKENS", "true")
+	defer os.Unsetenv("AUTH_ISSUE_TOKENS")
+	os.Setenv("JWT_SECRET", "jwtsecret")
+	defer os.Unsetenv("JWT_SECRET")
+	os.Setenv("JWT_TTL", "1h")
+	defer os.Unsetenv("JWT_TTL")
+
+	var resp LoginResponse
+	json.Unmarshal(login(t, "alice", "12345").Body.Bytes(), &resp)
+	if resp.Token == "" {
+		t.Fatalf("login returned no token")
+	}
+	authorized := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/products", nil)
+		req.Header.Set("Authorization", "Bearer "+resp.Token)
+		rr, _ := authRequest(req)
+		return rr.Code
+	}
+
+	c.Advance(59 * time.Minute)
+	if code := authorized(); code != http.StatusOK {
+		t.Errorf("token before expiry: got %v want %v", code, http.StatusOK)
+	}
+	c.Advance(2 * time.Minute)
+	if code := authorized(); code != http.StatusUnauthorized {
+		t.Errorf("token after expiry: got %v want %v", code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// supportedEncodings lists the response encodings we can produce, most preferred first
+var supportedEncodings = []string{"br", "gzip", "identity"}
+
+// negotiateEncoding picks the response encoding from an Accept-Encoding header: the supported encoding
+// with the highest q-value, preferring br, then gzip, then identity on ties. It returns "identity" when
+// the header is empty or rules out everything, so responses are never refused for their encoding.
+func negotiateEncoding(acceptEncoding string) string {
+	if strings.TrimSpace(acceptEncoding) == "" {
+		return "identity"
+	}
+
+	quality := make(map[string]float64)
+	wildcard, hasWildcard := 0.0, false
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, q, ok := parseCoding(part)
+		if !ok {
+			continue
+		}
+		if coding == "*" {
+			wildcard, hasWildcard = q, true
+			continue
+		}
+		quality[coding] = q
+	}
+
+	best, bestQ := "identity", 0.0
+	for _, coding := range supportedEncodings {
+		q, listed := quality[coding]
+		if !listed {
+			if !hasWildcard || coding == "identity" {
+				continue // Unlisted identity is only the fallback below
+			}
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
+
+// parseCoding parses one Accept-Encoding element like "gzip;q=0.8", defaulting q to 1
+func parseCoding(part string) (string, float64, bool) {
+	fields := strings.Split(part, ";")
+	coding := strings.ToLower(strings.TrimSpace(fields[0]))
+	if coding == "" {
+		return "", 0, false
+	}
+	q := 1.0
+	for _, param := range fields[1:] {
+		name, value, found := strings.Cut(strings.TrimSpace(param), "=")
+		if !found || !strings.EqualFold(strings.TrimSpace(name), "q") {
+			continue
+		}
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			return "", 0, false // Ignore elements with a malformed q-value
+		}
+		q = parsed
+	}
+	return coding, q, true
+}
+
+// compressionMiddleware compresses responses with the best encoding the client accepts
+func compressionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "identity" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// compressWriter encodes the response body once the handler has committed to a status with a body
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	enc         io.WriteCloser
+	wroteHeader bool
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if interimStatus(status) {
+		cw.ResponseWriter.WriteHeader(status) // Sent ahead of the final response, which is still to come
+		return
+	}
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+
+	// Bodiless responses, ones a handler already encoded, byte ranges and images are passed through untouched
+	h := cw.Header()
+	if status >= http.StatusOK && status != http.StatusNoContent && status != http.StatusNotModified &&
+		status != http.StatusPartialContent && h.Get("Content-Encoding") == "" &&
+		!strings.HasPrefix(h.Get("Content-Type"), "image/") {
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		if cw.encoding == "br" {
+			cw.enc = brotli.NewWriter(cw.ResponseWriter)
+		} else {
+			cw.enc = gzip.NewWriter(cw.ResponseWriter)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.enc == nil {
+		return cw.ResponseWriter.Write(b)
+	}
+	return cw.enc.Write(b)
+}
+
+// Flush pushes buffered compressed data to the client so streamed responses still stream
+func (cw *compressWriter) Flush() {
+	if f, ok := cw.enc.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Close finishes the compressed stream
+func (cw *compressWriter) Close() error {
+	if cw.enc == nil {
+		return nil
+	}
+	return cw.enc.Close()
+}
+
+// Unwrap lets http.ResponseController reach the underlying ResponseWriter
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+// TestNegotiateEncoding tests q-value parsing and the br > gzip > identity preference
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", "identity"},
+		{"gzip", "gzip"},
+		{"br", "br"},
+		{"gzip, br", "br"},
+		{"br, gzip", "br"},
+		{"GZIP", "gzip"},
+		{"gzip;q=1.0, br;q=0.5", "gzip"},
+		{"br;q=0.8, gzip;q=0.8", "br"},
+		{"br;q=0, gzip", "gzip"},
+		{"gzip;q=0, br;q=0", "identity"},
+		{"*", "br"},
+		{"*;q=0.5, gzip", "gzip"},
+		{"br;q=0, *;q=0.3", "gzip"},
+		{"identity;q=0, *;q=0", "identity"}, // Nothing acceptable falls back to identity
+		{"deflate", "identity"},
+		{"br;q=abc, gzip", "gzip"},
+		{"br;q=2, gzip;q=0.1", "gzip"},
+		{"gzip ; Q=0.7 , identity;q=0.9", "identity"},
+		{" , ,gzip", "gzip"},
+	}
+	for _, tt := range tests {
+		if got := negotiateEncoding(tt.header); got != tt.want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+// serveCompressed runs a handler writing body through compressionMiddleware
+func serveCompressed(acceptEncoding string, status int, body string) *httptest.ResponseRecorder {
+	handler := compressionMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/products", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+// TestCompressionMiddleware tests that bodies are encoded with the negotiated encoding
+func TestCompressionMiddleware(t *testing.T) {
+	body := strings.Repeat(`{"id":"1","name":"Product"}`, 20)
+
+	tests := []struct {
+		acceptEncoding string
+		want           string
+		decode         func(io.Reader) (io.Reader, error)
+	}{
+		{"gzip, br", "br", func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil }},
+		{"gzip", "gzip", func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }},
+		{"", "", func(r io.Reader) (io.Reader, error) { return r, nil }},
+	}
+	for _, tt := range tests {
+		rr := serveCompressed(tt.acceptEncoding, http.StatusOK, body)
+		if got := rr.Header().Get("Content-Encoding"); got != tt.want {
+			t.Errorf("Accept-Encoding %q: Content-Encoding = %q, want %q", tt.acceptEncoding, got, tt.want)
+			continue
+		}
+		if got := rr.Header().Get("Vary"); got != "Accept-Encoding" {
+			t.Errorf("Accept-Encoding %q: Vary = %q, want Accept-Encoding", tt.acceptEncoding, got)
+		}
+		r, err := tt.decode(rr.Body)
+		if err != nil {
+			t.Fatalf("Accept-Encoding %q: could not open body: %v", tt.acceptEncoding, err)
+		}
+		decoded, err := io.ReadAll(r)
+		if err != nil || string(decoded) != body {
+			t.Errorf("Accept-Encoding %q: decoded body = %q (%v), want original", tt.acceptEncoding, decoded, err)
+		}
+	}
+}
+
+// TestCompressionMiddleware_NotModified tests that bodiless responses are not encoded
+func TestCompressionMiddleware_NotModified(t *testing.T) {
+	rr := serveCompressed("br", http.StatusNotModified, "")
+	if got := rr.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("304 response has Content-Encoding %q", got)
+	}
+	if rr.Body.Len() != 0 {
+		t.Errorf("304 response has a body of %d bytes", rr.Body.Len())
+	}
+}
+
+// TestCompressionMiddleware_SkipsImagesAndRanges tests that images and partial content are not re-encoded
+func TestCompressionMiddleware_SkipsImagesAndRanges(t *testing.T) {
+	for _, tt := range []struct {
+		contentType string
+		status      int
+	}{
+		{"image/png", http.StatusOK},
+		{"application/octet-stream", http.StatusPartialContent},
+	} {
+		handler := compressionMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", tt.contentType)
+			w.WriteHeader(tt.status)
+			io.WriteString(w, "raw bytes")
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", "br, gzip")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if got := rr.Header().Get("Content-Encoding"); got != "" || rr.Body.String() != "raw bytes" {
+			t.Errorf("%s %d: Content-Encoding %q, body %q", tt.contentType, tt.status, got, rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Secret is a configuration value that must never be logged or served. It formats, logs and
+// marshals as "***" when set and as "" when unset, so a dump still shows whether it is configured.
+type Secret string
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return "***"
+}
+
+// LogValue redacts the secret in structured logs
+func (s Secret) LogValue() slog.Value { return slog.StringValue(s.String()) }
+
+// MarshalJSON redacts the secret in JSON output
+func (s Secret) MarshalJSON() ([]byte, error) { return []byte(`"` + s.String() + `"`), nil }
+
+// Config is a snapshot of the effective configuration for diagnostics. Handlers still read the
+// environment per request; this only records what those reads resolve to at the time it is loaded.
+type Config struct {
+	Port             string          `json:"port" env:"PORT"`
+	UpstreamURL      string          `json:"upstreamUrl" env:"DOTNET_PRODUCTS_API_URL"`             // Any password in the URL is redacted
+	UpstreamURLs     []string        `json:"upstreamUrls,omitempty" env:"DOTNET_PRODUCTS_API_URLS"` // Failover instances, redacted likewise
+	APIPrefix        string          `json:"apiPrefix" env:"DOTNET_API_PREFIX"`
+	UpstreamTimeout  time.Duration   `json:"upstreamTimeout" env:"UPSTREAM_TIMEOUT"`
+	ProductsTimeout  time.Duration   `json:"productsTimeout" env:"PRODUCTS_TIMEOUT"`
+	OrderTimeout     time.Duration   `json:"orderTimeout" env:"ORDER_TIMEOUT"`
+	ProductsCacheTTL time.Duration   `json:"productsCacheTtl" env:"PRODUCTS_CACHE_TTL"`
+	AuthBackend      string          `json:"authBackend" env:"AUTH_BACKEND"`
+	AuthRequired     bool            `json:"authRequired" env:"AUTH_REQUIRED"`
+	IssueTokens      bool            `json:"issueTokens" env:"AUTH_ISSUE_TOKENS"`
+	StrictConfig     bool            `json:"strictConfig" env:"STRICT_CONFIG"`
+	TrustedProxies   string          `json:"trustedProxies" env:"TRUSTED_PROXIES"`
+	ChaosEnabled     bool            `json:"chaosEnabled" env:"CHAOS_ENABLED"`
+	CacheBackend     string          `json:"cacheBackend" env:"CACHE_BACKEND"`
+	RedisURL         string          `json:"redisUrl" env:"REDIS_URL"` // Any password in the URL is redacted
+	Features         map[string]bool `json:"features"`                 // From FEATURES_FILE, so not settable in CONFIG_FILE
+
+	AuthPasskey Secret `json:"authPasskey" env:"AUTH_PASSKEY"`
+	JWTSecret   Secret `json:"jwtSecret" env:"JWT_SECRET"`
+	AdminToken  Secret `json:"adminToken" env:"ADMIN_TOKEN"`
+	APIKeys     Secret `json:"apiKeys" env:"API_KEYS"`
+}
+
+// loadConfig reads the effective configuration from the environment and current feature flags
+func loadConfig() Config {
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	upstream := os.Getenv("DOTNET_PRODUCTS_API_URL")
+	if upstream == "" {
+		upstream = "http://localhost:8080"
+	}
+	backend := os.Getenv("AUTH_BACKEND")
+	if backend == "" {
+		backend = "passkey"
+	}
+	chaos, _ := chaosConfigFromEnv()
+	var upstreams []string
+	if os.Getenv("DOTNET_PRODUCTS_API_URLS") != "" {
+		for _, base := range upstreamBases() {
+			upstreams = append(upstreams, redactURL(base))
+		}
+	}
+	cacheBackend := os.Getenv("CACHE_BACKEND")
+	if cacheBackend == "" {
+		cacheBackend = "memory"
+	}
+	return Config{
+		Port:             port,
+		UpstreamURL:      redactURL(upstream),
+		UpstreamURLs:     upstreams,
+		APIPrefix:        os.Getenv("DOTNET_API_PREFIX"),
+		UpstreamTimeout:  envDuration("UPSTREAM_TIMEOUT", defaultUpstreamTimeout),
+		ProductsTimeout:  upstreamTimeout("PRODUCTS_TIMEOUT"),
+		OrderTimeout:     upstreamTimeout("ORDER_TIMEOUT"),
+		ProductsCacheTTL: envDuration("PRODUCTS_CACHE_TTL", defaultProductsCacheTTL),
+		AuthBackend:      backend,
+		AuthRequired:     authRequired(),
+		IssueTokens:      tokensEnabled(),
+		StrictConfig:     strictConfig(),
+		TrustedProxies:   os.Getenv("TRUSTED_PROXIES"),
+		ChaosEnabled:     chaos.Enabled,
+		CacheBackend:     cacheBackend,
+		RedisURL:         redactURL(os.Getenv("REDIS_URL")),
+		Features:         features.snapshot(),
+		AuthPasskey:      Secret(os.Getenv("AUTH_PASSKEY")),
+		JWTSecret:        Secret(os.Getenv("JWT_SECRET")),
+		AdminToken:       Secret(os.Getenv("ADMIN_TOKEN")),
+		APIKeys:          Secret(os.Getenv("API_KEYS")),
+	}
+}
+
+// configFileEnv maps each setting CONFIG_FILE accepts, by its JSON name in Config, to its environment variable
+func configFileEnv() map[string]string {
+	keys := make(map[string]string)
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if env := field.Tag.Get("env"); env != "" {
+			keys[name] = env
+		}
+	}
+	return keys
+}
+
+// applyConfigFile reads settings from the JSON file at path and sets the environment variables they
+// stand for, skipping any already set so the environment takes precedence. Handlers read the
+// environment per request, so this makes the file's values visible everywhere; the usual startup
+// checks then validate the merged result. Settings use their names in Config, e.g.
+// {"port": 8081, "productsCacheTtl": "1m"}, and "env" sets any other variable by name, e.g.
+// {"env": {"CORS_MAX_AGE": "1h"}}. Lists such as apiKeys may be JSON arrays. It returns the
+// variables it set.
+func applyConfigFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var values map[string]interface{}
+	if err := dec.Decode(&values); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	settings := make(map[string]string)
+	envKeys := configFileEnv()
+	for name, value := range values {
+		if name == "env" {
+			vars, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s: env must be an object of variable names to values", path)
+			}
+			for key, v := range vars {
+				if settings[key], err = configValueString(v); err != nil {
+					return nil, fmt.Errorf("%s: env %s: %w", path, key, err)
+				}
+			}
+			continue
+		}
+		key, ok := envKeys[name]
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown setting %q", path, name)
+		}
+		if settings[key], err = configValueString(value); err != nil {
+			return nil, fmt.Errorf("%s: setting %q: %w", path, name, err)
+		}
+	}
+
+	var set []string
+	for _, key := range slices.Sorted(maps.Keys(settings)) {
+		if _, inEnv := os.LookupEnv(key); inEnv {
+			continue
+		}
+		os.Setenv(key, settings[key])
+		set = append(set, key)
+	}
+	return set, nil
+}
+
+// configValueString converts a decoded JSON value to the string form its environment variable takes
+func configValueString(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case json.Number:
+		return v.String(), nil
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			if _, nested := item.([]interface{}); nested {
+				return "", errors.New("lists can't be nested")
+			}
+			s, err := configValueString(item)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = s
+		}
+		return strings.Join(parts, ","), nil
+	default:
+		return "", fmt.Errorf("want a string, number, boolean or list, got %T", v)
+	}
+}
+
+// redactURL replaces any password in rawURL with "xxxxx"
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		// Don't echo an unparseable value that may still contain credentials
+		if strings.Contains(rawURL, "@") {
+			return "***"
+		}
+		return rawURL
+	}
+	return u.Redacted()
+}
+
+// MarshalJSON encodes the configuration with durations in the same "10s" form as the startup log
+func (c Config) MarshalJSON() ([]byte, error) {
+	type plain Config // Drops this method so the embedded copy encodes normally
+	return json.Marshal(struct {
+		plain
+		UpstreamTimeout  string `json:"upstreamTimeout"`
+		ProductsTimeout  string `json:"productsTimeout"`
+		OrderTimeout     string `json:"orderTimeout"`
+		ProductsCacheTTL string `json:"productsCacheTtl"`
+	}{
+		plain:            plain(c),
+		UpstreamTimeout:  c.UpstreamTimeout.String(),
+		ProductsTimeout:  c.ProductsTimeout.String(),
+		OrderTimeout:     c.OrderTimeout.String(),
+		ProductsCacheTTL: c.ProductsCacheTTL.String(),
+	})
+}
+
+// LogRedacted writes the configuration to logger as a single structured entry with secrets redacted
+func (c Config) LogRedacted(logger *slog.Logger) {
+	logger.Info("effective configuration",
+		"port", c.Port,
+		"upstreamUrl", c.UpstreamURL,
+		"upstreamUrls", c.UpstreamURLs,
+		"apiPrefix", c.APIPrefix,
+		"upstreamTimeout", c.UpstreamTimeout.String(),
+		"productsTimeout", c.ProductsTimeout.String(),
+		"orderTimeout", c.OrderTimeout.String(),
+		"productsCacheTtl", c.ProductsCacheTTL.String(),
+		"authBackend", c.AuthBackend,
+		"authRequired", c.AuthRequired,
+		"issueTokens", c.IssueTokens,
+		"strictConfig", c.StrictConfig,
+		"trustedProxies", c.TrustedProxies,
+		"chaosEnabled", c.ChaosEnabled,
+		"cacheBackend", c.CacheBackend,
+		"redisUrl", c.RedisURL,
+		"features", c.Features,
+		"authPasskey", c.AuthPasskey,
+		"jwtSecret", c.JWTSecret,
+		"adminToken", c.AdminToken,
+		"apiKeys", c.APIKeys,
+	)
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// confirmationNumber formats an upstream order id for customers as PREFIX-YYYYMMDD-ID. The date is
+// taken in UTC so the same order gets the same number whichever zone the server runs in.
+func confirmationNumber(prefix, id string, placedAt time.Time) string {
+	prefix = strings.TrimRight(strings.TrimSpace(prefix), "-")
+	id = strings.TrimSpace(id)
+	if prefix == "" || id == "" {
+		return id
+	}
+	return prefix + "-" + placedAt.UTC().Format("20060102") + "-" + id
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestConfirmationNumber tests prefix handling and that the date is the UTC order date
+func TestConfirmationNumber(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	tests := []struct {
+		name     string
+		prefix   string
+		id       string
+		placedAt time.Time
+		want     string
+	}{
+		{"basic", "SHOP", "a1b2", time.Date(2024, 1, 1, 9, 30, 0, 0, time.UTC), "SHOP-20240101-a1b2"},
+		{"zero padded month and day", "SHOP", "42", time.Date(2024, 3, 7, 0, 0, 0, 0, time.UTC), "SHOP-20240307-42"},
+		{"late evening west of UTC is the next UTC day", "SHOP", "42", time.Date(2024, 12, 31, 22, 0, 0, 0, newYork), "SHOP-20250101-42"},
+		{"leap day", "SHOP", "42", time.Date(2024, 2, 29, 23, 59, 59, 0, time.UTC), "SHOP-20240229-42"},
+		{"trailing dash in prefix", "SHOP-", "42", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), "SHOP-20240101-42"},
+		{"no prefix", "", "42", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), "42"},
+		{"no id", "SHOP", "", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), ""},
+	}
+	for _, tt := range tests {
+		if got := confirmationNumber(tt.prefix, tt.id, tt.placedAt); got != tt.want {
+			t.Errorf("%s: confirmationNumber(%q, %q, %v) = %q, want %q", tt.name, tt.prefix, tt.id, tt.placedAt, got, tt.want)
+		}
+	}
+}
+
+// TestOrderHandler_ConfirmationPrefix tests that placed orders get a confirmation number and keep the upstream id
+func TestOrderHandler_ConfirmationPrefix(t *testing.T) {
+	newFakeDotnet(t, nil)
+	os.Setenv("CONFIRMATION_PREFIX", "SHOP")
+	defer os.Unsetenv("CONFIRMATION_PREFIX")
+
+	order := PlaceOrderRequest{Items: []OrderItemRequest{{Id: "p1", Quantity: 1, Price: 5}}, DeliveryAddress: "1 Main St", TotalAmount: pricePtr(5)}
+	rr := httptest.NewRecorder()
+	orderHandler(rr, postJSON(t, "/order", order))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var resp PlaceOrderResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if want := "SHOP-" + time.Now().UTC().Format("20060102") + "-order-1"; resp.OrderId != want || resp.UpstreamOrderId != "order-1" {
+		t.Errorf("orderId = %q, upstreamOrderId = %q, want %q and order-1", resp.OrderId, resp.UpstreamOrderId, want)
+	}
+}
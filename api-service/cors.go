@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultCORSMaxAge is how long browsers may cache a preflight result when CORS_MAX_AGE is not set
+const defaultCORSMaxAge = 600 * time.Second
+
+// corsAllowedOrigins returns the comma-separated origins in CORS_ALLOWED_ORIGINS, or nil to allow any
+func corsAllowedOrigins() []string {
+	var origins []string
+	for _, origin := range strings.Split(os.Getenv("CORS_ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimRight(strings.TrimSpace(origin), "/"); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// checkCORSConfig refuses combinations browsers reject or that would be unsafe: credentialed CORS
+// must name its origins, since echoing every origin would let any site act as a logged-in user
+func checkCORSConfig() error {
+	origins := corsAllowedOrigins()
+	if slices.Contains(origins, "*") {
+		return errors.New("CORS_ALLOWED_ORIGINS must list origins; leave it unset to allow any origin")
+	}
+	if envBool("CORS_ALLOW_CREDENTIALS", false) && len(origins) == 0 {
+		return errors.New("CORS_ALLOW_CREDENTIALS=true requires CORS_ALLOWED_ORIGINS")
+	}
+	return nil
+}
+
+// applyCORS sets the CORS headers for an endpoint answering methods. headers is the endpoint's
+// default Access-Control-Allow-Headers, replaced by CORS_ALLOWED_HEADERS when that is set. Preflight
+// requests also get Access-Control-Max-Age from CORS_MAX_AGE so browsers don't preflight every call.
+//
+// Any origin is allowed with "*" unless CORS_ALLOWED_ORIGINS is set, in which case only a listed
+// Origin is echoed back. CORS_ALLOW_CREDENTIALS=true adds Access-Control-Allow-Credentials to those
+// echoed responses; "*" is never sent with credentials, as the spec forbids it.
+func applyCORS(w http.ResponseWriter, r *http.Request, methods, headers string) {
+	if configured := os.Getenv("CORS_ALLOWED_HEADERS"); configured != "" {
+		headers = configured
+	}
+	origins := corsAllowedOrigins()
+	credentials := envBool("CORS_ALLOW_CREDENTIALS", false)
+	if len(origins) == 0 && !credentials {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		// The response depends on the request's Origin, so shared caches must key on it
+		w.Header().Add("Vary", "Origin")
+		origin := r.Header.Get("Origin")
+		if origin == "" || !slices.Contains(origins, origin) {
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if credentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+	}
+	w.Header().Set("Access-Control-Allow-Methods", methods)
+	w.Header().Set("Access-Control-Allow-Headers", headers)
+	if r.Method == http.MethodOptions {
+		if maxAge := envSeconds("CORS_MAX_AGE", defaultCORSMaxAge); maxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(maxAge.Seconds())))
+		}
+	}
+}
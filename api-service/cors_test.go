@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// preflight sends an OPTIONS request to handler and returns the response
+func preflight(handler http.HandlerFunc, target string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodOptions, target, nil)
+	req.Header.Set("Origin", "https://shop.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	handler(rr, req)
+	return rr
+}
+
+// TestApplyCORS_PreflightHeaders tests the default and configured max-age and allowed headers
+func TestApplyCORS_PreflightHeaders(t *testing.T) {
+	rr := preflight(orderHandler, "/order")
+	if rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if got := rr.Header().Get("Access-Control-Max-Age"); got != "600" {
+		t.Errorf("Access-Control-Max-Age = %q, want 600", got)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Headers"); got != "Content-Type, Authorization, X-API-Key, X-Tenant-ID" {
+		t.Errorf("Access-Control-Allow-Headers = %q, want the endpoint default", got)
+	}
+
+	os.Setenv("CORS_MAX_AGE", "1h")
+	defer os.Unsetenv("CORS_MAX_AGE")
+	os.Setenv("CORS_ALLOWED_HEADERS", "Content-Type, Authorization, X-Request-ID")
+	defer os.Unsetenv("CORS_ALLOWED_HEADERS")
+	for _, handler := range []http.HandlerFunc{orderHandler, authHandler} {
+		rr := preflight(handler, "/")
+		if got := rr.Header().Get("Access-Control-Max-Age"); got != "3600" {
+			t.Errorf("Access-Control-Max-Age = %q, want 3600", got)
+		}
+		if got := rr.Header().Get("Access-Control-Allow-Headers"); got != "Content-Type, Authorization, X-Request-ID" {
+			t.Errorf("Access-Control-Allow-Headers = %q, want CORS_ALLOWED_HEADERS", got)
+		}
+	}
+
+	os.Setenv("CORS_MAX_AGE", "900")
+	if got := preflight(orderHandler, "/order").Header().Get("Access-Control-Max-Age"); got != "900" {
+		t.Errorf("Access-Control-Max-Age = %q with CORS_MAX_AGE=900, want 900", got)
+	}
+	os.Setenv("CORS_MAX_AGE", "0s")
+	if got := preflight(orderHandler, "/order").Header().Get("Access-Control-Max-Age"); got != "" {
+		t.Errorf("Access-Control-Max-Age = %q with CORS_MAX_AGE=0s, want none", got)
+	}
+}
+
+// TestApplyCORS_MaxAgeOnlyOnPreflight tests that ordinary responses don't carry Access-Control-Max-Age
+func TestApplyCORS_MaxAgeOnlyOnPreflight(t *testing.T) {
+	rr := httptest.NewRecorder()
+	applyCORS(rr, httptest.NewRequest(http.MethodGet, "/products", nil), "GET, OPTIONS", "Content-Type")
+	if got := rr.Header().Get("Access-Control-Max-Age"); got != "" {
+		t.Errorf("Access-Control-Max-Age = %q on a GET, want none", got)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want *", got)
+	}
+}
+
+// TestApplyCORS_Credentials tests that credentialed CORS echoes listed origins and never sends "*"
+func TestApplyCORS_Credentials(t *testing.T) {
+	os.Setenv("CORS_ALLOW_CREDENTIALS", "true")
+	defer os.Unsetenv("CORS_ALLOW_CREDENTIALS")
+	os.Setenv("CORS_ALLOWED_ORIGINS", "https://shop.example.com, https://admin.example.com/")
+	defer os.Unsetenv("CORS_ALLOWED_ORIGINS")
+
+	rr := preflight(orderHandler, "/order")
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "https://shop.example.com" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want the request origin", got)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("Access-Control-Allow-Credentials = %q, want true", got)
+	}
+	if got := rr.Header().Get("Vary"); got != "Origin" {
+		t.Errorf("Vary = %q, want Origin", got)
+	}
+
+	// An unlisted origin gets no CORS grant at all
+	rr = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/products", nil)
+	req.Header.Set("Origin", "https://evil.example.net")
+	applyCORS(rr, req, "GET, OPTIONS", "Content-Type")
+	for _, header := range []string{"Access-Control-Allow-Origin", "Access-Control-Allow-Credentials", "Access-Control-Allow-Methods"} {
+		if got := rr.Header().Get(header); got != "" {
+			t.Errorf("%s = %q for an unlisted origin, want none", header, got)
+		}
+	}
+
+	// Without a list, credentials fail closed rather than falling back to "*"
+	os.Unsetenv("CORS_ALLOWED_ORIGINS")
+	rr = preflight(orderHandler, "/order")
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Access-Control-Allow-Origin = %q with credentials and no origins, want none", got)
+	}
+}
+
+// TestCheckCORSConfig tests that unsafe credential and origin combinations are refused
+func TestCheckCORSConfig(t *testing.T) {
+	tests := []struct {
+		credentials, origins string
+		wantErr              bool
+	}{
+		{"", "", false},
+		{"", "https://shop.example.com", false},
+		{"true", "https://shop.example.com", false},
+		{"true", "", true},
+		{"", "*", true},
+		{"true", "https://shop.example.com,*", true},
+	}
+	for _, tt := range tests {
+		os.Setenv("CORS_ALLOW_CREDENTIALS", tt.credentials)
+		os.Setenv("CORS_ALLOWED_ORIGINS", tt.origins)
+		if err := checkCORSConfig(); (err != nil) != tt.wantErr {
+			t.Errorf("credentials %q, origins %q: checkCORSConfig() = %v, want error %v", tt.credentials, tt.origins, err, tt.wantErr)
+		}
+	}
+	os.Unsetenv("CORS_ALLOW_CREDENTIALS")
+	os.Unsetenv("CORS_ALLOWED_ORIGINS")
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Coupon is a percent-off promotion loaded from COUPONS_FILE
+type Coupon struct {
+	PercentOff float64    `json:"percentOff"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"` // Optional: RFC 3339 timestamp after which the code is rejected
+}
+
+// UnmarshalJSON accepts either a bare number ("SAVE10": 10) or an object with percentOff and expiresAt
+func (c *Coupon) UnmarshalJSON(data []byte) error {
+	var percent float64
+	if err := json.Unmarshal(data, &percent); err == nil {
+		*c = Coupon{PercentOff: percent}
+		return nil
+	}
+	type plain Coupon // Avoid recursing into this method
+	return json.Unmarshal(data, (*plain)(c))
+}
+
+// loadCoupons reads the coupon code to Coupon mapping from a JSON file. Codes are case-insensitive.
+func loadCoupons(path string) (map[string]Coupon, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]Coupon
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	coupons := make(map[string]Coupon, len(raw))
+	for code, coupon := range raw {
+		if coupon.PercentOff <= 0 || coupon.PercentOff > 100 {
+			return nil, fmt.Errorf("coupon %q: percentOff must be between 0 and 100", code)
+		}
+		coupons[strings.ToUpper(code)] = coupon
+	}
+	return coupons, nil
+}
+
+// itemsSubtotal recomputes the order total from its items rather than trusting the client's total
+func itemsSubtotal(items []OrderItemRequest) float64 {
+	var total float64
+	for _, item := range items {
+		total += float64(item.Price) * float64(item.Quantity)
+	}
+	return roundMoney(total)
+}
+
+// roundMoney rounds an amount to whole cents
+func roundMoney(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// applyCoupon looks up the order's coupon code in COUPONS_FILE and, if valid, sets TotalAmount to the
+// discounted recomputed total. It returns the discount applied; orders without a code are left untouched.
+func applyCoupon(order *PlaceOrderRequest, now time.Time) (float64, error) {
+	if order.CouponCode == "" {
+		return 0, nil
+	}
+	path := os.Getenv("COUPONS_FILE")
+	if path == "" {
+		return 0, &requestError{Status: http.StatusBadRequest, Message: "Coupon codes are not enabled"}
+	}
+	coupons, err := loadCoupons(path)
+	if err != nil {
+		return 0, fmt.Errorf("loading coupons: %w", err)
+	}
+
+	coupon, ok := coupons[strings.ToUpper(strings.TrimSpace(order.CouponCode))]
+	if !ok {
+		return 0, &requestError{Status: http.StatusBadRequest, Message: fmt.Sprintf("Invalid coupon code %q", order.CouponCode)}
+	}
+	if coupon.ExpiresAt != nil && !now.Before(*coupon.ExpiresAt) {
+		return 0, &requestError{Status: http.StatusBadRequest, Message: fmt.Sprintf("Coupon code %q has expired", order.CouponCode)}
+	}
+
+	subtotal := itemsSubtotal(order.Items)
+	discount := roundMoney(subtotal * coupon.PercentOff / 100)
+	total := Price(roundMoney(subtotal - discount))
+	order.TotalAmount = &total
+	return discount, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCouponsFile writes a coupons file and points COUPONS_FILE at it
+func writeCouponsFile(t *testing.T, contents string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "coupons.json")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("Could not write coupons file: %v", err)
+	}
+	os.Setenv("COUPONS_FILE", path)
+	t.Cleanup(func() { os.Unsetenv("COUPONS_FILE") })
+}
+
+// TestOrderHandler_CouponApplied tests that a valid coupon discounts the recomputed total
+func TestOrderHandler_CouponApplied(t *testing.T) {
+	writeCouponsFile(t, `{"SAVE10": 10, "SPRING": {"percentOff": 25, "expiresAt": "2999-01-01T00:00:00Z"}}`)
+	dotnet := newFakeDotnet(t, nil)
+
+	order := PlaceOrderRequest{
+		Items:           []OrderItemRequest{{Id: "prod1", Quantity: 2, Price: 50}},
+		DeliveryAddress: "1 Main St",
+		TotalAmount:     pricePtr(1), // Client total is ignored
+		CouponCode:      "save10",
+	}
+	rr := httptest.NewRecorder()
+	orderHandler(rr, postJSON(t, "/order", order))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v (%s)", rr.Code, http.StatusOK, rr.Body.String())
+	}
+
+	var resp PlaceOrderResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.Discount != 10 {
+		t.Errorf("response discount = %v, want 10", resp.Discount)
+	}
+	if got := dotnet.lastOrder(t).TotalAmount; got == nil || *got != 90 {
+		t.Errorf("forwarded total = %v, want 90", got)
+	}
+}
+
+// TestOrderHandler_CouponRejected tests invalid, expired and disabled coupon codes
+func TestOrderHandler_CouponRejected(t *testing.T) {
+	newFakeDotnet(t, nil)
+	order := PlaceOrderRequest{Items: []OrderItemRequest{{Id: "prod1", Quantity: 1, Price: 50}}, TotalAmount: pricePtr(50), DeliveryAddress: "1 Main St"}
+
+	tests := []struct {
+		name    string
+		coupons string
+		code    string
+	}{
+		{"unknown code", `{"SAVE10": 10}`, "BOGUS"},
+		{"expired code", `{"OLD": {"percentOff": 10, "expiresAt": "2000-01-01T00:00:00Z"}}`, "OLD"},
+		{"coupons disabled", "", "SAVE10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.coupons != "" {
+				writeCouponsFile(t, tt.coupons)
+			}
+			order.CouponCode = tt.code
+			rr := httptest.NewRecorder()
+			orderHandler(rr, postJSON(t, "/order", order))
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+			}
+			var resp ErrorResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || resp.Error == "" {
+				t.Errorf("expected a JSON error message, got %q", rr.Body.String())
+			}
+		})
+	}
+}
+
+// TestApplyCoupon_NoCode tests that orders without a coupon are forwarded unchanged
+func TestApplyCoupon_NoCode(t *testing.T) {
+	order := PlaceOrderRequest{Items: []OrderItemRequest{{Id: "prod1", Quantity: 1, Price: 50}}, TotalAmount: pricePtr(50), DeliveryAddress: "1 Main St"}
+	discount, err := applyCoupon(&order, time.Now())
+	if err != nil || discount != 0 || *order.TotalAmount != 50 {
+		t.Errorf("applyCoupon without code = %v, %v, total %v", discount, err, *order.TotalAmount)
+	}
+}
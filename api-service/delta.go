@@ -0,0 +1,84 @@
+package main
+
+// Partial catalog refresh. With PRODUCTS_DELTA_REFRESH=true an expired catalog is refreshed by asking
+// the Dotnet service only for the products changed since the last refresh
+// (GET /products/changed?since=<RFC3339>) and merging them into the cached copy. The delta response is
+//
+//	{"changed": [<product>, ...], "removed": ["<id>", ...], "asOf": "<RFC3339>"}
+//
+// since is compared against the Dotnet service's clock, not this replica's, so any skew between the
+// two, or a change committed with a timestamp before the previous delta was answered, would make a
+// since taken from time.Now() skip changes until the next full fetch. The optional asOf is the
+// service's own watermark and is sent back as the next since when present. Otherwise since is the
+// local start of the previous fetch minus PRODUCTS_DELTA_SINCE_MARGIN, which re-requests a few
+// changes already merged (harmless, as merging is idempotent) but only absorbs skew up to the margin.
+//
+// A full fetch is made instead when nothing is cached, when the delta call fails (e.g. a Dotnet
+// service without the endpoint answers 404), and at least every PRODUCTS_FULL_REFRESH_INTERVAL so
+// any drift between the merged copy and the real catalog is corrected.
+
+import (
+	"context"
+	"time"
+)
+
+// defaultFullRefreshInterval is how often delta refresh still fetches the whole catalog when
+// PRODUCTS_FULL_REFRESH_INTERVAL is not set
+const defaultFullRefreshInterval = time.Hour
+
+// defaultDeltaSinceMargin is how far before a fetch started the next delta's since is set, when the
+// Dotnet service sends no asOf and PRODUCTS_DELTA_SINCE_MARGIN is not set
+const defaultDeltaSinceMargin = 30 * time.Second
+
+// DeltaCatalogClient is implemented by clients that can fetch only the products changed since a time
+type DeltaCatalogClient interface {
+	GetChangedProducts(ctx context.Context, since time.Time) (ProductDelta, error)
+}
+
+// deltaRefreshEnabled reports whether PRODUCTS_DELTA_REFRESH=true lets the cache refresh from deltas
+func deltaRefreshEnabled() bool {
+	return envBool("PRODUCTS_DELTA_REFRESH", false)
+}
+
+// deltaWatermark returns the since of the next delta after a fetch that started at started: the
+// Dotnet service's asOf when it sent one, and started less PRODUCTS_DELTA_SINCE_MARGIN otherwise
+func deltaWatermark(asOf, started time.Time) time.Time {
+	if !asOf.IsZero() {
+		return asOf
+	}
+	return started.Add(-envDuration("PRODUCTS_DELTA_SINCE_MARGIN", defaultDeltaSinceMargin))
+}
+
+// mergeProductDelta returns products with delta applied: changed products replace the ones with the
+// same id in place, new ones are appended in the order received, and removed ids are dropped.
+// products itself is not modified, since it is shared with readers of the cached entry.
+func mergeProductDelta(products []Product, delta ProductDelta) []Product {
+	changed := make(map[string]Product, len(delta.Changed))
+	for _, p := range delta.Changed {
+		changed[p.Id] = p
+	}
+	removed := make(map[string]bool, len(delta.Removed))
+	for _, id := range delta.Removed {
+		removed[id] = true
+	}
+
+	merged := make([]Product, 0, len(products)+len(delta.Changed))
+	seen := make(map[string]bool, len(products))
+	for _, p := range products {
+		seen[p.Id] = true
+		if removed[p.Id] {
+			continue
+		}
+		if update, ok := changed[p.Id]; ok {
+			p = update
+		}
+		merged = append(merged, p)
+	}
+	for _, p := range delta.Changed {
+		if !seen[p.Id] && !removed[p.Id] {
+			seen[p.Id] = true // A product listed twice in the delta is appended once, with its last version
+			merged = append(merged, changed[p.Id])
+		}
+	}
+	return merged
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestMergeProductDelta tests that changes replace products in place, new ones are appended and removals dropped
+func TestMergeProductDelta(t *testing.T) {
+	products := []Product{{Id: "a", Stock: 1}, {Id: "b", Stock: 2}, {Id: "c", Stock: 3}}
+	tests := []struct {
+		name  string
+		delta ProductDelta
+		want  []Product
+	}{
+		{"no changes", ProductDelta{}, products},
+		{"update", ProductDelta{Changed: []Product{{Id: "b", Stock: 0}}}, []Product{{Id: "a", Stock: 1}, {Id: "b", Stock: 0}, {Id: "c", Stock: 3}}},
+		{"add", ProductDelta{Changed: []Product{{Id: "d", Stock: 4}}}, []Product{{Id: "a", Stock: 1}, {Id: "b", Stock: 2}, {Id: "c", Stock: 3}, {Id: "d", Stock: 4}}},
+		{"remove", ProductDelta{Removed: []string{"a", "unknown"}}, []Product{{Id: "b", Stock: 2}, {Id: "c", Stock: 3}}},
+		{"changed and removed", ProductDelta{Changed: []Product{{Id: "c", Stock: 9}, {Id: "d", Stock: 4}}, Removed: []string{"c", "d"}}, []Product{{Id: "a", Stock: 1}, {Id: "b", Stock: 2}}},
+		{"added twice", ProductDelta{Changed: []Product{{Id: "d", Stock: 4}, {Id: "d", Stock: 5}}}, []Product{{Id: "a", Stock: 1}, {Id: "b", Stock: 2}, {Id: "c", Stock: 3}, {Id: "d", Stock: 5}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := append([]Product(nil), products...)
+			if got := mergeProductDelta(products, tt.delta); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeProductDelta() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(products, before) {
+				t.Errorf("mergeProductDelta modified its input: %v", products)
+			}
+		})
+	}
+}
+
+// newDeltaUpstream serves the full catalog and, when changed is non-nil, the delta endpoint, counting calls to each
+func newDeltaUpstream(t *testing.T, catalog []Product, changed http.HandlerFunc) (full, deltas *atomic.Int32) {
+	t.Helper()
+	full, deltas = new(atomic.Int32), new(atomic.Int32)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/all-products":
+			full.Add(1)
+			json.NewEncoder(w).Encode(catalog)
+		case "/products/changed":
+			deltas.Add(1)
+			if changed == nil {
+				http.NotFound(w, r)
+				return
+			}
+			changed(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	os.Setenv("DOTNET_PRODUCTS_API_URL", server.URL)
+	os.Setenv("PRODUCTS_DELTA_REFRESH", "true")
+	productsCache.invalidate()
+	t.Cleanup(func() {
+		server.Close()
+		os.Unsetenv("DOTNET_PRODUCTS_API_URL")
+		os.Unsetenv("PRODUCTS_DELTA_REFRESH")
+		productsCache.invalidate()
+	})
+	return full, deltas
+}
+
+// TestProductCache_DeltaRefresh tests that an expired catalog is refreshed from the changes since the
+// last fetch, asking from PRODUCTS_DELTA_SINCE_MARGIN before it started and then from the delta's asOf
+func TestProductCache_DeltaRefresh(t *testing.T) {
+	var since atomic.Value
+	asOf := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	full, deltas := newDeltaUpstream(t, []Product{{Id: "a", Stock: 1}, {Id: "b", Stock: 2}}, func(w http.ResponseWriter, r *http.Request) {
+		since.Store(r.URL.Query().Get("since"))
+		json.NewEncoder(w).Encode(ProductDelta{Changed: []Product{{Id: "b", Stock: 0}}, Removed: []string{"a"}, AsOf: asOf})
+	})
+	os.Setenv("PRODUCTS_DELTA_SINCE_MARGIN", "1m")
+	defer os.Unsetenv("PRODUCTS_DELTA_SINCE_MARGIN")
+
+	before := time.Now()
+	if _, err := productsCache.get(context.Background(), 0); err != nil {
+		t.Fatalf("first get: %v", err)
+	}
+	after := time.Now()
+	entry, err := productsCache.get(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("second get: %v", err)
+	}
+
+	if want := []Product{{Id: "b", Stock: 0}}; !reflect.DeepEqual(entry.Products, want) {
+		t.Errorf("products after delta = %v, want %v", entry.Products, want)
+	}
+	if full.Load() != 1 || deltas.Load() != 1 {
+		t.Errorf("got %d full and %d delta fetches, want one of each", full.Load(), deltas.Load())
+	}
+	s, _ := since.Load().(string)
+	got, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil || got.Before(before.Add(-time.Minute)) || got.After(after.Add(-time.Minute)) {
+		t.Errorf("since = %q, want a minute before the start of the first fetch", s)
+	}
+
+	if _, err := productsCache.get(context.Background(), 0); err != nil {
+		t.Fatalf("third get: %v", err)
+	}
+	if s, _ := since.Load().(string); s != asOf.Format(time.RFC3339Nano) {
+		t.Errorf("since after a delta with asOf = %q, want %q", s, asOf.Format(time.RFC3339Nano))
+	}
+}
+
+// TestProductCache_DeltaFallback tests that a missing delta endpoint falls back to a full refresh
+func TestProductCache_DeltaFallback(t *testing.T) {
+	full, deltas := newDeltaUpstream(t, []Product{{Id: "a", Stock: 1}}, nil)
+
+	for i := 0; i < 2; i++ {
+		entry, err := productsCache.get(context.Background(), 0)
+		if err != nil || len(entry.Products) != 1 {
+			t.Fatalf("get %d = %v, %v, want the full catalog", i, entry, err)
+		}
+	}
+	if full.Load() != 2 || deltas.Load() != 1 {
+		t.Errorf("got %d full and %d delta fetches, want 2 full after 1 failed delta", full.Load(), deltas.Load())
+	}
+}
+
+// TestProductCache_DeltaRefreshPeriodicFull tests that PRODUCTS_FULL_REFRESH_INTERVAL forces a full fetch, as does delta refresh being off
+func TestProductCache_DeltaRefreshPeriodicFull(t *testing.T) {
+	full, deltas := newDeltaUpstream(t, []Product{{Id: "a", Stock: 1}}, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(ProductDelta{})
+	})
+	os.Setenv("PRODUCTS_FULL_REFRESH_INTERVAL", "1ns")
+	defer os.Unsetenv("PRODUCTS_FULL_REFRESH_INTERVAL")
+	productsCache.get(context.Background(), 0)
+	productsCache.get(context.Background(), 0)
+
+	os.Unsetenv("PRODUCTS_FULL_REFRESH_INTERVAL")
+	os.Setenv("PRODUCTS_DELTA_REFRESH", "false")
+	productsCache.get(context.Background(), 0)
+	if full.Load() != 3 || deltas.Load() != 0 {
+		t.Errorf("got %d full and %d delta fetches, want only full ones", full.Load(), deltas.Load())
+	}
+}
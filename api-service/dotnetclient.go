@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/salus-templates/shopping-cart-backend/api-service/dotnetclient"
+)
+
+// The Dotnet API's types, from package dotnetclient
+type (
+	Product            = dotnetclient.Product
+	OrderItemRequest   = dotnetclient.OrderItemRequest
+	PlaceOrderRequest  = dotnetclient.PlaceOrderRequest
+	PlaceOrderResponse = dotnetclient.PlaceOrderResponse
+	PriceMismatch      = dotnetclient.PriceMismatch
+	OrderBreakdown     = dotnetclient.OrderBreakdown
+	ProductDelta       = dotnetclient.ProductDelta
+	Price              = dotnetclient.Price
+)
+
+// upstreamError is an error to report to the client with its status and message. dotnetclient
+// returns them for upstream failures, and handlers use them for their own server-side failures.
+type upstreamError = dotnetclient.Error
+
+// errOrderOutcomeUnknown marks a PlaceOrder failure after the order may have reached the Dotnet
+// service, when it may have been placed and must not be blindly resubmitted
+var errOrderOutcomeUnknown = dotnetclient.ErrOrderOutcomeUnknown
+
+// DotnetClient is how handlers talk to the Dotnet products service. Failures are *upstreamErrors
+// carrying the status and message to report to the client.
+type DotnetClient interface {
+	// GetProducts returns the full catalog, never nil
+	GetProducts(ctx context.Context) ([]Product, error)
+	// GetProduct returns one product from the catalog, or a 404 upstreamError if there is none with id
+	GetProduct(ctx context.Context, id string) (Product, error)
+	// PlaceOrder forwards an order and returns Dotnet's status code and response, which may be an
+	// unsuccessful order such as one with out-of-stock items
+	PlaceOrder(ctx context.Context, order PlaceOrderRequest) (int, PlaceOrderResponse, error)
+}
+
+// LocalizedCatalogClient is implemented by clients that can fetch the catalog in a given language
+type LocalizedCatalogClient interface {
+	// GetLocalizedProducts is GetProducts sending acceptLanguage as Accept-Language. It also returns the
+	// Content-Language of the response, empty when the Dotnet service didn't send one.
+	GetLocalizedProducts(ctx context.Context, acceptLanguage string) ([]Product, string, error)
+}
+
+// dotnet is the client used by the handlers; swapped for a fake in tests
+var dotnet DotnetClient = HTTPDotnetClient{}
+
+// HTTPDotnetClient is the dotnetclient.Client configured from the environment for each call. When
+// BaseURL is empty the request's tenant's instances from TENANT_UPSTREAMS_FILE, or else those in
+// DOTNET_PRODUCTS_API_URLS (or DOTNET_PRODUCTS_API_URL), are used, failing over between them;
+// timeouts and retries always come from the environment (PRODUCTS_TIMEOUT, ORDER_TIMEOUT,
+// UPSTREAM_MAX_ATTEMPTS, ...).
+type HTTPDotnetClient struct {
+	BaseURL string
+}
+
+// client returns the dotnetclient.Client for one call, so configuration changes apply to the next
+func (c HTTPDotnetClient) client() dotnetclient.Client {
+	return dotnetclient.Client{
+		Transport:       failoverTransport{baseURL: c.BaseURL},
+		PathPrefix:      os.Getenv("DOTNET_API_PREFIX"),
+		ProductsTimeout: upstreamTimeout("PRODUCTS_TIMEOUT"),
+		OrderTimeout:    upstreamTimeout("ORDER_TIMEOUT"),
+	}
+}
+
+func (c HTTPDotnetClient) GetProducts(ctx context.Context) ([]Product, error) {
+	return c.client().GetProducts(ctx)
+}
+
+func (c HTTPDotnetClient) GetLocalizedProducts(ctx context.Context, acceptLanguage string) ([]Product, string, error) {
+	return c.client().GetLocalizedProducts(ctx, acceptLanguage)
+}
+
+func (c HTTPDotnetClient) GetProduct(ctx context.Context, id string) (Product, error) {
+	return c.client().GetProduct(ctx, id)
+}
+
+func (c HTTPDotnetClient) GetChangedProducts(ctx context.Context, since time.Time) (ProductDelta, error) {
+	return c.client().GetChangedProducts(ctx, since)
+}
+
+func (c HTTPDotnetClient) PlaceOrder(ctx context.Context, order PlaceOrderRequest) (int, PlaceOrderResponse, error) {
+	return c.client().PlaceOrder(ctx, order)
+}
+
+// failoverTransport is the dotnetclient.Transport over the Dotnet instances for each request, in
+// order of preference: baseURL, else those of the request's tenant, else the configured ones.
+// Idempotent requests are retried with retryPolicyFromEnv and fail over on any failure; others
+// only fail over when they couldn't connect.
+type failoverTransport struct {
+	baseURL string
+}
+
+func (t failoverTransport) Do(ctx context.Context, idempotent bool, newRequest func(base string) (*http.Request, error)) (*http.Response, error) {
+	return withFailover(ctx, t.instances(ctx), !idempotent, func(base string) (*http.Response, error) {
+		if idempotent {
+			return doWithRetry(ctx, newUpstreamClient(), func() (*http.Request, error) { return newRequest(base) }, retryPolicyFromEnv())
+		}
+		req, err := newRequest(base)
+		if err != nil {
+			return nil, err
+		}
+		return newUpstreamClient().Do(req)
+	})
+}
+
+func (t failoverTransport) instances(ctx context.Context) []upstreamInstance {
+	if t.baseURL != "" {
+		return []upstreamInstance{{base: t.baseURL}}
+	}
+	if instances := tenantInstances(ctx); len(instances) > 0 {
+		return instances
+	}
+	return upstreamInstances()
+}
@@ -0,0 +1,209 @@
+// Package dotnetclient is a typed client for the Dotnet products service's HTTP API. It builds the
+// endpoint URLs, bounds each call with a timeout, decodes the responses and maps failures to *Errors
+// carrying the status and message to report to clients. Choosing an instance, retrying and failing
+// over are left to the Transport.
+package dotnetclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrOrderOutcomeUnknown marks a PlaceOrder failure after the order may have reached the Dotnet
+// service, when it may have been placed and must not be blindly resubmitted
+var ErrOrderOutcomeUnknown = errors.New("order may have been placed")
+
+// Error is a failed call, with the HTTP status and message to report to the client
+type Error struct {
+	Status  int    // HTTP status to return to the client
+	Message string // Client-facing error message
+	Err     error  // Underlying cause, for logging
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+// Unwrap exposes the cause to errors.Is and errors.As
+func (e *Error) Unwrap() error { return e.Err }
+
+// Transport sends a request to the Dotnet service. newRequest builds it for the base URL of the
+// instance chosen, and may be called again to retry or to try another instance. An idempotent
+// request may be resent after any failure; any other request only when it couldn't connect (see
+// IsConnectError), since the first attempt may have taken effect.
+type Transport interface {
+	Do(ctx context.Context, idempotent bool, newRequest func(base string) (*http.Request, error)) (*http.Response, error)
+}
+
+// Client calls the Dotnet service's endpoints through Transport
+type Client struct {
+	Transport       Transport
+	PathPrefix      string        // Put before every endpoint path, e.g. "/api/v1"
+	ProductsTimeout time.Duration // Bounds each catalog call, retries and failover included; 0 for none
+	OrderTimeout    time.Duration // Bounds PlaceOrder; 0 for none
+}
+
+// EndpointURL builds the URL of the endpoint at path on the instance at base, with exactly one
+// slash between base, prefix and path
+func EndpointURL(base, prefix, path string) string {
+	url := strings.TrimRight(base, "/")
+	for _, part := range []string{prefix, path} {
+		if part = strings.Trim(part, "/"); part != "" {
+			url += "/" + part
+		}
+	}
+	return url
+}
+
+// IsConnectError reports whether err is a failure to connect, so the request was never sent
+func IsConnectError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// withTimeout bounds ctx by timeout, if there is one
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// GetProducts returns the full catalog, never nil
+func (c Client) GetProducts(ctx context.Context) ([]Product, error) {
+	products, _, err := c.GetLocalizedProducts(ctx, "")
+	return products, err
+}
+
+// GetLocalizedProducts is GetProducts sending acceptLanguage as Accept-Language. It also returns the
+// Content-Language of the response, empty when the Dotnet service didn't send one.
+func (c Client) GetLocalizedProducts(ctx context.Context, acceptLanguage string) ([]Product, string, error) {
+	ctx, cancel := withTimeout(ctx, c.ProductsTimeout)
+	defer cancel()
+	resp, err := c.Transport.Do(ctx, true, func(base string) (*http.Request, error) {
+		targetURL := EndpointURL(base, c.PathPrefix, "/all-products")
+		log.Printf("Fetching products from Dotnet Products Service: %s", targetURL)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, targetURL, nil)
+		if err == nil && acceptLanguage != "" {
+			req.Header.Set("Accept-Language", acceptLanguage)
+		}
+		return req, err
+	})
+	if err != nil {
+		return nil, "", &Error{Status: http.StatusBadGateway, Message: "Failed to fetch products from backend service", Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", &Error{Status: http.StatusBadGateway, Message: fmt.Sprintf("Backend service error: %d", resp.StatusCode)}
+	}
+
+	// A null or empty body means an empty catalog, which must reach clients as [] rather than null
+	var products []Product
+	if err := json.NewDecoder(resp.Body).Decode(&products); err != nil && !errors.Is(err, io.EOF) {
+		return nil, "", &Error{Status: http.StatusInternalServerError, Message: "Failed to parse products data from backend", Err: err}
+	}
+	if products == nil {
+		products = []Product{}
+	}
+	return products, resp.Header.Get("Content-Language"), nil
+}
+
+// GetProduct looks the product up in the full catalog, as the Dotnet service has no single-product
+// endpoint. It returns a 404 *Error if there is none with id.
+func (c Client) GetProduct(ctx context.Context, id string) (Product, error) {
+	products, err := c.GetProducts(ctx)
+	if err != nil {
+		return Product{}, err
+	}
+	for _, p := range products {
+		if p.Id == id {
+			return p, nil
+		}
+	}
+	return Product{}, &Error{Status: http.StatusNotFound, Message: "Product not found"}
+}
+
+// GetChangedProducts calls GET /products/changed?since=..., bounded like GetProducts
+func (c Client) GetChangedProducts(ctx context.Context, since time.Time) (ProductDelta, error) {
+	ctx, cancel := withTimeout(ctx, c.ProductsTimeout)
+	defer cancel()
+	resp, err := c.Transport.Do(ctx, true, func(base string) (*http.Request, error) {
+		targetURL := EndpointURL(base, c.PathPrefix, "/products/changed") + "?since=" + url.QueryEscape(since.UTC().Format(time.RFC3339Nano))
+		log.Printf("Fetching product changes from Dotnet Products Service: %s", targetURL)
+		return http.NewRequestWithContext(ctx, http.MethodGet, targetURL, nil)
+	})
+	if err != nil {
+		return ProductDelta{}, &Error{Status: http.StatusBadGateway, Message: "Failed to fetch product changes from backend service", Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return ProductDelta{}, &Error{Status: http.StatusBadGateway, Message: fmt.Sprintf("Backend service error: %d", resp.StatusCode)}
+	}
+	var delta ProductDelta
+	if err := json.NewDecoder(resp.Body).Decode(&delta); err != nil {
+		return ProductDelta{}, &Error{Status: http.StatusInternalServerError, Message: "Failed to parse product changes from backend", Err: err}
+	}
+	return delta, nil
+}
+
+// PlaceOrder forwards an order and returns Dotnet's status code and response, which may be an
+// unsuccessful order such as one with out-of-stock items. It is sent as a non-idempotent request, so
+// it is never retried once it may have reached an instance. Only a failure to connect means the
+// order never reached Dotnet; every other error, a timeout or cancellation after the order was
+// written included, wraps ErrOrderOutcomeUnknown.
+func (c Client) PlaceOrder(ctx context.Context, order PlaceOrderRequest) (int, PlaceOrderResponse, error) {
+	body, err := json.Marshal(order)
+	if err != nil {
+		return 0, PlaceOrderResponse{}, &Error{Status: http.StatusInternalServerError, Message: "Internal server error", Err: err}
+	}
+
+	ctx, cancel := withTimeout(ctx, c.OrderTimeout)
+	defer cancel()
+	var errBuild error
+	resp, err := c.Transport.Do(ctx, false, func(base string) (*http.Request, error) {
+		targetURL := EndpointURL(base, c.PathPrefix, "/place-order")
+		log.Printf("Proxying order request to Dotnet Products Service: %s", targetURL)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, targetURL, bytes.NewReader(body))
+		if err != nil {
+			errBuild = err
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
+	if errBuild != nil {
+		return 0, PlaceOrderResponse{}, &Error{Status: http.StatusInternalServerError, Message: "Internal server error", Err: errBuild}
+	}
+	if err != nil {
+		if !IsConnectError(err) {
+			err = fmt.Errorf("%w: %w", ErrOrderOutcomeUnknown, err)
+		}
+		return 0, PlaceOrderResponse{}, &Error{Status: http.StatusBadGateway, Message: "Failed to place order with backend service", Err: err}
+	}
+	defer resp.Body.Close()
+
+	if code := resp.StatusCode; code != http.StatusOK {
+		log.Printf("An error occured: Dotnet service returned non-OK status: %d", code)
+	}
+
+	var orderResponse PlaceOrderResponse
+	if err := json.NewDecoder(resp.Body).Decode(&orderResponse); err != nil {
+		return 0, PlaceOrderResponse{}, &Error{Status: http.StatusInternalServerError, Message: "Failed to parse order response from backend", Err: fmt.Errorf("%w: %w", ErrOrderOutcomeUnknown, err)}
+	}
+	return resp.StatusCode, orderResponse, nil
+}
@@ -0,0 +1,150 @@
+package dotnetclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// singleTransport sends every request to one instance, once
+type singleTransport struct {
+	base string
+}
+
+func (t singleTransport) Do(ctx context.Context, idempotent bool, newRequest func(base string) (*http.Request, error)) (*http.Response, error) {
+	req, err := newRequest(t.base)
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(req)
+}
+
+// TestEndpointURL tests that base, prefix and path are joined with single slashes
+func TestEndpointURL(t *testing.T) {
+	tests := []struct {
+		base, prefix, path, want string
+	}{
+		{"http://dotnet:8080", "", "/all-products", "http://dotnet:8080/all-products"},
+		{"http://dotnet:8080/", "api/v1/", "all-products", "http://dotnet:8080/api/v1/all-products"},
+		{"http://dotnet:8080/base", "/api", "/place-order", "http://dotnet:8080/base/api/place-order"},
+	}
+	for _, tt := range tests {
+		if got := EndpointURL(tt.base, tt.prefix, tt.path); got != tt.want {
+			t.Errorf("EndpointURL(%q, %q, %q) = %q, want %q", tt.base, tt.prefix, tt.path, got, tt.want)
+		}
+	}
+}
+
+// TestClient_GetProducts tests the requested URL and language, and decoding of the catalog
+func TestClient_GetProducts(t *testing.T) {
+	body := `[{"id":"p1","name":"Widget","price":"4.50","stock":3}]`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/all-products" {
+			t.Errorf("requested %s, want /api/v1/all-products", r.URL.Path)
+		}
+		if lang := r.Header.Get("Accept-Language"); lang != "" {
+			w.Header().Set("Content-Language", lang)
+		}
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+	client := Client{Transport: singleTransport{server.URL}, PathPrefix: "/api/v1"}
+
+	products, err := client.GetProducts(context.Background())
+	if err != nil || len(products) != 1 || products[0].Price != 4.5 {
+		t.Errorf("GetProducts = %+v, %v, want the one product", products, err)
+	}
+	if _, lang, err := client.GetLocalizedProducts(context.Background(), "de"); err != nil || lang != "de" {
+		t.Errorf("GetLocalizedProducts = %q, %v, want Content-Language de", lang, err)
+	}
+
+	body = "null"
+	if products, err := client.GetProducts(context.Background()); err != nil || products == nil || len(products) != 0 {
+		t.Errorf("GetProducts of a null catalog = %#v, %v, want empty non-nil", products, err)
+	}
+	body = `[{"id":"p1","name":"Widget"},{"id":"p2","name":"Gadget"}]`
+	if p, err := client.GetProduct(context.Background(), "p2"); err != nil || p.Name != "Gadget" {
+		t.Errorf("GetProduct(p2) = %+v, %v, want Gadget", p, err)
+	}
+	var clientErr *Error
+	if _, err := client.GetProduct(context.Background(), "missing"); !errors.As(err, &clientErr) || clientErr.Status != http.StatusNotFound {
+		t.Errorf("GetProduct(missing) = %v, want a 404 Error", err)
+	}
+}
+
+// TestClient_ErrorMapping tests the statuses reported for failed, unreadable and slow responses
+func TestClient_ErrorMapping(t *testing.T) {
+	status, body := http.StatusInternalServerError, ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+	client := Client{Transport: singleTransport{server.URL}}
+
+	var clientErr *Error
+	if _, err := client.GetProducts(context.Background()); !errors.As(err, &clientErr) || clientErr.Status != http.StatusBadGateway {
+		t.Errorf("GetProducts on a 500 = %v, want a 502 Error", err)
+	}
+	if _, err := client.GetChangedProducts(context.Background(), time.Now()); !errors.As(err, &clientErr) || clientErr.Status != http.StatusBadGateway {
+		t.Errorf("GetChangedProducts on a 500 = %v, want a 502 Error", err)
+	}
+	status, body = http.StatusOK, "{not json"
+	if _, err := client.GetProducts(context.Background()); !errors.As(err, &clientErr) || clientErr.Status != http.StatusInternalServerError {
+		t.Errorf("GetProducts with an unreadable catalog = %v, want a 500 Error", err)
+	}
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer slow.Close()
+	client = Client{Transport: singleTransport{slow.URL}, ProductsTimeout: 10 * time.Millisecond}
+	if _, err := client.GetProducts(context.Background()); !errors.As(err, &clientErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetProducts past ProductsTimeout = %v, want a deadline Error", err)
+	}
+}
+
+// TestClient_PlaceOrder tests that Dotnet's status and response are passed through, and which
+// failures mark the order's outcome unknown
+func TestClient_PlaceOrder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var order PlaceOrderRequest
+		json.NewDecoder(r.Body).Decode(&order)
+		switch order.DeliveryAddress {
+		case "stall":
+			time.Sleep(100 * time.Millisecond)
+		case "garbled":
+			w.Write([]byte("{not json"))
+		default:
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(PlaceOrderResponse{Success: false, OutOfStockItems: []string{order.Items[0].Id}})
+		}
+	}))
+	defer server.Close()
+	client := Client{Transport: singleTransport{server.URL}, OrderTimeout: 20 * time.Millisecond}
+	order := PlaceOrderRequest{Items: []OrderItemRequest{{Id: "p1", Quantity: 1}}}
+
+	status, resp, err := client.PlaceOrder(context.Background(), order)
+	if err != nil || status != http.StatusConflict || len(resp.OutOfStockItems) != 1 || resp.OutOfStockItems[0] != "p1" {
+		t.Errorf("PlaceOrder = %d, %+v, %v, want 409 listing p1", status, resp, err)
+	}
+
+	var clientErr *Error
+	for _, address := range []string{"stall", "garbled"} {
+		order.DeliveryAddress = address
+		if _, _, err := client.PlaceOrder(context.Background(), order); !errors.As(err, &clientErr) || !errors.Is(err, ErrOrderOutcomeUnknown) {
+			t.Errorf("PlaceOrder (%s) = %v, want ErrOrderOutcomeUnknown", address, err)
+		}
+	}
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	client.Transport = singleTransport{closed.URL}
+	if _, _, err := client.PlaceOrder(context.Background(), order); !errors.As(err, &clientErr) || clientErr.Status != http.StatusBadGateway || errors.Is(err, ErrOrderOutcomeUnknown) {
+		t.Errorf("PlaceOrder to a closed server = %v, want a 502 Error for an unsent order", err)
+	}
+}
@@ -0,0 +1,107 @@
+package dotnetclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Price is a monetary amount that decodes from a JSON number or a numeric string such as "19.99",
+// since the Dotnet service has sent both. It always encodes as a JSON number.
+type Price float64
+
+func (p *Price) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("price %q is not a number", s)
+		}
+		*p = Price(v)
+		return nil
+	}
+	var v float64
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("price %s is not a number", data)
+	}
+	*p = Price(v)
+	return nil
+}
+
+type Product struct {
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	Price       Price  `json:"price"`
+	ImageUrl    string `json:"imageUrl"`
+	Description string `json:"description"`
+	Stock       int    `json:"stock"`              // New: Stock quantity
+	Category    string `json:"category,omitempty"` // Optional: products without one are "Uncategorized"
+}
+
+// OrderItemRequest from React app
+type OrderItemRequest struct {
+	Id       string `json:"id"`
+	Name     string `json:"name"`
+	Quantity int    `json:"quantity"`
+	Price    Price  `json:"price"`
+}
+
+// PlaceOrderRequest from React app to Go
+type PlaceOrderRequest struct {
+	Items           []OrderItemRequest `json:"items"`
+	TotalAmount     *Price             `json:"totalAmount,omitempty"` // Optional: omitted or 0 is computed from the items
+	DeliveryAddress string             `json:"deliveryAddress"`
+	OrderDate       string             `json:"orderDate"`
+	ReservationId   string             `json:"reservationId,omitempty"` // Optional: from POST /cart/reserve
+	CouponCode      string             `json:"couponCode,omitempty"`    // Optional: promo code from COUPONS_FILE
+	Nonce           string             `json:"nonce,omitempty"`         // Optional: unique per submission, repeats are rejected
+	Region          string             `json:"region,omitempty"`        // Optional: region code like US-CA selecting the tax rate
+	MaxTotal        *Price             `json:"maxTotal,omitempty"`      // Optional: spend cap, orders whose computed total exceeds it are rejected
+	Email           string             `json:"email,omitempty"`         // Contact email, forwarded lowercased; guest orders need this or Phone
+	Phone           string             `json:"phone,omitempty"`         // Contact phone; guest orders need this or Email
+}
+
+// PlaceOrderResponse from Dotnet to Go, and then Go to React
+type PlaceOrderResponse struct {
+	Success         bool            `json:"success"`
+	Message         string          `json:"message,omitempty"`
+	OrderId         string          `json:"orderId,omitempty"`
+	UpstreamOrderId string          `json:"upstreamOrderId,omitempty"` // Dotnet's own id when OrderId is a formatted confirmation number
+	OutOfStockItems []string        `json:"outOfStockItems,omitempty"` // New: List of items that caused failure
+	PriceMismatches []PriceMismatch `json:"priceMismatches,omitempty"` // Items whose submitted price differs from the catalog
+	UnknownItems    []string        `json:"unknownItems,omitempty"`    // Items not in the catalog, so their price can't be checked
+	Discount        float64         `json:"discount,omitempty"`        // Discount applied from the order's coupon code
+	Breakdown       *OrderBreakdown `json:"breakdown,omitempty"`       // Subtotal, discount, tax and total of a placed order
+}
+
+// PriceMismatch is an order item whose submitted price doesn't match the catalog
+type PriceMismatch struct {
+	Id           string  `json:"id"`
+	Price        float64 `json:"price"`        // As submitted
+	CatalogPrice float64 `json:"catalogPrice"` // Current price in the catalog
+}
+
+// OrderBreakdown itemizes an order's total for display. It is computed by the shopping cart API
+// rather than by the Dotnet service, which only sees the (possibly discounted) totalAmount.
+type OrderBreakdown struct {
+	Subtotal float64 `json:"subtotal"` // Sum of item prices times quantities
+	Discount float64 `json:"discount"` // Coupon discount off the subtotal
+	Tax      float64 `json:"tax"`      // Tax on the discounted subtotal
+	Total    float64 `json:"total"`
+}
+
+// ProductDelta is the Dotnet service's answer to "what changed since"
+type ProductDelta struct {
+	Changed []Product `json:"changed"`       // Products added or updated, in full
+	Removed []string  `json:"removed"`       // Ids of products no longer in the catalog
+	AsOf    time.Time `json:"asOf,omitzero"` // Optional: the Dotnet service's time the delta is complete up to
+}
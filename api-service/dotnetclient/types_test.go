@@ -0,0 +1,43 @@
+package dotnetclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// TestPrice_UnmarshalJSON tests decoding prices from numbers and numeric strings
+func TestPrice_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    Price
+		wantErr bool
+	}{
+		{`19.99`, 19.99, false},
+		{`"19.99"`, 19.99, false},
+		{`0`, 0, false},
+		{`"5"`, 5, false},
+		{`null`, 0, false},
+		{`"abc"`, 0, true},
+		{`""`, 0, true},
+		{`"NaN"`, 0, true},
+		{`true`, 0, true},
+	}
+	for _, tt := range tests {
+		var p Price
+		err := json.Unmarshal([]byte(tt.raw), &p)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Unmarshal(%s) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			continue
+		}
+		if p != tt.want {
+			t.Errorf("Unmarshal(%s) = %v, want %v", tt.raw, p, tt.want)
+		}
+	}
+
+	// Prices always encode back as numbers
+	out, _ := json.Marshal(OrderItemRequest{Id: "p1", Price: 19.99})
+	if !bytes.Contains(out, []byte(`"price":19.99`)) {
+		t.Errorf("Marshal() = %s, want a numeric price", out)
+	}
+}
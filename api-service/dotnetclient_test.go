@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// stubDotnet is a DotnetClient returning canned results
+type stubDotnet struct {
+	products []Product
+	err      error
+}
+
+func (s stubDotnet) GetProducts(ctx context.Context) ([]Product, error) { return s.products, s.err }
+
+func (s stubDotnet) GetProduct(ctx context.Context, id string) (Product, error) {
+	return Product{}, s.err
+}
+
+func (s stubDotnet) PlaceOrder(ctx context.Context, order PlaceOrderRequest) (int, PlaceOrderResponse, error) {
+	return 0, PlaceOrderResponse{}, s.err
+}
+
+// useDotnet swaps the client used by the handlers for the duration of the test
+func useDotnet(t *testing.T, client DotnetClient) {
+	t.Helper()
+	orig := dotnet
+	dotnet = client
+	productsCache.invalidate()
+	t.Cleanup(func() {
+		dotnet = orig
+		productsCache.invalidate()
+	})
+}
+
+// TestHTTPDotnetClient_GetProducts tests URL building, retries and the empty-catalog case
+func TestHTTPDotnetClient_GetProducts(t *testing.T) {
+	os.Setenv("DOTNET_API_PREFIX", "/api/v1")
+	defer os.Unsetenv("DOTNET_API_PREFIX")
+	origSleep := sleep
+	sleep = func(context.Context, time.Duration) error { return nil }
+	defer func() { sleep = origSleep }()
+
+	calls := 0
+	body := `[{"id":"p1","name":"Widget","price":"4.50","stock":3}]`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path != "/api/v1/all-products" {
+			t.Errorf("requested %s, want /api/v1/all-products", r.URL.Path)
+		}
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+	client := HTTPDotnetClient{BaseURL: server.URL}
+
+	products, err := client.GetProducts(context.Background())
+	if err != nil || len(products) != 1 || products[0].Price != 4.5 || calls != 2 {
+		t.Errorf("GetProducts = %+v, %v after %d calls, want one product after a retry", products, err, calls)
+	}
+
+	body = "null"
+	if products, err := client.GetProducts(context.Background()); err != nil || products == nil || len(products) != 0 {
+		t.Errorf("GetProducts of a null catalog = %#v, %v, want empty non-nil", products, err)
+	}
+
+	if p, err := client.GetProduct(context.Background(), "p1"); err == nil {
+		t.Errorf("GetProduct of a null catalog = %+v, want not found", p)
+	}
+	body = `[{"id":"p1","name":"Widget"},{"id":"p2","name":"Gadget"}]`
+	if p, err := client.GetProduct(context.Background(), "p2"); err != nil || p.Name != "Gadget" {
+		t.Errorf("GetProduct(p2) = %+v, %v, want Gadget", p, err)
+	}
+	var upErr *upstreamError
+	if _, err := client.GetProduct(context.Background(), "missing"); !errors.As(err, &upErr) || upErr.Status != http.StatusNotFound {
+		t.Errorf("GetProduct(missing) error = %v, want a 404 upstreamError", err)
+	}
+}
+
+// TestHTTPDotnetClient_ErrorMapping tests the statuses reported for upstream failures
+func TestHTTPDotnetClient_ErrorMapping(t *testing.T) {
+	os.Setenv("UPSTREAM_MAX_ATTEMPTS", "1")
+	defer os.Unsetenv("UPSTREAM_MAX_ATTEMPTS")
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusInternalServerError) })
+	server := httptest.NewServer(handler)
+	client := HTTPDotnetClient{BaseURL: server.URL}
+
+	var upErr *upstreamError
+	if _, err := client.GetProducts(context.Background()); !errors.As(err, &upErr) || upErr.Status != http.StatusBadGateway {
+		t.Errorf("GetProducts on a 500 = %v, want a 502 upstreamError", err)
+	}
+	if _, _, err := client.PlaceOrder(context.Background(), PlaceOrderRequest{}); !errors.As(err, &upErr) || !errors.Is(err, errOrderOutcomeUnknown) {
+		t.Errorf("PlaceOrder with an unreadable response = %v, want errOrderOutcomeUnknown", err)
+	}
+
+	// A reused connection failing could have carried the order, so the unsent case uses a server
+	// never connected to
+	server.Close()
+	closed := httptest.NewServer(handler)
+	closed.Close()
+	client = HTTPDotnetClient{BaseURL: closed.URL}
+	if _, _, err := client.PlaceOrder(context.Background(), PlaceOrderRequest{}); !errors.As(err, &upErr) || upErr.Status != http.StatusBadGateway || errors.Is(err, errOrderOutcomeUnknown) {
+		t.Errorf("PlaceOrder to a closed server = %v, want a 502 upstreamError for an unsent order", err)
+	}
+}
+
+// TestHTTPDotnetClient_PlaceOrder tests that Dotnet's status and response are passed through
+func TestHTTPDotnetClient_PlaceOrder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var order PlaceOrderRequest
+		json.NewDecoder(r.Body).Decode(&order)
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(PlaceOrderResponse{Success: false, OutOfStockItems: []string{order.Items[0].Id}})
+	}))
+	defer server.Close()
+
+	status, resp, err := HTTPDotnetClient{BaseURL: server.URL}.PlaceOrder(context.Background(), PlaceOrderRequest{Items: []OrderItemRequest{{Id: "p1", Quantity: 1}}})
+	if err != nil || status != http.StatusConflict || len(resp.OutOfStockItems) != 1 || resp.OutOfStockItems[0] != "p1" {
+		t.Errorf("PlaceOrder = %d, %+v, %v, want 409 listing p1", status, resp, err)
+	}
+}
+
+// TestProductsHandler_StubDotnet tests that handlers go through the swappable client
+func TestProductsHandler_StubDotnet(t *testing.T) {
+	useDotnet(t, stubDotnet{err: &upstreamError{Status: http.StatusBadGateway, Message: "Failed to fetch products from backend service"}})
+
+	rr := httptest.NewRecorder()
+	productsHandler(rr, httptest.NewRequest(http.MethodGet, "/products", nil))
+	if rr.Code != http.StatusBadGateway {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadGateway)
+	}
+
+	useDotnet(t, stubDotnet{products: []Product{{Id: "p1", Name: "Widget", Stock: 1}}})
+	if status, products := getProducts(t, ""); status != http.StatusOK || len(products) != 1 {
+		t.Errorf("handler returned %d with %d products, want 200 with 1", status, len(products))
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// Length limits of an address, in bytes, from RFC 5321
+const (
+	maxEmailLength      = 254
+	maxEmailLocalLength = 64
+)
+
+// emailAtext are the ASCII characters besides letters and digits allowed in an unquoted local part (RFC 5322 atext)
+const emailAtext = "!#$%&'*+-/=?^_`{|}~"
+
+// validateEmail checks that email is a deliverable-looking address: a dot-atom or quoted local part
+// as in RFC 5322, which may hold UTF-8 as RFC 6531 allows, and a domain name of at least two labels,
+// which may be internationalized. Comments, IP literals and folding whitespace, which real customer
+// addresses never use, are refused. The error reads after the field name, e.g. "email must ...".
+func validateEmail(email string) error {
+	if !utf8.ValidString(email) {
+		return errors.New("must be valid UTF-8")
+	}
+	if len(email) > maxEmailLength {
+		return errors.New("must be at most 254 bytes")
+	}
+	at := strings.LastIndexByte(email, '@')
+	if at < 0 {
+		return errors.New("must contain an @")
+	}
+	local, domain := email[:at], email[at+1:]
+	if err := validateEmailLocal(local); err != nil {
+		return err
+	}
+	return validateEmailDomain(domain)
+}
+
+// validateEmailLocal checks the part of an address before the @
+func validateEmailLocal(local string) error {
+	if local == "" {
+		return errors.New("must have a name before the @")
+	}
+	if len(local) > maxEmailLocalLength {
+		return errors.New("must have at most 64 bytes before the @")
+	}
+	if strings.HasPrefix(local, `"`) {
+		return validateEmailQuoted(local)
+	}
+	for _, atom := range strings.Split(local, ".") {
+		if atom == "" {
+			return errors.New("must not start or end with a dot or have two in a row before the @")
+		}
+		for _, r := range atom {
+			if !emailAtom(r) {
+				return errors.New("contains a character not allowed in an email address")
+			}
+		}
+	}
+	return nil
+}
+
+// emailAtom reports whether r may appear unquoted in a local part: atext, or any non-ASCII
+// character other than spaces and controls
+func emailAtom(r rune) bool {
+	if r < utf8.RuneSelf {
+		return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || strings.ContainsRune(emailAtext, r)
+	}
+	return !unicode.IsSpace(r) && !unicode.IsControl(r)
+}
+
+// validateEmailQuoted checks a quoted local part such as "john doe", where any printable character
+// may appear and a quote or backslash is escaped with a backslash
+func validateEmailQuoted(local string) error {
+	if len(local) < 2 || !strings.HasSuffix(local, `"`) {
+		return errors.New("has an unterminated quote before the @")
+	}
+	escaped := false
+	for _, r := range local[1 : len(local)-1] {
+		switch {
+		case unicode.IsControl(r):
+			return errors.New("contains a control character")
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			return errors.New("has an unescaped quote before the @")
+		}
+	}
+	if escaped {
+		return errors.New("has an unterminated quote before the @")
+	}
+	return nil
+}
+
+// validateEmailDomain checks the part of an address after the @. Internationalized names are checked
+// in their ASCII (punycode) form, which is also what limits their length.
+func validateEmailDomain(domain string) error {
+	if domain == "" {
+		return errors.New("must have a domain after the @")
+	}
+	if strings.HasPrefix(domain, "[") {
+		return errors.New("must have a domain name, not an IP address, after the @")
+	}
+	ascii, err := idna.Lookup.ToASCII(domain)
+	if err != nil || len(ascii) > 253 {
+		return errors.New("must have a valid domain name after the @")
+	}
+	labels := strings.Split(ascii, ".")
+	if len(labels) < 2 {
+		return errors.New("must have a domain like example.com after the @")
+	}
+	for _, label := range labels {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return errors.New("must have a valid domain name after the @")
+		}
+	}
+	// A top-level domain is never all digits, which also refuses bare IPv4 addresses
+	if strings.Trim(labels[len(labels)-1], "0123456789") == "" {
+		return errors.New("must have a domain name, not an IP address, after the @")
+	}
+	return nil
+}
+
+// normalizeEmail trims and lowercases an address
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestValidateEmail tests ordinary, unusual-but-valid, internationalized and malformed addresses
+func TestValidateEmail(t *testing.T) {
+	valid := []string{
+		"jane@example.com",
+		"Jane.Doe@Example.COM",
+		"jane+orders@mail.example.co.uk",
+		"o'brien@example.ie",
+		"x@example.io",
+		"1234@123.example",
+		"a!#$%&*/=?^_`{|}~-@example.com",
+		`"john doe"@example.com`,
+		`"quoted\"inside"@example.com`,
+		"josé@example.com",           // UTF-8 local part (RFC 6531)
+		"用户@例子.广告",                   // Internationalized local part and domain
+		"user@bücher.example",        // IDN domain
+		"user@xn--bcher-kva.example", // The same domain in punycode
+		"δοκιμή@παράδειγμα.δοκιμή",
+		strings.Repeat("a", 64) + "@example.com",
+	}
+	for _, email := range valid {
+		if err := validateEmail(email); err != nil {
+			t.Errorf("validateEmail(%q) = %v, want valid", email, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"jane",
+		"jane@",
+		"@example.com",
+		"jane@localhost",
+		"jane@example..com",
+		"jane@example.com.",
+		"jane@-example.com",
+		"jane@example-.com",
+		"jane@exa_mple.com",
+		"jane@[192.0.2.1]",
+		"jane@192.0.2.1",
+		".jane@example.com",
+		"jane.@example.com",
+		"ja..ne@example.com",
+		"jane doe@example.com",
+		"jane@doe@example.com",
+		"jane(comment)@example.com",
+		`"unterminated@example.com`,
+		`"bad"quote"@example.com`,
+		`"trailing\"@example.com`,
+		"jane\x00@example.com",
+		"jane\u00a0doe@example.com", // No-break space
+		"jane@exam ple.com",
+		"\xffjane@example.com",
+		strings.Repeat("a", 65) + "@example.com",
+		"jane@" + strings.Repeat("a", 64) + ".com",
+		"jane@" + strings.Repeat(strings.Repeat("a", 60)+".", 5) + "com",
+	}
+	for _, email := range invalid {
+		if err := validateEmail(email); err == nil {
+			t.Errorf("validateEmail(%q) = nil, want an error", email)
+		}
+	}
+}
+
+// TestOrderHandler_Email tests that an order's email is forwarded trimmed and lowercased, and a malformed one is a field error
+func TestOrderHandler_Email(t *testing.T) {
+	dotnet := newFakeDotnet(t, []Product{{Id: "prod1", Name: "Headphones", Price: 99.99, Stock: 10}})
+
+	rr := httptest.NewRecorder()
+	orderHandler(rr, postJSON(t, "/order", guestOrder("  Jane.Doe@Example.COM ")))
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if got := dotnet.lastOrder(t).Email; got != "jane.doe@example.com" {
+		t.Errorf("upstream got email %q, want it lowercased", got)
+	}
+
+	rr = httptest.NewRecorder()
+	orderHandler(rr, postJSON(t, "/order", guestOrder("jane@example")))
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+	var resp ValidationErrorResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	if len(resp.Fields) != 1 || resp.Fields[0].Field != "email" {
+		t.Errorf("fields = %+v, want one email error", resp.Fields)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// envDuration reads a time.Duration (e.g. "30s") from the environment, falling back to def when unset or invalid
+func envDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("Invalid %s value %q: %v. Using default '%s'.", key, v, err, def)
+		return def
+	}
+	return d
+}
+
+// envSeconds is envDuration also taking a bare integer as seconds, like the max-age settings
+func envSeconds(key string, def time.Duration) time.Duration {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return time.Duration(n) * time.Second
+	}
+	return envDuration(key, def)
+}
+
+// envInt reads an integer from the environment, falling back to def when unset or invalid
+func envInt(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("Invalid %s value %q: %v. Using default '%d'.", key, v, err, def)
+		return def
+	}
+	return n
+}
+
+// envFloat reads a float from the environment, falling back to def when unset or invalid
+func envFloat(key string, def float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Printf("Invalid %s value %q: %v. Using default '%g'.", key, v, err, def)
+		return def
+	}
+	return f
+}
+
+// envBool reads a boolean (e.g. "true", "0") from the environment, falling back to def when unset or invalid
+func envBool(key string, def bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Printf("Invalid %s value %q: %v. Using default '%t'.", key, v, err, def)
+		return def
+	}
+	return b
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// exportHandler serves the catalog as a downloadable file, CSV (default) or JSON via ?format=
+func exportHandler(w http.ResponseWriter, r *http.Request) {
+	// Set CORS headers for any origin
+	applyCORS(w, r, "GET, OPTIONS", "Content-Type, Authorization, X-API-Key, X-Tenant-ID")
+
+	// Handle preflight OPTIONS request
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet, http.MethodOptions)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		writeError(w, r, http.StatusBadRequest, "format must be csv or json")
+		return
+	}
+
+	entry, err := tenantProductCache(r.Context()).get(r.Context(), envDuration("PRODUCTS_CACHE_TTL", defaultProductsCacheTTL))
+	if err != nil {
+		log.Printf("An error occured loading products for export: %v", err)
+		writeUpstreamError(w, r, err)
+		return
+	}
+
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="products.json"`)
+		if _, err := w.Write(entry.Body); err != nil {
+			log.Printf("Error writing products export: %v", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="products.csv"`)
+	if err := writeProductsCSV(w, entry.Products); err != nil {
+		log.Printf("Error writing products export: %v", err)
+	}
+}
+
+// writeProductsCSV streams products as CSV rows, flushing each row to the client as it is written
+func writeProductsCSV(w http.ResponseWriter, products []Product) error {
+	cw := csv.NewWriter(w)
+	rc := http.NewResponseController(w) // Reaches through middleware wrappers to flush
+
+	if err := cw.Write([]string{"id", "name", "price", "stock", "description"}); err != nil {
+		return err
+	}
+	for _, p := range products {
+		row := []string{
+			p.Id,
+			p.Name,
+			strconv.FormatFloat(float64(p.Price), 'f', 2, 64),
+			strconv.Itoa(p.Stock),
+			p.Description,
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+		if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
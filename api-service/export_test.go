@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestExportHandler_CSV tests the default CSV export, including quoting of awkward fields
+func TestExportHandler_CSV(t *testing.T) {
+	newFakeDotnet(t, []Product{
+		{Id: "prod1", Name: "Headphones", Price: 99.99, Stock: 10, Description: "Noise cancelling"},
+		{Id: "prod2", Name: `USB-C Hub, "Pro"`, Price: 29.5, Stock: 0, Description: "Line one\nline two"},
+	})
+
+	rr := httptest.NewRecorder()
+	exportHandler(rr, httptest.NewRequest(http.MethodGet, "/products/export", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if got := rr.Header().Get("Content-Disposition"); !strings.HasPrefix(got, "attachment") {
+		t.Errorf("Content-Disposition = %q, want an attachment", got)
+	}
+	if !rr.Flushed {
+		t.Error("expected rows to be flushed as they are written")
+	}
+
+	rows, err := csv.NewReader(rr.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("export is not valid CSV: %v", err)
+	}
+	want := [][]string{
+		{"id", "name", "price", "stock", "description"},
+		{"prod1", "Headphones", "99.99", "10", "Noise cancelling"},
+		{"prod2", `USB-C Hub, "Pro"`, "29.50", "0", "Line one\nline two"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("got rows %q, want %q", rows, want)
+	}
+}
+
+// TestExportHandler_Formats tests the JSON pass-through and rejection of unknown formats
+func TestExportHandler_Formats(t *testing.T) {
+	newFakeDotnet(t, []Product{{Id: "prod1", Name: "Headphones", Price: 99.99, Stock: 10}})
+
+	rr := httptest.NewRecorder()
+	exportHandler(rr, httptest.NewRequest(http.MethodGet, "/products/export?format=json", nil))
+	if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Header().Get("Content-Type"), "application/json") {
+		t.Errorf("json export: status %v, content type %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rr.Body.String(), `"id":"prod1"`) {
+		t.Errorf("json export body = %q", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	exportHandler(rr, httptest.NewRequest(http.MethodGet, "/products/export?format=xlsx", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("unknown format: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
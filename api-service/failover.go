@@ -0,0 +1,206 @@
+package main
+
+// Failover between Dotnet instances. DOTNET_PRODUCTS_API_URLS lists several instances of the Dotnet
+// service, comma separated, in order of preference. Each call goes to the first instance not known
+// to be down and fails over to the next on a connection error or 5xx. A failed instance is then
+// skipped for UPSTREAM_FAILOVER_COOLDOWN, or until it answers again when every instance is down.
+// Orders are only failed over when the connection could not be made, since an order that reached an
+// instance may have been placed even if the answer was a 5xx.
+//
+// Instances may be given weights as url|weight, e.g. "http://big:8080|3,http://small:8080|1". Calls
+// are then spread over the healthy instances by smooth weighted round-robin, so the first gets three
+// calls for every one the second gets, interleaved; instances without a weight count as 1. The
+// remaining instances still follow in configured order for failover.
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/salus-templates/shopping-cart-backend/api-service/dotnetclient"
+)
+
+// defaultFailoverCooldown is how long a failed instance is skipped when UPSTREAM_FAILOVER_COOLDOWN is not set
+const defaultFailoverCooldown = 30 * time.Second
+
+// upstreamInstance is one Dotnet instance from DOTNET_PRODUCTS_API_URLS or TENANT_UPSTREAMS_FILE
+type upstreamInstance struct {
+	base   string
+	weight int // Share of calls under weighted round-robin; 0 when no instance has a weight
+}
+
+// upstreamInstances returns the Dotnet instances to call, in order of preference: those in
+// DOTNET_PRODUCTS_API_URLS, else DOTNET_PRODUCTS_API_URL, else the development default. Weights are
+// only set when at least one instance was given one, the others then getting 1.
+func upstreamInstances() []upstreamInstance {
+	if instances := parseUpstreamInstances(os.Getenv("DOTNET_PRODUCTS_API_URLS")); len(instances) > 0 {
+		return instances
+	}
+	base := os.Getenv("DOTNET_PRODUCTS_API_URL")
+	if base == "" {
+		log.Println("DOTNET_PRODUCTS_API_URL environment variable is not set. Using default 'http://localhost:8080'.")
+		base = "http://localhost:8080" // Default for development
+	}
+	return []upstreamInstance{{base: base}}
+}
+
+// parseUpstreamInstances parses a comma separated list of url or url|weight entries, as in
+// DOTNET_PRODUCTS_API_URLS, returning nil when it lists none
+func parseUpstreamInstances(value string) []upstreamInstance {
+	var instances []upstreamInstance
+	weighted := false
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		base, weight, hasWeight := strings.Cut(entry, "|")
+		instance := upstreamInstance{base: strings.TrimSpace(base), weight: 1}
+		if hasWeight {
+			weighted = true
+			if n, err := strconv.Atoi(strings.TrimSpace(weight)); err == nil && n > 0 {
+				instance.weight = n
+			} else {
+				log.Printf("Invalid weight %q for upstream %s: must be a positive integer. Using default '1'.", weight, redactURL(instance.base))
+			}
+		}
+		instances = append(instances, instance)
+	}
+	if !weighted {
+		for i := range instances {
+			instances[i].weight = 0
+		}
+	}
+	return instances
+}
+
+// upstreamBases returns the base URLs of upstreamInstances, in order of preference
+func upstreamBases() []string {
+	instances := upstreamInstances()
+	bases := make([]string, len(instances))
+	for i, instance := range instances {
+		bases[i] = instance.base
+	}
+	return bases
+}
+
+// endpointHealth remembers which upstream instances recently failed, and balances calls over
+// weighted ones; safe for concurrent use
+type endpointHealth struct {
+	mu        sync.Mutex
+	downUntil map[string]time.Time
+	current   map[string]int // Smooth weighted round-robin state per base
+	now       func() time.Time
+}
+
+// upstreamEndpoints tracks the Dotnet instances for every HTTPDotnetClient
+var upstreamEndpoints = newEndpointHealth()
+
+func newEndpointHealth() *endpointHealth {
+	return &endpointHealth{downUntil: make(map[string]time.Time), current: make(map[string]int), now: time.Now}
+}
+
+// order returns the bases of instances with the ones not known to be down first, in their configured
+// order but led by the weighted round-robin pick when weighted, followed by the down ones, soonest to
+// recover first, so a call still has somewhere to go when all are down
+func (h *endpointHealth) order(instances []upstreamInstance) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := h.now()
+	ordered := make([]string, 0, len(instances))
+	var healthy []upstreamInstance
+	var down []string
+	for _, instance := range instances {
+		if until, ok := h.downUntil[instance.base]; ok && now.Before(until) {
+			down = append(down, instance.base)
+		} else {
+			healthy = append(healthy, instance)
+			ordered = append(ordered, instance.base)
+		}
+	}
+	if len(healthy) > 1 && healthy[0].weight > 0 {
+		pick := h.pickLocked(healthy)
+		ordered = append(ordered[:pick], ordered[pick+1:]...)
+		ordered = append([]string{healthy[pick].base}, ordered...)
+	}
+	for len(down) > 0 {
+		soonest := 0
+		for i, base := range down {
+			if h.downUntil[base].Before(h.downUntil[down[soonest]]) {
+				soonest = i
+			}
+		}
+		ordered = append(ordered, down[soonest])
+		down = append(down[:soonest], down[soonest+1:]...)
+	}
+	return ordered
+}
+
+// pickLocked chooses among instances by smooth weighted round-robin, returning the chosen index:
+// every instance gains its weight, the one with the most is chosen and gives back the total, which
+// spreads an instance's calls evenly rather than in bursts; h.mu must be held
+func (h *endpointHealth) pickLocked(instances []upstreamInstance) int {
+	total, best := 0, 0
+	for i, instance := range instances {
+		h.current[instance.base] += instance.weight
+		total += instance.weight
+		if h.current[instance.base] > h.current[instances[best].base] {
+			best = i
+		}
+	}
+	h.current[instances[best].base] -= total
+	return best
+}
+
+// markDown skips base for UPSTREAM_FAILOVER_COOLDOWN
+func (h *endpointHealth) markDown(base string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.downUntil[base] = h.now().Add(envDuration("UPSTREAM_FAILOVER_COOLDOWN", defaultFailoverCooldown))
+}
+
+// markUp forgets any failure of base after it answered
+func (h *endpointHealth) markUp(base string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.downUntil, base)
+}
+
+// withFailover calls call with the base of each of instances, healthiest first, until one neither fails with an
+// error nor answers 5xx, and returns that result or the last instance's. With connectOnly only
+// errors connecting fail over, for calls that must not reach two instances. A caller canceling ctx
+// ends the call without marking the instance down.
+func withFailover(ctx context.Context, instances []upstreamInstance, connectOnly bool, call func(base string) (*http.Response, error)) (*http.Response, error) {
+	ordered := upstreamEndpoints.order(instances)
+	for i, base := range ordered {
+		resp, err := call(base)
+		if ctx.Err() != nil {
+			return resp, err
+		}
+		failed := err != nil || resp.StatusCode >= 500
+		if !failed {
+			upstreamEndpoints.markUp(base)
+			return resp, nil
+		}
+		upstreamEndpoints.markDown(base)
+		last := i == len(ordered)-1
+		if last || (connectOnly && !dotnetclient.IsConnectError(err)) {
+			return resp, err
+		}
+		if err != nil {
+			log.Printf("Upstream %s failed, failing over to %s: %v", redactURL(base), redactURL(ordered[i+1]), err)
+		} else {
+			log.Printf("Upstream %s returned status %d, failing over to %s", redactURL(base), resp.StatusCode, redactURL(ordered[i+1]))
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+	}
+	return nil, errors.New("no upstream configured") // upstreamInstances always returns at least one
+}
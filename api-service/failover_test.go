@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// useFailover points DOTNET_PRODUCTS_API_URLS at bases, with fresh instance health whose clock the test controls
+func useFailover(t *testing.T, bases string) *time.Time {
+	t.Helper()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	orig := upstreamEndpoints
+	upstreamEndpoints = newEndpointHealth()
+	upstreamEndpoints.now = func() time.Time { return now }
+	os.Setenv("DOTNET_PRODUCTS_API_URLS", bases)
+	os.Setenv("UPSTREAM_MAX_ATTEMPTS", "1")
+	t.Cleanup(func() {
+		upstreamEndpoints = orig
+		os.Unsetenv("DOTNET_PRODUCTS_API_URLS")
+		os.Unsetenv("UPSTREAM_MAX_ATTEMPTS")
+	})
+	return &now
+}
+
+// TestUpstreamBases tests the list form, its precedence over the single URL and the default
+func TestUpstreamBases(t *testing.T) {
+	defer os.Unsetenv("DOTNET_PRODUCTS_API_URLS")
+	defer os.Unsetenv("DOTNET_PRODUCTS_API_URL")
+	tests := []struct {
+		urls, url string
+		want      []string
+	}{
+		{"", "", []string{"http://localhost:8080"}},
+		{"", "http://dotnet:8080", []string{"http://dotnet:8080"}},
+		{"http://a:8080, http://b:8080,", "http://dotnet:8080", []string{"http://a:8080", "http://b:8080"}},
+	}
+	for _, tt := range tests {
+		os.Setenv("DOTNET_PRODUCTS_API_URLS", tt.urls)
+		os.Setenv("DOTNET_PRODUCTS_API_URL", tt.url)
+		if got := upstreamBases(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("upstreamBases() with URLS %q and URL %q = %v, want %v", tt.urls, tt.url, got, tt.want)
+		}
+	}
+}
+
+// TestHTTPDotnetClient_Failover tests that the catalog is fetched from the healthy instance and the failing one is skipped until its cooldown ends
+func TestHTTPDotnetClient_Failover(t *testing.T) {
+	failing, failingHits := newFailingUpstream(t)
+	healthy := newFakeDotnet(t, []Product{{Id: "p1"}})
+	now := useFailover(t, failing.URL+","+healthy.Server.URL)
+	get := func() {
+		t.Helper()
+		products, err := HTTPDotnetClient{}.GetProducts(context.Background())
+		if err != nil || len(products) != 1 {
+			t.Fatalf("GetProducts = %v, %v, want the healthy instance's catalog", products, err)
+		}
+	}
+
+	get()
+	get()
+	if n := atomic.LoadInt32(failingHits); n != 1 {
+		t.Errorf("failing instance got %d requests, want 1 before it is skipped", n)
+	}
+	*now = now.Add(defaultFailoverCooldown)
+	get()
+	if n := atomic.LoadInt32(failingHits); n != 2 {
+		t.Errorf("failing instance got %d requests, want it tried again after the cooldown", n)
+	}
+}
+
+// TestHTTPDotnetClient_FailoverOrders tests that orders fail over when an instance can't be reached but not after a 5xx
+func TestHTTPDotnetClient_FailoverOrders(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	failing, failingHits := newFailingUpstream(t)
+	healthy := newFakeDotnet(t, nil)
+	order := PlaceOrderRequest{Items: []OrderItemRequest{{Id: "p1", Quantity: 1, Price: 5}}, DeliveryAddress: "1 Main St"}
+
+	useFailover(t, down.URL+","+healthy.Server.URL)
+	if status, resp, err := (HTTPDotnetClient{}).PlaceOrder(context.Background(), order); err != nil || status != http.StatusOK || !resp.Success {
+		t.Fatalf("PlaceOrder with the first instance down = %v, %+v, %v, want it placed on the second", status, resp, err)
+	}
+
+	useFailover(t, failing.URL+","+healthy.Server.URL)
+	(HTTPDotnetClient{}).PlaceOrder(context.Background(), order)
+	if atomic.LoadInt32(failingHits) != 1 || len(healthy.Orders) != 1 {
+		t.Errorf("order after a 503 reached %d failing and %d healthy instances, want it not resent", atomic.LoadInt32(failingHits), len(healthy.Orders))
+	}
+}
+
+// TestEndpointHealth_AllDown tests that with every instance down they are still tried, soonest to recover first
+func TestEndpointHealth_AllDown(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	h := newEndpointHealth()
+	h.now = func() time.Time { return now }
+	h.markDown("a")
+	now = now.Add(time.Second)
+	h.markDown("b")
+	h.markDown("c")
+	h.markUp("c")
+
+	if got, want := h.order([]upstreamInstance{{base: "b"}, {base: "a"}, {base: "c"}}), []string{"c", "a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("order() = %v, want %v", got, want)
+	}
+}
+
+// TestUpstreamInstances tests parsing url|weight, with weights only set when some instance has one
+func TestUpstreamInstances(t *testing.T) {
+	defer os.Unsetenv("DOTNET_PRODUCTS_API_URLS")
+	tests := []struct {
+		urls string
+		want []upstreamInstance
+	}{
+		{"http://a:8080,http://b:8080", []upstreamInstance{{base: "http://a:8080"}, {base: "http://b:8080"}}},
+		{"http://a:8080|3, http://b:8080", []upstreamInstance{{base: "http://a:8080", weight: 3}, {base: "http://b:8080", weight: 1}}},
+		{"http://a:8080|0,http://b:8080|x,http://c:8080|2", []upstreamInstance{{base: "http://a:8080", weight: 1}, {base: "http://b:8080", weight: 1}, {base: "http://c:8080", weight: 2}}},
+	}
+	for _, tt := range tests {
+		os.Setenv("DOTNET_PRODUCTS_API_URLS", tt.urls)
+		if got := upstreamInstances(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("upstreamInstances() with %q = %v, want %v", tt.urls, got, tt.want)
+		}
+	}
+}
+
+// TestEndpointHealth_WeightedRoundRobin tests that concurrent picks are spread in proportion to the weights, skipping down instances
+func TestEndpointHealth_WeightedRoundRobin(t *testing.T) {
+	h := newEndpointHealth()
+	instances := []upstreamInstance{{base: "a", weight: 5}, {base: "b", weight: 3}, {base: "c", weight: 2}}
+	const picks = 10000
+	var mu sync.Mutex
+	counts := make(map[string]int)
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < picks/8; i++ {
+				first := h.order(instances)[0]
+				mu.Lock()
+				counts[first]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	for _, instance := range instances {
+		want := picks * instance.weight / 10
+		if got := counts[instance.base]; got < want*95/100 || got > want*105/100 {
+			t.Errorf("instance %s got %d of %d calls, want about %d", instance.base, got, picks, want)
+		}
+	}
+
+	h.markDown("a")
+	for i := 0; i < 10; i++ {
+		if got := h.order(instances); got[0] == "a" || got[2] != "a" {
+			t.Fatalf("order() with a down = %v, want a last", got)
+		}
+	}
+}
+
+// TestHTTPDotnetClient_Weighted tests that weighted instances share the catalog fetches
+func TestHTTPDotnetClient_Weighted(t *testing.T) {
+	var bigHits, smallHits atomic.Int32
+	serve := func(hits *atomic.Int32) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits.Add(1)
+			w.Write([]byte(`[]`))
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+	big, small := serve(&bigHits), serve(&smallHits)
+	useFailover(t, big.URL+"|3,"+small.URL+"|1")
+
+	for i := 0; i < 8; i++ {
+		if _, err := (HTTPDotnetClient{}).GetProducts(context.Background()); err != nil {
+			t.Fatalf("GetProducts: %v", err)
+		}
+	}
+	if bigHits.Load() != 6 || smallHits.Load() != 2 {
+		t.Errorf("instances got %d and %d fetches, want 6 and 2", bigHits.Load(), smallHits.Load())
+	}
+}
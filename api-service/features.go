@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// featureFlags holds the flags loaded from FEATURES_FILE; the file is read at startup and on
+// POST /admin/features/reload rather than on every request
+type featureFlags struct {
+	mu    sync.RWMutex
+	flags map[string]bool
+}
+
+// features is the process-wide flag set served by featuresHandler
+var features = &featureFlags{flags: map[string]bool{}}
+
+// loadFeatures reads a JSON object of boolean flags such as {"coupons":true,"guestCheckout":false}
+func loadFeatures(path string) (map[string]bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var flags map[string]bool
+	if err := json.Unmarshal(data, &flags); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if flags == nil {
+		flags = map[string]bool{}
+	}
+	return flags, nil
+}
+
+// reload rereads FEATURES_FILE, keeping the current flags if it is missing or malformed.
+// Without FEATURES_FILE only the FEATURES overrides apply.
+func (f *featureFlags) reload() error {
+	flags := map[string]bool{}
+	if path := os.Getenv("FEATURES_FILE"); path != "" {
+		var err error
+		if flags, err = loadFeatures(path); err != nil {
+			return err
+		}
+	}
+	f.mu.Lock()
+	f.flags = flags
+	f.mu.Unlock()
+	return nil
+}
+
+// snapshot returns the file flags with the FEATURES overrides applied, e.g. FEATURES="guestCheckout=true,coupons=false"
+func (f *featureFlags) snapshot() map[string]bool {
+	f.mu.RLock()
+	flags := make(map[string]bool, len(f.flags))
+	for name, on := range f.flags {
+		flags[name] = on
+	}
+	f.mu.RUnlock()
+
+	for _, override := range strings.Split(os.Getenv("FEATURES"), ",") {
+		name, value, found := strings.Cut(strings.TrimSpace(override), "=")
+		if name == "" {
+			continue
+		}
+		on, err := strconv.ParseBool(strings.TrimSpace(value))
+		if !found || err != nil {
+			log.Printf("Ignoring invalid FEATURES override %q", override)
+			continue
+		}
+		flags[strings.TrimSpace(name)] = on
+	}
+	return flags
+}
+
+// featuresHandler returns the feature flags the frontend uses to toggle UI features
+func featuresHandler(w http.ResponseWriter, r *http.Request) {
+	// Set CORS headers for any origin
+	applyCORS(w, r, "GET, OPTIONS", "Content-Type")
+
+	// Handle preflight OPTIONS request
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet, http.MethodOptions)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, features.snapshot())
+}
+
+// featuresReloadHandler rereads FEATURES_FILE and returns the resulting flags
+func featuresReloadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	if err := features.reload(); err != nil {
+		log.Printf("Error reloading feature flags, keeping the current ones: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "Failed to reload feature flags")
+		return
+	}
+	log.Println("Feature flags reloaded")
+	writeJSON(w, r, http.StatusOK, features.snapshot())
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// useFeaturesFile points FEATURES_FILE at a temp file with contents and resets the loaded flags afterwards
+func useFeaturesFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "features.json")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("Could not write features file: %v", err)
+	}
+	os.Setenv("FEATURES_FILE", path)
+	t.Cleanup(func() {
+		os.Unsetenv("FEATURES_FILE")
+		features.reload()
+	})
+	return path
+}
+
+// getFeatures calls featuresHandler and decodes the flags
+func getFeatures(t *testing.T) map[string]bool {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	featuresHandler(rr, httptest.NewRequest(http.MethodGet, "/features", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var flags map[string]bool
+	if err := json.Unmarshal(rr.Body.Bytes(), &flags); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	return flags
+}
+
+// TestFeaturesHandler tests serving flags from FEATURES_FILE with FEATURES overrides
+func TestFeaturesHandler(t *testing.T) {
+	useFeaturesFile(t, `{"coupons":true,"guestCheckout":false}`)
+	if err := features.reload(); err != nil {
+		t.Fatalf("reload returned error: %v", err)
+	}
+
+	if got, want := getFeatures(t), map[string]bool{"coupons": true, "guestCheckout": false}; !reflect.DeepEqual(got, want) {
+		t.Errorf("features = %v, want %v", got, want)
+	}
+
+	os.Setenv("FEATURES", "guestCheckout=true, wishlist=1, broken")
+	defer os.Unsetenv("FEATURES")
+	if got, want := getFeatures(t), map[string]bool{"coupons": true, "guestCheckout": true, "wishlist": true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("features with overrides = %v, want %v", got, want)
+	}
+}
+
+// TestLoadFeatures_Invalid tests that malformed files and non-boolean flags are rejected
+func TestLoadFeatures_Invalid(t *testing.T) {
+	for _, contents := range []string{`{"coupons":`, `{"coupons":"yes"}`, `[true]`} {
+		path := useFeaturesFile(t, contents)
+		if _, err := loadFeatures(path); err == nil {
+			t.Errorf("loadFeatures(%s) returned no error", contents)
+		}
+	}
+}
+
+// TestFeaturesReloadHandler tests that reload picks up file changes and keeps the old flags on a bad file
+func TestFeaturesReloadHandler(t *testing.T) {
+	withAdminToken(t, "secret")
+	path := useFeaturesFile(t, `{"coupons":false}`)
+	features.reload()
+
+	// The file is cached until reloaded
+	os.WriteFile(path, []byte(`{"coupons":true}`), 0o600)
+	if got := getFeatures(t); got["coupons"] {
+		t.Errorf("flags changed before reload: %v", got)
+	}
+
+	rr := adminRequest(featuresReloadHandler, httptest.NewRequest(http.MethodPost, "/admin/features/reload", nil), "secret")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if got := getFeatures(t); !got["coupons"] {
+		t.Errorf("flags after reload = %v, want coupons on", got)
+	}
+
+	os.WriteFile(path, []byte(`not json`), 0o600)
+	rr = adminRequest(featuresReloadHandler, httptest.NewRequest(http.MethodPost, "/admin/features/reload", nil), "secret")
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusInternalServerError)
+	}
+	if got := getFeatures(t); !got["coupons"] {
+		t.Errorf("flags after failed reload = %v, want the previous flags", got)
+	}
+
+	rr = adminRequest(featuresReloadHandler, httptest.NewRequest(http.MethodPost, "/admin/features/reload", nil), "")
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+// Client header forwarding. FORWARD_HEADERS lists client request headers, comma separated, that are
+// passed on to the Dotnet service, e.g. "X-Tenant-Id, X-Client-Version", so it can see who a call is
+// for. Only the listed headers are forwarded, and never ones that carry credentials or describe the
+// connection (Authorization, Cookie, Host, ...): listing one of those refuses startup. Headers are
+// forwarded on the calls a request makes for itself, such as placing its order, but not on catalog
+// fetches, whose result is cached and served to every client.
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// unforwardableHeaders describe the client's connection or are set for the upstream call itself;
+// headers that may carry credentials are refused by sensitiveHeader
+var unforwardableHeaders = map[string]bool{
+	"Connection":        true,
+	"Content-Length":    true,
+	"Content-Type":      true,
+	"Host":              true,
+	"Keep-Alive":        true,
+	"Proxy-Connection":  true,
+	"Set-Cookie":        true,
+	"Te":                true,
+	"Trailer":           true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+	"User-Agent":        true,
+}
+
+// forwardedHeadersKey is the request context key for the headers forwardHeadersMiddleware kept
+type forwardedHeadersKey struct{}
+
+// parseForwardHeaders returns the canonical header names in a FORWARD_HEADERS value, or an error
+// naming any that may never be forwarded
+func parseForwardHeaders(value string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if sensitiveHeader(name) || unforwardableHeaders[name] {
+			return nil, fmt.Errorf("header %s can't be forwarded", name)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// forwardHeadersMiddleware keeps the request's FORWARD_HEADERS in its context for forwardedHeaders
+func forwardHeadersMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		names, err := parseForwardHeaders(os.Getenv("FORWARD_HEADERS"))
+		if err != nil || len(names) == 0 {
+			// An invalid list stops startup; should it be set later, nothing is forwarded
+			next.ServeHTTP(w, r)
+			return
+		}
+		forwarded := make(http.Header)
+		for _, name := range names {
+			if values := r.Header.Values(name); len(values) > 0 {
+				forwarded[name] = values
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), forwardedHeadersKey{}, forwarded)))
+	})
+}
+
+// forwardedHeaders returns the client headers to pass on to the Dotnet service for ctx's request
+func forwardedHeaders(ctx context.Context) http.Header {
+	h, _ := ctx.Value(forwardedHeadersKey{}).(http.Header)
+	return h
+}
+
+// withoutForwardedHeaders returns ctx with no client headers to forward, for calls whose result is
+// shared between clients
+func withoutForwardedHeaders(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forwardedHeadersKey{}, http.Header(nil))
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// TestParseForwardHeaders tests canonicalizing the list and refusing credential and connection headers
+func TestParseForwardHeaders(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"x-tenant-id, X-Client-Version,", []string{"X-Tenant-Id", "X-Client-Version"}, false},
+		{"X-Tenant-Id,authorization", nil, true},
+		{"Cookie", nil, true},
+		{"X-Api-Key", nil, true},
+		{"X-Auth-Token", nil, true},
+		{"Host", nil, true},
+		{"Transfer-Encoding", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseForwardHeaders(tt.value)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseForwardHeaders(%q) = %v, %v, want %v, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// TestForwardHeaders tests that only listed client headers reach the Dotnet service with an order, and none with the shared catalog fetch
+func TestForwardHeaders(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[string]http.Header)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen[r.URL.Path] = r.Header.Clone()
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/place-order" {
+			json.NewEncoder(w).Encode(PlaceOrderResponse{Success: true, OrderId: "order-1"})
+			return
+		}
+		w.Write([]byte(`[{"id":"prod1","price":10,"stock":5}]`))
+	}))
+	defer upstream.Close()
+	os.Setenv("DOTNET_PRODUCTS_API_URL", upstream.URL)
+	os.Setenv("FORWARD_HEADERS", "X-Tenant-Id, X-Client-Version")
+	defer os.Unsetenv("DOTNET_PRODUCTS_API_URL")
+	defer os.Unsetenv("FORWARD_HEADERS")
+	productsCache.invalidate()
+	defer productsCache.invalidate()
+
+	send := func(handler http.HandlerFunc, req *http.Request) {
+		t.Helper()
+		req.Header.Set("X-Tenant-Id", "acme")
+		req.Header.Set("X-Unlisted", "nope")
+		req.Header.Set("Authorization", "Bearer client-token")
+		req.Header.Set("Cookie", "session=abc")
+		rr := httptest.NewRecorder()
+		forwardHeadersMiddleware(handler).ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v (%s)", rr.Code, http.StatusOK, rr.Body.String())
+		}
+	}
+	send(orderHandler, postJSON(t, "/order", PlaceOrderRequest{Items: []OrderItemRequest{{Id: "prod1", Quantity: 1, Price: 10}}, DeliveryAddress: "1 Main St"}))
+	send(productsHandler, httptest.NewRequest(http.MethodGet, "/products", nil))
+
+	order := seen["/place-order"]
+	if got := order.Get("X-Tenant-Id"); got != "acme" {
+		t.Errorf("order X-Tenant-Id = %q, want acme", got)
+	}
+	for _, name := range []string{"X-Unlisted", "Authorization", "Cookie", "X-Client-Version"} {
+		if got := order.Get(name); got != "" {
+			t.Errorf("order forwarded %s: %q", name, got)
+		}
+	}
+	if got := seen["/all-products"].Get("X-Tenant-Id"); got != "" {
+		t.Errorf("catalog fetch forwarded X-Tenant-Id %q, want the shared catalog fetched without it", got)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+// Guest checkout. With GUEST_CHECKOUT=true, POST /order also takes orders without a login token or
+// API key, even under AUTH_REQUIRED=true. Orders that send credentials are still authenticated as
+// before. Guest orders must carry a contact email or phone, and each client may place
+// at most GUEST_ORDER_RATE_LIMIT of them per GUEST_ORDER_RATE_WINDOW, counted per tenant and client
+// IP so a guest can't flood the Dotnet service the way an anonymous /order endpoint otherwise could.
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults for the guest order rate limit when GUEST_ORDER_RATE_LIMIT / GUEST_ORDER_RATE_WINDOW are not set
+const (
+	defaultGuestOrderRateLimit  = 5
+	defaultGuestOrderRateWindow = time.Minute
+)
+
+// guestCheckoutEnabled reports whether GUEST_CHECKOUT=true lets /order take orders without credentials
+func guestCheckoutEnabled() bool {
+	return envBool("GUEST_CHECKOUT", false)
+}
+
+// guestKey is the request context key marking a guest order
+type guestKey struct{}
+
+// isGuest reports whether ctx's request is a guest order, placed without credentials
+func isGuest(ctx context.Context) bool {
+	guest, _ := ctx.Value(guestKey{}).(bool)
+	return guest
+}
+
+// allowGuests sends requests with a login token or API key to member and the rest, marked as guest
+// orders, to guest. Preflight requests go to member, which lets them through.
+func allowGuests(member, guest http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions || r.Header.Get("Authorization") != "" || r.Header.Get("X-API-Key") != "" {
+			member.ServeHTTP(w, r)
+			return
+		}
+		guest.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), guestKey{}, true)))
+	})
+}
+
+// rateWindow counts requests for one key in the current window
+type rateWindow struct {
+	count int
+	ends  time.Time
+}
+
+// rateLimiter allows each key a number of requests per fixed window
+type rateLimiter struct {
+	mu    sync.Mutex
+	byKey map[string]*rateWindow
+	clock Clock
+}
+
+// guestOrderLimiter is the process-wide rate limiter for guest orders
+var guestOrderLimiter = newRateLimiter(realClock{})
+
+func newRateLimiter(clock Clock) *rateLimiter {
+	return &rateLimiter{byKey: make(map[string]*rateWindow), clock: clock}
+}
+
+// allow counts a request for key and reports whether it is within limit for the window; when it
+// isn't, it also returns how long until the window ends. A limit of zero or less means unlimited.
+func (l *rateLimiter) allow(key string, limit int, window time.Duration) (bool, time.Duration) {
+	if limit <= 0 {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.clock.Now()
+	l.pruneLocked(now)
+	w, ok := l.byKey[key]
+	if !ok {
+		w = &rateWindow{ends: now.Add(window)}
+		l.byKey[key] = w
+	}
+	if w.count >= limit {
+		return false, w.ends.Sub(now)
+	}
+	w.count++
+	return true, 0
+}
+
+// pruneLocked drops keys whose window has ended; l.mu must be held
+func (l *rateLimiter) pruneLocked(now time.Time) {
+	for key, w := range l.byKey {
+		if !now.Before(w.ends) {
+			delete(l.byKey, key)
+		}
+	}
+}
+
+// limitGuestOrders rejects guest orders beyond GUEST_ORDER_RATE_LIMIT per GUEST_ORDER_RATE_WINDOW
+// from one client with 429 and a Retry-After. Preflight requests aren't counted.
+func limitGuestOrders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key := tenantFrom(r.Context()) + "|" + clientIP(r)
+		limit := envInt("GUEST_ORDER_RATE_LIMIT", defaultGuestOrderRateLimit)
+		if ok, retryAfter := guestOrderLimiter.allow(key, limit, envDuration("GUEST_ORDER_RATE_WINDOW", defaultGuestOrderRateWindow)); !ok {
+			applyCORS(w, r, "POST, OPTIONS", "Content-Type, Authorization, X-API-Key, X-Tenant-ID")
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			writeError(w, r, http.StatusTooManyRequests, "Too many guest orders, please retry later")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validateGuestContact checks that a guest order has an email or phone to reach the customer by,
+// trimming the phone in place. The email itself is checked by validateOrder, like any order's.
+func validateGuestContact(order *PlaceOrderRequest) []FieldError {
+	order.Phone = strings.TrimSpace(order.Phone)
+	var fields []FieldError
+	if strings.TrimSpace(order.Email) == "" && order.Phone == "" {
+		fields = append(fields, FieldError{Field: "email", Message: "or phone is required for guest checkout"})
+	}
+	if order.Phone != "" && !validPhone(order.Phone) {
+		fields = append(fields, FieldError{Field: "phone", Message: "must be a phone number of 7 to 15 digits"})
+	}
+	return fields
+}
+
+// validPhone reports whether phone is 7 to 15 digits (the E.164 maximum), optionally led by "+" and
+// separated by spaces, dots, dashes or parentheses
+func validPhone(phone string) bool {
+	digits := 0
+	for i, r := range phone {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+		case r == '+' && i == 0:
+		case r == ' ' || r == '.' || r == '-' || r == '(' || r == ')':
+		default:
+			return false
+		}
+	}
+	return digits >= 7 && digits <= 15
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestRateLimiter tests that each key gets limit requests per window, and a new window once it ends
+func TestRateLimiter(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	l := newRateLimiter(clock)
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.allow("a", 2, time.Minute); !ok {
+			t.Fatalf("request %d was limited", i+1)
+		}
+	}
+	clock.Advance(20 * time.Second)
+	if ok, retryAfter := l.allow("a", 2, time.Minute); ok || retryAfter != 40*time.Second {
+		t.Errorf("third request = %v, retry after %v, want limited for 40s", ok, retryAfter)
+	}
+	if ok, _ := l.allow("b", 2, time.Minute); !ok {
+		t.Error("another key was limited")
+	}
+	clock.Advance(40 * time.Second)
+	if ok, _ := l.allow("a", 2, time.Minute); !ok {
+		t.Error("request in a new window was limited")
+	}
+	for i := 0; i < 5; i++ {
+		if ok, _ := l.allow("a", 0, time.Minute); !ok {
+			t.Fatal("a limit of 0 should be unlimited")
+		}
+	}
+}
+
+// TestValidateGuestContact tests that a guest order needs an email or a valid phone
+func TestValidateGuestContact(t *testing.T) {
+	tests := []struct {
+		name       string
+		email      string
+		phone      string
+		wantFields []string
+	}{
+		{"email", " jane@example.com ", "", nil},
+		{"phone", "", "+1 (555) 010-0199", nil},
+		{"both", "jane@example.com", "555.010.0199", nil},
+		{"neither", " ", "", []string{"email"}},
+		{"short phone", "", "555-019", []string{"phone"}},
+		{"long phone", "", "+1234567890123456", []string{"phone"}},
+		{"letters in phone", "", "555-010-CALL", []string{"phone"}},
+		{"plus inside phone", "", "555+0100199", []string{"phone"}},
+		{"invalid phone", "", "12", []string{"phone"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := PlaceOrderRequest{Email: tt.email, Phone: tt.phone}
+			fields := validateGuestContact(&order)
+			if len(fields) != len(tt.wantFields) {
+				t.Fatalf("validateGuestContact() = %v, want errors for %v", fields, tt.wantFields)
+			}
+			for i, f := range fields {
+				if f.Field != tt.wantFields[i] {
+					t.Errorf("field error %d is for %q, want %q", i, f.Field, tt.wantFields[i])
+				}
+			}
+		})
+	}
+}
+
+// useGuestCheckout turns on AUTH_REQUIRED with an API key and, when guest is set, GUEST_CHECKOUT, with a fresh guest rate limit
+func useGuestCheckout(t *testing.T, guest bool) {
+	t.Helper()
+	os.Setenv("AUTH_REQUIRED", "true")
+	os.Setenv("API_KEYS", "member-key")
+	if guest {
+		os.Setenv("GUEST_CHECKOUT", "true")
+	}
+	orig := guestOrderLimiter
+	guestOrderLimiter = newRateLimiter(realClock{})
+	t.Cleanup(func() {
+		os.Unsetenv("AUTH_REQUIRED")
+		os.Unsetenv("API_KEYS")
+		os.Unsetenv("GUEST_CHECKOUT")
+		guestOrderLimiter = orig
+	})
+}
+
+// guestOrder is a valid order for prod1, with the given contact email
+func guestOrder(email string) PlaceOrderRequest {
+	return PlaceOrderRequest{
+		Items:           []OrderItemRequest{{Id: "prod1", Name: "Headphones", Quantity: 1, Price: 99.99}},
+		TotalAmount:     pricePtr(99.99),
+		DeliveryAddress: "1 Main St",
+		Email:           email,
+	}
+}
+
+// TestOrderHandler_GuestCheckout tests that guests can order with contact details while credentials are still checked
+func TestOrderHandler_GuestCheckout(t *testing.T) {
+	useGuestCheckout(t, true)
+	dotnet := newFakeDotnet(t, []Product{{Id: "prod1", Name: "Headphones", Price: 99.99, Stock: 10}})
+	handler := routes()
+	order := func(apiKey string, v PlaceOrderRequest) *httptest.ResponseRecorder {
+		t.Helper()
+		req := postJSON(t, "/order", v)
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := order("", guestOrder("jane@example.com")); rr.Code != http.StatusOK {
+		t.Fatalf("guest order: handler returned wrong status code: got %v want %v, body %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	if got := dotnet.lastOrder(t).Email; got != "jane@example.com" {
+		t.Errorf("upstream got email %q, want the guest's", got)
+	}
+	if rr := order("", guestOrder("")); rr.Code != http.StatusBadRequest {
+		t.Errorf("guest order without contact: handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+
+	// Members need no contact details, but their credentials must be valid
+	if rr := order("member-key", guestOrder("")); rr.Code != http.StatusOK {
+		t.Errorf("member order: handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if rr := order("stolen-key", guestOrder("jane@example.com")); rr.Code != http.StatusUnauthorized {
+		t.Errorf("invalid API key: handler returned wrong status code: got %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+
+	// Guest checkout covers /order only
+	req := postJSON(t, "/orders/batch", BatchOrderRequest{Orders: []PlaceOrderRequest{guestOrder("jane@example.com")}})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("guest batch: handler returned wrong status code: got %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+}
+
+// TestOrderHandler_GuestRateLimit tests that guests are limited to GUEST_ORDER_RATE_LIMIT orders, invalid ones included, and members aren't
+func TestOrderHandler_GuestRateLimit(t *testing.T) {
+	useGuestCheckout(t, true)
+	os.Setenv("GUEST_ORDER_RATE_LIMIT", "2")
+	defer os.Unsetenv("GUEST_ORDER_RATE_LIMIT")
+	newFakeDotnet(t, []Product{{Id: "prod1", Name: "Headphones", Price: 99.99, Stock: 10}})
+	handler := routes()
+	order := func(apiKey string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := postJSON(t, "/order", guestOrder("jane@example.com"))
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	invalid := postJSON(t, "/order", guestOrder(""))
+	handler.ServeHTTP(httptest.NewRecorder(), invalid)
+	if rr := order(""); rr.Code != http.StatusOK {
+		t.Fatalf("second guest order: handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	rr := order("")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("third guest order: handler returned wrong status code: got %v want %v", rr.Code, http.StatusTooManyRequests)
+	}
+	if got := rr.Header().Get("Retry-After"); got == "" || got == "0" {
+		t.Errorf("Retry-After = %q, want the seconds left in the window", got)
+	}
+	if rr := order("member-key"); rr.Code != http.StatusOK {
+		t.Errorf("member order: handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+}
+
+// TestOrderHandler_GuestCheckoutDisabled tests that without GUEST_CHECKOUT orders need credentials but no contact fields
+func TestOrderHandler_GuestCheckoutDisabled(t *testing.T) {
+	useGuestCheckout(t, false)
+	newFakeDotnet(t, []Product{{Id: "prod1", Name: "Headphones", Price: 99.99, Stock: 10}})
+	handler := routes()
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, postJSON(t, "/order", guestOrder("jane@example.com")))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("order without credentials: handler returned wrong status code: got %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+
+	// Members need no contact details, though an email they give must be valid
+	for _, tt := range []struct {
+		email string
+		want  int
+	}{{"", http.StatusOK}, {"not-an-email", http.StatusBadRequest}} {
+		req := postJSON(t, "/order", guestOrder(tt.email))
+		req.Header.Set("X-API-Key", "member-key")
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("member order with email %q: handler returned wrong status code: got %v want %v", tt.email, rr.Code, tt.want)
+		}
+	}
+}
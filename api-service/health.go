@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Defaults for /healthz when HEALTH_CACHE_TTL / HEALTH_CHECK_TIMEOUT are not set
+const (
+	defaultHealthCacheTTL     = 5 * time.Second
+	defaultHealthCheckTimeout = 2 * time.Second
+)
+
+// HealthResponse is the body returned by /healthz
+type HealthResponse struct {
+	Status   string   `json:"status"`
+	Error    string   `json:"error,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// configWarnings inspects the configuration for insecure settings that shouldn't reach production.
+// It only looks at environment variables so it is cheap enough to run on every probe.
+func configWarnings() []string {
+	var warnings []string
+	if tokensEnabled() && os.Getenv("JWT_SECRET") == "" {
+		warnings = append(warnings, "AUTH_ISSUE_TOKENS is enabled but JWT_SECRET is not set, so tokens are signed with the development secret and rejected")
+	} else if authRequired() && os.Getenv("JWT_SECRET") == "" {
+		warnings = append(warnings, "AUTH_REQUIRED is enabled but JWT_SECRET is not set, so only API keys are accepted")
+	}
+	return warnings
+}
+
+// healthCache remembers the last upstream health check so frequent probes don't each call the Dotnet service
+type healthCache struct {
+	mu        sync.Mutex
+	checkedAt time.Time
+	err       error
+	now       func() time.Time
+	check     func(ctx context.Context) error
+}
+
+// upstreamHealth is the process-wide health cache used by healthzHandler
+var upstreamHealth = newHealthCache(checkUpstream)
+
+func newHealthCache(check func(ctx context.Context) error) *healthCache {
+	return &healthCache{now: time.Now, check: check}
+}
+
+// get returns the cached result if it is younger than ttl and checks again otherwise. Concurrent
+// callers wait for one check rather than each making their own.
+func (c *healthCache) get(ctx context.Context, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.checkedAt.IsZero() && c.now().Sub(c.checkedAt) < ttl {
+		return c.err
+	}
+	c.err = c.check(ctx)
+	c.checkedAt = c.now()
+	return c.err
+}
+
+// lastChecked returns when the cached result was checked, or the zero time before the first check
+func (c *healthCache) lastChecked() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.checkedAt
+}
+
+// checkUpstream reports whether the Dotnet service answers UPSTREAM_HEALTH_PATH with a 2xx status.
+// With several instances in DOTNET_PRODUCTS_API_URLS one healthy instance is enough, as calls fail
+// over to it; the error is the last instance's.
+func checkUpstream(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, envDuration("HEALTH_CHECK_TIMEOUT", defaultHealthCheckTimeout))
+	defer cancel()
+
+	path := os.Getenv("UPSTREAM_HEALTH_PATH")
+	if path == "" {
+		path = "/all-products"
+	}
+	var err error
+	for _, base := range upstreamBases() {
+		if err = checkInstance(ctx, upstreamPathURL(base, path)); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// checkInstance reports whether GET targetURL answers with a 2xx status
+func checkInstance(ctx context.Context, targetURL string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, targetURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := newUpstreamClient().Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("backend service returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// healthzHandler reports readiness, returning 503 while shutting down or while the Dotnet service is
+// unreachable. Insecure configuration is reported as "degraded" with a 200 so it is visible without
+// failing the probe.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		methodNotAllowed(w, r, http.MethodGet, http.MethodHead)
+		return
+	}
+
+	// Fail readiness as soon as shutdown starts so load balancers drain us first
+	if draining.Load() {
+		writeJSON(w, r, http.StatusServiceUnavailable, HealthResponse{Status: "draining"})
+		return
+	}
+
+	// The check outlives a probe that gives up so its result can still be cached
+	if err := upstreamHealth.get(context.WithoutCancel(r.Context()), envDuration("HEALTH_CACHE_TTL", defaultHealthCacheTTL)); err != nil {
+		log.Printf("Health check failed: %v", err)
+		writeJSON(w, r, http.StatusServiceUnavailable, HealthResponse{Status: "unavailable", Error: "backend service unavailable"})
+		return
+	}
+	if warnings := configWarnings(); len(warnings) > 0 {
+		writeJSON(w, r, http.StatusOK, HealthResponse{Status: "degraded", Warnings: warnings})
+		return
+	}
+	writeJSON(w, r, http.StatusOK, HealthResponse{Status: "ok"})
+}
+
+// pingHandler answers "pong" in plain text for uptime checks that don't parse JSON. Unlike /healthz it
+// never calls the Dotnet service.
+func pingHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		methodNotAllowed(w, r, http.MethodGet, http.MethodHead)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		w.Write([]byte("pong"))
+	}
+}
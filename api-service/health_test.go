@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// newCountingHealthCache returns a health cache with a controllable clock whose check returns *result and counts calls
+func newCountingHealthCache() (*healthCache, *time.Time, *int, *error) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var calls int
+	var result error
+	c := newHealthCache(func(ctx context.Context) error {
+		calls++
+		return result
+	})
+	c.now = func() time.Time { return now }
+	return c, &now, &calls, &result
+}
+
+// TestHealthCache tests that results are reused within the TTL and rechecked after it
+func TestHealthCache(t *testing.T) {
+	c, now, calls, _ := newCountingHealthCache()
+
+	for i := 0; i < 3; i++ {
+		if err := c.get(context.Background(), 5*time.Second); err != nil {
+			t.Errorf("healthy check returned %v", err)
+		}
+	}
+	if *calls != 1 {
+		t.Errorf("upstream checked %d times within the TTL, want 1", *calls)
+	}
+
+	*now = now.Add(5 * time.Second)
+	c.get(context.Background(), 5*time.Second)
+	if *calls != 2 {
+		t.Errorf("upstream checked %d times after the TTL, want 2", *calls)
+	}
+}
+
+// TestHealthCache_Unhealthy tests that a failed check is cached too
+func TestHealthCache_Unhealthy(t *testing.T) {
+	c, _, calls, result := newCountingHealthCache()
+	*result = errors.New("connection refused")
+
+	for i := 0; i < 2; i++ {
+		if err := c.get(context.Background(), 5*time.Second); err == nil {
+			t.Errorf("unhealthy check returned nil")
+		}
+	}
+	if *calls != 1 {
+		t.Errorf("upstream checked %d times within the TTL, want 1", *calls)
+	}
+}
+
+// TestHealthzHandler tests the handler status for healthy and cached-unhealthy upstreams
+func TestHealthzHandler(t *testing.T) {
+	c, _, calls, result := newCountingHealthCache()
+	orig := upstreamHealth
+	upstreamHealth = c
+	defer func() { upstreamHealth = orig }()
+	os.Setenv("HEALTH_CACHE_TTL", "1m")
+	defer os.Unsetenv("HEALTH_CACHE_TTL")
+
+	*result = errors.New("connection refused")
+	for i := 0; i < 2; i++ {
+		rr := httptest.NewRecorder()
+		healthzHandler(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		if rr.Code != http.StatusServiceUnavailable {
+			t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusServiceUnavailable)
+		}
+	}
+	if *calls != 1 {
+		t.Errorf("upstream checked %d times, want 1", *calls)
+	}
+}
+
+// TestHealthzHandler_DegradedConfig tests that issuing tokens with the development JWT secret is reported
+func TestHealthzHandler_DegradedConfig(t *testing.T) {
+	c, _, _, _ := newCountingHealthCache()
+	orig := upstreamHealth
+	upstreamHealth = c
+	defer func() { upstreamHealth = orig }()
+	os.Setenv("AUTH_ISSUE_TOKENS", "true")
+	defer os.Unsetenv("AUTH_ISSUE_TOKENS")
+
+	get := func() HealthResponse {
+		rr := httptest.NewRecorder()
+		healthzHandler(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		if rr.Code != http.StatusOK {
+			t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+		var body HealthResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decoding health response: %v", err)
+		}
+		return body
+	}
+
+	if body := get(); body.Status != "degraded" || len(body.Warnings) != 1 {
+		t.Errorf("health with dev JWT secret = %+v, want degraded with one warning", body)
+	}
+
+	os.Setenv("JWT_SECRET", "prod-secret")
+	defer os.Unsetenv("JWT_SECRET")
+	if body := get(); body.Status != "ok" || len(body.Warnings) != 0 {
+		t.Errorf("health with JWT_SECRET set = %+v, want ok", body)
+	}
+}
+
+// TestPing tests the plain-text pong, served through the full routes without auth or upstream
+func TestPing(t *testing.T) {
+	os.Setenv("AUTH_REQUIRED", "true")
+	defer os.Unsetenv("AUTH_REQUIRED")
+	os.Setenv("DOTNET_PRODUCTS_API_URL", "http://127.0.0.1:1") // Nothing listens here
+	defer os.Unsetenv("DOTNET_PRODUCTS_API_URL")
+
+	rr := httptest.NewRecorder()
+	routes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/ping", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if body := rr.Body.String(); body != "pong" {
+		t.Errorf("handler returned %q, want pong", body)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Errorf("Content-Type = %q, want text/plain; charset=utf-8", ct)
+	}
+}
+
+// TestCheckUpstream tests the upstream check against a real server
+func TestCheckUpstream(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	os.Setenv("DOTNET_PRODUCTS_API_URL", server.URL)
+	defer os.Unsetenv("DOTNET_PRODUCTS_API_URL")
+
+	if err := checkUpstream(context.Background()); err != nil {
+		t.Errorf("healthy upstream returned %v", err)
+	}
+	status = http.StatusInternalServerError
+	if err := checkUpstream(context.Background()); err == nil {
+		t.Errorf("failing upstream returned nil")
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// defaultImageCacheMaxAge is the Cache-Control max-age for proxied images when IMAGE_CACHE_MAX_AGE is not set
+const defaultImageCacheMaxAge = 86400
+
+// imageHeaders are the upstream response headers passed through to the client
+var imageHeaders = []string{"Content-Type", "Content-Length", "Content-Range", "Accept-Ranges", "ETag", "Last-Modified", "Cache-Control"}
+
+// imageOriginAllowed reports whether u's scheme and host match an origin in IMAGE_PROXY_ALLOWED_ORIGINS,
+// a comma-separated list such as "https://cdn.example.com,https://images.example.com:8443". Nothing is
+// allowed when it is unset, so the proxy can't be pointed at internal services.
+func imageOriginAllowed(u *url.URL) bool {
+	if (u.Scheme != "http" && u.Scheme != "https") || u.User != nil {
+		return false
+	}
+	origin := strings.ToLower(u.Scheme + "://" + u.Host)
+	for _, allowed := range strings.Split(os.Getenv("IMAGE_PROXY_ALLOWED_ORIGINS"), ",") {
+		if allowed = strings.ToLower(strings.TrimRight(strings.TrimSpace(allowed), "/")); allowed != "" && allowed == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// newImageClient returns a client for fetching product images that refuses redirects off the allowlist
+func newImageClient() *http.Client {
+	client := newUpstreamClient()
+	client.Timeout = envDuration("UPSTREAM_TIMEOUT", defaultUpstreamTimeout) // Covers streaming the image body too
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 5 {
+			return errors.New("stopped after 5 redirects")
+		}
+		if !imageOriginAllowed(req.URL) {
+			return errors.New("redirect to disallowed image origin " + req.URL.Host)
+		}
+		return nil
+	}
+	return client
+}
+
+// productImageHandler proxies a product's image through this service so the frontend avoids
+// CORS and mixed-content issues, passing Range requests through for large images
+func productImageHandler(w http.ResponseWriter, r *http.Request) {
+	// Set CORS headers for any origin
+	applyCORS(w, r, "GET, OPTIONS", "Content-Type, Range, X-Tenant-ID")
+
+	// Handle preflight OPTIONS request
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet, http.MethodOptions)
+		return
+	}
+
+	entry, err := tenantProductCache(r.Context()).get(r.Context(), envDuration("PRODUCTS_CACHE_TTL", defaultProductsCacheTTL))
+	if err != nil {
+		log.Printf("An error occured loading products for image: %v", err)
+		writeUpstreamError(w, r, err)
+		return
+	}
+	id := r.PathValue("id")
+	var imageURL string
+	for _, p := range entry.Products {
+		if p.Id == id {
+			imageURL = p.ImageUrl
+			break
+		}
+	}
+	if imageURL == "" {
+		writeError(w, r, http.StatusNotFound, "Product image not found")
+		return
+	}
+
+	u, err := url.Parse(imageURL)
+	if err != nil || !imageOriginAllowed(u) {
+		log.Printf("Refusing to proxy image for product %s from disallowed URL %q", id, imageURL)
+		writeError(w, r, http.StatusForbidden, "Image host not allowed")
+		return
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, u.String(), nil)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	for _, h := range []string{"Range", "If-Range", "If-None-Match", "If-Modified-Since"} {
+		if v := r.Header.Get(h); v != "" {
+			req.Header.Set(h, v)
+		}
+	}
+	resp, err := newImageClient().Do(req)
+	if err != nil {
+		log.Printf("Error fetching image for product %s: %v", id, err)
+		writeError(w, r, http.StatusBadGateway, "Failed to fetch product image")
+		return
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		writeError(w, r, http.StatusNotFound, "Product image not found")
+		return
+	case resp.StatusCode == http.StatusNotModified || resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// Passed through below without a body check
+	case resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent:
+		log.Printf("Image host returned status %d for product %s", resp.StatusCode, id)
+		writeError(w, r, http.StatusBadGateway, "Failed to fetch product image")
+		return
+	case !strings.HasPrefix(resp.Header.Get("Content-Type"), "image/"):
+		log.Printf("Image URL for product %s returned Content-Type %q", id, resp.Header.Get("Content-Type"))
+		writeError(w, r, http.StatusBadGateway, "Product image is not an image")
+		return
+	}
+
+	for _, h := range imageHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			w.Header().Set(h, v)
+		}
+	}
+	if w.Header().Get("Cache-Control") == "" {
+		w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(envInt("IMAGE_CACHE_MAX_AGE", defaultImageCacheMaxAge)))
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(resp.StatusCode)
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		log.Printf("Error proxying image for product %s: %v", id, err)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+// pngBytes stands in for an image body
+var pngBytes = append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0xAB}, 1024)...)
+
+// newImageHost serves pngBytes at /p1.png with Range support and returns the server allowlisted
+func newImageHost(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/p1.png" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		http.ServeContent(w, r, "p1.png", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), bytes.NewReader(pngBytes))
+	}))
+	os.Setenv("IMAGE_PROXY_ALLOWED_ORIGINS", server.URL)
+	t.Cleanup(func() {
+		server.Close()
+		os.Unsetenv("IMAGE_PROXY_ALLOWED_ORIGINS")
+	})
+	return server
+}
+
+// getImage requests a product image through productImageHandler
+func getImage(id, rangeHeader string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/products/"+id+"/image", nil)
+	req.SetPathValue("id", id)
+	if rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+	rr := httptest.NewRecorder()
+	productImageHandler(rr, req)
+	return rr
+}
+
+// TestProductImageHandler tests proxying a whole image
+func TestProductImageHandler(t *testing.T) {
+	images := newImageHost(t)
+	newFakeDotnet(t, []Product{{Id: "p1", ImageUrl: images.URL + "/p1.png"}})
+
+	rr := getImage("p1", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if !bytes.Equal(rr.Body.Bytes(), pngBytes) {
+		t.Errorf("handler returned %d bytes, want the %d byte image", rr.Body.Len(), len(pngBytes))
+	}
+	if got := rr.Header().Get("Content-Type"); got != "image/png" {
+		t.Errorf("Content-Type = %q, want image/png", got)
+	}
+	if got := rr.Header().Get("Cache-Control"); got != "public, max-age=86400" {
+		t.Errorf("Cache-Control = %q, want public, max-age=86400", got)
+	}
+	if got := rr.Header().Get("Accept-Ranges"); got != "bytes" {
+		t.Errorf("Accept-Ranges = %q, want bytes", got)
+	}
+}
+
+// TestProductImageHandler_PublicUnderAuth tests that images stay reachable without credentials under
+// AUTH_REQUIRED=true, as <img> tags can't send them, while the catalog itself doesn't
+func TestProductImageHandler_PublicUnderAuth(t *testing.T) {
+	images := newImageHost(t)
+	newFakeDotnet(t, []Product{{Id: "p1", ImageUrl: images.URL + "/p1.png"}})
+	os.Setenv("AUTH_REQUIRED", "true")
+	defer os.Unsetenv("AUTH_REQUIRED")
+	handler := routes()
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/products/p1/image", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("image without credentials: handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/products", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("catalog without credentials: handler returned wrong status code: got %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+}
+
+// TestProductImageHandler_Range tests that Range requests return 206 with the requested bytes
+func TestProductImageHandler_Range(t *testing.T) {
+	images := newImageHost(t)
+	newFakeDotnet(t, []Product{{Id: "p1", ImageUrl: images.URL + "/p1.png"}})
+
+	rr := getImage("p1", "bytes=0-7")
+	if rr.Code != http.StatusPartialContent {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusPartialContent)
+	}
+	if !bytes.Equal(rr.Body.Bytes(), pngBytes[:8]) {
+		t.Errorf("handler returned %q, want the first 8 bytes", rr.Body.Bytes())
+	}
+	if got, want := rr.Header().Get("Content-Range"), "bytes 0-7/1032"; got != want {
+		t.Errorf("Content-Range = %q, want %q", got, want)
+	}
+}
+
+// TestProductImageHandler_NotFound tests unknown products, products without images and missing images
+func TestProductImageHandler_NotFound(t *testing.T) {
+	images := newImageHost(t)
+	newFakeDotnet(t, []Product{
+		{Id: "p1", ImageUrl: images.URL + "/missing.png"},
+		{Id: "p2"},
+	})
+
+	for _, id := range []string{"p1", "p2", "unknown"} {
+		if rr := getImage(id, ""); rr.Code != http.StatusNotFound {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", id, rr.Code, http.StatusNotFound)
+		}
+	}
+}
+
+// TestProductImageHandler_DisallowedOrigin tests that image URLs off the allowlist are not fetched
+func TestProductImageHandler_DisallowedOrigin(t *testing.T) {
+	var fetched bool
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched = true
+	}))
+	defer internal.Close()
+	newFakeDotnet(t, []Product{{Id: "p1", ImageUrl: internal.URL + "/secret"}})
+	os.Setenv("IMAGE_PROXY_ALLOWED_ORIGINS", "https://cdn.example.com")
+	defer os.Unsetenv("IMAGE_PROXY_ALLOWED_ORIGINS")
+
+	if rr := getImage("p1", ""); rr.Code != http.StatusForbidden {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusForbidden)
+	}
+	if fetched {
+		t.Errorf("disallowed image URL was fetched")
+	}
+}
+
+// TestImageOriginAllowed tests scheme and host matching against the allowlist
+func TestImageOriginAllowed(t *testing.T) {
+	os.Setenv("IMAGE_PROXY_ALLOWED_ORIGINS", "https://cdn.example.com, https://img.example.com:8443/")
+	defer os.Unsetenv("IMAGE_PROXY_ALLOWED_ORIGINS")
+
+	tests := []struct {
+		raw  string
+		want bool
+	}{
+		{"https://cdn.example.com/a.png", true},
+		{"https://CDN.example.com/a.png", true},
+		{"https://img.example.com:8443/a.png", true},
+		{"http://cdn.example.com/a.png", false},
+		{"https://cdn.example.com:444/a.png", false},
+		{"https://evil.com/a.png", false},
+		{"https://user@cdn.example.com/a.png", false},
+		{"file:///etc/passwd", false},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.raw)
+		if got := imageOriginAllowed(u); got != tt.want {
+			t.Errorf("imageOriginAllowed(%q) = %v, want %v", tt.raw, got, tt.want)
+		}
+	}
+}
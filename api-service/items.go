@@ -0,0 +1,42 @@
+package main
+
+import "fmt"
+
+// mergeItems combines line items with the same product id into one, summing their quantities.
+// Items keep the position and other fields of their first occurrence.
+func mergeItems(items []OrderItemRequest) []OrderItemRequest {
+	merged := make([]OrderItemRequest, 0, len(items))
+	index := make(map[string]int, len(items))
+	for _, item := range items {
+		if i, ok := index[item.Id]; ok {
+			merged[i].Quantity += item.Quantity
+			continue
+		}
+		index[item.Id] = len(merged)
+		merged = append(merged, item)
+	}
+	return merged
+}
+
+// duplicateItemErrors reports line items that repeat an earlier item's product id. A duplicate
+// whose price differs from the first occurrence is always an error; otherwise duplicates are only
+// an error when they won't be merged.
+func duplicateItemErrors(items []OrderItemRequest, merge bool) []FieldError {
+	var fields []FieldError
+	first := make(map[string]int, len(items))
+	for i, item := range items {
+		j, seen := first[item.Id]
+		if !seen {
+			first[item.Id] = i
+			continue
+		}
+		path := fmt.Sprintf("items[%d]", i)
+		switch {
+		case item.Price != items[j].Price:
+			fields = append(fields, FieldError{Field: path + ".price", Message: fmt.Sprintf("must match the price of item %q at items[%d]", item.Id, j)})
+		case !merge:
+			fields = append(fields, FieldError{Field: path + ".id", Message: fmt.Sprintf("duplicates item %q at items[%d]", item.Id, j)})
+		}
+	}
+	return fields
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// TestMergeItems tests that repeated ids are summed in first-occurrence order
+func TestMergeItems(t *testing.T) {
+	items := []OrderItemRequest{
+		{Id: "p1", Name: "Headphones", Quantity: 1, Price: 10},
+		{Id: "p2", Name: "Speaker", Quantity: 2, Price: 5},
+		{Id: "p1", Name: "Headphones", Quantity: 3, Price: 10},
+	}
+	want := []OrderItemRequest{
+		{Id: "p1", Name: "Headphones", Quantity: 4, Price: 10},
+		{Id: "p2", Name: "Speaker", Quantity: 2, Price: 5},
+	}
+	if got := mergeItems(items); !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeItems() = %+v, want %+v", got, want)
+	}
+	if items[0].Quantity != 1 {
+		t.Error("mergeItems modified its input")
+	}
+}
+
+// TestDuplicateItemErrors tests both merge modes and that duplicates must agree on price
+func TestDuplicateItemErrors(t *testing.T) {
+	consistent := []OrderItemRequest{{Id: "p1", Quantity: 1, Price: 10}, {Id: "p1", Quantity: 1, Price: 10}}
+	inconsistent := []OrderItemRequest{{Id: "p1", Quantity: 1, Price: 10}, {Id: "p1", Quantity: 1, Price: 8}}
+
+	if got := duplicateItemErrors(consistent, true); len(got) != 0 {
+		t.Errorf("merge mode with consistent prices = %+v, want no errors", got)
+	}
+	wantDup := []FieldError{{Field: "items[1].id", Message: `duplicates item "p1" at items[0]`}}
+	if got := duplicateItemErrors(consistent, false); !reflect.DeepEqual(got, wantDup) {
+		t.Errorf("reject mode = %+v, want %+v", got, wantDup)
+	}
+	wantPrice := []FieldError{{Field: "items[1].price", Message: `must match the price of item "p1" at items[0]`}}
+	for _, merge := range []bool{true, false} {
+		if got := duplicateItemErrors(inconsistent, merge); !reflect.DeepEqual(got, wantPrice) {
+			t.Errorf("merge=%v with inconsistent prices = %+v, want %+v", merge, got, wantPrice)
+		}
+	}
+}
+
+// TestValidateOrder_DuplicateItems tests merging in validateOrder and the cap on merged quantities
+func TestValidateOrder_DuplicateItems(t *testing.T) {
+	order := PlaceOrderRequest{
+		Items:           []OrderItemRequest{{Id: "p1", Quantity: 60, Price: 10}, {Id: "p1", Quantity: 30, Price: 10}},
+		DeliveryAddress: "1 Main St",
+	}
+	if got := validateOrder(&order); len(got) != 0 {
+		t.Fatalf("validateOrder() = %+v, want no errors", got)
+	}
+	if len(order.Items) != 1 || order.Items[0].Quantity != 90 {
+		t.Errorf("validated items = %+v, want one p1 line of 90", order.Items)
+	}
+
+	order.Items = append(order.Items, OrderItemRequest{Id: "p1", Quantity: 11, Price: 10})
+	want := []FieldError{{Field: "items", Message: `total quantity of item "p1" must be <= 100`}}
+	if got := validateOrder(&order); !reflect.DeepEqual(got, want) {
+		t.Errorf("validateOrder() over the merged cap = %+v, want %+v", got, want)
+	}
+}
+
+// TestOrderHandler_DuplicateItems tests that duplicates are merged before forwarding, or rejected when merging is off
+func TestOrderHandler_DuplicateItems(t *testing.T) {
+	dotnet := newFakeDotnet(t, nil)
+	order := PlaceOrderRequest{
+		Items:           []OrderItemRequest{{Id: "p1", Quantity: 1, Price: 10}, {Id: "p1", Quantity: 2, Price: 10}},
+		TotalAmount:     pricePtr(30),
+		DeliveryAddress: "1 Main St",
+	}
+
+	rr := httptest.NewRecorder()
+	orderHandler(rr, postJSON(t, "/order", order))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v (%s)", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	if items := dotnet.lastOrder(t).Items; len(items) != 1 || items[0].Quantity != 3 {
+		t.Errorf("forwarded items = %+v, want one p1 line of 3", items)
+	}
+
+	os.Setenv("MERGE_DUPLICATE_ITEMS", "false")
+	defer os.Unsetenv("MERGE_DUPLICATE_ITEMS")
+	rr = httptest.NewRecorder()
+	orderHandler(rr, postJSON(t, "/order", order))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code with merging off: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"log"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxAcceptLanguageLength caps the Accept-Language header we parse; browsers send well under this
+const maxAcceptLanguageLength = 256
+
+// languageRange matches one Accept-Language range such as "de", "en-US" or "*"
+var languageRange = regexp.MustCompile(`^(\*|[A-Za-z]{1,8}(-[A-Za-z0-9]{1,8})*)$`)
+
+// productLanguages returns the catalog languages listed in PRODUCT_LANGUAGES (e.g. "en,de,fr-CA"),
+// first one taking precedence for "*". Nil leaves Accept-Language out of catalog requests.
+func productLanguages() []string {
+	var languages []string
+	for _, lang := range strings.Split(os.Getenv("PRODUCT_LANGUAGES"), ",") {
+		if lang = strings.TrimSpace(lang); lang != "" {
+			languages = append(languages, lang)
+		}
+	}
+	return languages
+}
+
+// parseAcceptLanguage returns the language ranges of an Accept-Language header, most preferred
+// first, dropping ranges with q=0. It reports false for an over-long or malformed header.
+func parseAcceptLanguage(header string) ([]string, bool) {
+	if len(header) > maxAcceptLanguageLength {
+		return nil, false
+	}
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var ranges []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" && params == "" {
+			continue // Tolerate empty list elements like "en,,de"
+		}
+		if !languageRange.MatchString(tag) {
+			return nil, false
+		}
+		q := 1.0
+		if params != "" {
+			value, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+			parsed, err := strconv.ParseFloat(value, 64)
+			if !ok || err != nil || parsed < 0 || parsed > 1 || len(value) > 5 {
+				return nil, false
+			}
+			q = parsed
+		}
+		if q > 0 {
+			ranges = append(ranges, weighted{tag, q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+	tags := make([]string, len(ranges))
+	for i, r := range ranges {
+		tags[i] = r.tag
+	}
+	return tags, true
+}
+
+// negotiateLanguage picks the supported language that best matches an Accept-Language header, or ""
+// for none. A range matches a language equal to it or more specific ("de" matches "de-CH"), and a
+// range no language matches falls back to its prefixes ("de-CH" to "de"), as in RFC 4647 lookup.
+func negotiateLanguage(header string, supported []string) string {
+	if header == "" || len(supported) == 0 {
+		return ""
+	}
+	ranges, ok := parseAcceptLanguage(header)
+	if !ok {
+		log.Printf("Ignoring invalid Accept-Language header %q", truncate(header, 64))
+		return ""
+	}
+	for _, r := range ranges {
+		if r == "*" {
+			return supported[0]
+		}
+		for _, lang := range supported {
+			if strings.EqualFold(lang, r) || hasPrefixFold(lang, r+"-") {
+				return lang
+			}
+		}
+		for prefix := r; strings.Contains(prefix, "-"); {
+			prefix = prefix[:strings.LastIndex(prefix, "-")]
+			for _, lang := range supported {
+				if strings.EqualFold(lang, prefix) {
+					return lang
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// hasPrefixFold is strings.HasPrefix ignoring case
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// truncate shortens s to at most n bytes for logging
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+// catalogKey identifies one catalog cache: a tenant's catalog in a language, "" being the default of either
+type catalogKey struct {
+	tenant, lang string
+}
+
+// localizedCaches holds one catalog cache per tenant and language from PRODUCT_LANGUAGES; the
+// default catalog without tenants stays in productsCache. Keys only ever come from the configured
+// lists, so the map stays small.
+var localizedCaches = struct {
+	mu    sync.Mutex
+	byKey map[catalogKey]*productCache
+}{byKey: make(map[catalogKey]*productCache)}
+
+// productCacheFor returns the catalog cache for a tenant and negotiated language, "" being the
+// default tenant and catalog
+func productCacheFor(tenant, lang string) *productCache {
+	if tenant == "" && lang == "" {
+		return productsCache
+	}
+	localizedCaches.mu.Lock()
+	defer localizedCaches.mu.Unlock()
+	key := catalogKey{tenant: tenant, lang: lang}
+	c, ok := localizedCaches.byKey[key]
+	if !ok {
+		c = &productCache{lang: lang, tenant: tenant}
+		localizedCaches.byKey[key] = c
+	}
+	return c
+}
+
+// invalidateLocalizedCaches drops every tenant's and localized catalog, alongside productsCache.invalidate.
+// The caches are invalidated after releasing localizedCaches.mu, as that also deletes from the shared
+// store and would otherwise hold up every productCacheFor.
+func invalidateLocalizedCaches() {
+	localizedCaches.mu.Lock()
+	caches := slices.Collect(maps.Values(localizedCaches.byKey))
+	localizedCaches.mu.Unlock()
+	for _, c := range caches {
+		c.invalidate()
+	}
+}
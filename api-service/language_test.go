@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestNegotiateLanguage tests matching Accept-Language ranges against PRODUCT_LANGUAGES
+func TestNegotiateLanguage(t *testing.T) {
+	supported := []string{"en", "de-CH", "fr"}
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"fr", "fr"},
+		{"FR", "fr"},
+		{"de", "de-CH"}, // A range matches more specific languages
+		{"fr-CA", "fr"}, // and falls back to its prefixes
+		{"ja, de;q=0.5, fr;q=0.8", "fr"},
+		{"fr;q=0, en;q=0.1", "en"}, // q=0 means not acceptable
+		{"*", "en"},
+		{"ja", ""},
+		{"en;q=2", ""}, // Malformed headers are ignored
+		{"en-", ""},
+		{"en\r\nX-Evil: 1", ""},
+		{strings.Repeat("en-US,", 50) + "fr", ""}, // Over the length cap
+	}
+	for _, tt := range tests {
+		if got := negotiateLanguage(tt.header, supported); got != tt.want {
+			t.Errorf("negotiateLanguage(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+	if got := negotiateLanguage("fr", nil); got != "" {
+		t.Errorf("negotiateLanguage without PRODUCT_LANGUAGES = %q, want none", got)
+	}
+}
+
+// newLanguageUpstream fakes a Dotnet service that localizes product names and records the
+// Accept-Language of each catalog request
+func newLanguageUpstream(t *testing.T) *[]string {
+	t.Helper()
+	var mu sync.Mutex
+	var seen []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lang := r.Header.Get("Accept-Language")
+		mu.Lock()
+		seen = append(seen, lang)
+		mu.Unlock()
+		name := "Mug"
+		if lang == "de" {
+			name = "Tasse"
+			w.Header().Set("Content-Language", "de")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode([]Product{{Id: "p1", Name: name, Stock: 1}})
+	}))
+	os.Setenv("DOTNET_PRODUCTS_API_URL", server.URL)
+	productsCache.invalidate()
+	invalidateLocalizedCaches()
+	t.Cleanup(func() {
+		server.Close()
+		os.Unsetenv("DOTNET_PRODUCTS_API_URL")
+		productsCache.invalidate()
+		invalidateLocalizedCaches()
+	})
+	return &seen
+}
+
+// TestProductsHandler_AcceptLanguage tests that the negotiated language reaches the upstream and its
+// Content-Language comes back, with each language cached separately
+func TestProductsHandler_AcceptLanguage(t *testing.T) {
+	seen := newLanguageUpstream(t)
+	os.Setenv("PRODUCT_LANGUAGES", "en,de")
+	defer os.Unsetenv("PRODUCT_LANGUAGES")
+
+	get := func(acceptLanguage string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/products", nil)
+		if acceptLanguage != "" {
+			req.Header.Set("Accept-Language", acceptLanguage)
+		}
+		rr := httptest.NewRecorder()
+		productsHandler(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+		return rr
+	}
+
+	rr := get("de-DE, en;q=0.5")
+	if !strings.Contains(rr.Body.String(), "Tasse") || rr.Header().Get("Content-Language") != "de" {
+		t.Errorf("German catalog = %s with Content-Language %q, want Tasse and de", rr.Body.String(), rr.Header().Get("Content-Language"))
+	}
+	if vary := rr.Header().Values("Vary"); !strings.Contains(strings.Join(vary, ","), "Accept-Language") {
+		t.Errorf("Vary = %v, want Accept-Language", vary)
+	}
+	rr = get("")
+	if !strings.Contains(rr.Body.String(), "Mug") || rr.Header().Get("Content-Language") != "" {
+		t.Errorf("default catalog = %s with Content-Language %q, want Mug and none", rr.Body.String(), rr.Header().Get("Content-Language"))
+	}
+	get("de") // Served from the German cache
+
+	if want := []string{"de", ""}; strings.Join(*seen, "|") != strings.Join(want, "|") {
+		t.Errorf("upstream Accept-Language = %q, want %q", *seen, want)
+	}
+}
+
+// TestProductsHandler_AcceptLanguageDisabled tests that without PRODUCT_LANGUAGES the header isn't forwarded
+func TestProductsHandler_AcceptLanguageDisabled(t *testing.T) {
+	seen := newLanguageUpstream(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/products", nil)
+	req.Header.Set("Accept-Language", "de")
+	rr := httptest.NewRecorder()
+	productsHandler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if len(*seen) != 1 || (*seen)[0] != "" {
+		t.Errorf("upstream Accept-Language = %q, want it not forwarded", *seen)
+	}
+}
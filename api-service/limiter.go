@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Defaults for order concurrency when MAX_CONCURRENT_ORDERS / ORDER_QUEUE_TIMEOUT are not set
+const (
+	defaultMaxConcurrentOrders = 50
+	defaultOrderQueueTimeout   = 250 * time.Millisecond
+)
+
+// concurrencyLimiter bounds how many calls run at once. The limit is passed to each acquire so it
+// can follow configuration changes without rebuilding the limiter.
+type concurrencyLimiter struct {
+	mu       sync.Mutex
+	inFlight int
+	freed    chan struct{} // Closed and replaced whenever a slot is released
+	onChange func(inFlight int)
+}
+
+// orderLimiters bound concurrent place-order calls to the Dotnet service, separately for each tenant
+// so one tenant's burst can't take every slot. The in-flight gauge counts all of them.
+var orderLimiters = newPerTenant(func(string) *concurrencyLimiter {
+	previous := 0
+	return newConcurrencyLimiter(func(n int) {
+		ordersInFlight.Add(float64(n - previous))
+		previous = n
+	})
+})
+
+// ordersInFlightTotal returns the number of orders being placed, over every tenant
+func ordersInFlightTotal() int {
+	total := 0
+	orderLimiters.each(func(_ string, l *concurrencyLimiter) { total += l.current() })
+	return total
+}
+
+func newConcurrencyLimiter(onChange func(inFlight int)) *concurrencyLimiter {
+	return &concurrencyLimiter{freed: make(chan struct{}), onChange: onChange}
+}
+
+// acquire takes a slot if fewer than limit are in use, waiting up to wait for one to free up.
+// A limit of zero or less means unlimited. It reports false if no slot was taken, in which case
+// release must not be called.
+func (l *concurrencyLimiter) acquire(ctx context.Context, limit int, wait time.Duration) bool {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		l.mu.Lock()
+		if limit <= 0 || l.inFlight < limit {
+			l.inFlight++
+			l.changedLocked()
+			l.mu.Unlock()
+			return true
+		}
+		freed := l.freed
+		l.mu.Unlock()
+
+		select {
+		case <-freed:
+		case <-timer.C:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// release gives back a slot taken by acquire and wakes any waiters
+func (l *concurrencyLimiter) release() {
+	l.mu.Lock()
+	l.inFlight--
+	l.changedLocked()
+	close(l.freed)
+	l.freed = make(chan struct{})
+	l.mu.Unlock()
+}
+
+// changedLocked reports the new in-flight count; l.mu must be held
+func (l *concurrencyLimiter) changedLocked() {
+	if l.onChange != nil {
+		l.onChange(l.inFlight)
+	}
+}
+
+// current returns the number of slots in use
+func (l *concurrencyLimiter) current() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight
+}
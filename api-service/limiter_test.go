@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// TestConcurrencyLimiter tests that acquire waits for a released slot and gives up after the timeout
+func TestConcurrencyLimiter(t *testing.T) {
+	var counts []int
+	l := newConcurrencyLimiter(func(n int) { counts = append(counts, n) })
+	ctx := context.Background()
+
+	if !l.acquire(ctx, 1, time.Millisecond) {
+		t.Fatal("first acquire failed")
+	}
+	if l.acquire(ctx, 1, 10*time.Millisecond) {
+		t.Fatal("acquire beyond the limit succeeded")
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		l.release()
+	}()
+	if !l.acquire(ctx, 1, time.Second) {
+		t.Fatal("acquire did not take the released slot")
+	}
+	l.release()
+
+	if got, want := fmt.Sprint(counts), "[1 0 1 0]"; got != want {
+		t.Errorf("in-flight counts = %v, want %v", got, want)
+	}
+	if !l.acquire(ctx, 0, 0) || !l.acquire(ctx, 0, 0) {
+		t.Error("acquire with no limit failed")
+	}
+}
+
+// TestOrderHandler_ConcurrencyLimit tests that orders beyond MAX_CONCURRENT_ORDERS get a 503
+func TestOrderHandler_ConcurrencyLimit(t *testing.T) {
+	received := make(chan struct{})
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		close(received)
+		<-unblock
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"success":true,"orderId":"order-1"}`))
+	}))
+	defer server.Close()
+	os.Setenv("DOTNET_PRODUCTS_API_URL", server.URL)
+	defer os.Unsetenv("DOTNET_PRODUCTS_API_URL")
+	os.Setenv("MAX_CONCURRENT_ORDERS", "1")
+	defer os.Unsetenv("MAX_CONCURRENT_ORDERS")
+	os.Setenv("ORDER_QUEUE_TIMEOUT", "20ms")
+	defer os.Unsetenv("ORDER_QUEUE_TIMEOUT")
+
+	first := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		orderHandler(first, postJSON(t, "/order", batchOrder("p1", "1 Main St")))
+		close(done)
+	}()
+	<-received
+	if got := testutil.ToFloat64(ordersInFlight); got != 1 {
+		t.Errorf("orders_in_flight = %v, want 1", got)
+	}
+
+	rr := httptest.NewRecorder()
+	orderHandler(rr, postJSON(t, "/order", batchOrder("p2", "2 Main St")))
+	if status := rr.Code; status != http.StatusServiceUnavailable {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusServiceUnavailable)
+	}
+	if !strings.Contains(rr.Body.String(), "Too many orders") {
+		t.Errorf("saturated order response = %q", rr.Body.String())
+	}
+
+	close(unblock)
+	<-done
+	if status := first.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code for the first order: got %v want %v", status, http.StatusOK)
+	}
+	if got := testutil.ToFloat64(ordersInFlight); got != 0 {
+		t.Errorf("orders_in_flight after completion = %v, want 0", got)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Defaults for the login lockout when LOGIN_MAX_ATTEMPTS / LOGIN_LOCKOUT_DURATION are not set
+const (
+	defaultLoginMaxAttempts     = 5
+	defaultLoginLockoutDuration = 15 * time.Minute
+)
+
+// LockedResponse is returned with 423 Locked while a username/IP pair is locked out
+type LockedResponse struct {
+	Error             string `json:"error"`
+	RetryAfterSeconds int    `json:"retryAfterSeconds"`
+}
+
+// loginAttempts counts failures for one lockout key
+type loginAttempts struct {
+	failures    int
+	windowEnds  time.Time // Failures are forgotten after this unless the key got locked
+	lockedUntil time.Time
+}
+
+// loginLockout locks a key out after too many failed logins within the lockout duration
+type loginLockout struct {
+	mu    sync.Mutex
+	byKey map[string]*loginAttempts
+	clock Clock
+}
+
+// lockouts is the process-wide lockout state used by authHandler
+var lockouts = newLoginLockout(realClock{})
+
+func newLoginLockout(clock Clock) *loginLockout {
+	return &loginLockout{
+		byKey: make(map[string]*loginAttempts),
+		clock: clock,
+	}
+}
+
+// locked reports whether key is locked out and for how much longer
+func (l *loginLockout) locked(key string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.clock.Now()
+	l.pruneLocked(now)
+	a, ok := l.byKey[key]
+	if !ok || !now.Before(a.lockedUntil) {
+		return 0, false
+	}
+	return a.lockedUntil.Sub(now), true
+}
+
+// fail records a failed login for key. Once maxAttempts failures land within duration the key is
+// locked for duration, and fail reports how long the lock lasts.
+func (l *loginLockout) fail(key string, maxAttempts int, duration time.Duration) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.clock.Now()
+	l.pruneLocked(now)
+	a, ok := l.byKey[key]
+	if !ok {
+		a = &loginAttempts{windowEnds: now.Add(duration)}
+		l.byKey[key] = a
+	}
+	a.failures++
+	if a.failures < maxAttempts {
+		return 0, false
+	}
+	a.lockedUntil = now.Add(duration)
+	return duration, true
+}
+
+// reset forgets the failures for key, e.g. after a successful login
+func (l *loginLockout) reset(key string) {
+	l.mu.Lock()
+	delete(l.byKey, key)
+	l.mu.Unlock()
+}
+
+// pruneLocked drops keys whose lock or failure window has expired; l.mu must be held
+func (l *loginLockout) pruneLocked(now time.Time) {
+	for key, a := range l.byKey {
+		expires := a.windowEnds
+		if !a.lockedUntil.IsZero() {
+			expires = a.lockedUntil
+		}
+		if !now.Before(expires) {
+			delete(l.byKey, key)
+		}
+	}
+}
+
+// lockoutKey identifies the username/client IP pair a login attempt is counted against
+func lockoutKey(username string, r *http.Request) string {
+	return username + "|" + clientIP(r)
+}
+
+// writeLocked responds with 423 Locked and how long the client should wait before retrying
+func writeLocked(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	if prefersPlainText(r.Header.Get("Accept")) {
+		writePlainError(w, http.StatusLocked, "account locked")
+		return
+	}
+	writeJSON(w, r, http.StatusLocked, LockedResponse{Error: "account locked", RetryAfterSeconds: seconds})
+}
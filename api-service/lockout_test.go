@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// useFakeLockoutClock swaps in a fresh lockout store whose clock the test controls
+func useFakeLockoutClock(t *testing.T) *fakeClock {
+	t.Helper()
+	c := newFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	orig := lockouts
+	lockouts = newLoginLockout(c)
+	t.Cleanup(func() { lockouts = orig })
+	return c
+}
+
+// login posts credentials to authHandler and returns the recorder
+func login(t *testing.T, username, passkey string) *httptest.ResponseRecorder {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	authHandler(rr, postJSON(t, "/auth", LoginRequest{Username: username, Passkey: passkey}))
+	return rr
+}
+
+// TestAuthHandler_Lockout tests that repeated failures lock the user out with 423 until the lock expires
+func TestAuthHandler_Lockout(t *testing.T) {
+	c := useFakeLockoutClock(t)
+	os.Setenv("AUTH_PASSKEY", "testpasskey")
+	defer os.Unsetenv("AUTH_PASSKEY")
+	os.Setenv("LOGIN_MAX_ATTEMPTS", "3")
+	defer os.Unsetenv("LOGIN_MAX_ATTEMPTS")
+	os.Setenv("LOGIN_LOCKOUT_DURATION", "1m")
+	defer os.Unsetenv("LOGIN_LOCKOUT_DURATION")
+
+	for i := 0; i < 2; i++ {
+		if rr := login(t, "alice", "wrong"); rr.Code != http.StatusOK {
+			t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+	}
+
+	rr := login(t, "alice", "wrong")
+	if rr.Code != http.StatusLocked {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusLocked)
+	}
+	var locked LockedResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &locked); err != nil {
+		t.Fatalf("could not decode locked response: %v", err)
+	}
+	if locked.Error != "account locked" || locked.RetryAfterSeconds != 60 {
+		t.Errorf("locked response = %+v, want account locked for 60s", locked)
+	}
+	if got := rr.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("Retry-After = %q, want 60", got)
+	}
+
+	// The correct passkey is refused while locked
+	c.Advance(30 * time.Second)
+	rr = login(t, "alice", "testpasskey")
+	if rr.Code != http.StatusLocked {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusLocked)
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &locked); err != nil || locked.RetryAfterSeconds != 30 {
+		t.Errorf("retryAfterSeconds = %d, want 30", locked.RetryAfterSeconds)
+	}
+
+	// Other usernames from the same IP are not affected
+	if rr := login(t, "bob", "testpasskey"); rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	// The lock lifts once it expires
+	c.Advance(30 * time.Second)
+	var resp LoginResponse
+	rr = login(t, "alice", "testpasskey")
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || rr.Code != http.StatusOK || !resp.Success {
+		t.Errorf("login after lock expiry: status %d, body %s", rr.Code, rr.Body.String())
+	}
+}
+
+// TestAuthHandler_LockoutResetOnSuccess tests that a successful login clears earlier failures
+func TestAuthHandler_LockoutResetOnSuccess(t *testing.T) {
+	useFakeLockoutClock(t)
+	os.Setenv("AUTH_PASSKEY", "testpasskey")
+	defer os.Unsetenv("AUTH_PASSKEY")
+	os.Setenv("LOGIN_MAX_ATTEMPTS", "2")
+	defer os.Unsetenv("LOGIN_MAX_ATTEMPTS")
+
+	login(t, "alice", "wrong")
+	login(t, "alice", "testpasskey")
+	if rr := login(t, "alice", "wrong"); rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+}
+
+// TestLoginLockout_FailureWindow tests that failures older than the lockout duration are forgotten
+func TestLoginLockout_FailureWindow(t *testing.T) {
+	c := newFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	l := newLoginLockout(c)
+
+	l.fail("alice|192.0.2.1", 2, time.Minute)
+	c.Advance(time.Minute)
+	if _, locked := l.fail("alice|192.0.2.1", 2, time.Minute); locked {
+		t.Errorf("failure outside the window locked the key")
+	}
+	if _, locked := l.fail("alice|192.0.2.1", 2, time.Minute); !locked {
+		t.Errorf("second failure inside the window did not lock the key")
+	}
+}
+
+// TestLockoutKey tests that attempts are keyed by username and client IP without the port
+func TestLockoutKey(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/auth", nil)
+	r.RemoteAddr = "203.0.113.7:5123"
+	if got := lockoutKey("alice", r); got != "alice|203.0.113.7" {
+		t.Errorf("lockoutKey = %q, want alice|203.0.113.7", got)
+	}
+}
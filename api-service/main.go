@@ -1,66 +1,38 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
-	"time"
+	"os/signal"
+	"strings"
+	"syscall"
 )
 
 // LoginRequest represents the structure of the incoming JSON request for login
 type LoginRequest struct {
-	Passkey string `json:"passkey"`
+	Username string `json:"username,omitempty"` // Required by the users-file auth backend
+	Passkey  string `json:"passkey"`
 }
 
 // LoginResponse represents the structure of the JSON response for login
 type LoginResponse struct {
 	Success bool   `json:"success"`
 	Message string `json:"message,omitempty"`
-}
-
-// Product struct to match the structure of products from the Dotnet service (now includes Stock)
-type Product struct {
-	Id          string  `json:"id"`
-	Name        string  `json:"name"`
-	Price       float64 `json:"price"`
-	ImageUrl    string  `json:"imageUrl"`
-	Description string  `json:"description"`
-	Stock       int     `json:"stock"` // New: Stock quantity
-}
-
-// OrderItemRequest from React app
-type OrderItemRequest struct {
-	Id       string  `json:"id"`
-	Name     string  `json:"name"`
-	Quantity int     `json:"quantity"`
-	Price    float64 `json:"price"`
-}
-
-// PlaceOrderRequest from React app to Go
-type PlaceOrderRequest struct {
-	Items           []OrderItemRequest `json:"items"`
-	TotalAmount     float64            `json:"totalAmount"`
-	DeliveryAddress string             `json:"deliveryAddress"`
-	OrderDate       string             `json:"orderDate"`
-}
-
-// PlaceOrderResponse from Dotnet to Go, and then Go to React
-type PlaceOrderResponse struct {
-	Success         bool     `json:"success"`
-	Message         string   `json:"message,omitempty"`
-	OrderId         string   `json:"orderId,omitempty"`
-	OutOfStockItems []string `json:"outOfStockItems,omitempty"` // New: List of items that caused failure
+	Token   string `json:"token,omitempty"` // Bearer token for requireAuth, when AUTH_ISSUE_TOKENS=true
 }
 
 // authHandler handles authentication requests
 func authHandler(w http.ResponseWriter, r *http.Request) {
 	// Set CORS headers to allow requests from any origin
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	applyCORS(w, r, "POST, OPTIONS", "Content-Type")
 
 	// Handle preflight OPTIONS request
 	if r.Method == http.MethodOptions {
@@ -70,46 +42,75 @@ func authHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Only allow POST requests
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(w, r, http.MethodPost, http.MethodOptions)
 		return
 	}
 
 	// Decode the JSON request body
 	var req LoginRequest
-	err := json.NewDecoder(r.Body).Decode(&req)
-	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeJSON(r, &req, "Invalid request body"); err != nil {
+		writeRequestError(w, r, err)
 		return
 	}
 
-	// Get the configured passkey from an environment variable
-	configuredPasskey := os.Getenv("AUTH_PASSKEY")
-	if configuredPasskey == "" {
-		log.Println("AUTH_PASSKEY environment variable is not set. Using default '12345'.")
-		configuredPasskey = "12345" // Fallback for development if not set
+	// Refuse attempts while this username/IP pair is locked out, without checking the passkey
+	key := lockoutKey(req.Username, r)
+	if retryAfter, locked := lockouts.locked(key); locked {
+		log.Printf("Login attempt for user '%s': LOCKED", req.Username)
+		writeLocked(w, r, retryAfter)
+		return
 	}
 
-	// Compare the provided passkey with the configured passkey
+	// Verify the credentials with the configured auth backend
 	var resp LoginResponse
-	if req.Passkey == configuredPasskey {
+	identity, err := authenticator.Authenticate(r.Context(), Credentials{Username: req.Username, Passkey: req.Passkey})
+	switch {
+	case err == nil:
+		lockouts.reset(key)
 		resp = LoginResponse{Success: true, Message: "Authentication successful"}
-		log.Printf("Login attempt for passkey '%s': SUCCESS", req.Passkey)
-	} else {
+		if tokensEnabled() {
+			token, err := issueToken(identity, jwtSecret(), envDuration("JWT_TTL", defaultTokenTTL), clock.Now())
+			if err != nil {
+				log.Printf("Error issuing token for user '%s': %v", req.Username, err)
+				writeError(w, r, http.StatusInternalServerError, "Internal server error")
+				return
+			}
+			resp.Token = token
+		}
+		log.Printf("Login attempt for user '%s': SUCCESS", req.Username)
+	case errors.Is(err, errInvalidCredentials):
 		resp = LoginResponse{Success: false, Message: "Invalid passkey"}
-		log.Printf("Login attempt for passkey '%s': FAILED (Incorrect passkey)", req.Passkey)
+		log.Printf("Login attempt for user '%s': FAILED (Incorrect passkey)", req.Username)
+
+		maxAttempts := envInt("LOGIN_MAX_ATTEMPTS", defaultLoginMaxAttempts)
+		if maxAttempts > 0 {
+			lockFor := envDuration("LOGIN_LOCKOUT_DURATION", defaultLoginLockoutDuration)
+			if retryAfter, locked := lockouts.fail(key, maxAttempts, lockFor); locked {
+				log.Printf("Locking out user '%s' for %s after %d failed attempts", req.Username, lockFor, maxAttempts)
+				writeLocked(w, r, retryAfter)
+				return
+			}
+		}
+
+		// Slow down online guessing; give up quietly if the client goes away while we wait
+		if d := envDuration("LOGIN_FAIL_DELAY", 0); d > 0 {
+			if err := loginFailSleep(r.Context(), d); err != nil {
+				return
+			}
+		}
+	default:
+		log.Printf("Error authenticating user '%s': %v", req.Username, err)
+		writeError(w, r, http.StatusInternalServerError, "Internal server error")
+		return
 	}
 
-	// Set content type and encode response as JSON
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	writeJSON(w, r, http.StatusOK, resp)
 }
 
 // productsHandler fetches, decodes, re-encodes, and responds with products
 func productsHandler(w http.ResponseWriter, r *http.Request) {
 	// Set CORS headers for any origin
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	applyCORS(w, r, "GET, HEAD, OPTIONS", "Content-Type, Authorization, X-API-Key, X-Tenant-ID")
 
 	// Handle preflight OPTIONS request
 	if r.Method == http.MethodOptions {
@@ -117,59 +118,150 @@ func productsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	// HEAD runs the same lookup and sets the same headers as GET, only without the body
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		methodNotAllowed(w, r, http.MethodGet, http.MethodHead, http.MethodOptions)
 		return
 	}
 
-	dotnetProductsApiURL := os.Getenv("DOTNET_PRODUCTS_API_URL")
-	if dotnetProductsApiURL == "" {
-		log.Println("DOTNET_PRODUCTS_API_URL environment variable is not set. Using default 'http://localhost:8080'.")
-		dotnetProductsApiURL = "http://localhost:8080" // Default for development
+	filter, err := parseProductFilter(r.URL.Query())
+	if err != nil {
+		writeRequestError(w, r, err)
+		return
+	}
+	fields, err := parseProductFields(r.URL.Query())
+	if err != nil {
+		writeRequestError(w, r, err)
+		return
 	}
 
-	// Construct the full URL for the Dotnet service
-	targetURL := fmt.Sprintf("%s/all-products", dotnetProductsApiURL)
-	log.Printf("Fetching products from Dotnet Products Service: %s", targetURL)
-
-	// Create an HTTP client with a timeout
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(targetURL)
+	// Serve the catalog from the cache, refetching from the Dotnet service when it has expired.
+	// During maintenance any cached copy is served as-is rather than refetched, and when the
+	// Dotnet service is down a recently expired copy is served marked as stale.
+	// With PRODUCT_LANGUAGES set the catalog is fetched and cached per negotiated language.
+	languages := productLanguages()
+	cache := productCacheFor(tenantFrom(r.Context()), negotiateLanguage(r.Header.Get("Accept-Language"), languages))
+	entry, stale := cache.cached(), false
+	if entry == nil || !maintenanceMode.Load() {
+		entry, stale, err = cache.lookup(
+			r.Context(),
+			envDuration("PRODUCTS_CACHE_TTL", defaultProductsCacheTTL),
+			envDuration("PRODUCTS_STALE_MAX", defaultProductsStaleMax),
+		)
+	}
 	if err != nil {
-		log.Printf("Error fetching products from Dotnet service: %v", err)
-		http.Error(w, "Failed to fetch products from backend service", http.StatusBadGateway)
+		log.Printf("An error occured loading products: %v", err)
+		writeUpstreamError(w, r, err)
 		return
 	}
-	defer resp.Body.Close()
+	if stale {
+		w.Header().Set("Warning", `110 - "Response is Stale"`)
+		w.Header().Set("X-Cache", "STALE")
+	}
+	if len(languages) > 0 {
+		w.Header().Add("Vary", "Accept-Language")
+	}
+	if entry.ContentLanguage != "" {
+		w.Header().Set("Content-Language", entry.ContentLanguage)
+	}
+
+	// The body is either a JSON array or NDJSON depending on Accept, and each needs its own ETag, as
+	// do the sanitized copy, a MAX_PRODUCTS_RETURNED that truncates, each DEFAULT_PRODUCT_IMAGE and
+	// snake_case keys so changing them invalidates what clients have cached
+	ndjson := wantsNDJSON(r)
+	sanitize := outputSanitizer()
+	etag := entry.ETag
+	if ndjson {
+		etag = strings.TrimSuffix(etag, `"`) + `-ndjson"`
+	}
+	if sanitize != nil {
+		etag = strings.TrimSuffix(etag, `"`) + `-sanitized"`
+	}
+	maxProducts := envInt("MAX_PRODUCTS_RETURNED", 0)
+	if maxProducts > 0 && len(entry.Products) > maxProducts {
+		etag = strings.TrimSuffix(etag, `"`) + fmt.Sprintf(`-max%d"`, maxProducts)
+	}
+	defaultImage := os.Getenv("DEFAULT_PRODUCT_IMAGE")
+	if defaultImage != "" {
+		sum := sha256.Sum256([]byte(defaultImage))
+		etag = strings.TrimSuffix(etag, `"`) + `-img` + hex.EncodeToString(sum[:4]) + `"`
+	}
+	if snakeCaseResponses() {
+		etag = strings.TrimSuffix(etag, `"`) + `-snake"`
+	}
 
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("An error occured: Dotnet service returned non-OK status: %d", resp.StatusCode)
-		http.Error(w, fmt.Sprintf("Backend service error: %d", resp.StatusCode), http.StatusBadGateway)
+	// Let clients revalidate their copy instead of downloading the catalog again.
+	// If-Modified-Since is only consulted when the client did not send If-None-Match.
+	// A 304 carries the same caching headers as the 200, so caches refresh their stored copy's.
+	w.Header().Add("Vary", "Accept")
+	if os.Getenv("TENANT_UPSTREAMS_FILE") != "" {
+		w.Header().Add("Vary", "X-Tenant-ID")
+	}
+	w.Header().Set("Cache-Control", productsCacheControl(stale))
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", entry.LastModified.UTC().Format(http.TimeFormat))
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if etagMatches(inm, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	} else if notModifiedSince(r.Header.Get("If-Modified-Since"), entry.LastModified) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
-	// Decode the JSON response from the Dotnet service
-	var products []Product
-	err = json.NewDecoder(resp.Body).Decode(&products)
-	if err != nil {
-		log.Printf("Error decoding products from Dotnet service: %v", err)
-		http.Error(w, "Failed to parse products data from backend", http.StatusInternalServerError)
+	products := entry.Products
+	if filter.active() {
+		products = filter.apply(products)
+	}
+	// Cap what one response carries so an accidentally huge catalog can't overwhelm clients
+	if maxProducts > 0 && len(products) > maxProducts {
+		products = products[:maxProducts]
+		w.Header().Set("X-Truncated", "true")
+	}
+	// Filters match the raw text; only what is sent is sanitized
+	if sanitize != nil {
+		products = sanitizeProducts(products, sanitize)
+	}
+
+	// Add the computed fields the frontend shows alongside each product, and DEFAULT_PRODUCT_IMAGE
+	// for products without an image so the frontend doesn't show a broken one
+	response := productResponses(products, envInt("LOW_STOCK_THRESHOLD", defaultLowStockThreshold), defaultImage)
+
+	if r.Method == http.MethodHead {
+		contentType := "application/json"
+		if ndjson {
+			contentType = "application/x-ndjson"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusOK)
 		return
 	}
 
-	// Re-encode the products slice as JSON and write to the response
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(products); err != nil {
-		log.Printf("Error encoding products for response: %v", err)
+	// Stream one product per line for clients that asked for NDJSON, projected to ?fields= if given
+	if ndjson {
+		if fields != nil {
+			err = writeProductsNDJSON(w, projectProducts(response, fields))
+		} else {
+			err = writeProductsNDJSON(w, response)
+		}
+		if err != nil {
+			log.Printf("Error streaming products response: %v", err)
+		}
+		return
 	}
+
+	if fields != nil {
+		writeData(w, r, http.StatusOK, projectProducts(response, fields))
+		return
+	}
+	writeData(w, r, http.StatusOK, response)
 }
 
 // orderHandler proxies and processes order requests to the Dotnet products-service
 func orderHandler(w http.ResponseWriter, r *http.Request) {
 	// Set CORS headers for any origin
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	applyCORS(w, r, "POST, OPTIONS", "Content-Type, Authorization, X-API-Key, X-Tenant-ID")
 
 	// Handle preflight OPTIONS request
 	if r.Method == http.MethodOptions {
@@ -178,86 +270,122 @@ func orderHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(w, r, http.MethodPost, http.MethodOptions)
 		return
 	}
 
-	dotnetProductsApiURL := os.Getenv("DOTNET_PRODUCTS_API_URL")
-	if dotnetProductsApiURL == "" {
-		log.Println("DOTNET_PRODUCTS_API_URL environment variable is not set. Using default 'http://localhost:8080'.")
-		dotnetProductsApiURL = "http://localhost:8080" // Default for development
+	if rejectDuringMaintenance(w, r) {
+		return
 	}
 
-	// Construct the full URL for the Dotnet service's place-order endpoint
-	targetURL := fmt.Sprintf("%s/place-order", dotnetProductsApiURL)
-	log.Printf("Proxying order request to Dotnet Products Service: %s", targetURL)
-
 	// Decode the incoming order request from React
 	var orderRequest PlaceOrderRequest
-	err := json.NewDecoder(r.Body).Decode(&orderRequest)
-	if err != nil {
+	if err := decodeOrderRequest(r, &orderRequest); err != nil {
 		log.Printf("Error decoding order request from client: %v", err)
-		http.Error(w, "Invalid order request body", http.StatusBadRequest)
+		writeRequestError(w, r, err)
 		return
 	}
 
-	// Re-encode the order request to send to Dotnet service
-	requestBodyBytes, err := json.Marshal(orderRequest)
+	// The request context carries the trace for the upstream call and ends it if the client goes away.
+	// A slow order gets interim 102 Processing responses so the client knows it is still being worked on.
+	status, orderResponse, err := placeOrderWithProgress(w, r, orderRequest)
 	if err != nil {
-		log.Printf("Error marshalling order request for Dotnet: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		var upErr *upstreamError
+		if errors.As(err, &upErr) {
+			log.Printf("Error placing order: %v", err)
+			writeUpstreamError(w, r, err)
+			return
+		}
+		writeRequestError(w, r, err)
 		return
 	}
 
-	// Create a new HTTP POST request to the Dotnet service
-	client := &http.Client{Timeout: 10 * time.Second}
-	proxyReq, err := http.NewRequest("POST", targetURL, bytes.NewBuffer(requestBodyBytes))
-	if err != nil {
-		log.Printf("Error creating proxy order request: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+	// Re-encode the Dotnet response and send it back to React, passing through the status code from Dotnet
+	writeData(w, r, status, orderResponse)
+}
+
+func main() {
+	// Fill in settings from CONFIG_FILE that the environment leaves unset, before anything reads them
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		set, err := applyConfigFile(path)
+		if err != nil {
+			log.Fatalf("Invalid CONFIG_FILE: %v", err)
+		}
+		log.Printf("Loaded %d setting(s) from %s: %s", len(set), path, strings.Join(set, ", "))
 	}
-	proxyReq.Header.Set("Content-Type", "application/json") // Ensure JSON content type for Dotnet
 
-	// Perform the request to Dotnet
-	proxyResp, err := client.Do(proxyReq)
+	// Compile the order contract once so a broken schema stops startup rather than every order
+	if path := os.Getenv("ORDER_SCHEMA_FILE"); path != "" {
+		schema, err := loadSchemaFile(path)
+		if err != nil {
+			log.Fatalf("Invalid ORDER_SCHEMA_FILE: %v", err)
+		}
+		orderSchema = schema
+		log.Printf("Validating orders against the schema in %s", path)
+	}
+
+	// Choose the auth backend
+	var err error
+	authenticator, err = newAuthenticatorFromEnv()
 	if err != nil {
-		log.Printf("Error placing order with Dotnet service: %v", err)
-		http.Error(w, "Failed to place order with backend service", http.StatusBadGateway)
-		return
+		log.Fatalf("Invalid auth configuration: %v", err)
+	}
+	if err := checkTokenConfig(); err != nil {
+		log.Fatalf("Invalid auth configuration: %v", err)
 	}
-	defer proxyResp.Body.Close()
 
-	if code := proxyResp.StatusCode; code != http.StatusOK {
-		log.Printf("An error occured: Dotnet service returned non-OK status: %d", code)
+	// Refuse to start with a TRUSTED_PROXIES list that would silently trust nothing
+	if _, err := parseTrustedProxies(os.Getenv("TRUSTED_PROXIES")); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
 	}
 
-	// Decode the response from the Dotnet service
-	var orderResponse PlaceOrderResponse
-	err = json.NewDecoder(proxyResp.Body).Decode(&orderResponse)
-	if err != nil {
-		log.Printf("Error decoding order response from Dotnet service: %v", err)
-		http.Error(w, "Failed to parse order response from backend", http.StatusInternalServerError)
-		return
+	// Never forward credentials to the Dotnet service, even when listed
+	if _, err := parseForwardHeaders(os.Getenv("FORWARD_HEADERS")); err != nil {
+		log.Fatalf("Invalid FORWARD_HEADERS: %v", err)
 	}
 
-	// --- This is where you can add logic to modify the 'orderResponse' if needed ---
-	// For now, we just re-encode it as is.
-	// --------------------------------------------------------------------------------
+	// Catch a broken TENANT_UPSTREAMS_FILE now rather than failing every shop request
+	if path := os.Getenv("TENANT_UPSTREAMS_FILE"); path != "" {
+		tenants, err := tenantUpstreams.get(path)
+		if err != nil {
+			log.Fatalf("Invalid TENANT_UPSTREAMS_FILE: %v", err)
+		}
+		log.Printf("Routing shop requests for %d tenants from %s", len(tenants), path)
+	}
 
-	// Re-encode the Dotnet response and send it back to React
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(proxyResp.StatusCode) // Pass through the status code from Dotnet
-	if err := json.NewEncoder(w).Encode(orderResponse); err != nil {
-		log.Printf("Error encoding order response for client: %v", err)
+	// Never start with a CORS setup that would send "*" with credentials
+	if err := checkCORSConfig(); err != nil {
+		log.Fatalf("Invalid CORS configuration: %v", err)
 	}
-}
 
-func main() {
-	// Register the handlers
-	http.HandleFunc("/auth", authHandler)
-	http.HandleFunc("/products", productsHandler)
-	http.HandleFunc("/order", orderHandler) // New endpoint for order processing
+	// Chaos mode is for resilience testing only and is refused under STRICT_CONFIG
+	if _, err := chaosConfigFromEnv(); err != nil {
+		log.Fatalf("Invalid chaos configuration: %v", err)
+	}
+
+	// Load feature flags so a malformed FEATURES_FILE fails fast
+	if err := features.reload(); err != nil {
+		log.Fatalf("Invalid feature flags: %v", err)
+	}
+
+	// Share the products cache and order nonces between replicas when CACHE_BACKEND=redis
+	store, err := newStoreFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid cache backend configuration: %v", err)
+	}
+	if store != nil {
+		sharedStore = store
+		orderNonces = &nonceStore{store: store, fallback: orderNonces.store}
+	}
+
+	// Open the access log; SIGHUP reopens it so logrotate can move the file away
+	if err := accessLog.open(os.Getenv("ACCESS_LOG_FILE")); err != nil {
+		log.Fatalf("Failed to open ACCESS_LOG_FILE: %v", err)
+	}
+	go reopenOnSIGHUP(accessLog)
+
+	// Record what this process is actually running with, secrets redacted
+	loadConfig().LogRedacted(slog.Default())
 
 	// Define the port to listen on
 	port := "8080" // Default port for the Go app
@@ -267,9 +395,30 @@ func main() {
 
 	fmt.Printf("Go authentication, products and order processing proxy service listening on :%s\n", port)
 	log.Printf("Go authentication, products and order processing proxy service starting on port %s", port)
-	// Start the HTTP server
-	err := http.ListenAndServe(":"+port, nil)
+	// Export traces when an OTLP endpoint is configured
+	shutdownTracing, err := setupTracing(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	// Start the HTTP server, draining gracefully on SIGTERM or SIGINT
+	ln, err := net.Listen("tcp", ":"+port)
 	if err != nil {
 		log.Fatalf("Server failed to start: %v", err)
 	}
+	servers := []boundServer{{Server: &http.Server{Handler: routes()}, Listener: ln}}
+	if port := adminPort(); port != "" {
+		adminLn, err := net.Listen("tcp", ":"+port)
+		if err != nil {
+			log.Fatalf("Admin server failed to start: %v", err)
+		}
+		servers = append(servers, boundServer{Server: &http.Server{Handler: adminRoutes()}, Listener: adminLn})
+		log.Printf("Serving /metrics, /admin/*, /status and /debug/pprof/* on admin port %s only", port)
+	}
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+	if err := serve(stop, servers...); err != nil {
+		log.Fatalf("Server failed: %v", err)
+	}
 }
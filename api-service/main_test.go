@@ -9,6 +9,14 @@ import (
 	"testing"
 )
 
+// TestMain lets tests reach the fake upstreams that httptest serves on loopback
+func TestMain(m *testing.M) {
+	os.Setenv("ALLOW_PRIVATE_UPSTREAM", "true")
+	// Most order tests fake only the place-order endpoint; price verification tests turn this back off
+	os.Setenv("TRUST_CLIENT_PRICES", "true")
+	os.Exit(m.Run())
+}
+
 // TestAuthHandler_Success tests successful authentication
 func TestAuthHandler_Success(t *testing.T) {
 	// Set a test passkey environment variable
@@ -123,6 +131,39 @@ func TestAuthHandler_InvalidJSON(t *testing.T) {
 	}
 }
 
+// TestOrderHandler_DecodeErrorMessages tests that malformed bodies report where and why decoding failed
+func TestOrderHandler_DecodeErrorMessages(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"syntax error", `{"items": [{"id": "p1",}]}`, "Invalid order request body: invalid JSON at offset 24"},
+		{"wrong field type", `{"deliveryAddress": 42}`, `Invalid order request body: field "deliveryAddress" expected string, got number`},
+		{"wrong top-level type", `[]`, "Invalid order request body: expected object, got array"},
+		{"truncated", `{"items": [`, "Invalid order request body: unexpected end of JSON input"},
+		{"empty", ``, "Invalid order request body: request body is empty"},
+		{"invalid price", `{"totalAmount": "ten"}`, `Invalid order request body: price "ten" is not a number`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/order", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+
+			orderHandler(rr, req)
+
+			if status := rr.Code; status != http.StatusBadRequest {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+			}
+			var response ErrorResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil || response.Error != tt.want {
+				t.Errorf("error = %q, want %q", response.Error, tt.want)
+			}
+		})
+	}
+}
+
 // TestAuthHandler_OptionsMethod tests handling of OPTIONS preflight request
 func TestAuthHandler_OptionsMethod(t *testing.T) {
 	req := httptest.NewRequest(http.MethodOptions, "/auth", nil)
@@ -135,3 +176,89 @@ func TestAuthHandler_OptionsMethod(t *testing.T) {
 			status, http.StatusOK)
 	}
 }
+
+// TestAuthHandler_UnsupportedMediaType tests that non-JSON content types are rejected with 415
+func TestAuthHandler_UnsupportedMediaType(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/auth", bytes.NewBufferString("passkey=testpasskey"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+
+	authHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusUnsupportedMediaType {
+		t.Errorf("handler returned wrong status code for form post: got %v want %v",
+			status, http.StatusUnsupportedMediaType)
+	}
+	var response ErrorResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil || response.Error == "" {
+		t.Errorf("handler returned non-JSON error body: %q", rr.Body.String())
+	}
+}
+
+// TestAuthHandler_JSONWithCharset tests that a charset parameter on the content type is accepted
+func TestAuthHandler_JSONWithCharset(t *testing.T) {
+	os.Setenv("AUTH_PASSKEY", "testpasskey")
+	defer os.Unsetenv("AUTH_PASSKEY")
+
+	reqBody, _ := json.Marshal(LoginRequest{Passkey: "testpasskey"})
+	req := httptest.NewRequest(http.MethodPost, "/auth", bytes.NewBuffer(reqBody))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	rr := httptest.NewRecorder()
+
+	authHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			status, http.StatusOK)
+	}
+}
+
+// TestOrderHandler_UnsupportedMediaType tests that orders without a JSON content type are rejected with 415
+func TestOrderHandler_UnsupportedMediaType(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/order", bytes.NewBufferString(`{"items":[]}`))
+	req.Header.Set("Content-Type", "text/plain")
+	rr := httptest.NewRecorder()
+
+	orderHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusUnsupportedMediaType {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			status, http.StatusUnsupportedMediaType)
+	}
+}
+
+// TestHandlers_MethodNotAllowed tests that each handler's 405 sets Allow and returns a JSON error
+func TestHandlers_MethodNotAllowed(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		allow   string
+	}{
+		{"auth", authHandler, http.MethodGet, "POST, OPTIONS"},
+		{"products", productsHandler, http.MethodPost, "GET, HEAD, OPTIONS"},
+		{"order", orderHandler, http.MethodGet, "POST, OPTIONS"},
+		{"orders batch", batchOrderHandler, http.MethodPut, "POST, OPTIONS"},
+		{"cart reserve", reserveHandler, http.MethodGet, "POST, OPTIONS"},
+		{"categories", categoriesHandler, http.MethodDelete, "GET, OPTIONS"},
+		{"export", exportHandler, http.MethodPost, "GET, OPTIONS"},
+		{"image", productImageHandler, http.MethodPost, "GET, OPTIONS"},
+		{"healthz", healthzHandler, http.MethodPost, "GET, HEAD"},
+		{"maintenance", maintenanceHandler, http.MethodDelete, "GET, POST"},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		tt.handler(rr, httptest.NewRequest(tt.method, "/", nil))
+
+		if rr.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", tt.name, rr.Code, http.StatusMethodNotAllowed)
+		}
+		if got := rr.Header().Get("Allow"); got != tt.allow {
+			t.Errorf("%s: Allow = %q, want %q", tt.name, got, tt.allow)
+		}
+		var body ErrorResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body.Error != "Method not allowed" {
+			t.Errorf("%s: body = %q, want a JSON method not allowed error", tt.name, rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metricsRegistry holds every metric exported at /metrics
+var metricsRegistry = prometheus.NewRegistry()
+
+// sizeBuckets spans 100 B to about 1.6 MB, covering single orders up to the full catalog
+var sizeBuckets = prometheus.ExponentialBuckets(100, 4, 8)
+
+// httpMetrics are the per-handler request metrics recorded by its middleware
+type httpMetrics struct {
+	requests      *prometheus.CounterVec
+	duration      *prometheus.HistogramVec
+	requestBytes  *prometheus.HistogramVec
+	responseBytes *prometheus.HistogramVec
+}
+
+// serverMetrics are the HTTP metrics for this process
+var serverMetrics = newHTTPMetrics(metricsRegistry)
+
+// ordersInFlight is the number of orders currently being placed with the Dotnet service
+var ordersInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "orders_in_flight",
+	Help: "Orders currently being placed with the Dotnet service, bounded by MAX_CONCURRENT_ORDERS.",
+})
+
+// ordersQueued is the number of orders waiting in ORDER_QUEUE_SIZE queues for a free order slot
+var ordersQueued = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "orders_queue_depth",
+	Help: "Orders waiting in the order queue for a free slot, bounded by ORDER_QUEUE_SIZE.",
+})
+
+// Products cache lookups, for tuning PRODUCTS_CACHE_TTL
+var (
+	productsCacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "products_cache_hits_total",
+		Help: "Catalog lookups answered from a fresh cached catalog.",
+	})
+	productsCacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "products_cache_misses_total",
+		Help: "Catalog lookups that found no fresh cached catalog and refetched it from the Dotnet service.",
+	})
+	productsCacheStale = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "products_cache_stale_total",
+		Help: "Misses answered with an expired catalog because the refetch failed, bounded by PRODUCTS_STALE_MAX.",
+	})
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		ordersInFlight,
+		ordersQueued,
+		productsCacheHits,
+		productsCacheMisses,
+		productsCacheStale,
+	)
+}
+
+// newHTTPMetrics creates the HTTP metrics and registers them with reg
+func newHTTPMetrics(reg prometheus.Registerer) *httpMetrics {
+	m := &httpMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests by handler and status code.",
+		}, []string{"handler", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Time to serve HTTP requests by handler.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"handler"}),
+		requestBytes: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_size_bytes",
+			Help:    "HTTP request body sizes by handler.",
+			Buckets: sizeBuckets,
+		}, []string{"handler"}),
+		responseBytes: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_response_size_bytes",
+			Help:    "HTTP response body sizes as sent, after compression, by handler.",
+			Buckets: sizeBuckets,
+		}, []string{"handler"}),
+	}
+	reg.MustRegister(m.requests, m.duration, m.requestBytes, m.responseBytes)
+	return m
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// sizeRecorder captures the status code and counts response body bytes
+type sizeRecorder struct {
+	statusRecorder
+	n int64
+}
+
+func (rec *sizeRecorder) Write(b []byte) (int, error) {
+	n, err := rec.statusRecorder.Write(b)
+	rec.n += int64(n)
+	return n, err
+}
+
+// middleware records request counts, latency and body sizes. Handlers are labelled by the ServeMux
+// pattern they matched, so it must wrap the mux without replacing the request on the way in.
+func (m *httpMetrics) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		body := &countingReader{ReadCloser: r.Body}
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = body
+		}
+		rec := &sizeRecorder{statusRecorder: statusRecorder{ResponseWriter: w}}
+		next.ServeHTTP(rec, r)
+
+		handler := r.Pattern
+		if handler == "" || handler == notFoundPattern {
+			handler = "unmatched" // Keep unknown paths from each getting their own series
+		}
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		// Handlers may not read the whole body, so trust a declared length over the bytes read
+		requestSize := body.n
+		if r.ContentLength > 0 {
+			requestSize = r.ContentLength
+		}
+
+		m.requests.WithLabelValues(handler, strconv.Itoa(status)).Inc()
+		m.duration.WithLabelValues(handler).Observe(time.Since(start).Seconds())
+		m.requestBytes.WithLabelValues(handler).Observe(float64(requestSize))
+		m.responseBytes.WithLabelValues(handler).Observe(float64(rec.n))
+	})
+}
+
+// metricsHandler serves the Prometheus metrics
+var metricsHandler = promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
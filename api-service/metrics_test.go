@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// TestHTTPMetrics_Sizes tests that request and response sizes are recorded under the matched pattern
+func TestHTTPMetrics_Sizes(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := newHTTPMetrics(reg)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/order", func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Write([]byte("0123456789"))
+	})
+	handler := m.middleware(mux)
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/order", bytes.NewBufferString(strings.Repeat("x", 250)))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nope/123", nil))
+
+	want := `
+# HELP http_request_size_bytes HTTP request body sizes by handler.
+# TYPE http_request_size_bytes histogram
+http_request_size_bytes_bucket{handler="/order",le="100"} 0
+http_request_size_bytes_bucket{handler="/order",le="400"} 2
+http_request_size_bytes_bucket{handler="/order",le="1600"} 2
+http_request_size_bytes_bucket{handler="/order",le="6400"} 2
+http_request_size_bytes_bucket{handler="/order",le="25600"} 2
+http_request_size_bytes_bucket{handler="/order",le="102400"} 2
+http_request_size_bytes_bucket{handler="/order",le="409600"} 2
+http_request_size_bytes_bucket{handler="/order",le="1.6384e+06"} 2
+http_request_size_bytes_bucket{handler="/order",le="+Inf"} 2
+http_request_size_bytes_sum{handler="/order"} 500
+http_request_size_bytes_count{handler="/order"} 2
+http_request_size_bytes_bucket{handler="unmatched",le="100"} 1
+http_request_size_bytes_bucket{handler="unmatched",le="400"} 1
+http_request_size_bytes_bucket{handler="unmatched",le="1600"} 1
+http_request_size_bytes_bucket{handler="unmatched",le="6400"} 1
+http_request_size_bytes_bucket{handler="unmatched",le="25600"} 1
+http_request_size_bytes_bucket{handler="unmatched",le="102400"} 1
+http_request_size_bytes_bucket{handler="unmatched",le="409600"} 1
+http_request_size_bytes_bucket{handler="unmatched",le="1.6384e+06"} 1
+http_request_size_bytes_bucket{handler="unmatched",le="+Inf"} 1
+http_request_size_bytes_sum{handler="unmatched"} 0
+http_request_size_bytes_count{handler="unmatched"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "http_request_size_bytes"); err != nil {
+		t.Error(err)
+	}
+
+	if got := testutil.ToFloat64(m.requests.WithLabelValues("/order", "200")); got != 2 {
+		t.Errorf("http_requests_total{handler=/order,code=200} = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(m.requests.WithLabelValues("unmatched", "404")); got != 1 {
+		t.Errorf("http_requests_total{handler=unmatched,code=404} = %v, want 1", got)
+	}
+}
+
+// TestHTTPMetrics_ResponseSize tests that response bytes are counted and chunked request bodies are measured as read
+func TestHTTPMetrics_ResponseSize(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := newHTTPMetrics(reg)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/products", func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Write(bytes.Repeat([]byte("p"), 1000))
+	})
+	handler := m.middleware(mux)
+
+	req := httptest.NewRequest(http.MethodPost, "/products", strings.NewReader("abcdef"))
+	req.ContentLength = -1 // Unknown length, as with a chunked body
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if _, sum := histogramCountSum(t, reg, "http_response_size_bytes"); sum != 1000 {
+		t.Errorf("http_response_size_bytes_sum = %v, want 1000", sum)
+	}
+	if _, sum := histogramCountSum(t, reg, "http_request_size_bytes"); sum != 6 {
+		t.Errorf("http_request_size_bytes_sum = %v, want 6", sum)
+	}
+}
+
+// histogramCountSum returns the sample count and sum of the single series of a histogram in reg
+func histogramCountSum(t *testing.T, reg *prometheus.Registry, name string) (uint64, float64) {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather returned error: %v", err)
+	}
+	for _, f := range families {
+		if f.GetName() == name && len(f.GetMetric()) == 1 {
+			h := f.GetMetric()[0].GetHistogram()
+			return h.GetSampleCount(), h.GetSampleSum()
+		}
+	}
+	t.Fatalf("histogram %s not found", name)
+	return 0, 0
+}
+
+// TestMetricsHandler tests that /metrics serves the exposition format
+func TestMetricsHandler(t *testing.T) {
+	rr := httptest.NewRecorder()
+	metricsHandler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if !strings.Contains(rr.Body.String(), "go_goroutines") {
+		t.Errorf("metrics output is missing the Go collector")
+	}
+}
+
+// TestProductsCacheMetrics tests that catalog lookups count as hits, misses and stale serves
+func TestProductsCacheMetrics(t *testing.T) {
+	stubSleep(t)
+	os.Setenv("PRODUCTS_STALE_MAX", "1h")
+	defer os.Unsetenv("PRODUCTS_STALE_MAX")
+	dotnet := newFakeDotnet(t, []Product{{Id: "prod1", Name: "Headphones", Price: 99.99, Stock: 10}})
+
+	hits, misses, stale := testutil.ToFloat64(productsCacheHits), testutil.ToFloat64(productsCacheMisses), testutil.ToFloat64(productsCacheStale)
+	check := func(when string, wantHits, wantMisses, wantStale float64) {
+		t.Helper()
+		gotHits := testutil.ToFloat64(productsCacheHits) - hits
+		gotMisses := testutil.ToFloat64(productsCacheMisses) - misses
+		gotStale := testutil.ToFloat64(productsCacheStale) - stale
+		if gotHits != wantHits || gotMisses != wantMisses || gotStale != wantStale {
+			t.Errorf("%s: hits, misses, stale = %v, %v, %v, want %v, %v, %v", when, gotHits, gotMisses, gotStale, wantHits, wantMisses, wantStale)
+		}
+	}
+
+	getProducts(t, "")
+	check("first request", 0, 1, 0)
+	getProducts(t, "")
+	check("cached request", 1, 1, 0)
+
+	os.Setenv("PRODUCTS_CACHE_TTL", "1ns")
+	defer os.Unsetenv("PRODUCTS_CACHE_TTL")
+	dotnet.Server.Close()
+	getProducts(t, "")
+	check("expired during an outage", 1, 2, 1)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"log"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// statusRecorder wraps a ResponseWriter to capture the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 && !interimStatus(status) {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying ResponseWriter
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// interimStatus reports whether status is a 1xx informational response such as 102 Processing,
+// which precedes the final response rather than being it. 101 Switching Protocols is final.
+func interimStatus(status int) bool {
+	return status >= 100 && status < 200 && status != http.StatusSwitchingProtocols
+}
+
+// logSampler decides which successful requests get logged; safe for concurrent use
+type logSampler struct {
+	mu   sync.Mutex
+	rate float64
+	rng  *rand.Rand
+}
+
+// newLogSampler returns a sampler that keeps roughly rate (0.0–1.0) of requests, seeded for reproducibility
+func newLogSampler(rate float64, seed int64) *logSampler {
+	if rate < 0 {
+		rate = 0
+	}
+	if rate > 1 {
+		rate = 1
+	}
+	return &logSampler{rate: rate, rng: rand.New(rand.NewSource(seed))}
+}
+
+// sample reports whether the next successful request should be logged
+func (s *logSampler) sample() bool {
+	if s.rate >= 1 {
+		return true
+	}
+	if s.rate <= 0 {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rng.Float64() < s.rate
+}
+
+// loggingMiddleware logs each completed request. Errors (4xx/5xx) are always logged;
+// successful requests are logged only when chosen by the sampler (LOG_SAMPLE_RATE).
+func loggingMiddleware(next http.Handler, sampler *logSampler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if status < 400 && !sampler.sample() {
+			return
+		}
+		log.Printf("%s %s %d %s", r.Method, r.URL.Path, status, time.Since(start))
+	})
+}
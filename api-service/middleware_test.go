@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// captureLog redirects the standard logger into a buffer for the duration of the test
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+func statusHandler(status int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	})
+}
+
+// TestLoggingMiddleware_AlwaysLogsErrors tests that 4xx/5xx responses are logged even with sampling off
+func TestLoggingMiddleware_AlwaysLogsErrors(t *testing.T) {
+	buf := captureLog(t)
+	sampler := newLogSampler(0, 1)
+
+	for _, status := range []int{http.StatusBadRequest, http.StatusBadGateway} {
+		loggingMiddleware(statusHandler(status), sampler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/products", nil))
+	}
+	loggingMiddleware(statusHandler(http.StatusOK), sampler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/products", nil))
+
+	if got := strings.Count(buf.String(), "GET /products"); got != 2 {
+		t.Errorf("logged %d requests, want 2 (errors only):\n%s", got, buf.String())
+	}
+}
+
+// TestLoggingMiddleware_SamplesSuccesses tests that roughly LOG_SAMPLE_RATE of successful requests are logged
+func TestLoggingMiddleware_SamplesSuccesses(t *testing.T) {
+	buf := captureLog(t)
+	handler := loggingMiddleware(statusHandler(http.StatusOK), newLogSampler(0.25, 42))
+
+	const requests = 1000
+	for i := 0; i < requests; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/products", nil))
+	}
+
+	logged := strings.Count(buf.String(), "GET /products")
+	if logged < 200 || logged > 300 {
+		t.Errorf("logged %d of %d requests, want roughly 25%%", logged, requests)
+	}
+}
+
+// TestLogSampler_Deterministic tests that the same seed produces the same sampling decisions
+func TestLogSampler_Deterministic(t *testing.T) {
+	a, b := newLogSampler(0.5, 7), newLogSampler(0.5, 7)
+	for i := 0; i < 100; i++ {
+		if a.sample() != b.sample() {
+			t.Fatalf("samplers with the same seed diverged at decision %d", i)
+		}
+	}
+	if !newLogSampler(1, 0).sample() {
+		t.Error("rate 1.0 should log every request")
+	}
+}
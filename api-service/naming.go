@@ -0,0 +1,128 @@
+package main
+
+// Response field naming. Response types carry camelCase JSON tags, which is what the React app
+// expects. With RESPONSE_NAMING=snake every JSON response is re-encoded with its object keys in
+// snake_case instead (imageUrl becomes image_url), for consumers that prefer it. Only keys that are
+// camelCase identifiers are renamed, so keys that are data, such as ids with dashes, pass through;
+// feature names in map keys are renamed like any other key.
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"unicode"
+)
+
+// snakeCaseResponses reports whether RESPONSE_NAMING=snake asks for snake_case response keys
+func snakeCaseResponses() bool {
+	return os.Getenv("RESPONSE_NAMING") == "snake"
+}
+
+// marshalResponse encodes v as a JSON response body, with snake_case keys when RESPONSE_NAMING=snake
+func marshalResponse(v interface{}) ([]byte, error) {
+	body, err := json.Marshal(v)
+	if err != nil || !snakeCaseResponses() {
+		return body, err
+	}
+	return snakeCaseKeys(body)
+}
+
+// jsonFrame is an object or array being copied by snakeCaseKeys
+type jsonFrame struct {
+	object bool
+	n      int // Tokens copied so far; in an object keys are the even ones
+}
+
+// snakeCaseKeys copies the JSON document src with its object keys converted to snake_case. It
+// works on the token stream rather than decoding into maps so key order and numbers are kept as
+// they were.
+func snakeCaseKeys(src []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(src))
+	dec.UseNumber()
+	var out bytes.Buffer
+	var stack []jsonFrame
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return out.Bytes(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		isKey := false
+		closing := tok == json.Delim('}') || tok == json.Delim(']')
+		if len(stack) > 0 && !closing {
+			top := &stack[len(stack)-1]
+			switch {
+			case top.object && top.n%2 == 0:
+				isKey = true
+				if top.n > 0 {
+					out.WriteByte(',')
+				}
+			case top.object:
+				out.WriteByte(':')
+			case top.n > 0:
+				out.WriteByte(',')
+			}
+			top.n++
+		}
+
+		switch t := tok.(type) {
+		case json.Delim:
+			out.WriteRune(rune(t))
+			if closing {
+				stack = stack[:len(stack)-1]
+			} else {
+				stack = append(stack, jsonFrame{object: t == '{'})
+			}
+		case string:
+			if isKey {
+				t = snakeCase(t)
+			}
+			b, _ := json.Marshal(t)
+			out.Write(b)
+		case json.Number:
+			out.WriteString(t.String())
+		case bool:
+			if t {
+				out.WriteString("true")
+			} else {
+				out.WriteString("false")
+			}
+		case nil:
+			out.WriteString("null")
+		}
+	}
+}
+
+// snakeCase converts a camelCase identifier such as imageUrl or orderID to snake_case (image_url,
+// order_id). Anything else, e.g. a key that is already snake_case or contains other characters, is
+// returned unchanged.
+func snakeCase(key string) string {
+	runes := []rune(key)
+	if len(runes) == 0 || !unicode.IsLower(runes[0]) {
+		return key
+	}
+	for _, r := range runes {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return key
+		}
+	}
+	var b bytes.Buffer
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// A word starts at an upper case letter after a lower case one or digit, or at the last
+			// letter of an acronym that is followed by lower case (the R in "HTTPRequest")
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || nextLower {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestSnakeCase tests the conversion of camelCase keys and that other keys are left alone
+func TestSnakeCase(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"id", "id"},
+		{"imageUrl", "image_url"},
+		{"totalAmount", "total_amount"},
+		{"orderID", "order_id"},
+		{"lastHTTPRequest", "last_http_request"},
+		{"line2Address", "line2_address"},
+		{"already_snake", "already_snake"},
+		{"SKU-A1", "SKU-A1"},
+		{"prod-xL", "prod-xL"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := snakeCase(tt.key); got != tt.want {
+			t.Errorf("snakeCase(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+// TestSnakeCaseKeys tests that nested object keys are renamed while values, key order and numbers are kept
+func TestSnakeCaseKeys(t *testing.T) {
+	src := `{"orderId":"o-1","items":[{"productId":"p1","unitPrice":10.50}],"meta":{"requestId":"r1","notes":null,"giftWrap":false},"totalAmount":1e2,"imageUrl":"uses camelCase"}`
+	want := `{"order_id":"o-1","items":[{"product_id":"p1","unit_price":10.50}],"meta":{"request_id":"r1","notes":null,"gift_wrap":false},"total_amount":1e2,"image_url":"uses camelCase"}`
+	got, err := snakeCaseKeys([]byte(src))
+	if err != nil {
+		t.Fatalf("snakeCaseKeys: %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("snakeCaseKeys() = %s, want %s", got, want)
+	}
+}
+
+// TestProductsHandler_ResponseNaming tests the product response keys with the default and snake_case naming
+func TestProductsHandler_ResponseNaming(t *testing.T) {
+	newProductsUpstream(t, func() []Product {
+		return []Product{{Id: "p1", Name: "Mug", ImageUrl: "https://cdn.example.com/mug.png", Stock: 1}}
+	})
+	tests := []struct {
+		naming  string
+		want    []string
+		notWant []string
+	}{
+		{"", []string{`"imageUrl":"https://cdn.example.com/mug.png"`, `"lowStock":true`}, []string{`"image_url"`, `"low_stock"`}},
+		{"snake", []string{`"image_url":"https://cdn.example.com/mug.png"`, `"low_stock":true`}, []string{`"imageUrl"`, `"lowStock"`}},
+	}
+	etags := make(map[string]bool)
+	for _, tt := range tests {
+		t.Run("naming "+tt.naming, func(t *testing.T) {
+			os.Setenv("RESPONSE_NAMING", tt.naming)
+			defer os.Unsetenv("RESPONSE_NAMING")
+			rr := httptest.NewRecorder()
+			productsHandler(rr, httptest.NewRequest(http.MethodGet, "/products", nil))
+			if status := rr.Code; status != http.StatusOK {
+				t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+			}
+			body := rr.Body.String()
+			for _, s := range tt.want {
+				if !strings.Contains(body, s) {
+					t.Errorf("body %s does not contain %s", body, s)
+				}
+			}
+			for _, s := range tt.notWant {
+				if strings.Contains(body, s) {
+					t.Errorf("body %s contains %s", body, s)
+				}
+			}
+			etags[rr.Header().Get("ETag")] = true
+		})
+	}
+	if len(etags) != 2 {
+		t.Errorf("got ETags %v, want one per naming", etags)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Defaults for order replay protection when ORDER_NONCE_WINDOW / ORDER_NONCE_MAX are not set
+const (
+	defaultOrderNonceWindow = 5 * time.Minute
+	defaultOrderNonceMax    = 10000
+)
+
+// nonceStore remembers recently used order nonces so a resubmitted order can be rejected. Unlike
+// idempotency keys no response is stored, only the nonce itself.
+type nonceStore struct {
+	store    Store
+	fallback Store // Used while store fails, e.g. when a shared Redis is down; nil fails the claim
+}
+
+// orderNonces is the process-wide nonce store used by placeOrder. In memory it remembers at most
+// ORDER_NONCE_MAX nonces; beyond that the oldest are forgotten early.
+var orderNonces = newNonceStore(newMemoryStore(func() int { return envInt("ORDER_NONCE_MAX", defaultOrderNonceMax) }))
+
+func newNonceStore(store Store) *nonceStore {
+	return &nonceStore{store: store}
+}
+
+// nonceKey is the Store key for a nonce claimed by tenant, "" being the one used without tenants
+func nonceKey(tenant, nonce string) string {
+	if tenant != "" {
+		return "order-nonce:" + tenant + ":" + nonce
+	}
+	return "order-nonce:" + nonce
+}
+
+// claim records nonce for ctx's tenant and reports whether it was unused within window. While the store fails the
+// fallback is used, which only catches resubmissions to this process but still catches the common
+// case of a client retrying against the same replica.
+func (s *nonceStore) claim(ctx context.Context, nonce string, window time.Duration) (bool, error) {
+	claimed, err := s.store.SetIfAbsent(ctx, nonceKey(tenantFrom(ctx), nonce), []byte{1}, window)
+	if err != nil && s.fallback != nil {
+		log.Printf("Error claiming order nonce in the shared store, using local state: %v", err)
+		return s.fallback.SetIfAbsent(ctx, nonceKey(tenantFrom(ctx), nonce), []byte{1}, window)
+	}
+	return claimed, err
+}
+
+// release forgets nonce so the same order can be retried, e.g. after the Dotnet service failed
+func (s *nonceStore) release(ctx context.Context, nonce string) error {
+	if s.fallback != nil {
+		// The nonce may have been claimed in the fallback while the store was failing
+		s.fallback.Delete(ctx, nonceKey(tenantFrom(ctx), nonce))
+	}
+	return s.store.Delete(ctx, nonceKey(tenantFrom(ctx), nonce))
+}
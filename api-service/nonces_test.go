@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// useFakeNonceClock swaps in a fresh in-memory nonce store whose clock the test controls
+func useFakeNonceClock(t *testing.T) *time.Time {
+	t.Helper()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := newMemoryStore(nil)
+	store.now = func() time.Time { return now }
+	orig := orderNonces
+	orderNonces = newNonceStore(store)
+	t.Cleanup(func() { orderNonces = orig })
+	return &now
+}
+
+// claimNonce claims nonce in orderNonces, failing the test on a store error
+func claimNonce(t *testing.T, nonce string, window time.Duration) bool {
+	t.Helper()
+	claimed, err := orderNonces.claim(context.Background(), nonce, window)
+	if err != nil {
+		t.Fatalf("claim(%q): %v", nonce, err)
+	}
+	return claimed
+}
+
+// TestNonceStore tests accepting, rejecting a repeat, and accepting again once the window has passed
+func TestNonceStore(t *testing.T) {
+	now := useFakeNonceClock(t)
+
+	if !claimNonce(t, "n1", time.Minute) {
+		t.Errorf("first use of nonce was rejected")
+	}
+	*now = now.Add(59 * time.Second)
+	if claimNonce(t, "n1", time.Minute) {
+		t.Errorf("repeated nonce within the window was accepted")
+	}
+	if !claimNonce(t, "n2", time.Minute) {
+		t.Errorf("different nonce was rejected")
+	}
+	*now = now.Add(2 * time.Second)
+	if !claimNonce(t, "n1", time.Minute) {
+		t.Errorf("nonce was rejected after the window expired")
+	}
+}
+
+// TestNonceStore_Release tests that a released nonce can be claimed again and survives pruning of its old entry
+func TestNonceStore_Release(t *testing.T) {
+	now := useFakeNonceClock(t)
+
+	claimNonce(t, "n1", time.Minute)
+	orderNonces.release(context.Background(), "n1")
+	*now = now.Add(30 * time.Second)
+	if !claimNonce(t, "n1", time.Minute) {
+		t.Fatalf("released nonce was rejected")
+	}
+	*now = now.Add(45 * time.Second) // Past the first claim's window, inside the second's
+	if claimNonce(t, "n1", time.Minute) {
+		t.Errorf("re-claimed nonce was forgotten with its released entry")
+	}
+}
+
+// TestOrderHandler_DuplicateNonce tests that resubmitting an order with the same nonce returns 409
+func TestOrderHandler_DuplicateNonce(t *testing.T) {
+	useFakeNonceClock(t)
+	dotnet := newFakeDotnet(t, nil)
+	order := PlaceOrderRequest{
+		Items:           []OrderItemRequest{{Id: "p1", Quantity: 1, Price: 10}},
+		TotalAmount:     pricePtr(10),
+		DeliveryAddress: "1 Main St",
+		Nonce:           "abc123",
+	}
+
+	rr := httptest.NewRecorder()
+	orderHandler(rr, postJSON(t, "/order", order))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	rr = httptest.NewRecorder()
+	orderHandler(rr, postJSON(t, "/order", order))
+	if rr.Code != http.StatusConflict {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusConflict)
+	}
+	if n := len(dotnet.Orders); n != 1 {
+		t.Errorf("Dotnet service received %d orders, want 1", n)
+	}
+}
+
+// TestOrderHandler_NonceAfterUpstreamFailure tests that an order timing out after it was sent keeps
+// its nonce, while one that never reached Dotnet releases it for a retry
+func TestOrderHandler_NonceAfterUpstreamFailure(t *testing.T) {
+	useFakeNonceClock(t)
+	unblock := make(chan struct{})
+	var received atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/place-order" {
+			w.Write([]byte(`[]`))
+			return
+		}
+		received.Add(1)
+		<-unblock
+	}))
+	defer server.Close()
+	defer close(unblock)
+	os.Setenv("DOTNET_PRODUCTS_API_URL", server.URL)
+	defer os.Unsetenv("DOTNET_PRODUCTS_API_URL")
+	os.Setenv("ORDER_TIMEOUT", "20ms")
+	defer os.Unsetenv("ORDER_TIMEOUT")
+	order := PlaceOrderRequest{
+		Items:           []OrderItemRequest{{Id: "p1", Quantity: 1, Price: 10}},
+		TotalAmount:     pricePtr(10),
+		DeliveryAddress: "1 Main St",
+		Nonce:           "stalled",
+	}
+
+	rr := httptest.NewRecorder()
+	orderHandler(rr, postJSON(t, "/order", order))
+	if rr.Code != http.StatusBadGateway {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadGateway)
+	}
+	rr = httptest.NewRecorder()
+	orderHandler(rr, postJSON(t, "/order", order))
+	if rr.Code != http.StatusConflict {
+		t.Errorf("resubmitting a timed-out order: handler returned wrong status code: got %v want %v", rr.Code, http.StatusConflict)
+	}
+	if n := received.Load(); n != 1 {
+		t.Errorf("Dotnet service received %d orders, want 1", n)
+	}
+
+	// Nothing listens at a closed server's address, so the order can't have been placed
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	os.Setenv("DOTNET_PRODUCTS_API_URL", closed.URL)
+	order.Nonce = "unsent"
+	orderHandler(httptest.NewRecorder(), postJSON(t, "/order", order))
+	if !claimNonce(t, "unsent", time.Minute) {
+		t.Error("nonce of an order that never reached Dotnet was kept")
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// notFoundPattern is the catch-all route notFoundHandler is registered under
+const notFoundPattern = "/"
+
+// NotFoundResponse is the body returned for paths no endpoint is registered at
+type NotFoundResponse struct {
+	Error NotFoundError `json:"error"`
+}
+
+// NotFoundError describes the unknown route
+type NotFoundError struct {
+	Code    string `json:"code"` // Always "not_found"
+	Message string `json:"message"`
+	Path    string `json:"path"`
+}
+
+// notFoundHandler answers requests to unknown paths with a JSON 404 naming the path, instead of the
+// ServeMux's plaintext default. Clients that prefer text/plain still get the bare message.
+func notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	// Control characters in the path must not reach the plaintext body's single line
+	path := strings.Map(func(c rune) rune {
+		if c < ' ' || c == 0x7f {
+			return -1
+		}
+		return c
+	}, r.URL.Path)
+	message := "No endpoint at " + path
+	if prefersPlainText(r.Header.Get("Accept")) {
+		writePlainError(w, http.StatusNotFound, message)
+		return
+	}
+	writeJSON(w, r, http.StatusNotFound, NotFoundResponse{Error: NotFoundError{Code: "not_found", Message: message, Path: path}})
+}
@@ -0,0 +1,125 @@
+package main
+
+// Order queueing. By default an order that finds MAX_CONCURRENT_ORDERS in flight polls for a slot for
+// up to ORDER_QUEUE_TIMEOUT, in no particular order, and is then rejected. With ORDER_QUEUE_SIZE set,
+// up to that many such orders instead line up and are handed slots first come first served, waiting
+// up to ORDER_QUEUE_MAX_WAIT each, so a burst is smoothed out rather than shed. Orders arriving when
+// the queue is full are rejected at once, which is the backpressure telling clients to slow down.
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// defaultOrderQueueMaxWait is how long a queued order waits for a slot when ORDER_QUEUE_MAX_WAIT is not set
+const defaultOrderQueueMaxWait = 5 * time.Second
+
+// Reasons an order left the queue without a slot
+var (
+	errOrderQueueFull    = errors.New("order queue full")
+	errOrderQueueTimeout = errors.New("order timed out in the queue")
+)
+
+// orderQueue lines orders up in front of a concurrencyLimiter. Only the order at the front waits on
+// the limiter itself; the rest wait for their turn, so slots go out in arrival order.
+type orderQueue struct {
+	mu      sync.Mutex
+	waiting *list.List // Of *queuedOrder, oldest first
+	onDepth func(depth int)
+}
+
+// queuedOrder is one order waiting in an orderQueue
+type queuedOrder struct {
+	turn chan struct{} // Closed once the order reaches the front
+}
+
+// orderQueues queue orders separately for each tenant, like orderLimiters. The depth gauge counts all of them.
+var orderQueues = newPerTenant(func(string) *orderQueue {
+	previous := 0
+	return newOrderQueue(func(n int) {
+		ordersQueued.Add(float64(n - previous))
+		previous = n
+	})
+})
+
+// ordersQueuedTotal returns the number of orders waiting in a queue, over every tenant
+func ordersQueuedTotal() int {
+	total := 0
+	orderQueues.each(func(_ string, q *orderQueue) { total += q.depth() })
+	return total
+}
+
+func newOrderQueue(onDepth func(depth int)) *orderQueue {
+	return &orderQueue{waiting: list.New(), onDepth: onDepth}
+}
+
+// acquire takes a slot from l when one is free and nobody is queued for it. Otherwise the order joins
+// the back of the queue, unless size orders are already waiting, and waits up to maxWait to be given
+// a slot. It returns errOrderQueueFull, errOrderQueueTimeout or ctx's error if no slot was taken, in
+// which case l.release must not be called.
+func (q *orderQueue) acquire(ctx context.Context, l *concurrencyLimiter, limit, size int, maxWait time.Duration) error {
+	deadline := time.Now().Add(maxWait)
+	q.mu.Lock()
+	if q.waiting.Len() == 0 && l.acquire(ctx, limit, 0) {
+		q.mu.Unlock()
+		return nil
+	}
+	if q.waiting.Len() >= size {
+		q.mu.Unlock()
+		return errOrderQueueFull
+	}
+	order := &queuedOrder{turn: make(chan struct{})}
+	e := q.waiting.PushBack(order)
+	if q.waiting.Front() == e {
+		close(order.turn)
+	}
+	q.changedLocked()
+	q.mu.Unlock()
+	defer q.leave(e)
+
+	timer := time.NewTimer(maxWait)
+	defer timer.Stop()
+	select {
+	case <-order.turn:
+	case <-timer.C:
+		return errOrderQueueTimeout
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if l.acquire(ctx, limit, time.Until(deadline)) {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return errOrderQueueTimeout
+}
+
+// leave takes e out of the queue, passing the turn on if it was at the front
+func (q *orderQueue) leave(e *list.Element) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	front := q.waiting.Front() == e
+	q.waiting.Remove(e)
+	if next := q.waiting.Front(); front && next != nil {
+		close(next.Value.(*queuedOrder).turn)
+	}
+	q.changedLocked()
+}
+
+// changedLocked reports the new queue depth; q.mu must be held
+func (q *orderQueue) changedLocked() {
+	if q.onDepth != nil {
+		q.onDepth(q.waiting.Len())
+	}
+}
+
+// depth returns the number of orders waiting
+func (q *orderQueue) depth() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.waiting.Len()
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// waitForDepth waits until q holds depth orders
+func waitForDepth(t *testing.T, q *orderQueue, depth int) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); q.depth() != depth; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("queue depth = %d, want %d", q.depth(), depth)
+		}
+	}
+}
+
+// TestOrderQueue_Enqueue tests that orders take free slots directly, queue when none is free, and are refused once the queue is full
+func TestOrderQueue_Enqueue(t *testing.T) {
+	var depths []int
+	q := newOrderQueue(func(n int) { depths = append(depths, n) })
+	l := newConcurrencyLimiter(nil)
+	ctx := context.Background()
+
+	if err := q.acquire(ctx, l, 1, 1, time.Second); err != nil {
+		t.Fatalf("acquire with a free slot = %v", err)
+	}
+	if q.depth() != 0 {
+		t.Errorf("queue depth = %d after taking a free slot, want 0", q.depth())
+	}
+	queued := make(chan error)
+	go func() { queued <- q.acquire(ctx, l, 1, 1, time.Second) }()
+	waitForDepth(t, q, 1)
+	if err := q.acquire(ctx, l, 1, 1, time.Second); !errors.Is(err, errOrderQueueFull) {
+		t.Errorf("acquire with a full queue = %v, want %v", err, errOrderQueueFull)
+	}
+
+	l.release()
+	if err := <-queued; err != nil {
+		t.Errorf("queued acquire = %v", err)
+	}
+	l.release()
+	if got, want := fmt.Sprint(depths), "[1 0]"; got != want {
+		t.Errorf("queue depths = %v, want %v", got, want)
+	}
+}
+
+// TestOrderQueue_Dequeue tests that queued orders are given slots in the order they arrived
+func TestOrderQueue_Dequeue(t *testing.T) {
+	q := newOrderQueue(nil)
+	l := newConcurrencyLimiter(nil)
+	ctx := context.Background()
+	if err := q.acquire(ctx, l, 1, 3, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	served := make(chan int, 3)
+	for i := 1; i <= 3; i++ {
+		go func() {
+			if err := q.acquire(ctx, l, 1, 3, time.Second); err != nil {
+				t.Errorf("order %d: acquire = %v", i, err)
+				return
+			}
+			served <- i
+		}()
+		waitForDepth(t, q, i)
+	}
+	for want := 1; want <= 3; want++ {
+		l.release()
+		if got := <-served; got != want {
+			t.Errorf("order %d was served, want order %d", got, want)
+		}
+	}
+	l.release()
+	if q.depth() != 0 || l.current() != 0 {
+		t.Errorf("queue depth = %d and %d in flight, want both 0", q.depth(), l.current())
+	}
+}
+
+// TestOrderQueue_TimeoutEviction tests that an order leaves the queue after maxWait, handing the next order its turn
+func TestOrderQueue_TimeoutEviction(t *testing.T) {
+	q := newOrderQueue(nil)
+	l := newConcurrencyLimiter(nil)
+	ctx := context.Background()
+	if err := q.acquire(ctx, l, 1, 2, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	evicted := make(chan error)
+	go func() { evicted <- q.acquire(ctx, l, 1, 2, 20*time.Millisecond) }()
+	waitForDepth(t, q, 1)
+	next := make(chan error)
+	go func() { next <- q.acquire(ctx, l, 1, 2, time.Second) }()
+	waitForDepth(t, q, 2)
+
+	if err := <-evicted; !errors.Is(err, errOrderQueueTimeout) {
+		t.Errorf("acquire past maxWait = %v, want %v", err, errOrderQueueTimeout)
+	}
+	waitForDepth(t, q, 1)
+	l.release()
+	if err := <-next; err != nil {
+		t.Errorf("acquire behind an evicted order = %v", err)
+	}
+	l.release()
+
+	// A cancelled request leaves the queue too
+	if err := q.acquire(ctx, l, 1, 2, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	defer l.release()
+	cancelled, cancel := context.WithCancel(ctx)
+	go func() {
+		waitForDepth(t, q, 1)
+		cancel()
+	}()
+	if err := q.acquire(cancelled, l, 1, 2, time.Second); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled acquire = %v, want %v", err, context.Canceled)
+	}
+	if q.depth() != 0 {
+		t.Errorf("queue depth = %d after cancellation, want 0", q.depth())
+	}
+}
+
+// TestOrderHandler_Queue tests that with ORDER_QUEUE_SIZE an order beyond MAX_CONCURRENT_ORDERS waits for a slot, and a 503 once it ages out
+func TestOrderHandler_Queue(t *testing.T) {
+	received := make(chan struct{}, 2)
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		<-unblock
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"success":true,"orderId":"order-1"}`))
+	}))
+	defer server.Close()
+	os.Setenv("DOTNET_PRODUCTS_API_URL", server.URL)
+	defer os.Unsetenv("DOTNET_PRODUCTS_API_URL")
+	os.Setenv("MAX_CONCURRENT_ORDERS", "1")
+	defer os.Unsetenv("MAX_CONCURRENT_ORDERS")
+	os.Setenv("ORDER_QUEUE_SIZE", "1")
+	defer os.Unsetenv("ORDER_QUEUE_SIZE")
+	os.Setenv("ORDER_QUEUE_MAX_WAIT", "20ms")
+	defer os.Unsetenv("ORDER_QUEUE_MAX_WAIT")
+
+	place := func(rr *httptest.ResponseRecorder, id string, done chan struct{}) {
+		orderHandler(rr, postJSON(t, "/order", batchOrder(id, "1 Main St")))
+		close(done)
+	}
+	first, firstDone := httptest.NewRecorder(), make(chan struct{})
+	go place(first, "p1", firstDone)
+	<-received
+
+	// The queue holds the order until MAX_WAIT, then lets it go with a 503
+	rr := httptest.NewRecorder()
+	orderHandler(rr, postJSON(t, "/order", batchOrder("p2", "2 Main St")))
+	if status := rr.Code; status != http.StatusServiceUnavailable {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusServiceUnavailable)
+	}
+
+	os.Setenv("ORDER_QUEUE_MAX_WAIT", "5s")
+	second, secondDone := httptest.NewRecorder(), make(chan struct{})
+	go place(second, "p3", secondDone)
+	waitForDepth(t, orderQueues.get(""), 1)
+	if got := testutil.ToFloat64(ordersQueued); got != 1 {
+		t.Errorf("orders_queue_depth = %v, want 1", got)
+	}
+
+	close(unblock)
+	<-firstDone
+	<-secondDone
+	for i, rr := range []*httptest.ResponseRecorder{first, second} {
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("order %d: handler returned wrong status code: got %v want %v", i+1, status, http.StatusOK)
+		}
+	}
+	if got := testutil.ToFloat64(ordersQueued); got != 0 {
+		t.Errorf("orders_queue_depth after completion = %v, want 0", got)
+	}
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Defaults for POST /orders/batch when ORDER_BATCH_WORKERS / ORDER_BATCH_MAX are not set
+const (
+	defaultOrderBatchWorkers = 4
+	defaultOrderBatchMax     = 50
+)
+
+// errOrderCapacity is wrapped in the 503 returned when no order slot frees up in time
+var errOrderCapacity = errors.New("order concurrency limit reached")
+
+// placeOrder validates an order, applies its reservation and coupon, and forwards it to the Dotnet
+// service. It returns the Dotnet status code and response, a *validationError or *requestError for
+// an invalid order, or an *upstreamError when the order could not be placed.
+func placeOrder(ctx context.Context, orderRequest PlaceOrderRequest) (int, PlaceOrderResponse, error) {
+	// Validate the order and forward the delivery address in normalized form. Guests must also leave
+	// a way to reach them, since there is no account to look them up by.
+	fields := validateOrder(&orderRequest)
+	if isGuest(ctx) {
+		fields = append(fields, validateGuestContact(&orderRequest)...)
+	}
+	if len(fields) > 0 {
+		return 0, PlaceOrderResponse{}, &validationError{Fields: fields}
+	}
+
+	// An expired or unknown reservation, or one that doesn't cover every item, is dropped so the order
+	// proceeds unreserved. Its own hold is then not counted against the order's stock below.
+	holds := reservationsFor(tenantFrom(ctx))
+	var dropped string
+	if id := orderRequest.ReservationId; id != "" {
+		if live, covered := holds.covers(id, orderRequest.Items); !covered {
+			if live {
+				log.Printf("Reservation %s does not cover the ordered items, placing order without it", id)
+			} else {
+				log.Printf("Reservation %s has expired, placing order without it", id)
+			}
+			orderRequest.ReservationId, dropped = "", id
+		}
+	}
+
+	// Unless TRUST_CLIENT_PRICES=true, every item must be in the catalog at the price the client saw, and
+	// the order is forwarded at catalog prices so a tampered price can't slip through within the tolerance
+	trustPrices := envBool("TRUST_CLIENT_PRICES", false)
+	if !trustPrices {
+		entry, err := tenantProductCache(ctx).get(ctx, envDuration("PRODUCTS_CACHE_TTL", defaultProductsCacheTTL))
+		if err != nil {
+			var upErr *upstreamError
+			if !errors.As(err, &upErr) {
+				err = &upstreamError{Status: http.StatusBadGateway, Message: "Failed to verify prices with backend service", Err: err}
+			}
+			return 0, PlaceOrderResponse{}, err
+		}
+		mismatches, unknown := verifyPrices(orderRequest.Items, entry.Products, envFloat("PRICE_TOLERANCE", defaultPriceTolerance))
+		if len(mismatches) > 0 || len(unknown) > 0 {
+			log.Printf("Rejecting order before proxying, prices differ for %v and unknown items %v", mismatches, unknown)
+			message := "Some item prices have changed"
+			if len(mismatches) == 0 {
+				message = "Some items are not in the catalog"
+			}
+			return http.StatusConflict, PlaceOrderResponse{Success: false, Message: message, PriceMismatches: mismatches, UnknownItems: unknown}, nil
+		}
+		orderRequest.Items = withCatalogPrices(orderRequest.Items, entry.Products)
+	}
+
+	// A total the client sent must match its items as they will be forwarded, so at catalog prices unless
+	// they are trusted, since each item's price may be off by the tolerance. VALIDATE_ORDER_TOTAL=false
+	// skips the check and a coupon will replace the total anyway. Repriced orders forward the total of
+	// their items rather than the client's; an omitted or zero total is computed below.
+	if orderRequest.TotalAmount != nil && *orderRequest.TotalAmount != 0 && orderRequest.CouponCode == "" {
+		total, itemsTotal := float64(*orderRequest.TotalAmount), itemsSubtotal(orderRequest.Items)
+		if envBool("VALIDATE_ORDER_TOTAL", true) && roundMoney(math.Abs(total-itemsTotal)) > envFloat("PRICE_TOLERANCE", defaultPriceTolerance) {
+			log.Printf("Rejecting order: totalAmount %.2f does not match items total %.2f", total, itemsTotal)
+			return 0, PlaceOrderResponse{}, &requestError{Status: http.StatusUnprocessableEntity, Message: fmt.Sprintf("totalAmount %.2f does not match the items total of %.2f", total, itemsTotal)}
+		}
+		if !trustPrices {
+			recomputed := Price(itemsTotal)
+			orderRequest.TotalAmount = &recomputed
+		}
+	}
+
+	// Fail fast on items a fresh cached catalog already shows as short once live holds are set aside;
+	// Dotnet still has the final say. Reserved orders had their stock checked at reservation, and
+	// STOCK_PRECHECK=false skips this when the catalog may be stale, e.g. while stock is edited
+	// directly in the Dotnet service.
+	if orderRequest.ReservationId == "" && envBool("STOCK_PRECHECK", true) {
+		entry := tenantProductCache(ctx).cached()
+		if entry != nil && time.Since(entry.FetchedAt) < envDuration("PRODUCTS_CACHE_TTL", defaultProductsCacheTTL) {
+			if ids := outOfStockItems(orderRequest.Items, entry.Products, holds.held(dropped)); len(ids) > 0 {
+				log.Printf("Rejecting order before proxying, insufficient cached stock for: %v", ids)
+				return http.StatusConflict, PlaceOrderResponse{Success: false, Message: "Some items are out of stock", OutOfStockItems: ids}, nil
+			}
+		}
+	}
+
+	// Apply any coupon code to the recomputed total before forwarding
+	discount, err := applyCoupon(&orderRequest, time.Now())
+	if err != nil {
+		var reqErr *requestError
+		if errors.As(err, &reqErr) {
+			return 0, PlaceOrderResponse{}, err
+		}
+		return 0, PlaceOrderResponse{}, &upstreamError{Status: http.StatusInternalServerError, Message: "Internal server error", Err: err}
+	}
+	// Clients that leave the total out (or send 0) get it computed from the items, as priced above,
+	// rather than forwarding a zero total to Dotnet
+	if orderRequest.TotalAmount == nil || *orderRequest.TotalAmount == 0 {
+		total := Price(itemsSubtotal(orderRequest.Items))
+		orderRequest.TotalAmount = &total
+	}
+
+	// Look up the tax rate now so a broken TAX_RATES_FILE fails the order before it reaches Dotnet
+	rate, err := taxRateFor(orderRequest.Region)
+	if err != nil {
+		return 0, PlaceOrderResponse{}, &upstreamError{Status: http.StatusInternalServerError, Message: "Internal server error", Err: err}
+	}
+
+	// Honor the client's spend cap against the total we compute, not the one it sent
+	breakdown := orderBreakdown(orderRequest.Items, discount, rate)
+	if orderRequest.MaxTotal != nil && breakdown.Total > float64(*orderRequest.MaxTotal) {
+		log.Printf("Rejecting order: total %.2f exceeds maxTotal %.2f", breakdown.Total, float64(*orderRequest.MaxTotal))
+		return 0, PlaceOrderResponse{}, &requestError{Status: http.StatusUnprocessableEntity, Message: "total exceeds maxTotal"}
+	}
+	if minTotal := envFloat("MIN_ORDER_TOTAL", 0); minTotal > 0 && breakdown.Total < minTotal {
+		log.Printf("Rejecting order: total %.2f is below MIN_ORDER_TOTAL %.2f", breakdown.Total, minTotal)
+		return 0, PlaceOrderResponse{}, &requestError{Status: http.StatusUnprocessableEntity, Message: fmt.Sprintf("Order total %.2f is below the minimum order total of %.2f", breakdown.Total, minTotal)}
+	}
+
+	// Shed load rather than queue indefinitely when MAX_CONCURRENT_ORDERS orders are already in flight,
+	// lining up to ORDER_QUEUE_SIZE of them first when that is set
+	orderLimiter := orderLimiters.get(tenantFrom(ctx))
+	maxConcurrent := envInt("MAX_CONCURRENT_ORDERS", defaultMaxConcurrentOrders)
+	if size := envInt("ORDER_QUEUE_SIZE", 0); size > 0 {
+		queue := orderQueues.get(tenantFrom(ctx))
+		if err := queue.acquire(ctx, orderLimiter, maxConcurrent, size, envDuration("ORDER_QUEUE_MAX_WAIT", defaultOrderQueueMaxWait)); err != nil {
+			log.Printf("Rejecting order: %v", err)
+			message := "Too many orders in progress, please retry"
+			if errors.Is(err, errOrderQueueTimeout) {
+				message = "Timed out waiting for an order slot, please retry"
+			}
+			return 0, PlaceOrderResponse{}, &upstreamError{Status: http.StatusServiceUnavailable, Message: message, Err: fmt.Errorf("%w: %w", errOrderCapacity, err)}
+		}
+	} else if !orderLimiter.acquire(ctx, maxConcurrent, envDuration("ORDER_QUEUE_TIMEOUT", defaultOrderQueueTimeout)) {
+		log.Printf("Rejecting order: too many orders in flight")
+		return 0, PlaceOrderResponse{}, &upstreamError{Status: http.StatusServiceUnavailable, Message: "Too many orders in progress, please retry", Err: errOrderCapacity}
+	}
+	defer orderLimiter.release()
+
+	// Reject a resubmission of an order already sent within ORDER_NONCE_WINDOW
+	if nonce := orderRequest.Nonce; nonce != "" {
+		claimed, err := orderNonces.claim(ctx, nonce, envDuration("ORDER_NONCE_WINDOW", defaultOrderNonceWindow))
+		if err != nil {
+			return 0, PlaceOrderResponse{}, &upstreamError{Status: http.StatusInternalServerError, Message: "Internal server error", Err: err}
+		}
+		if !claimed {
+			log.Printf("Rejecting order with reused nonce %s", nonce)
+			return 0, PlaceOrderResponse{}, &requestError{Status: http.StatusConflict, Message: "Duplicate order submission"}
+		}
+	}
+
+	status, orderResponse, err := dotnet.PlaceOrder(ctx, orderRequest)
+	if err != nil {
+		// An order that never reached Dotnet may be retried with the same nonce. The release doesn't use
+		// ctx, which may be canceled by then.
+		if orderRequest.Nonce != "" && !errors.Is(err, errOrderOutcomeUnknown) {
+			if err := orderNonces.release(context.WithoutCancel(ctx), orderRequest.Nonce); err != nil {
+				log.Printf("Error releasing order nonce %s: %v", orderRequest.Nonce, err)
+			}
+		}
+		return 0, PlaceOrderResponse{}, err
+	}
+
+	// --- This is where you can add logic to modify the 'orderResponse' if needed ---
+	// Once the order is placed, its reserved stock no longer needs holding.
+	// --------------------------------------------------------------------------------
+	if orderResponse.Success && orderRequest.ReservationId != "" {
+		holds.release(orderRequest.ReservationId)
+	}
+	orderResponse.Discount = discount
+	if orderResponse.Success {
+		orderResponse.Breakdown = &breakdown
+
+		// Show customers a friendlier confirmation number, keeping Dotnet's id for support lookups
+		if prefix := os.Getenv("CONFIRMATION_PREFIX"); prefix != "" && orderResponse.OrderId != "" {
+			orderResponse.UpstreamOrderId = orderResponse.OrderId
+			orderResponse.OrderId = confirmationNumber(prefix, orderResponse.OrderId, time.Now())
+		}
+	}
+
+	return status, orderResponse, nil
+}
+
+// BatchOrderRequest from B2B clients submitting several orders at once
+type BatchOrderRequest struct {
+	Orders []PlaceOrderRequest `json:"orders"`
+}
+
+// BatchOrderResult is the outcome of one order in a batch, at the same index as the order
+type BatchOrderResult struct {
+	Index           int             `json:"index"`
+	Status          int             `json:"status"` // Status the order would have got from POST /order
+	Success         bool            `json:"success"`
+	OrderId         string          `json:"orderId,omitempty"`
+	UpstreamOrderId string          `json:"upstreamOrderId,omitempty"`
+	Message         string          `json:"message,omitempty"`
+	OutOfStockItems []string        `json:"outOfStockItems,omitempty"`
+	PriceMismatches []PriceMismatch `json:"priceMismatches,omitempty"`
+	UnknownItems    []string        `json:"unknownItems,omitempty"`
+	Discount        float64         `json:"discount,omitempty"`
+	Breakdown       *OrderBreakdown `json:"breakdown,omitempty"`
+	Fields          []FieldError    `json:"fields,omitempty"` // Invalid fields when Status is 400
+}
+
+// BatchOrderResponse from Go to the client, returned with 200 when every order succeeded and 207 otherwise
+type BatchOrderResponse struct {
+	Results   []BatchOrderResult `json:"results"`
+	Succeeded int                `json:"succeeded"`
+	Failed    int                `json:"failed"`
+}
+
+// batchOrderHandler places each order of a batch through placeOrder with bounded concurrency
+func batchOrderHandler(w http.ResponseWriter, r *http.Request) {
+	// Set CORS headers for any origin
+	applyCORS(w, r, "POST, OPTIONS", "Content-Type, Authorization, X-API-Key, X-Tenant-ID")
+
+	// Handle preflight OPTIONS request
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost, http.MethodOptions)
+		return
+	}
+
+	if rejectDuringMaintenance(w, r) {
+		return
+	}
+
+	var batch BatchOrderRequest
+	if err := decodeJSON(r, &batch, "Invalid batch order request body"); err != nil {
+		writeRequestError(w, r, err)
+		return
+	}
+	if len(batch.Orders) == 0 {
+		writeError(w, r, http.StatusBadRequest, "At least one order is required")
+		return
+	}
+	if limit := envInt("ORDER_BATCH_MAX", defaultOrderBatchMax); len(batch.Orders) > limit {
+		writeError(w, r, http.StatusRequestEntityTooLarge, "Too many orders in batch")
+		return
+	}
+
+	workers := envInt("ORDER_BATCH_WORKERS", defaultOrderBatchWorkers)
+	if workers < 1 {
+		workers = 1
+	}
+
+	// Each worker writes only its own slot, so results keep the input order without locking
+	ctx := r.Context()
+	results := make([]BatchOrderResult, len(batch.Orders))
+	var g errgroup.Group
+	g.SetLimit(workers)
+	for i, order := range batch.Orders {
+		g.Go(func() error {
+			results[i] = batchOrderResult(ctx, i, order)
+			return nil
+		})
+	}
+	g.Wait()
+
+	resp := BatchOrderResponse{Results: results}
+	for _, result := range results {
+		if result.Success {
+			resp.Succeeded++
+		} else {
+			resp.Failed++
+		}
+	}
+	log.Printf("Batch of %d orders placed: %d succeeded, %d failed", len(results), resp.Succeeded, resp.Failed)
+
+	status := http.StatusOK
+	if resp.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	writeData(w, r, status, resp)
+}
+
+// batchOrderResult places one order of a batch and reports the outcome
+func batchOrderResult(ctx context.Context, index int, order PlaceOrderRequest) BatchOrderResult {
+	status, orderResponse, err := placeOrder(ctx, order)
+	if err != nil {
+		result := BatchOrderResult{Index: index, Status: http.StatusBadRequest, Message: err.Error()}
+		var valErr *validationError
+		var reqErr *requestError
+		var upErr *upstreamError
+		switch {
+		case errors.As(err, &valErr):
+			result.Message, result.Fields = "Invalid order", valErr.Fields
+		case errors.As(err, &reqErr):
+			result.Status = reqErr.Status
+		case errors.As(err, &upErr):
+			log.Printf("Error placing order %d of batch: %v", index, err)
+			result.Status, result.Message = upErr.Status, upErr.Message
+		}
+		return result
+	}
+	return BatchOrderResult{
+		Index:           index,
+		Status:          status,
+		Success:         orderResponse.Success && status == http.StatusOK,
+		OrderId:         orderResponse.OrderId,
+		UpstreamOrderId: orderResponse.UpstreamOrderId,
+		Message:         orderResponse.Message,
+		OutOfStockItems: orderResponse.OutOfStockItems,
+		PriceMismatches: orderResponse.PriceMismatches,
+		UnknownItems:    orderResponse.UnknownItems,
+		Discount:        orderResponse.Discount,
+		Breakdown:       orderResponse.Breakdown,
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newBatchUpstream fakes the Dotnet place-order endpoint, rejecting orders for item "sold-out" and
+// recording the highest number of orders in flight at once
+func newBatchUpstream(t *testing.T, delay time.Duration) *int32 {
+	t.Helper()
+	var inFlight, maxInFlight int32
+	var mu sync.Mutex
+	var n int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cur := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			old := atomic.LoadInt32(&maxInFlight)
+			if cur <= old || atomic.CompareAndSwapInt32(&maxInFlight, old, cur) {
+				break
+			}
+		}
+		time.Sleep(delay)
+
+		var order PlaceOrderRequest
+		json.NewDecoder(r.Body).Decode(&order)
+		w.Header().Set("Content-Type", "application/json")
+		if order.Items[0].Id == "sold-out" {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(PlaceOrderResponse{Success: false, Message: "Out of stock", OutOfStockItems: []string{"sold-out"}})
+			return
+		}
+		mu.Lock()
+		n++
+		id := fmt.Sprintf("order-%d", n)
+		mu.Unlock()
+		json.NewEncoder(w).Encode(PlaceOrderResponse{Success: true, Message: "Order placed", OrderId: id})
+	}))
+	os.Setenv("DOTNET_PRODUCTS_API_URL", server.URL)
+	t.Cleanup(func() {
+		server.Close()
+		os.Unsetenv("DOTNET_PRODUCTS_API_URL")
+	})
+	return &maxInFlight
+}
+
+// batchOrder builds an order for one unit of item id
+func batchOrder(id, address string) PlaceOrderRequest {
+	return PlaceOrderRequest{
+		Items:           []OrderItemRequest{{Id: id, Name: id, Quantity: 1, Price: 10}},
+		TotalAmount:     pricePtr(10),
+		DeliveryAddress: address,
+	}
+}
+
+// TestBatchOrderHandler_MixedResults tests that partial failures return 207 with per-order detail in input order
+func TestBatchOrderHandler_MixedResults(t *testing.T) {
+	newBatchUpstream(t, 0)
+
+	rr := httptest.NewRecorder()
+	batchOrderHandler(rr, postJSON(t, "/orders/batch", BatchOrderRequest{Orders: []PlaceOrderRequest{
+		batchOrder("p1", "1 Main St"),
+		batchOrder("sold-out", "2 Main St"),
+		batchOrder("p3", ""),
+		batchOrder("p4", "4 Main St"),
+	}}))
+
+	if rr.Code != http.StatusMultiStatus {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusMultiStatus)
+	}
+	var resp BatchOrderResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("could not decode batch response: %v", err)
+	}
+	if resp.Succeeded != 2 || resp.Failed != 2 || len(resp.Results) != 4 {
+		t.Fatalf("batch response = %+v, want 2 succeeded and 2 failed", resp)
+	}
+
+	want := []struct {
+		status  int
+		success bool
+	}{
+		{http.StatusOK, true},
+		{http.StatusConflict, false},
+		{http.StatusBadRequest, false},
+		{http.StatusOK, true},
+	}
+	for i, w := range want {
+		got := resp.Results[i]
+		if got.Index != i || got.Status != w.status || got.Success != w.success {
+			t.Errorf("result %d = %+v, want status %d success %v", i, got, w.status, w.success)
+		}
+		if got.Success && got.OrderId == "" {
+			t.Errorf("result %d succeeded without an order id", i)
+		}
+	}
+	if got := resp.Results[1].OutOfStockItems; len(got) != 1 || got[0] != "sold-out" {
+		t.Errorf("outOfStockItems = %v, want [sold-out]", got)
+	}
+}
+
+// TestBatchOrderHandler_AllSucceeded tests that a fully successful batch returns 200
+func TestBatchOrderHandler_AllSucceeded(t *testing.T) {
+	newBatchUpstream(t, 0)
+
+	rr := httptest.NewRecorder()
+	batchOrderHandler(rr, postJSON(t, "/orders/batch", BatchOrderRequest{Orders: []PlaceOrderRequest{
+		batchOrder("p1", "1 Main St"),
+		batchOrder("p2", "2 Main St"),
+	}}))
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+}
+
+// TestBatchOrderHandler_BoundedConcurrency tests that no more than ORDER_BATCH_WORKERS orders are in flight
+func TestBatchOrderHandler_BoundedConcurrency(t *testing.T) {
+	maxInFlight := newBatchUpstream(t, 20*time.Millisecond)
+	os.Setenv("ORDER_BATCH_WORKERS", "2")
+	defer os.Unsetenv("ORDER_BATCH_WORKERS")
+
+	var orders []PlaceOrderRequest
+	for i := 0; i < 6; i++ {
+		orders = append(orders, batchOrder(fmt.Sprintf("p%d", i), "1 Main St"))
+	}
+	rr := httptest.NewRecorder()
+	batchOrderHandler(rr, postJSON(t, "/orders/batch", BatchOrderRequest{Orders: orders}))
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if got := atomic.LoadInt32(maxInFlight); got > 2 {
+		t.Errorf("max orders in flight = %d, want at most 2", got)
+	}
+}
+
+// TestBatchOrderHandler_Validation tests that empty and oversized batches are rejected
+func TestBatchOrderHandler_Validation(t *testing.T) {
+	os.Setenv("ORDER_BATCH_MAX", "1")
+	defer os.Unsetenv("ORDER_BATCH_MAX")
+
+	tests := []struct {
+		name   string
+		orders []PlaceOrderRequest
+		want   int
+	}{
+		{"empty", nil, http.StatusBadRequest},
+		{"too many", []PlaceOrderRequest{batchOrder("p1", "a"), batchOrder("p2", "b")}, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		batchOrderHandler(rr, postJSON(t, "/orders/batch", BatchOrderRequest{Orders: tt.orders}))
+		if rr.Code != tt.want {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", tt.name, rr.Code, tt.want)
+		}
+	}
+}
+
+// TestOrderHandler_ClientCancelAbortsUpstream tests that a client disconnecting cancels the Dotnet call
+func TestOrderHandler_ClientCancelAbortsUpstream(t *testing.T) {
+	received := make(chan struct{})
+	aborted := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body) // The server only notices a closed connection once the body is read
+		close(received)
+		select {
+		case <-r.Context().Done():
+			close(aborted)
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+	os.Setenv("DOTNET_PRODUCTS_API_URL", server.URL)
+	defer os.Unsetenv("DOTNET_PRODUCTS_API_URL")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := postJSON(t, "/order", batchOrder("p1", "1 Main St")).WithContext(ctx)
+	done := make(chan struct{})
+	go func() {
+		orderHandler(httptest.NewRecorder(), req)
+		close(done)
+	}()
+
+	<-received
+	cancel()
+	select {
+	case <-aborted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("upstream request was not aborted after the client went away")
+	}
+	<-done
+}
+
+// TestOrderHandler_MaxTotal tests the spend cap against the computed total, tax included, around the boundary
+func TestOrderHandler_MaxTotal(t *testing.T) {
+	os.Setenv("TAX_RATE", "0.08")
+	defer os.Unsetenv("TAX_RATE")
+
+	tests := []struct {
+		name     string
+		maxTotal *Price
+		want     int
+	}{
+		{"no cap", nil, http.StatusOK},
+		{"cap equals total", pricePtr(108), http.StatusOK},
+		{"cap a cent below total", pricePtr(107.99), http.StatusUnprocessableEntity},
+		{"cap below subtotal", pricePtr(0), http.StatusUnprocessableEntity},
+		{"negative cap", pricePtr(-1), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dotnet := newFakeDotnet(t, nil)
+			order := PlaceOrderRequest{
+				Items:           []OrderItemRequest{{Id: "prod1", Quantity: 2, Price: 50}},
+				DeliveryAddress: "1 Main St",
+				TotalAmount:     pricePtr(100),
+				MaxTotal:        tt.maxTotal,
+			}
+			rr := httptest.NewRecorder()
+			orderHandler(rr, postJSON(t, "/order", order))
+			if rr.Code != tt.want {
+				t.Fatalf("handler returned wrong status code: got %v want %v (%s)", rr.Code, tt.want, rr.Body.String())
+			}
+			if tt.want == http.StatusUnprocessableEntity {
+				if got := strings.TrimSpace(rr.Body.String()); got != `{"error":"total exceeds maxTotal"}` {
+					t.Errorf("body = %s, want the maxTotal error", got)
+				}
+			}
+			if forwarded := len(dotnet.Orders) == 1; forwarded != (tt.want == http.StatusOK) {
+				t.Errorf("order forwarded = %v, want %v", forwarded, tt.want == http.StatusOK)
+			}
+		})
+	}
+}
+
+// TestOrderHandler_MinOrderTotal tests that MIN_ORDER_TOTAL rejects orders whose computed total falls short
+func TestOrderHandler_MinOrderTotal(t *testing.T) {
+	os.Setenv("TAX_RATE", "0.08")
+	defer os.Unsetenv("TAX_RATE")
+
+	tests := []struct {
+		name     string
+		minTotal string
+		want     int
+	}{
+		{"unset", "", http.StatusOK},
+		{"zero", "0", http.StatusOK},
+		{"minimum equals total", "108", http.StatusOK},
+		{"minimum a cent above total", "108.01", http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("MIN_ORDER_TOTAL", tt.minTotal)
+			defer os.Unsetenv("MIN_ORDER_TOTAL")
+			dotnet := newFakeDotnet(t, nil)
+			order := PlaceOrderRequest{
+				Items:           []OrderItemRequest{{Id: "prod1", Quantity: 2, Price: 50}},
+				DeliveryAddress: "1 Main St",
+				TotalAmount:     pricePtr(100),
+			}
+			rr := httptest.NewRecorder()
+			orderHandler(rr, postJSON(t, "/order", order))
+			if rr.Code != tt.want {
+				t.Fatalf("handler returned wrong status code: got %v want %v (%s)", rr.Code, tt.want, rr.Body.String())
+			}
+			if tt.want == http.StatusUnprocessableEntity {
+				want := `{"error":"Order total 108.00 is below the minimum order total of 108.01"}`
+				if got := strings.TrimSpace(rr.Body.String()); got != want {
+					t.Errorf("body = %s, want %s", got, want)
+				}
+			}
+			if forwarded := len(dotnet.Orders) == 1; forwarded != (tt.want == http.StatusOK) {
+				t.Errorf("order forwarded = %v, want %v", forwarded, tt.want == http.StatusOK)
+			}
+		})
+	}
+}
+
+// TestOrderHandler_TotalAmount tests that an omitted or zero total is computed and a sent one must match the items
+func TestOrderHandler_TotalAmount(t *testing.T) {
+	tests := []struct {
+		name     string
+		total    *Price
+		validate string
+		want     int
+		forward  Price
+	}{
+		{"omitted", nil, "", http.StatusOK, 30},
+		{"zero", pricePtr(0), "", http.StatusOK, 30},
+		{"provided", pricePtr(30), "", http.StatusOK, 30},
+		{"provided within tolerance", pricePtr(30.01), "", http.StatusOK, 30.01},
+		{"provided mismatch", pricePtr(25), "", http.StatusUnprocessableEntity, 0},
+		{"mismatch without validation", pricePtr(25), "false", http.StatusOK, 25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("VALIDATE_ORDER_TOTAL", tt.validate)
+			defer os.Unsetenv("VALIDATE_ORDER_TOTAL")
+			dotnet := newFakeDotnet(t, nil)
+			order := PlaceOrderRequest{
+				Items:           []OrderItemRequest{{Id: "prod1", Quantity: 2, Price: 10}, {Id: "prod2", Quantity: 1, Price: 10}},
+				DeliveryAddress: "1 Main St",
+				TotalAmount:     tt.total,
+			}
+			rr := httptest.NewRecorder()
+			orderHandler(rr, postJSON(t, "/order", order))
+			if rr.Code != tt.want {
+				t.Fatalf("handler returned wrong status code: got %v want %v (%s)", rr.Code, tt.want, rr.Body.String())
+			}
+			if tt.want != http.StatusOK {
+				want := `{"error":"totalAmount 25.00 does not match the items total of 30.00"}`
+				if got := strings.TrimSpace(rr.Body.String()); got != want {
+					t.Errorf("body = %s, want %s", got, want)
+				}
+				return
+			}
+			if got := dotnet.lastOrder(t).TotalAmount; got == nil || *got != tt.forward {
+				t.Errorf("forwarded total = %v, want %v", got, tt.forward)
+			}
+		})
+	}
+}
+
+// pricePtr returns a pointer to p, for optional Price fields
+func pricePtr(p Price) *Price {
+	return &p
+}
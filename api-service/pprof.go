@@ -0,0 +1,24 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/http/pprof"
+)
+
+// pprofEnabled reports whether ENABLE_PPROF=true exposes the profiling endpoints; it is off by default
+func pprofEnabled() bool {
+	return envBool("ENABLE_PPROF", false)
+}
+
+// registerPprof serves the net/http/pprof handlers under /debug/pprof/, behind requireAdmin so
+// profiles are never public even when enabled. Named profiles such as /debug/pprof/heap go through
+// Index. Importing net/http/pprof also registers on http.DefaultServeMux, which this service never serves.
+func registerPprof(mux *http.ServeMux) {
+	log.Println("WARNING: pprof endpoints enabled under /debug/pprof/ for admin callers. Only enable this for debugging.")
+	mux.Handle("/debug/pprof/", requireAdmin(http.HandlerFunc(pprof.Index)))
+	mux.Handle("/debug/pprof/cmdline", requireAdmin(http.HandlerFunc(pprof.Cmdline)))
+	mux.Handle("/debug/pprof/profile", requireAdmin(http.HandlerFunc(pprof.Profile)))
+	mux.Handle("/debug/pprof/symbol", requireAdmin(http.HandlerFunc(pprof.Symbol)))
+	mux.Handle("/debug/pprof/trace", requireAdmin(http.HandlerFunc(pprof.Trace)))
+}
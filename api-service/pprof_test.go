@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// getPprof requests a pprof page through the full route table with an optional bearer token
+func getPprof(path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rr := httptest.NewRecorder()
+	routes().ServeHTTP(rr, req)
+	return rr
+}
+
+// TestPprof_DisabledByDefault tests that the profiling endpoints don't exist unless ENABLE_PPROF is set
+func TestPprof_DisabledByDefault(t *testing.T) {
+	withAdminToken(t, "secret")
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/heap", "/debug/pprof/cmdline"} {
+		if rr := getPprof(path, "secret"); rr.Code != http.StatusNotFound {
+			t.Errorf("handler returned wrong status code for %s: got %v want %v", path, rr.Code, http.StatusNotFound)
+		}
+	}
+}
+
+// TestPprof_Enabled tests that enabled profiling endpoints are served to admins only
+func TestPprof_Enabled(t *testing.T) {
+	withAdminToken(t, "secret")
+	os.Setenv("ENABLE_PPROF", "true")
+	defer os.Unsetenv("ENABLE_PPROF")
+
+	if rr := getPprof("/debug/pprof/heap", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("handler returned wrong status code without a token: got %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+	if rr := getPprof("/debug/pprof/heap", "wrong"); rr.Code != http.StatusForbidden {
+		t.Errorf("handler returned wrong status code with a wrong token: got %v want %v", rr.Code, http.StatusForbidden)
+	}
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/heap", "/debug/pprof/cmdline"} {
+		if rr := getPprof(path, "secret"); rr.Code != http.StatusOK {
+			t.Errorf("handler returned wrong status code for %s: got %v want %v", path, rr.Code, http.StatusOK)
+		}
+	}
+}
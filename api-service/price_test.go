@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// serveRawProducts fakes the Dotnet products endpoint with a raw JSON body
+func serveRawProducts(t *testing.T, body string) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, body)
+	}))
+	os.Setenv("DOTNET_PRODUCTS_API_URL", server.URL)
+	productsCache.invalidate()
+	t.Cleanup(func() {
+		server.Close()
+		os.Unsetenv("DOTNET_PRODUCTS_API_URL")
+		productsCache.invalidate()
+	})
+}
+
+// TestProductsHandler_StringPrices tests that products with string prices are served with numeric prices
+func TestProductsHandler_StringPrices(t *testing.T) {
+	serveRawProducts(t, `[{"id":"p1","price":"19.99"},{"id":"p2","price":5}]`)
+
+	status, products := getProducts(t, "?maxPrice=10")
+	if status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if len(products) != 1 || products[0].Id != "p2" {
+		t.Errorf("got products %v, want [p2]", productIDs(products))
+	}
+}
+
+// TestProductsHandler_NonNumericPrice tests that an unparseable upstream price is reported as a 500
+func TestProductsHandler_NonNumericPrice(t *testing.T) {
+	serveRawProducts(t, `[{"id":"p1","price":"free"}]`)
+
+	if status, _ := getProducts(t, ""); status != http.StatusInternalServerError {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
+	}
+}
+
+// TestOrderHandler_StringPrices tests that orders accept string prices and reject non-numeric ones
+func TestOrderHandler_StringPrices(t *testing.T) {
+	dotnet := newFakeDotnet(t, nil)
+
+	post := func(price string) int {
+		body := `{"items":[{"id":"p1","quantity":2,"price":` + price + `}],"totalAmount":"10.50","deliveryAddress":"1 Main St"}`
+		req := httptest.NewRequest(http.MethodPost, "/order", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		orderHandler(rr, req)
+		return rr.Code
+	}
+
+	if code := post(`"5.25"`); code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", code, http.StatusOK)
+	}
+	if got := dotnet.lastOrder(t).Items[0].Price; got != 5.25 {
+		t.Errorf("forwarded price = %v, want 5.25", got)
+	}
+	if code := post(`"five"`); code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", code, http.StatusBadRequest)
+	}
+}
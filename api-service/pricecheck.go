@@ -0,0 +1,47 @@
+package main
+
+import "math"
+
+// defaultPriceTolerance is how far a submitted price may be from the catalog when PRICE_TOLERANCE is not set
+const defaultPriceTolerance = 0.01
+
+// verifyPrices compares each item's submitted price with the catalog, allowing a difference of up to
+// tolerance. It returns the mismatched items and the ids of items missing from the catalog, each once.
+func verifyPrices(items []OrderItemRequest, products []Product, tolerance float64) ([]PriceMismatch, []string) {
+	prices := make(map[string]Price, len(products))
+	for _, p := range products {
+		prices[p.Id] = p.Price
+	}
+	var mismatches []PriceMismatch
+	var unknown []string
+	reported := make(map[string]bool)
+	for _, item := range items {
+		if reported[item.Id] {
+			continue
+		}
+		price, known := prices[item.Id]
+		switch {
+		case !known:
+			unknown = append(unknown, item.Id)
+			reported[item.Id] = true
+		case roundMoney(math.Abs(float64(item.Price-price))) > tolerance:
+			mismatches = append(mismatches, PriceMismatch{Id: item.Id, Price: float64(item.Price), CatalogPrice: float64(price)})
+			reported[item.Id] = true
+		}
+	}
+	return mismatches, unknown
+}
+
+// withCatalogPrices returns a copy of items priced from the catalog; items must all be in products
+func withCatalogPrices(items []OrderItemRequest, products []Product) []OrderItemRequest {
+	prices := make(map[string]Price, len(products))
+	for _, p := range products {
+		prices[p.Id] = p.Price
+	}
+	priced := make([]OrderItemRequest, len(items))
+	for i, item := range items {
+		item.Price = prices[item.Id]
+		priced[i] = item
+	}
+	return priced
+}
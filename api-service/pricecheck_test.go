@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// verifyClientPrices turns price verification on for the duration of the test, as in production
+func verifyClientPrices(t *testing.T) {
+	t.Helper()
+	os.Setenv("TRUST_CLIENT_PRICES", "false")
+	t.Cleanup(func() { os.Setenv("TRUST_CLIENT_PRICES", "true") })
+}
+
+// TestVerifyPrices tests the tolerance and that each mismatched or unknown id is reported once
+func TestVerifyPrices(t *testing.T) {
+	products := []Product{{Id: "p1", Price: 10}, {Id: "p2", Price: 2.5}}
+	items := []OrderItemRequest{
+		{Id: "p1", Quantity: 1, Price: 10.01}, // Within a cent
+		{Id: "p2", Quantity: 1, Price: 2.48},
+		{Id: "p2", Quantity: 1, Price: 2.48},
+		{Id: "gone", Quantity: 1, Price: 1},
+	}
+	mismatches, unknown := verifyPrices(items, products, 0.01)
+	if want := []PriceMismatch{{Id: "p2", Price: 2.48, CatalogPrice: 2.5}}; !reflect.DeepEqual(mismatches, want) {
+		t.Errorf("mismatches = %+v, want %+v", mismatches, want)
+	}
+	if want := []string{"gone"}; !reflect.DeepEqual(unknown, want) {
+		t.Errorf("unknown = %v, want %v", unknown, want)
+	}
+}
+
+// TestOrderHandler_PriceVerification tests matching, mismatched and unknown items against the catalog
+func TestOrderHandler_PriceVerification(t *testing.T) {
+	verifyClientPrices(t)
+	tests := []struct {
+		name       string
+		item       OrderItemRequest
+		wantStatus int
+		wantResp   PlaceOrderResponse
+	}{
+		{"match", OrderItemRequest{Id: "p1", Quantity: 2, Price: 19.99}, http.StatusOK, PlaceOrderResponse{}},
+		{"within tolerance", OrderItemRequest{Id: "p1", Quantity: 1, Price: 19.98}, http.StatusOK, PlaceOrderResponse{}},
+		{"mismatch", OrderItemRequest{Id: "p1", Quantity: 2, Price: 0.99}, http.StatusConflict, PlaceOrderResponse{
+			Message:         "Some item prices have changed",
+			PriceMismatches: []PriceMismatch{{Id: "p1", Price: 0.99, CatalogPrice: 19.99}},
+		}},
+		{"unknown item", OrderItemRequest{Id: "nope", Quantity: 1, Price: 5}, http.StatusConflict, PlaceOrderResponse{
+			Message:      "Some items are not in the catalog",
+			UnknownItems: []string{"nope"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dotnet := newFakeDotnet(t, []Product{{Id: "p1", Name: "Lamp", Price: 19.99, Stock: 10}})
+			order := PlaceOrderRequest{Items: []OrderItemRequest{tt.item}, DeliveryAddress: "1 Main St", TotalAmount: pricePtr(tt.item.Price * Price(tt.item.Quantity))}
+			rr := httptest.NewRecorder()
+			orderHandler(rr, postJSON(t, "/order", order))
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v (%s)", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				var resp PlaceOrderResponse
+				json.Unmarshal(rr.Body.Bytes(), &resp)
+				if !reflect.DeepEqual(resp, tt.wantResp) {
+					t.Errorf("response = %+v, want %+v", resp, tt.wantResp)
+				}
+				if len(dotnet.Orders) != 0 {
+					t.Errorf("rejected order was forwarded")
+				}
+				return
+			}
+			// Accepted orders are forwarded at the catalog price
+			if got := dotnet.lastOrder(t).Items[0].Price; got != 19.99 {
+				t.Errorf("forwarded price = %v, want the catalog price 19.99", got)
+			}
+		})
+	}
+}
+
+// TestOrderHandler_TotalAtCatalogPrices tests that a sent total is checked against, and replaced by,
+// the catalog total, so per-item tolerance can't add up to an understated total
+func TestOrderHandler_TotalAtCatalogPrices(t *testing.T) {
+	verifyClientPrices(t)
+	tests := []struct {
+		name       string
+		quantity   int
+		wantStatus int
+	}{
+		{"one item", 1, http.StatusOK},
+		{"large quantity", 100, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dotnet := newFakeDotnet(t, []Product{{Id: "p1", Name: "Lamp", Price: 19.99, Stock: 1000}})
+			// Each item a cent under the catalog price, within PRICE_TOLERANCE, and a total to match
+			item := OrderItemRequest{Id: "p1", Quantity: tt.quantity, Price: 19.98}
+			order := PlaceOrderRequest{Items: []OrderItemRequest{item}, DeliveryAddress: "1 Main St", TotalAmount: pricePtr(19.98 * Price(tt.quantity))}
+			rr := httptest.NewRecorder()
+			orderHandler(rr, postJSON(t, "/order", order))
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v (%s)", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				if len(dotnet.Orders) != 0 {
+					t.Errorf("rejected order was forwarded")
+				}
+				return
+			}
+			if got := dotnet.lastOrder(t).TotalAmount; got == nil || *got != 19.99 {
+				t.Errorf("forwarded total = %v, want the catalog total 19.99", got)
+			}
+		})
+	}
+}
+
+// TestOrderHandler_TrustClientPrices tests that TRUST_CLIENT_PRICES=true forwards submitted prices unchecked
+func TestOrderHandler_TrustClientPrices(t *testing.T) {
+	dotnet := newFakeDotnet(t, []Product{{Id: "p1", Name: "Lamp", Price: 19.99, Stock: 10}})
+	order := PlaceOrderRequest{Items: []OrderItemRequest{{Id: "p1", Quantity: 1, Price: 0.99}}, DeliveryAddress: "1 Main St", TotalAmount: pricePtr(0.99)}
+	rr := httptest.NewRecorder()
+	orderHandler(rr, postJSON(t, "/order", order))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if got := dotnet.lastOrder(t).Items[0].Price; got != 0.99 {
+		t.Errorf("forwarded price = %v, want the submitted 0.99", got)
+	}
+}
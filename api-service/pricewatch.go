@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// defaultPriceWebhookTimeout bounds each price change notification when PRICE_CHANGE_WEBHOOK_TIMEOUT is not set
+const defaultPriceWebhookTimeout = 5 * time.Second
+
+// PriceChange is one product whose price differs between two catalog refreshes
+type PriceChange struct {
+	Id       string  `json:"id"`
+	Name     string  `json:"name"`
+	OldPrice float64 `json:"oldPrice"`
+	NewPrice float64 `json:"newPrice"`
+}
+
+// PriceChangeNotification is the payload POSTed to PRICE_CHANGE_WEBHOOK_URL
+type PriceChangeNotification struct {
+	DetectedAt string        `json:"detectedAt"`
+	Tenant     string        `json:"tenant,omitempty"` // Whose catalog changed, with TENANT_UPSTREAMS_FILE
+	Changes    []PriceChange `json:"changes"`
+}
+
+// priceChanges lists the products in next whose price differs from prev, in next's order.
+// Products added or removed between the two catalogs are not price changes.
+func priceChanges(prev, next []Product) []PriceChange {
+	oldPrices := make(map[string]Price, len(prev))
+	for _, p := range prev {
+		oldPrices[p.Id] = p.Price
+	}
+	var changes []PriceChange
+	for _, p := range next {
+		if old, ok := oldPrices[p.Id]; ok && old != p.Price {
+			changes = append(changes, PriceChange{Id: p.Id, Name: p.Name, OldPrice: float64(old), NewPrice: float64(p.Price)})
+		}
+	}
+	return changes
+}
+
+// notifyPriceChanges POSTs changes to PRICE_CHANGE_WEBHOOK_URL in the background. It does nothing
+// when the URL is not set, and failures are only logged so catalog refreshes never wait on the webhook.
+func notifyPriceChanges(tenant string, changes []PriceChange) {
+	url := os.Getenv("PRICE_CHANGE_WEBHOOK_URL")
+	if url == "" || len(changes) == 0 {
+		return
+	}
+	timeout := envDuration("PRICE_CHANGE_WEBHOOK_TIMEOUT", defaultPriceWebhookTimeout)
+	go func() {
+		if err := sendPriceChangeWebhook(url, timeout, tenant, changes, time.Now()); err != nil {
+			log.Printf("Error sending price change webhook: %v", err)
+		}
+	}()
+}
+
+// sendPriceChangeWebhook POSTs one notification for changes to tenant's catalog to url
+func sendPriceChangeWebhook(url string, timeout time.Duration, tenant string, changes []PriceChange, now time.Time) error {
+	body, err := json.Marshal(PriceChangeNotification{DetectedAt: now.UTC().Format(time.RFC3339), Tenant: tenant, Changes: changes})
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: timeout, Transport: userAgentTransport{}}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	log.Printf("Sent price change webhook for %d product(s)", len(changes))
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestPriceChanges tests that only products present in both catalogs with a different price are reported
+func TestPriceChanges(t *testing.T) {
+	prev := []Product{
+		{Id: "p1", Name: "Headphones", Price: 99.99},
+		{Id: "p2", Name: "Speaker", Price: 49.99},
+		{Id: "p3", Name: "Cable", Price: 9.99},
+	}
+	next := []Product{
+		{Id: "p3", Name: "Cable", Price: 7.49},
+		{Id: "p1", Name: "Headphones", Price: 99.99},
+		{Id: "p4", Name: "Charger", Price: 19.99},
+		{Id: "p2", Name: "Speaker", Price: 59.99, Stock: 2},
+	}
+
+	want := []PriceChange{
+		{Id: "p3", Name: "Cable", OldPrice: 9.99, NewPrice: 7.49},
+		{Id: "p2", Name: "Speaker", OldPrice: 49.99, NewPrice: 59.99},
+	}
+	if got := priceChanges(prev, next); !reflect.DeepEqual(got, want) {
+		t.Errorf("priceChanges = %+v, want %+v", got, want)
+	}
+	if got := priceChanges(prev, prev); len(got) != 0 {
+		t.Errorf("priceChanges of identical catalogs = %+v, want none", got)
+	}
+}
+
+// TestProductCache_PriceChangeWebhook tests that a refresh with changed prices posts the diff to the webhook
+func TestProductCache_PriceChangeWebhook(t *testing.T) {
+	received := make(chan PriceChangeNotification, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n PriceChangeNotification
+		json.NewDecoder(r.Body).Decode(&n)
+		received <- n
+	}))
+	defer webhook.Close()
+	os.Setenv("PRICE_CHANGE_WEBHOOK_URL", webhook.URL)
+	defer os.Unsetenv("PRICE_CHANGE_WEBHOOK_URL")
+
+	price := Price(99.99)
+	newProductsUpstream(t, func() []Product {
+		return []Product{{Id: "prod1", Name: "Headphones", Price: price, Stock: 10}}
+	})
+	productsCache.mu.Lock()
+	productsCache.previous = nil // Don't compare against another test's catalog
+	productsCache.mu.Unlock()
+
+	getProducts(t, "")
+	productsCache.invalidate()
+	getProducts(t, "")
+	select {
+	case n := <-received:
+		t.Errorf("webhook called for an unchanged catalog: %+v", n)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	price = 89.99
+	productsCache.invalidate()
+	getProducts(t, "")
+	select {
+	case n := <-received:
+		want := []PriceChange{{Id: "prod1", Name: "Headphones", OldPrice: 99.99, NewPrice: 89.99}}
+		if !reflect.DeepEqual(n.Changes, want) || n.DetectedAt == "" {
+			t.Errorf("webhook payload = %+v, want changes %+v", n, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook was not called after a price change")
+	}
+}
+
+// TestSendPriceChangeWebhook_Error tests that a failing webhook is reported as an error
+func TestSendPriceChangeWebhook_Error(t *testing.T) {
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer webhook.Close()
+
+	if err := sendPriceChangeWebhook(webhook.URL, time.Second, "", []PriceChange{{Id: "p1"}}, time.Now()); err == nil {
+		t.Error("sendPriceChangeWebhook returned no error for a 500")
+	}
+}
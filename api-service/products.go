@@ -0,0 +1,212 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// productFilter narrows the catalog returned by productsHandler based on query parameters
+type productFilter struct {
+	Query    string   // ?q= case-insensitive substring of name or description
+	Category string   // ?category= case-insensitive exact match on the normalized category
+	MinPrice *float64 // ?minPrice= inclusive lower bound
+	MaxPrice *float64 // ?maxPrice= inclusive upper bound
+
+	HideOutOfStock bool // ?hideOutOfStock=true drops products with no stock
+}
+
+// parseProductFilter reads the product filters from the query string
+func parseProductFilter(query url.Values) (productFilter, error) {
+	f := productFilter{
+		Query:    strings.ToLower(strings.TrimSpace(query.Get("q"))),
+		Category: strings.ToLower(strings.TrimSpace(query.Get("category"))),
+	}
+	var err error
+	if f.MinPrice, err = parsePriceParam(query, "minPrice"); err != nil {
+		return f, err
+	}
+	if f.MaxPrice, err = parsePriceParam(query, "maxPrice"); err != nil {
+		return f, err
+	}
+	if v := query.Get("hideOutOfStock"); v != "" {
+		if f.HideOutOfStock, err = strconv.ParseBool(v); err != nil {
+			return f, &requestError{Status: http.StatusBadRequest, Message: "hideOutOfStock must be true or false"}
+		}
+	}
+	return f, nil
+}
+
+// parsePriceParam parses an optional non-negative price query parameter
+func parsePriceParam(query url.Values, param string) (*float64, error) {
+	v := query.Get(param)
+	if v == "" {
+		return nil, nil
+	}
+	price, err := strconv.ParseFloat(v, 64)
+	if err != nil || price < 0 {
+		return nil, &requestError{Status: http.StatusBadRequest, Message: fmt.Sprintf("%s must be a non-negative number", param)}
+	}
+	return &price, nil
+}
+
+// active reports whether any filter was requested
+func (f productFilter) active() bool {
+	return f.Query != "" || f.Category != "" || f.MinPrice != nil || f.MaxPrice != nil || f.HideOutOfStock
+}
+
+// matches reports whether a product passes every requested filter
+func (f productFilter) matches(p Product) bool {
+	if f.Query != "" &&
+		!strings.Contains(strings.ToLower(p.Name), f.Query) &&
+		!strings.Contains(strings.ToLower(p.Description), f.Query) {
+		return false
+	}
+	if f.Category != "" && strings.ToLower(categoryName(p)) != f.Category {
+		return false
+	}
+	if f.MinPrice != nil && float64(p.Price) < *f.MinPrice {
+		return false
+	}
+	if f.MaxPrice != nil && float64(p.Price) > *f.MaxPrice {
+		return false
+	}
+	if f.HideOutOfStock && p.Stock <= 0 {
+		return false
+	}
+	return true
+}
+
+// apply returns the products passing the filter; the result is never nil so it encodes as []
+func (f productFilter) apply(products []Product) []Product {
+	filtered := make([]Product, 0, len(products))
+	for _, p := range products {
+		if f.matches(p) {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
+
+// wantsNDJSON reports whether the client asked for newline-delimited JSON via the Accept header
+func wantsNDJSON(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err == nil && mediaType == "application/x-ndjson" {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultLowStockThreshold is the stock level at or below which a product is flagged lowStock
+const defaultLowStockThreshold = 5
+
+// ProductResponse is a product as returned to React, with fields computed here rather than by the Dotnet service
+type ProductResponse struct {
+	Product
+	LowStock bool `json:"lowStock"` // In stock but at or below LOW_STOCK_THRESHOLD
+}
+
+// productResponses wraps products for the response, flagging those with 0 < Stock <= threshold as low
+// stock and giving products without an image defaultImage, unless it is empty
+func productResponses(products []Product, threshold int, defaultImage string) []ProductResponse {
+	response := make([]ProductResponse, len(products))
+	for i, p := range products {
+		if p.ImageUrl == "" {
+			p.ImageUrl = defaultImage
+		}
+		response[i] = ProductResponse{Product: p, LowStock: p.Stock > 0 && p.Stock <= threshold}
+	}
+	return response
+}
+
+// productFields maps each field name accepted by ?fields= to the value it selects from a product
+var productFields = map[string]func(ProductResponse) interface{}{
+	"id":          func(p ProductResponse) interface{} { return p.Id },
+	"name":        func(p ProductResponse) interface{} { return p.Name },
+	"price":       func(p ProductResponse) interface{} { return p.Price },
+	"imageUrl":    func(p ProductResponse) interface{} { return p.ImageUrl },
+	"description": func(p ProductResponse) interface{} { return p.Description },
+	"stock":       func(p ProductResponse) interface{} { return p.Stock },
+	"category":    func(p ProductResponse) interface{} { return p.Category },
+	"lowStock":    func(p ProductResponse) interface{} { return p.LowStock },
+}
+
+// parseProductFields reads ?fields=, a comma-separated list of product fields to return. It returns
+// nil when the parameter is absent so the full products are returned.
+func parseProductFields(query url.Values) ([]string, error) {
+	if !query.Has("fields") {
+		return nil, nil
+	}
+	var fields []string
+	for _, name := range strings.Split(query.Get("fields"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := productFields[name]; !ok {
+			allowed := slices.Sorted(maps.Keys(productFields))
+			return nil, &requestError{Status: http.StatusBadRequest, Message: fmt.Sprintf("unknown field %q in fields, allowed: %s", name, strings.Join(allowed, ", "))}
+		}
+		fields = append(fields, name)
+	}
+	if len(fields) == 0 {
+		return nil, &requestError{Status: http.StatusBadRequest, Message: "fields must name at least one field"}
+	}
+	return fields, nil
+}
+
+// projectProducts reduces each product to the requested fields
+func projectProducts(products []ProductResponse, fields []string) []map[string]interface{} {
+	projected := make([]map[string]interface{}, len(products))
+	for i, p := range products {
+		m := make(map[string]interface{}, len(fields))
+		for _, name := range fields {
+			m[name] = productFields[name](p)
+		}
+		projected[i] = m
+	}
+	return projected
+}
+
+// writeProductsNDJSON writes one JSON product per line, flushing after each so clients can render incrementally
+func writeProductsNDJSON[P any](w http.ResponseWriter, products []P) error {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	rc := http.NewResponseController(w)
+	for _, p := range products {
+		line, err := marshalResponse(p)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			return err
+		}
+		if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+	}
+	return nil
+}
+
+// productsCacheControl returns the Cache-Control for a /products response. With
+// PRODUCTS_CLIENT_CACHE_MAX_AGE set, browsers and CDNs may reuse the catalog for that many seconds;
+// otherwise, and for a stale catalog, they must revalidate it with its ETag first. Under
+// AUTH_REQUIRED=true the response is private, so shared caches never hand it to another client.
+func productsCacheControl(stale bool) string {
+	maxAge := envInt("PRODUCTS_CLIENT_CACHE_MAX_AGE", 0)
+	visibility := "public"
+	if authRequired() {
+		visibility = "private"
+	}
+	if maxAge <= 0 || stale {
+		return visibility + ", no-cache"
+	}
+	return visibility + ", max-age=" + strconv.Itoa(maxAge)
+}
@@ -0,0 +1,761 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newProductsUpstream starts a fake Dotnet service serving the products returned by catalog and counts its hits
+func newProductsUpstream(t *testing.T, catalog func() []Product) (*httptest.Server, *int32) {
+	t.Helper()
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(catalog())
+	}))
+	os.Setenv("DOTNET_PRODUCTS_API_URL", server.URL)
+	productsCache.invalidate()
+	t.Cleanup(func() {
+		server.Close()
+		os.Unsetenv("DOTNET_PRODUCTS_API_URL")
+		productsCache.invalidate()
+	})
+	return server, &hits
+}
+
+// TestProductsHandler_ETag tests that the ETag is stable for a catalog and honored via If-None-Match
+func TestProductsHandler_ETag(t *testing.T) {
+	newProductsUpstream(t, func() []Product {
+		return []Product{{Id: "prod1", Name: "Headphones", Price: 99.99, Stock: 10}}
+	})
+
+	rr := httptest.NewRecorder()
+	productsHandler(rr, httptest.NewRequest(http.MethodGet, "/products", nil))
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	etag := rr.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("handler did not set an ETag header")
+	}
+
+	// A second fetch of the same catalog yields the same ETag
+	productsCache.invalidate()
+	rr = httptest.NewRecorder()
+	productsHandler(rr, httptest.NewRequest(http.MethodGet, "/products", nil))
+	if got := rr.Header().Get("ETag"); got != etag {
+		t.Errorf("ETag changed for identical catalog: got %v want %v", got, etag)
+	}
+
+	// A matching If-None-Match yields 304 with no body
+	req := httptest.NewRequest(http.MethodGet, "/products", nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	productsHandler(rr, req)
+	if status := rr.Code; status != http.StatusNotModified {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotModified)
+	}
+	if rr.Body.Len() != 0 {
+		t.Errorf("expected empty body on 304, got %q", rr.Body.String())
+	}
+}
+
+// TestProductsHandler_ETagChangesWithCatalog tests that a changed catalog produces a new ETag
+func TestProductsHandler_ETagChangesWithCatalog(t *testing.T) {
+	stock := 10
+	newProductsUpstream(t, func() []Product {
+		return []Product{{Id: "prod1", Name: "Headphones", Price: 99.99, Stock: stock}}
+	})
+
+	rr := httptest.NewRecorder()
+	productsHandler(rr, httptest.NewRequest(http.MethodGet, "/products", nil))
+	oldETag := rr.Header().Get("ETag")
+
+	stock = 9
+	productsCache.invalidate()
+	req := httptest.NewRequest(http.MethodGet, "/products", nil)
+	req.Header.Set("If-None-Match", oldETag)
+	rr = httptest.NewRecorder()
+	productsHandler(rr, req)
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if got := rr.Header().Get("ETag"); got == oldETag {
+		t.Errorf("ETag did not change after catalog changed: %v", got)
+	}
+}
+
+// TestProductsHandler_Head tests that HEAD returns the GET headers with an empty body
+func TestProductsHandler_Head(t *testing.T) {
+	newProductsUpstream(t, func() []Product {
+		return []Product{{Id: "prod1", Name: "Headphones", Price: 99.99, Stock: 10}}
+	})
+
+	get := httptest.NewRecorder()
+	productsHandler(get, httptest.NewRequest(http.MethodGet, "/products", nil))
+	head := httptest.NewRecorder()
+	productsHandler(head, httptest.NewRequest(http.MethodHead, "/products", nil))
+
+	if status := head.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if head.Body.Len() != 0 {
+		t.Errorf("HEAD response has a body: %q", head.Body.String())
+	}
+	for _, name := range []string{"Content-Type", "ETag", "Last-Modified"} {
+		if got, want := head.Header().Get(name), get.Header().Get(name); got == "" || got != want {
+			t.Errorf("HEAD %s = %q, want GET's %q", name, got, want)
+		}
+	}
+
+	// Conditional HEAD requests revalidate like GET
+	req := httptest.NewRequest(http.MethodHead, "/products", nil)
+	req.Header.Set("If-None-Match", get.Header().Get("ETag"))
+	rr := httptest.NewRecorder()
+	productsHandler(rr, req)
+	if status := rr.Code; status != http.StatusNotModified {
+		t.Errorf("handler returned wrong status code for conditional HEAD: got %v want %v", status, http.StatusNotModified)
+	}
+}
+
+// TestProductsHandler_CacheControl tests Cache-Control from PRODUCTS_CLIENT_CACHE_MAX_AGE and AUTH_REQUIRED, also on a 304
+func TestProductsHandler_CacheControl(t *testing.T) {
+	newProductsUpstream(t, func() []Product {
+		return []Product{{Id: "prod1", Name: "Headphones", Price: 99.99, Stock: 10}}
+	})
+	defer os.Unsetenv("PRODUCTS_CLIENT_CACHE_MAX_AGE")
+	defer os.Unsetenv("AUTH_REQUIRED")
+	tests := []struct {
+		maxAge, authRequired string
+		want                 string
+	}{
+		{"", "", "public, no-cache"},
+		{"300", "", "public, max-age=300"},
+		{"0", "", "public, no-cache"},
+		{"-5", "", "public, no-cache"},
+		{"invalid", "", "public, no-cache"},
+		{"300", "true", "private, max-age=300"},
+		{"", "true", "private, no-cache"},
+	}
+	for _, tt := range tests {
+		os.Setenv("PRODUCTS_CLIENT_CACHE_MAX_AGE", tt.maxAge)
+		os.Setenv("AUTH_REQUIRED", tt.authRequired)
+
+		// The handler is called directly, so AUTH_REQUIRED only affects the headers here
+		rr := httptest.NewRecorder()
+		productsHandler(rr, httptest.NewRequest(http.MethodGet, "/products", nil))
+		if got := rr.Header().Get("Cache-Control"); got != tt.want {
+			t.Errorf("max-age %q, auth %q: Cache-Control = %q, want %q", tt.maxAge, tt.authRequired, got, tt.want)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/products", nil)
+		req.Header.Set("If-None-Match", rr.Header().Get("ETag"))
+		notModified := httptest.NewRecorder()
+		productsHandler(notModified, req)
+		if status := notModified.Code; status != http.StatusNotModified {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusNotModified)
+		}
+		if got := notModified.Header().Get("Cache-Control"); got != tt.want {
+			t.Errorf("max-age %q, auth %q: Cache-Control on 304 = %q, want %q", tt.maxAge, tt.authRequired, got, tt.want)
+		}
+	}
+}
+
+// TestProductsHandler_Vary tests that the response varies on every request header that selects its representation
+func TestProductsHandler_Vary(t *testing.T) {
+	newProductsUpstream(t, func() []Product { return []Product{{Id: "prod1"}} })
+	handler := withServerMiddleware(http.HandlerFunc(productsHandler))
+	vary := func() []string {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/products", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		return rr.Header().Values("Vary")
+	}
+
+	if got, want := vary(), []string{"Accept-Encoding", "Accept"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Vary = %v, want %v", got, want)
+	}
+
+	os.Setenv("PRODUCT_LANGUAGES", "en,de")
+	defer os.Unsetenv("PRODUCT_LANGUAGES")
+	useTenants(t, map[string]string{"acme": "http://acme:8080"})
+	if got, want := vary(), []string{"Accept-Encoding", "Accept-Language", "Accept", "X-Tenant-ID"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Vary with languages and tenants = %v, want %v", got, want)
+	}
+}
+
+// TestEtagMatches tests If-None-Match list and weak comparison handling
+func TestEtagMatches(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{`"abc"`, true},
+		{`W/"abc"`, true},
+		{`"xyz", "abc"`, true},
+		{`"xyz"`, false},
+		{"*", true},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, `"abc"`); got != tt.want {
+			t.Errorf("etagMatches(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+// TestProductsHandler_IfModifiedSince tests conditional GET using Last-Modified
+func TestProductsHandler_IfModifiedSince(t *testing.T) {
+	newProductsUpstream(t, func() []Product {
+		return []Product{{Id: "prod1", Name: "Headphones", Price: 99.99, Stock: 10}}
+	})
+
+	rr := httptest.NewRecorder()
+	productsHandler(rr, httptest.NewRequest(http.MethodGet, "/products", nil))
+	lastModified := rr.Header().Get("Last-Modified")
+	if _, err := http.ParseTime(lastModified); err != nil {
+		t.Fatalf("handler returned invalid Last-Modified %q: %v", lastModified, err)
+	}
+
+	tests := []struct {
+		name            string
+		ifModifiedSince string
+		want            int
+	}{
+		{"same timestamp", lastModified, http.StatusNotModified},
+		{"later timestamp", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), http.StatusNotModified},
+		{"earlier timestamp", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), http.StatusOK},
+		{"malformed date", "not-a-date", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/products", nil)
+			req.Header.Set("If-Modified-Since", tt.ifModifiedSince)
+			rr := httptest.NewRecorder()
+			productsHandler(rr, req)
+			if status := rr.Code; status != tt.want {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tt.want)
+			}
+			if tt.want == http.StatusOK && rr.Body.Len() == 0 {
+				t.Error("expected catalog body on 200")
+			}
+		})
+	}
+}
+
+// getProducts calls productsHandler with the given query string and decodes the product list
+func getProducts(t *testing.T, query string) (int, []Product) {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	productsHandler(rr, httptest.NewRequest(http.MethodGet, "/products"+query, nil))
+	var products []Product
+	if rr.Code == http.StatusOK {
+		if err := json.Unmarshal(rr.Body.Bytes(), &products); err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+	}
+	return rr.Code, products
+}
+
+func productIDs(products []Product) []string {
+	ids := make([]string, 0, len(products))
+	for _, p := range products {
+		ids = append(ids, p.Id)
+	}
+	return ids
+}
+
+// TestProductsHandler_CategoryFilter tests ?category= alone and combined with search and price filters
+func TestProductsHandler_CategoryFilter(t *testing.T) {
+	newFakeDotnet(t, []Product{
+		{Id: "p1", Name: "Go Programming", Price: 40, Category: "Books"},
+		{Id: "p2", Name: "Wireless Headphones", Price: 99.99, Category: "Electronics"},
+		{Id: "p3", Name: "Headphones Stand", Price: 20, Category: "Electronics"},
+		{Id: "p4", Name: "Headphones Buying Guide", Price: 10, Category: " books "},
+		{Id: "p5", Name: "Mystery Box", Price: 5},
+	})
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"category only", "?category=books", []string{"p1", "p4"}},
+		{"category case-insensitive", "?category=ELECTRONICS", []string{"p2", "p3"}},
+		{"uncategorized", "?category=uncategorized", []string{"p5"}},
+		{"category and search", "?category=electronics&q=headphones", []string{"p2", "p3"}},
+		{"search across categories", "?q=headphones", []string{"p2", "p3", "p4"}},
+		{"search within other category", "?category=books&q=headphones", []string{"p4"}},
+		{"category and price", "?category=electronics&maxPrice=50", []string{"p3"}},
+		{"all filters", "?category=Electronics&q=headphones&minPrice=50", []string{"p2"}},
+		{"unknown category", "?category=garden", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, products := getProducts(t, tt.query)
+			if status != http.StatusOK {
+				t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+			}
+			if got := productIDs(products); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got products %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestProductsHandler_UnknownCategoryIsEmptyArray tests that an unmatched filter encodes as [] rather than null
+func TestProductsHandler_UnknownCategoryIsEmptyArray(t *testing.T) {
+	newFakeDotnet(t, []Product{{Id: "p1", Category: "Books"}})
+
+	rr := httptest.NewRecorder()
+	productsHandler(rr, httptest.NewRequest(http.MethodGet, "/products?category=garden", nil))
+	if body := rr.Body.String(); body != "[]\n" {
+		t.Errorf("handler returned %q, want %q", body, "[]\n")
+	}
+}
+
+// TestProductsHandler_InvalidPriceFilter tests that a malformed price bound is rejected
+func TestProductsHandler_InvalidPriceFilter(t *testing.T) {
+	newFakeDotnet(t, nil)
+
+	if status, _ := getProducts(t, "?minPrice=cheap"); status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+}
+
+// TestProductsHandler_Fields tests that ?fields= projects each product to only the requested keys
+func TestProductsHandler_Fields(t *testing.T) {
+	newFakeDotnet(t, []Product{
+		{Id: "p1", Name: "Go Programming", Price: 40, Description: "A book", Stock: 2, Category: "Books"},
+	})
+
+	rr := httptest.NewRecorder()
+	productsHandler(rr, httptest.NewRequest(http.MethodGet, "/products?fields=id,%20price,lowStock", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var got []map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	want := []map[string]interface{}{{"id": "p1", "price": 40.0, "lowStock": true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("handler returned %v, want %v", got, want)
+	}
+}
+
+// TestProductsHandler_InvalidFields tests that unknown or empty field lists are rejected
+func TestProductsHandler_InvalidFields(t *testing.T) {
+	newFakeDotnet(t, nil)
+
+	for _, query := range []string{"?fields=id,secret", "?fields=", "?fields=,"} {
+		if status, _ := getProducts(t, query); status != http.StatusBadRequest {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", query, status, http.StatusBadRequest)
+		}
+	}
+}
+
+// TestProductsHandler_HideOutOfStock tests that hideOutOfStock composes with the other filters
+func TestProductsHandler_HideOutOfStock(t *testing.T) {
+	newFakeDotnet(t, []Product{
+		{Id: "p1", Name: "Wireless Headphones", Price: 99.99, Category: "Electronics", Stock: 3},
+		{Id: "p2", Name: "Headphones Stand", Price: 20, Category: "Electronics", Stock: 0},
+		{Id: "p3", Name: "Headphones Buying Guide", Price: 10, Category: "Books", Stock: -1},
+		{Id: "p4", Name: "Go Programming", Price: 40, Category: "Books", Stock: 1},
+	})
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"absent", "", []string{"p1", "p2", "p3", "p4"}},
+		{"false", "?hideOutOfStock=false", []string{"p1", "p2", "p3", "p4"}},
+		{"only", "?hideOutOfStock=true", []string{"p1", "p4"}},
+		{"with search", "?hideOutOfStock=true&q=headphones", []string{"p1"}},
+		{"with category", "?hideOutOfStock=1&category=books", []string{"p4"}},
+		{"with price", "?hideOutOfStock=true&maxPrice=50", []string{"p4"}},
+		{"all filters", "?hideOutOfStock=true&category=electronics&q=stand&maxPrice=50", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, products := getProducts(t, tt.query)
+			if status != http.StatusOK {
+				t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+			}
+			if got := productIDs(products); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got products %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if status, _ := getProducts(t, "?hideOutOfStock=maybe"); status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+}
+
+// TestProductsHandler_StaleOnUpstreamFailure tests serving the expired cache while the upstream is down
+func TestProductsHandler_StaleOnUpstreamFailure(t *testing.T) {
+	stubSleep(t)
+	os.Setenv("PRODUCTS_CACHE_TTL", "1ns") // Every request refetches
+	defer os.Unsetenv("PRODUCTS_CACHE_TTL")
+	os.Setenv("PRODUCTS_STALE_MAX", "1h")
+	defer os.Unsetenv("PRODUCTS_STALE_MAX")
+
+	dotnet := newFakeDotnet(t, []Product{{Id: "prod1", Name: "Headphones", Price: 99.99, Stock: 10}})
+	if status, _ := getProducts(t, ""); status != http.StatusOK {
+		t.Fatalf("products returned %v before the outage", status)
+	}
+	dotnet.Server.Close()
+
+	rr := httptest.NewRecorder()
+	productsHandler(rr, httptest.NewRequest(http.MethodGet, "/products", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code during outage: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if got := rr.Header().Get("X-Cache"); got != "STALE" {
+		t.Errorf("X-Cache = %q, want %q", got, "STALE")
+	}
+	if got := rr.Header().Get("Warning"); !strings.HasPrefix(got, "110") {
+		t.Errorf("Warning = %q, want a 110 warning", got)
+	}
+	os.Setenv("PRODUCTS_CLIENT_CACHE_MAX_AGE", "60")
+	defer os.Unsetenv("PRODUCTS_CLIENT_CACHE_MAX_AGE")
+	rr = httptest.NewRecorder()
+	productsHandler(rr, httptest.NewRequest(http.MethodGet, "/products", nil))
+	if got := rr.Header().Get("Cache-Control"); got != "public, no-cache" {
+		t.Errorf("Cache-Control of a stale catalog = %q, want %q", got, "public, no-cache")
+	}
+
+	// Once the stale window has passed the outage surfaces as a 502
+	os.Setenv("PRODUCTS_STALE_MAX", "1ns")
+	if status, _ := getProducts(t, ""); status != http.StatusBadGateway {
+		t.Errorf("handler returned wrong status code past the stale window: got %v want %v", status, http.StatusBadGateway)
+	}
+}
+
+// TestProductsHandler_UpstreamDownWithoutCache tests that an outage with nothing cached returns 502
+func TestProductsHandler_UpstreamDownWithoutCache(t *testing.T) {
+	stubSleep(t)
+	dotnet := newFakeDotnet(t, nil)
+	dotnet.Server.Close()
+
+	if status, _ := getProducts(t, ""); status != http.StatusBadGateway {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadGateway)
+	}
+}
+
+// TestProductsHandler_DeduplicatesConcurrentFetches tests that concurrent cache misses share one upstream fetch
+func TestProductsHandler_DeduplicatesConcurrentFetches(t *testing.T) {
+	_, hits := newProductsUpstream(t, func() []Product {
+		time.Sleep(100 * time.Millisecond) // Keep the fetch in flight while the other requests arrive
+		return []Product{{Id: "prod1", Name: "Headphones", Price: 99.99, Stock: 10}}
+	})
+
+	const concurrent = 50
+	var wg sync.WaitGroup
+	codes := make([]int, concurrent)
+	for i := 0; i < concurrent; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rr := httptest.NewRecorder()
+			productsHandler(rr, httptest.NewRequest(http.MethodGet, "/products", nil))
+			codes[i] = rr.Code
+		}(i)
+	}
+	wg.Wait()
+
+	for i, code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("request %d returned status %v", i, code)
+		}
+	}
+	if got := atomic.LoadInt32(hits); got != 1 {
+		t.Errorf("upstream hit %d times, want 1", got)
+	}
+}
+
+// flushCountingWriter is a ResponseWriter that records how often the handler flushed
+type flushCountingWriter struct {
+	*httptest.ResponseRecorder
+	flushes int
+}
+
+func (w *flushCountingWriter) Flush() {
+	w.flushes++
+	w.ResponseRecorder.Flush()
+}
+
+// TestProductsHandler_NDJSON tests that Accept: application/x-ndjson streams one flushed product per line
+func TestProductsHandler_NDJSON(t *testing.T) {
+	newFakeDotnet(t, []Product{
+		{Id: "p1", Name: "Headphones", Category: "Electronics"},
+		{Id: "p2", Name: "Go Book", Category: "Books"},
+		{Id: "p3", Name: "Speaker", Category: "Electronics"},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/products?category=electronics", nil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	w := &flushCountingWriter{ResponseRecorder: httptest.NewRecorder()}
+	productsHandler(w, req)
+
+	if got := w.Header().Get("Content-Type"); got != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q, want application/x-ndjson", got)
+	}
+	lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %q", len(lines), w.Body.String())
+	}
+	for i, line := range lines {
+		var p Product
+		if err := json.Unmarshal([]byte(line), &p); err != nil {
+			t.Errorf("line %d is not a JSON product: %q", i, line)
+		}
+	}
+	if w.flushes != 2 {
+		t.Errorf("flushed %d times, want once per product (2)", w.flushes)
+	}
+}
+
+// TestProductsHandler_NDJSONFallback tests that other Accept values still get a JSON array
+func TestProductsHandler_NDJSONFallback(t *testing.T) {
+	newFakeDotnet(t, []Product{{Id: "p1"}, {Id: "p2"}})
+
+	req := httptest.NewRequest(http.MethodGet, "/products", nil)
+	req.Header.Set("Accept", "application/json")
+	rr := httptest.NewRecorder()
+	productsHandler(rr, req)
+
+	var products []Product
+	if err := json.Unmarshal(rr.Body.Bytes(), &products); err != nil || len(products) != 2 {
+		t.Errorf("expected a JSON array of 2 products, got %q", rr.Body.String())
+	}
+}
+
+// TestProductResponses_LowStock tests the lowStock boundaries around the threshold
+func TestProductResponses_LowStock(t *testing.T) {
+	tests := []struct {
+		stock int
+		want  bool
+	}{
+		{-1, false},
+		{0, false}, // Out of stock, not low stock
+		{1, true},
+		{5, true}, // At the threshold
+		{6, false},
+	}
+	for _, tt := range tests {
+		got := productResponses([]Product{{Id: "p", Stock: tt.stock}}, 5, "")
+		if got[0].LowStock != tt.want {
+			t.Errorf("stock %d: lowStock = %v, want %v", tt.stock, got[0].LowStock, tt.want)
+		}
+	}
+}
+
+// TestProductsHandler_DefaultProductImage tests that DEFAULT_PRODUCT_IMAGE fills in only missing image URLs and changes the ETag
+func TestProductsHandler_DefaultProductImage(t *testing.T) {
+	newProductsUpstream(t, func() []Product {
+		return []Product{
+			{Id: "with", ImageUrl: "https://cdn.example.com/with.png"},
+			{Id: "without"},
+		}
+	})
+	get := func() ([]ProductResponse, string) {
+		rr := httptest.NewRecorder()
+		productsHandler(rr, httptest.NewRequest(http.MethodGet, "/products", nil))
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		var products []ProductResponse
+		json.Unmarshal(rr.Body.Bytes(), &products)
+		return products, rr.Header().Get("ETag")
+	}
+
+	products, plainETag := get()
+	if products[1].ImageUrl != "" {
+		t.Errorf("imageUrl without DEFAULT_PRODUCT_IMAGE = %q, want empty", products[1].ImageUrl)
+	}
+
+	os.Setenv("DEFAULT_PRODUCT_IMAGE", "https://cdn.example.com/placeholder.png")
+	defer os.Unsetenv("DEFAULT_PRODUCT_IMAGE")
+	products, etag := get()
+	if got := products[0].ImageUrl; got != "https://cdn.example.com/with.png" {
+		t.Errorf("product with an image got imageUrl %q, want it untouched", got)
+	}
+	if got := products[1].ImageUrl; got != "https://cdn.example.com/placeholder.png" {
+		t.Errorf("product without an image got imageUrl %q, want the default", got)
+	}
+	if etag == plainETag {
+		t.Errorf("ETag %s unchanged by DEFAULT_PRODUCT_IMAGE, want cached copies invalidated", etag)
+	}
+	if cached := productsCache.cached().Products[1].ImageUrl; cached != "" {
+		t.Errorf("cached product got imageUrl %q, want the default applied only to the response", cached)
+	}
+}
+
+// TestProductsHandler_MaxProductsReturned tests that MAX_PRODUCTS_RETURNED truncates the array and flags it with X-Truncated
+func TestProductsHandler_MaxProductsReturned(t *testing.T) {
+	newProductsUpstream(t, func() []Product {
+		return []Product{{Id: "a", Category: "audio"}, {Id: "b"}, {Id: "c", Category: "audio"}}
+	})
+
+	tests := []struct {
+		name      string
+		max       string
+		path      string
+		wantIds   []string
+		truncated bool
+	}{
+		{"unset", "", "/products", []string{"a", "b", "c"}, false},
+		{"above catalog size", "5", "/products", []string{"a", "b", "c"}, false},
+		{"at catalog size", "3", "/products", []string{"a", "b", "c"}, false},
+		{"below catalog size", "2", "/products", []string{"a", "b"}, true},
+		{"filtered results fit", "2", "/products?category=audio", []string{"a", "c"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("MAX_PRODUCTS_RETURNED", tt.max)
+			defer os.Unsetenv("MAX_PRODUCTS_RETURNED")
+			rr := httptest.NewRecorder()
+			productsHandler(rr, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if status := rr.Code; status != http.StatusOK {
+				t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+			}
+			var products []ProductResponse
+			json.Unmarshal(rr.Body.Bytes(), &products)
+			var ids []string
+			for _, p := range products {
+				ids = append(ids, p.Id)
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.wantIds, ",") {
+				t.Errorf("got products %v, want %v", ids, tt.wantIds)
+			}
+			if got := rr.Header().Get("X-Truncated") == "true"; got != tt.truncated {
+				t.Errorf("X-Truncated = %q, want truncated %v", rr.Header().Get("X-Truncated"), tt.truncated)
+			}
+		})
+	}
+}
+
+// TestProductsHandler_LowStockThreshold tests that LOW_STOCK_THRESHOLD sets the lowStock flag in the response
+func TestProductsHandler_LowStockThreshold(t *testing.T) {
+	newProductsUpstream(t, func() []Product {
+		return []Product{{Id: "a", Stock: 2}, {Id: "b", Stock: 3}, {Id: "c", Stock: 0}}
+	})
+	os.Setenv("LOW_STOCK_THRESHOLD", "2")
+	defer os.Unsetenv("LOW_STOCK_THRESHOLD")
+
+	rr := httptest.NewRecorder()
+	productsHandler(rr, httptest.NewRequest(http.MethodGet, "/products", nil))
+	var products []ProductResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &products); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	var low []string
+	for _, p := range products {
+		if p.LowStock {
+			low = append(low, p.Id)
+		}
+	}
+	if !reflect.DeepEqual(low, []string{"a"}) {
+		t.Errorf("lowStock products = %v, want [a]", low)
+	}
+	if !strings.Contains(rr.Body.String(), `"lowStock":false`) {
+		t.Errorf("response does not include lowStock for every product: %s", rr.Body.String())
+	}
+}
+
+// TestProductsHandler_EmptyUpstream tests that null, empty and [] upstream bodies all reach clients as []
+func TestProductsHandler_EmptyUpstream(t *testing.T) {
+	for _, body := range []string{"null", "", "[]", " \n"} {
+		serveRawProducts(t, body)
+
+		for _, query := range []string{"", "?q=anything"} {
+			rr := httptest.NewRecorder()
+			productsHandler(rr, httptest.NewRequest(http.MethodGet, "/products"+query, nil))
+			if rr.Code != http.StatusOK {
+				t.Errorf("upstream %q%s: handler returned wrong status code: got %v want %v", body, query, rr.Code, http.StatusOK)
+			}
+			if got := rr.Body.String(); got != "[]\n" {
+				t.Errorf("upstream %q%s: handler returned %q, want %q", body, query, got, "[]\n")
+			}
+		}
+
+		rr := httptest.NewRecorder()
+		exportHandler(rr, httptest.NewRequest(http.MethodGet, "/products/export?format=json", nil))
+		if got := rr.Body.String(); got != "[]\n" {
+			t.Errorf("upstream %q: export returned %q, want %q", body, got, "[]\n")
+		}
+	}
+}
+
+// TestProductCache_CallerCancelDoesNotAbortFetch tests that a caller leaving stops its wait but not the shared fetch
+func TestProductCache_CallerCancelDoesNotAbortFetch(t *testing.T) {
+	release := make(chan struct{})
+	_, hits := newProductsUpstream(t, func() []Product {
+		<-release
+		return []Product{{Id: "p1"}}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := productsCache.get(ctx, time.Minute)
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond) // Let the fetch start
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("canceled caller got %v, want context.Canceled", err)
+	}
+
+	close(release)
+	entry, err := productsCache.get(context.Background(), time.Minute)
+	if err != nil || len(entry.Products) != 1 {
+		t.Fatalf("second caller got %v, %v", entry, err)
+	}
+	if got := atomic.LoadInt32(hits); got != 1 {
+		t.Errorf("upstream hit %d times, want 1", got)
+	}
+}
+
+// TestProductCache_InvalidateDuringFetch tests that a fetch started before invalidate doesn't repopulate the cache
+func TestProductCache_InvalidateDuringFetch(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	newProductsUpstream(t, func() []Product {
+		close(started)
+		<-release
+		return []Product{{Id: "p1"}}
+	})
+
+	done := make(chan error)
+	go func() {
+		_, err := productsCache.get(context.Background(), time.Minute)
+		done <- err
+	}()
+	<-started // The fetch is under way
+	productsCache.invalidate()
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("in-flight caller got %v", err)
+	}
+	if entry := productsCache.cached(); entry != nil {
+		t.Errorf("cache holds %v after invalidate, want the in-flight fetch discarded", entry.Products)
+	}
+}
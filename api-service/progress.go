@@ -0,0 +1,52 @@
+package main
+
+// Early feedback for slow orders. Placing an order can legitimately take most of ORDER_TIMEOUT, and
+// proxies or clients that see no bytes for a while may give up on a request that is still being
+// processed. So while an order is outstanding, orderHandler sends an interim "102 Processing"
+// response every ORDER_PROGRESS_AFTER; the final response follows as usual. Interim responses carry
+// no body and are skipped for HTTP/1.0 clients, which don't understand them. The server sets no
+// WriteTimeout, so ORDER_TIMEOUT alone bounds how long a slow order may take.
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// defaultOrderProgressAfter is how long an order may run before 102 Processing is sent when
+// ORDER_PROGRESS_AFTER is not set
+const defaultOrderProgressAfter = 3 * time.Second
+
+// placedOrder is the outcome of placeOrder, passed back from the goroutine running it
+type placedOrder struct {
+	status   int
+	response PlaceOrderResponse
+	err      error
+}
+
+// placeOrderWithProgress runs placeOrder on its own goroutine and, every ORDER_PROGRESS_AFTER it is
+// still running, sends 102 Processing from this one, since a ResponseWriter must only be used by
+// the handler's goroutine. ORDER_PROGRESS_AFTER=0 disables the interim responses.
+func placeOrderWithProgress(w http.ResponseWriter, r *http.Request, order PlaceOrderRequest) (int, PlaceOrderResponse, error) {
+	after := envDuration("ORDER_PROGRESS_AFTER", defaultOrderProgressAfter)
+	if after <= 0 || !r.ProtoAtLeast(1, 1) {
+		return placeOrder(r.Context(), order)
+	}
+
+	done := make(chan placedOrder, 1)
+	go func(ctx context.Context) {
+		status, response, err := placeOrder(ctx, order)
+		done <- placedOrder{status, response, err}
+	}(r.Context())
+
+	ticker := time.NewTicker(after)
+	defer ticker.Stop()
+	for {
+		select {
+		case res := <-done:
+			return res.status, res.response, res.err
+		case <-ticker.C:
+			w.WriteHeader(http.StatusProcessing)
+		}
+	}
+}
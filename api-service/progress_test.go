@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestOrderHandler_Progress tests that a slow order gets 102 Processing before its final response and a fast one doesn't
+func TestOrderHandler_Progress(t *testing.T) {
+	tests := []struct {
+		name      string
+		after     string
+		delay     time.Duration
+		wantHints bool
+	}{
+		{"slow order", "50ms", 180 * time.Millisecond, true},
+		{"fast order", "50ms", 0, false},
+		{"disabled", "0", 180 * time.Millisecond, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("ORDER_PROGRESS_AFTER", tt.after)
+			defer os.Unsetenv("ORDER_PROGRESS_AFTER")
+			dotnet := newFakeDotnet(t, nil)
+			dotnet.Delay = tt.delay
+			// The full middleware stack, so the status recorders and compression see the interim responses too
+			server := httptest.NewServer(withServerMiddleware(routes()))
+			defer server.Close()
+
+			var hints atomic.Int32
+			ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+				Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+					if code == http.StatusProcessing {
+						hints.Add(1)
+					}
+					return nil
+				},
+			})
+			body, _ := json.Marshal(PlaceOrderRequest{
+				Items:           []OrderItemRequest{{Id: "prod1", Quantity: 1, Price: 10}},
+				DeliveryAddress: "1 Main St",
+				TotalAmount:     pricePtr(10),
+			})
+			req, _ := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/order", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("POST /order: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("handler returned wrong status code: got %v want %v", resp.StatusCode, http.StatusOK)
+			}
+			var placed PlaceOrderResponse
+			if err := json.NewDecoder(resp.Body).Decode(&placed); err != nil || !placed.Success {
+				t.Errorf("final response = %+v, %v, want the placed order", placed, err)
+			}
+			if got := hints.Load() > 0; got != tt.wantHints {
+				t.Errorf("got %d 102 Processing responses, want some: %v", hints.Load(), tt.wantHints)
+			}
+		})
+	}
+}
+
+// TestStatusRecorder_InterimStatus tests that a 1xx response is passed on but not recorded as the final status
+func TestStatusRecorder_InterimStatus(t *testing.T) {
+	rec := &statusRecorder{ResponseWriter: httptest.NewRecorder()}
+	rec.WriteHeader(http.StatusProcessing)
+	rec.WriteHeader(http.StatusCreated)
+	if rec.status != http.StatusCreated {
+		t.Errorf("recorded status = %d, want %d", rec.status, http.StatusCreated)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultRecordMaxFiles is how many recorded requests are kept when RECORD_REQUESTS_MAX_FILES is not set
+	defaultRecordMaxFiles = 100
+	// defaultRecordMaxBodyBytes is how much of each body is recorded when RECORD_REQUESTS_MAX_BODY_BYTES is not set
+	defaultRecordMaxBodyBytes = 64 << 10
+)
+
+// recordFilePrefix starts the name of every recorded request file, so pruning never touches other files
+const recordFilePrefix = "request-"
+
+// redactedHeaders are never written to recordings; headers naming a token, secret or key are redacted too
+var redactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"X-Api-Key":           true,
+}
+
+// RecordedRequest is the file written for each recorded request, with enough detail to replay it
+type RecordedRequest struct {
+	Time          string              `json:"time"`
+	RequestId     string              `json:"requestId"`
+	Method        string              `json:"method"`
+	URL           string              `json:"url"`
+	Headers       map[string][]string `json:"headers"`
+	Body          string              `json:"body"`
+	BodyTruncated bool                `json:"bodyTruncated,omitempty"` // Body holds only the first RECORD_REQUESTS_MAX_BODY_BYTES
+}
+
+// requestRecorder writes recordings to RECORD_REQUESTS_DIR and prunes the oldest beyond the limit
+type requestRecorder struct {
+	mu  sync.Mutex // Serializes writing and pruning so concurrent requests don't overshoot the limit
+	now func() time.Time
+}
+
+// recorder is the process-wide recorder used by recordRequests
+var recorder = &requestRecorder{now: time.Now}
+
+// recordRequests saves a sanitized copy of each request to next when RECORD_REQUESTS_DIR is set, so
+// production issues can be reproduced. Recording is off by default; failures to record are logged and
+// never affect the request.
+func recordRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dir := os.Getenv("RECORD_REQUESTS_DIR")
+		if dir == "" || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Read no more of the body than is recorded, then hand the handler the full body unchanged
+		maxBody := envInt("RECORD_REQUESTS_MAX_BODY_BYTES", defaultRecordMaxBodyBytes)
+		if maxBody < 0 {
+			maxBody = defaultRecordMaxBodyBytes
+		}
+		prefix, err := io.ReadAll(io.LimitReader(r.Body, int64(maxBody)+1))
+		r.Body = readCloser{io.MultiReader(bytes.NewReader(prefix), r.Body), r.Body}
+		if err != nil {
+			log.Printf("Error reading request body for recording: %v", err)
+		}
+		truncated := len(prefix) > maxBody
+		if truncated {
+			prefix = prefix[:maxBody]
+		}
+
+		now := recorder.now().UTC()
+		rec := RecordedRequest{
+			Time:          now.Format(time.RFC3339Nano),
+			RequestId:     requestIDFrom(r.Context()),
+			Method:        r.Method,
+			URL:           r.URL.RequestURI(),
+			Headers:       redactHeaders(r.Header),
+			Body:          string(prefix),
+			BodyTruncated: truncated,
+		}
+		maxFiles := envInt("RECORD_REQUESTS_MAX_FILES", defaultRecordMaxFiles)
+		if maxFiles < 1 {
+			maxFiles = defaultRecordMaxFiles
+		}
+		if err := recorder.write(dir, now, rec, maxFiles); err != nil {
+			log.Printf("Error recording request to %s: %v", dir, err)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// readCloser reads from Reader and closes Closer, so a re-assembled body still closes the original
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// redactHeaders copies h with the values of secret-bearing headers replaced
+func redactHeaders(h http.Header) map[string][]string {
+	out := make(map[string][]string, len(h))
+	for name, values := range h {
+		if sensitiveHeader(name) {
+			out[name] = []string{"[REDACTED]"}
+			continue
+		}
+		out[name] = append([]string(nil), values...)
+	}
+	return out
+}
+
+// sensitiveHeader reports whether the header named name (in canonical form) may carry a credential
+func sensitiveHeader(name string) bool {
+	if redactedHeaders[name] {
+		return true
+	}
+	lower := strings.ToLower(name)
+	for _, word := range []string{"token", "secret", "key", "password", "signature"} {
+		if strings.Contains(lower, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// write saves rec, received at now, as a new file in dir and removes the oldest recordings beyond maxFiles
+func (rr *requestRecorder) write(dir string, now time.Time, rec RecordedRequest, maxFiles int) error {
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	// Names sort by time, with the request id keeping requests in the same instant apart
+	id := rec.RequestId
+	if !validRequestID(id) || strings.ContainsAny(id, `/\`) {
+		id = randomID()
+	}
+	name := fmt.Sprintf("%s%s-%s.json", recordFilePrefix, now.Format("20060102T150405.000000000Z"), id)
+	// Recordings can contain customer details, so they are only readable by the service user
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+		return err
+	}
+	return pruneRecordings(dir, maxFiles)
+}
+
+// pruneRecordings removes the oldest recorded request files in dir until at most maxFiles remain
+func pruneRecordings(dir string, maxFiles int) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), recordFilePrefix) && strings.HasSuffix(e.Name(), ".json") {
+			names = append(names, e.Name())
+		}
+	}
+	if len(names) <= maxFiles {
+		return nil
+	}
+	sort.Strings(names)
+	for _, name := range names[:len(names)-maxFiles] {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordedFiles returns the names of the recordings in dir, oldest first
+func recordedFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	names, err := filepath.Glob(filepath.Join(dir, recordFilePrefix+"*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+// echoBody is a handler that responds with the request body it received
+var echoBody = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	io.Copy(w, r.Body)
+})
+
+// TestRecordRequests tests that a recording holds the request with credentials redacted and the handler still gets the body
+func TestRecordRequests(t *testing.T) {
+	dir := t.TempDir()
+	os.Setenv("RECORD_REQUESTS_DIR", dir)
+	defer os.Unsetenv("RECORD_REQUESTS_DIR")
+
+	body := `{"items":[{"id":"p1","quantity":2}]}`
+	req := httptest.NewRequest(http.MethodPost, "/order?envelope=true", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer super-secret")
+	req.Header.Set("Cookie", "session=abc")
+	req.Header.Set("X-API-Key", "service-key")
+	req.Header.Set("X-Session-Token", "tok")
+	rr := httptest.NewRecorder()
+	recordRequests(echoBody).ServeHTTP(rr, req)
+
+	if got := rr.Body.String(); got != body {
+		t.Errorf("handler got body %q, want %q", got, body)
+	}
+	files := recordedFiles(t, dir)
+	if len(files) != 1 {
+		t.Fatalf("got %d recordings, want 1", len(files))
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"super-secret", "session=abc", "service-key", "tok\""} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("recording contains %q:\n%s", secret, data)
+		}
+	}
+	var rec RecordedRequest
+	if err := json.Unmarshal(data, &rec); err != nil {
+		t.Fatalf("decoding recording: %v", err)
+	}
+	if rec.Method != http.MethodPost || rec.URL != "/order?envelope=true" || rec.Body != body || rec.BodyTruncated {
+		t.Errorf("recording = %+v, want the POST /order?envelope=true with its full body", rec)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, rec.Time); err != nil {
+		t.Errorf("recording time %q: %v", rec.Time, err)
+	}
+	for _, name := range []string{"Authorization", "Cookie", "X-Api-Key", "X-Session-Token"} {
+		if got := rec.Headers[name]; len(got) != 1 || got[0] != "[REDACTED]" {
+			t.Errorf("header %s = %v, want it redacted", name, got)
+		}
+	}
+	if got := rec.Headers["Content-Type"]; len(got) != 1 || got[0] != "application/json" {
+		t.Errorf("Content-Type = %v, want it kept", got)
+	}
+}
+
+// TestRecordRequests_OffByDefault tests that without RECORD_REQUESTS_DIR the request passes through untouched
+func TestRecordRequests_OffByDefault(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/order", strings.NewReader("{}"))
+	body := req.Body
+	var got io.ReadCloser
+	recordRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Body
+	})).ServeHTTP(httptest.NewRecorder(), req)
+	if got != body {
+		t.Error("handler got a wrapped body with recording off, want the original")
+	}
+}
+
+// TestRecordRequests_Bounds tests that bodies are truncated and only the newest recordings are kept
+func TestRecordRequests_Bounds(t *testing.T) {
+	dir := t.TempDir()
+	os.Setenv("RECORD_REQUESTS_DIR", dir)
+	defer os.Unsetenv("RECORD_REQUESTS_DIR")
+	os.Setenv("RECORD_REQUESTS_MAX_FILES", "2")
+	defer os.Unsetenv("RECORD_REQUESTS_MAX_FILES")
+	os.Setenv("RECORD_REQUESTS_MAX_BODY_BYTES", "4")
+	defer os.Unsetenv("RECORD_REQUESTS_MAX_BODY_BYTES")
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	recorder.now = func() time.Time { return now }
+	defer func() { recorder.now = time.Now }()
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("keep me"), 0o600)
+
+	for i, body := range []string{"first", "second", "third"} {
+		now = now.Add(time.Second)
+		rr := httptest.NewRecorder()
+		recordRequests(echoBody).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/order", strings.NewReader(body)))
+		if rr.Body.String() != body {
+			t.Errorf("request %d: handler got body %q, want the whole %q", i, rr.Body.String(), body)
+		}
+	}
+
+	files := recordedFiles(t, dir)
+	if len(files) != 2 {
+		t.Fatalf("got %d recordings, want the newest 2", len(files))
+	}
+	var rec RecordedRequest
+	data, _ := os.ReadFile(files[0])
+	json.Unmarshal(data, &rec)
+	if rec.Body != "seco" || !rec.BodyTruncated {
+		t.Errorf("oldest kept recording has body %q (truncated %t), want the second request cut to 4 bytes", rec.Body, rec.BodyTruncated)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Errorf("pruning removed an unrelated file: %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// defaultRedisTimeout bounds each Redis call when REDIS_TIMEOUT is not set. It is short because
+// every caller has a fallback (the upstream, or in-process state) that beats waiting on Redis.
+const defaultRedisTimeout = 250 * time.Millisecond
+
+// redisKeyPrefix namespaces this service's keys in a Redis shared with other applications
+const redisKeyPrefix = "shopping-cart:"
+
+// sharedStore is the Store replicas share state through, chosen at startup from CACHE_BACKEND; nil
+// keeps all state in process memory
+var sharedStore Store
+
+// redisStore is a Store in Redis, so state is shared between replicas and survives restarts
+type redisStore struct {
+	client *redis.Client
+}
+
+// newStoreFromEnv returns the shared Store selected by CACHE_BACKEND ("memory" or "redis"), or nil
+// for "memory", the default, where each process keeps its own state
+func newStoreFromEnv() (Store, error) {
+	switch backend := os.Getenv("CACHE_BACKEND"); backend {
+	case "", "memory":
+		return nil, nil
+	case "redis":
+		redisURL := os.Getenv("REDIS_URL")
+		if redisURL == "" {
+			return nil, errors.New("CACHE_BACKEND=redis requires REDIS_URL")
+		}
+		opts, err := redis.ParseURL(redisURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid REDIS_URL: %w", err)
+		}
+		store := &redisStore{client: redis.NewClient(opts)}
+		// An unreachable Redis is not fatal: callers fall back until it comes back
+		ctx, cancel := store.withTimeout(context.Background())
+		defer cancel()
+		if err := store.client.Ping(ctx).Err(); err != nil {
+			log.Printf("WARNING: Redis at %s is not reachable, falling back to local state until it is: %v", redactURL(redisURL), err)
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown CACHE_BACKEND %q", backend)
+	}
+}
+
+// withTimeout bounds one Redis call by REDIS_TIMEOUT
+func (s *redisStore) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, envDuration("REDIS_TIMEOUT", defaultRedisTimeout))
+}
+
+func (s *redisStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	value, err := s.client.Get(ctx, redisKeyPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (s *redisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	return s.client.Set(ctx, redisKeyPrefix+key, value, ttl).Err()
+}
+
+func (s *redisStore) SetIfAbsent(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	// SET NX with an expiry; errors as redis.Nil when the key already exists
+	err := s.client.SetArgs(ctx, redisKeyPrefix+key, value, redis.SetArgs{Mode: "NX", TTL: ttl}).Err()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *redisStore) Delete(ctx context.Context, key string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	return s.client.Del(ctx, redisKeyPrefix+key).Err()
+}
+
+// List scans this service's keys. Redis doesn't record when a key was written, so only the expiry is
+// reported. Each page of the scan is its own call bounded by REDIS_TIMEOUT.
+func (s *redisStore) List(ctx context.Context) ([]StoreEntry, error) {
+	var list []StoreEntry
+	var cursor uint64
+	for {
+		pageCtx, cancel := s.withTimeout(ctx)
+		keys, next, err := s.client.Scan(pageCtx, cursor, redisKeyPrefix+"*", 100).Result()
+		if err == nil && len(keys) > 0 {
+			var ttls []*redis.DurationCmd
+			_, err = s.client.Pipelined(pageCtx, func(pipe redis.Pipeliner) error {
+				for _, key := range keys {
+					ttls = append(ttls, pipe.PTTL(pageCtx, key))
+				}
+				return nil
+			})
+			now := time.Now()
+			for i, key := range keys {
+				// Keys that expired since the scan, or have no expiry (which this service never writes), are skipped
+				if ttl := ttls[i].Val(); err == nil && ttl > 0 {
+					list = append(list, StoreEntry{Key: key[len(redisKeyPrefix):], ExpiresAt: now.Add(ttl)})
+				}
+			}
+		}
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		if cursor = next; cursor == 0 {
+			return list, nil
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// useRedis points sharedStore at a fresh miniredis for the test, as CACHE_BACKEND=redis would
+func useRedis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	os.Setenv("CACHE_BACKEND", "redis")
+	os.Setenv("REDIS_URL", "redis://"+mr.Addr())
+	defer os.Unsetenv("CACHE_BACKEND")
+	defer os.Unsetenv("REDIS_URL")
+	store, err := newStoreFromEnv()
+	if err != nil {
+		t.Fatalf("newStoreFromEnv: %v", err)
+	}
+	sharedStore = store
+	t.Cleanup(func() {
+		store.(*redisStore).client.Close()
+		sharedStore = nil
+	})
+	return mr
+}
+
+// TestNewStoreFromEnv tests the CACHE_BACKEND choices
+func TestNewStoreFromEnv(t *testing.T) {
+	defer os.Unsetenv("CACHE_BACKEND")
+	defer os.Unsetenv("REDIS_URL")
+	tests := []struct {
+		backend, url string
+		wantStore    bool
+		wantErr      bool
+	}{
+		{"", "", false, false},
+		{"memory", "", false, false},
+		{"redis", "", false, true},
+		{"redis", "not a url", false, true},
+		{"memcached", "", false, true},
+		{"redis", "redis://127.0.0.1:1", true, false}, // Unreachable Redis is not fatal
+	}
+	for _, tt := range tests {
+		os.Setenv("CACHE_BACKEND", tt.backend)
+		os.Setenv("REDIS_URL", tt.url)
+		store, err := newStoreFromEnv()
+		if (store != nil) != tt.wantStore || (err != nil) != tt.wantErr {
+			t.Errorf("CACHE_BACKEND=%q REDIS_URL=%q: got %v, %v", tt.backend, tt.url, store, err)
+		}
+	}
+}
+
+// TestRedisStore tests the Store operations against Redis, including key expiry
+func TestRedisStore(t *testing.T) {
+	mr := useRedis(t)
+	ctx := context.Background()
+	s := sharedStore
+
+	if err := s.Set(ctx, "k", []byte("v1"), time.Minute); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if got, ok, err := s.Get(ctx, "k"); err != nil || !ok || string(got) != "v1" {
+		t.Errorf("Get = %q, %v, %v, want v1", got, ok, err)
+	}
+	if !mr.Exists(redisKeyPrefix + "k") {
+		t.Errorf("key not stored under the %q prefix", redisKeyPrefix)
+	}
+	if ok, err := s.SetIfAbsent(ctx, "k", []byte("v2"), time.Minute); ok || err != nil {
+		t.Errorf("SetIfAbsent on a live key = %v, %v, want false", ok, err)
+	}
+	mr.FastForward(time.Minute)
+	if _, ok, _ := s.Get(ctx, "k"); ok {
+		t.Errorf("Get returned a value past its TTL")
+	}
+	if ok, err := s.SetIfAbsent(ctx, "k", []byte("v3"), time.Minute); !ok || err != nil {
+		t.Errorf("SetIfAbsent on an expired key = %v, %v, want true", ok, err)
+	}
+	if err := s.Delete(ctx, "k"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, ok, _ := s.Get(ctx, "k"); ok {
+		t.Errorf("Get returned a deleted value")
+	}
+}
+
+// TestRedisStore_List tests that List returns this service's keys with their expiry but no write time
+func TestRedisStore_List(t *testing.T) {
+	mr := useRedis(t)
+	ctx := context.Background()
+	sharedStore.Set(ctx, "k", []byte("v"), time.Minute)
+	mr.Set("other-app:k", "v") // Not under this service's prefix
+
+	list, err := sharedStore.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 1 || list[0].Key != "k" || !list[0].WrittenAt.IsZero() {
+		t.Fatalf("List() = %v, want only k without a write time", list)
+	}
+	if in := time.Until(list[0].ExpiresAt); in <= 0 || in > time.Minute {
+		t.Errorf("k expires in %v, want within its one minute TTL", in)
+	}
+}
+
+// TestNonceStore_RedisFallback tests that nonces are shared through Redis and fall back to local state while it is down
+func TestNonceStore_RedisFallback(t *testing.T) {
+	mr := useRedis(t)
+	ctx := context.Background()
+	replicaA := &nonceStore{store: sharedStore, fallback: newMemoryStore(nil)}
+	replicaB := &nonceStore{store: sharedStore, fallback: newMemoryStore(nil)}
+
+	if ok, _ := replicaA.claim(ctx, "n1", time.Minute); !ok {
+		t.Fatalf("first claim was rejected")
+	}
+	if ok, _ := replicaB.claim(ctx, "n1", time.Minute); ok {
+		t.Errorf("another replica accepted a nonce claimed through Redis")
+	}
+
+	mr.Close()
+	if ok, err := replicaA.claim(ctx, "n2", time.Minute); !ok || err != nil {
+		t.Errorf("claim with Redis down = %v, %v, want the local fallback to accept it", ok, err)
+	}
+	if ok, _ := replicaA.claim(ctx, "n2", time.Minute); ok {
+		t.Errorf("local fallback accepted a repeated nonce")
+	}
+}
+
+// TestProductCache_SharedThroughRedis tests that replicas reuse a catalog fetched by another and that invalidate reaches the shared copy
+func TestProductCache_SharedThroughRedis(t *testing.T) {
+	useRedis(t)
+	var hits atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		json.NewEncoder(w).Encode([]Product{{Id: "p1", Stock: 3}})
+	}))
+	defer upstream.Close()
+	os.Setenv("DOTNET_PRODUCTS_API_URL", upstream.URL)
+	defer os.Unsetenv("DOTNET_PRODUCTS_API_URL")
+
+	replicaA, replicaB := &productCache{}, &productCache{}
+	first, err := replicaA.get(context.Background(), time.Minute)
+	if err != nil {
+		t.Fatalf("replica A: %v", err)
+	}
+	second, err := replicaB.get(context.Background(), time.Minute)
+	if err != nil {
+		t.Fatalf("replica B: %v", err)
+	}
+	if hits.Load() != 1 {
+		t.Errorf("upstream fetched %d times, want replica B to reuse replica A's catalog", hits.Load())
+	}
+	if second.ETag != first.ETag || !second.LastModified.Equal(first.LastModified) || len(second.Products) != 1 {
+		t.Errorf("replica B got %+v, want the same catalog, ETag and Last-Modified as replica A", second)
+	}
+
+	replicaA.invalidate()
+	replicaC := &productCache{}
+	if _, err := replicaC.get(context.Background(), time.Minute); err != nil {
+		t.Fatalf("replica C: %v", err)
+	}
+	if hits.Load() != 2 {
+		t.Errorf("upstream fetched %d times, want a fresh fetch after invalidate", hits.Load())
+	}
+}
+
+// TestProductCache_RedisDown tests that the catalog is fetched directly from the upstream while Redis is unreachable
+func TestProductCache_RedisDown(t *testing.T) {
+	mr := useRedis(t)
+	mr.Close()
+	newProductsUpstream(t, func() []Product { return []Product{{Id: "p1"}} })
+
+	rr := httptest.NewRecorder()
+	productsHandler(rr, httptest.NewRequest(http.MethodGet, "/products", nil))
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+}
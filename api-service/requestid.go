@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"net/http"
+)
+
+// maxRequestIDLength bounds client-supplied request ids so they can't bloat logs
+const maxRequestIDLength = 128
+
+// requestIDKey is the request context key for the id set by requestIDMiddleware
+type requestIDKey struct{}
+
+// requestIDFrom returns the id assigned to the request, or "" outside requestIDMiddleware
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestIDMiddleware gives every request an id, reusing a sane incoming X-Request-Id (e.g. from the
+// load balancer) so logs can be correlated across services, and echoes it in the response
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-Id")
+		if !validRequestID(id) {
+			id = randomID()
+		}
+		w.Header().Set("X-Request-Id", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// validRequestID reports whether id is non-empty, short and printable ASCII
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
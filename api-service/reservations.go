@@ -0,0 +1,257 @@
+package main
+
+// Two-step checkout with stock reservations:
+//
+//  1. The frontend POSTs the cart to /cart/reserve. We check the requested quantities against the
+//     cached catalog stock minus quantities already held by other live reservations, and if everything
+//     is available we hold it for CART_RESERVATION_TTL and return a reservationId.
+//  2. The frontend POSTs the order to /order with that reservationId. A live reservation is forwarded
+//     to the Dotnet service with the order and released once the order succeeds. An unknown or expired
+//     reservation, or one that doesn't hold every item of the order in the quantity ordered, is
+//     dropped and the order proceeds as a normal unreserved order, so the Dotnet service
+//     remains the source of truth for stock. Unreserved orders are pre-checked against the cached
+//     stock minus live holds, so they can't take stock another cart is holding.
+//
+// The Dotnet service has no reservation endpoint today, so holds are simulated in this process.
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// defaultReservationTTL is how long reserved stock is held when CART_RESERVATION_TTL is not set
+const defaultReservationTTL = 10 * time.Minute
+
+// ReserveRequest from React app to hold stock for the items in the cart
+type ReserveRequest struct {
+	Items []OrderItemRequest `json:"items"`
+}
+
+// ReserveResponse from Go to React
+type ReserveResponse struct {
+	Success         bool     `json:"success"`
+	Message         string   `json:"message,omitempty"`
+	ReservationId   string   `json:"reservationId,omitempty"`
+	ExpiresAt       string   `json:"expiresAt,omitempty"`
+	OutOfStockItems []string `json:"outOfStockItems,omitempty"`
+}
+
+// reservation is a hold on product quantities until ExpiresAt
+type reservation struct {
+	Items     map[string]int // Product id to reserved quantity
+	ExpiresAt time.Time
+}
+
+// reservationStore tracks live reservations in memory
+type reservationStore struct {
+	mu    sync.Mutex
+	byID  map[string]*reservation
+	now   func() time.Time
+	newID func() string
+}
+
+// reservations is the process-wide reservation store used by reserveHandler and orderHandler without tenants
+var reservations = newReservationStore()
+
+// tenantReservations holds one reservation store per tenant, so one tenant's holds never count
+// against another's stock or can be redeemed in its orders
+var tenantReservations = newPerTenant(func(string) *reservationStore { return newReservationStore() })
+
+// reservationsFor returns tenant's reservation store, "" being reservations
+func reservationsFor(tenant string) *reservationStore {
+	if tenant == "" {
+		return reservations
+	}
+	return tenantReservations.get(tenant)
+}
+
+func newReservationStore() *reservationStore {
+	return &reservationStore{
+		byID:  make(map[string]*reservation),
+		now:   time.Now,
+		newID: randomID,
+	}
+}
+
+// reserve holds the requested items if stock (product id to available quantity) minus live holds covers them.
+// It returns the new reservation id, or the ids of the items that could not be held.
+func (s *reservationStore) reserve(items []OrderItemRequest, stock map[string]int, ttl time.Duration) (string, time.Time, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.pruneLocked(now)
+
+	held := s.heldLocked("")
+	wanted := make(map[string]int)
+	for _, item := range items {
+		wanted[item.Id] += item.Quantity
+	}
+	var outOfStock []string
+	checked := make(map[string]bool)
+	for _, item := range items {
+		if checked[item.Id] {
+			continue // Duplicate line items were summed above
+		}
+		checked[item.Id] = true
+		if wanted[item.Id] > stock[item.Id]-held[item.Id] {
+			outOfStock = append(outOfStock, item.Id)
+		}
+	}
+	if len(outOfStock) > 0 {
+		return "", time.Time{}, outOfStock
+	}
+
+	res := &reservation{Items: make(map[string]int), ExpiresAt: now.Add(ttl)}
+	for _, item := range items {
+		res.Items[item.Id] += item.Quantity
+	}
+	id := s.newID()
+	s.byID[id] = res
+	return id, res.ExpiresAt, nil
+}
+
+// held returns the quantities held by live reservations other than except, by product id
+func (s *reservationStore) held(except string) map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked(s.now())
+	return s.heldLocked(except)
+}
+
+// heldLocked sums the quantities held by the stored reservations other than except; s.mu must be held
+func (s *reservationStore) heldLocked(except string) map[string]int {
+	held := make(map[string]int)
+	for resID, res := range s.byID {
+		if resID == except {
+			continue
+		}
+		for id, qty := range res.Items {
+			held[id] += qty
+		}
+	}
+	return held
+}
+
+// active reports whether id refers to a reservation that has not expired
+func (s *reservationStore) active(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked(s.now())
+	_, ok := s.byID[id]
+	return ok
+}
+
+// covers reports whether id refers to a live reservation, and whether it holds every item's
+// quantity, summed over duplicate line items
+func (s *reservationStore) covers(id string, items []OrderItemRequest) (live, covered bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked(s.now())
+	res, ok := s.byID[id]
+	if !ok {
+		return false, false
+	}
+	wanted := make(map[string]int)
+	for _, item := range items {
+		wanted[item.Id] += item.Quantity
+	}
+	for itemID, qty := range wanted {
+		if qty > res.Items[itemID] {
+			return true, false
+		}
+	}
+	return true, true
+}
+
+// release drops a reservation, e.g. once its order has been placed
+func (s *reservationStore) release(id string) {
+	s.mu.Lock()
+	delete(s.byID, id)
+	s.mu.Unlock()
+}
+
+// pruneLocked removes expired reservations; s.mu must be held
+func (s *reservationStore) pruneLocked(now time.Time) {
+	for id, res := range s.byID {
+		if !now.Before(res.ExpiresAt) {
+			delete(s.byID, id)
+		}
+	}
+}
+
+// randomID returns a random 128-bit hex identifier
+func randomID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("Error generating random id: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// reserveHandler holds stock for the submitted cart items and returns a reservation id
+func reserveHandler(w http.ResponseWriter, r *http.Request) {
+	// Set CORS headers for any origin
+	applyCORS(w, r, "POST, OPTIONS", "Content-Type, Authorization, X-API-Key, X-Tenant-ID")
+
+	// Handle preflight OPTIONS request
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost, http.MethodOptions)
+		return
+	}
+
+	var reserveRequest ReserveRequest
+	if err := decodeJSON(r, &reserveRequest, "Invalid reservation request body"); err != nil {
+		writeRequestError(w, r, err)
+		return
+	}
+	if len(reserveRequest.Items) == 0 {
+		writeError(w, r, http.StatusBadRequest, "At least one item is required")
+		return
+	}
+	for _, item := range reserveRequest.Items {
+		if item.Id == "" || item.Quantity <= 0 {
+			writeError(w, r, http.StatusBadRequest, "Each item needs an id and a positive quantity")
+			return
+		}
+	}
+
+	// Check availability against the cached catalog
+	entry, err := tenantProductCache(r.Context()).get(r.Context(), envDuration("PRODUCTS_CACHE_TTL", defaultProductsCacheTTL))
+	if err != nil {
+		log.Printf("An error occured loading products for reservation: %v", err)
+		writeError(w, r, http.StatusBadGateway, "Failed to check stock with backend service")
+		return
+	}
+	stock := make(map[string]int, len(entry.Products))
+	for _, p := range entry.Products {
+		stock[p.Id] = p.Stock
+	}
+
+	id, expiresAt, outOfStock := reservationsFor(tenantFrom(r.Context())).reserve(reserveRequest.Items, stock, envDuration("CART_RESERVATION_TTL", defaultReservationTTL))
+	if len(outOfStock) > 0 {
+		log.Printf("Reservation rejected, insufficient stock for: %v", outOfStock)
+		writeJSON(w, r, http.StatusConflict, ReserveResponse{
+			Success:         false,
+			Message:         "Some items are out of stock",
+			OutOfStockItems: outOfStock,
+		})
+		return
+	}
+
+	log.Printf("Reserved %d item(s) under reservation %s until %s", len(reserveRequest.Items), id, expiresAt.Format(time.RFC3339))
+	writeJSON(w, r, http.StatusOK, ReserveResponse{
+		Success:       true,
+		Message:       "Items reserved",
+		ReservationId: id,
+		ExpiresAt:     expiresAt.UTC().Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// useFakeReservationClock swaps the reservation store for one driven by the returned clock
+func useFakeReservationClock(t *testing.T) *time.Time {
+	t.Helper()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	orig := reservations
+	reservations = newReservationStore()
+	reservations.now = func() time.Time { return now }
+	t.Cleanup(func() { reservations = orig })
+	return &now
+}
+
+func reserve(t *testing.T, items []OrderItemRequest) (int, ReserveResponse) {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	reserveHandler(rr, postJSON(t, "/cart/reserve", ReserveRequest{Items: items}))
+	var resp ReserveResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	return rr.Code, resp
+}
+
+// TestReserveHandler_HappyPath tests that a reservation is forwarded with the order and then released
+func TestReserveHandler_HappyPath(t *testing.T) {
+	useFakeReservationClock(t)
+	dotnet := newFakeDotnet(t, []Product{{Id: "prod1", Name: "Headphones", Price: 99.99, Stock: 3}})
+
+	items := []OrderItemRequest{{Id: "prod1", Name: "Headphones", Quantity: 2, Price: 99.99}}
+	status, resp := reserve(t, items)
+	if status != http.StatusOK || !resp.Success || resp.ReservationId == "" {
+		t.Fatalf("reservation failed: status %v, response %+v", status, resp)
+	}
+
+	// The held stock is unavailable to other carts
+	if status, resp := reserve(t, items); status != http.StatusConflict || len(resp.OutOfStockItems) != 1 {
+		t.Errorf("second reservation should conflict: status %v, response %+v", status, resp)
+	}
+
+	rr := httptest.NewRecorder()
+	orderHandler(rr, postJSON(t, "/order", PlaceOrderRequest{Items: items, TotalAmount: pricePtr(199.98), DeliveryAddress: "1 Main St", ReservationId: resp.ReservationId}))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("order failed: status %v, body %s", rr.Code, rr.Body.String())
+	}
+	if got := dotnet.lastOrder(t).ReservationId; got != resp.ReservationId {
+		t.Errorf("upstream got reservationId %q, want %q", got, resp.ReservationId)
+	}
+	if reservations.active(resp.ReservationId) {
+		t.Error("reservation should be released after the order is placed")
+	}
+}
+
+// TestReserveHandler_ExpiredReservation tests that an expired reservation is dropped and the order proceeds
+func TestReserveHandler_ExpiredReservation(t *testing.T) {
+	now := useFakeReservationClock(t)
+	dotnet := newFakeDotnet(t, []Product{{Id: "prod1", Name: "Headphones", Price: 99.99, Stock: 3}})
+
+	items := []OrderItemRequest{{Id: "prod1", Name: "Headphones", Quantity: 3, Price: 99.99}}
+	_, resp := reserve(t, items)
+	*now = now.Add(defaultReservationTTL + time.Second)
+
+	rr := httptest.NewRecorder()
+	orderHandler(rr, postJSON(t, "/order", PlaceOrderRequest{Items: items, TotalAmount: pricePtr(299.97), DeliveryAddress: "1 Main St", ReservationId: resp.ReservationId}))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("order failed: status %v, body %s", rr.Code, rr.Body.String())
+	}
+	if got := dotnet.lastOrder(t).ReservationId; got != "" {
+		t.Errorf("expired reservation %q should not be forwarded", got)
+	}
+
+	// The expired hold no longer blocks new reservations
+	if status, _ := reserve(t, items); status != http.StatusOK {
+		t.Errorf("reservation after expiry returned status %v, want %v", status, http.StatusOK)
+	}
+}
+
+// TestReserveHandler_ReservationMustCoverOrder tests that a reservation only stands in for the items
+// and quantities it holds, and is dropped from any other order
+func TestReserveHandler_ReservationMustCoverOrder(t *testing.T) {
+	useFakeReservationClock(t)
+	dotnet := newFakeDotnet(t, []Product{
+		{Id: "prod1", Name: "Headphones", Price: 99.99, Stock: 3},
+		{Id: "prod2", Name: "Speaker", Price: 10, Stock: 1},
+	})
+	_, resp := reserve(t, []OrderItemRequest{{Id: "prod1", Name: "Headphones", Quantity: 1, Price: 99.99}})
+
+	// A different product isn't held, so the order is pre-checked like any unreserved one
+	rr := httptest.NewRecorder()
+	orderHandler(rr, postJSON(t, "/order", PlaceOrderRequest{Items: []OrderItemRequest{{Id: "prod2", Quantity: 100, Price: 10}}, TotalAmount: pricePtr(1000), DeliveryAddress: "1 Main St", ReservationId: resp.ReservationId}))
+	if rr.Code != http.StatusConflict {
+		t.Errorf("order for an unreserved product: handler returned wrong status code: got %v want %v", rr.Code, http.StatusConflict)
+	}
+	if len(dotnet.Orders) != 0 {
+		t.Errorf("Dotnet received %d orders, want 0", len(dotnet.Orders))
+	}
+
+	// More than was reserved is placed unreserved, without the order's own hold counting against it
+	rr = httptest.NewRecorder()
+	orderHandler(rr, postJSON(t, "/order", PlaceOrderRequest{Items: []OrderItemRequest{{Id: "prod1", Quantity: 3, Price: 99.99}}, TotalAmount: pricePtr(299.97), DeliveryAddress: "1 Main St", ReservationId: resp.ReservationId}))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("order for more than reserved: status %v, body %s", rr.Code, rr.Body.String())
+	}
+	if got := dotnet.lastOrder(t).ReservationId; got != "" {
+		t.Errorf("reservation %q forwarded with an order it doesn't cover", got)
+	}
+	if !reservations.active(resp.ReservationId) {
+		t.Error("reservation released by an order placed without it")
+	}
+}
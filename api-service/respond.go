@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrorResponse is the JSON body returned for API errors
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// requestError is returned by request helpers such as decodeJSON and carries the status to respond with
+type requestError struct {
+	Status  int
+	Message string
+}
+
+func (e *requestError) Error() string { return e.Message }
+
+// writeJSON encodes v as the JSON response body with the given status code, indented with two
+// spaces when the request asks for ?pretty=true and with the keys RESPONSE_NAMING selects
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	body, err := marshalResponse(v)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	if wantsPretty(r) {
+		var indented bytes.Buffer
+		json.Indent(&indented, body, "", "  ")
+		body = indented.Bytes()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(body, '\n'))
+}
+
+// wantsPretty reports whether ?pretty=true asks for indented JSON, for reading responses by hand
+func wantsPretty(r *http.Request) bool {
+	pretty, _ := strconv.ParseBool(r.URL.Query().Get("pretty"))
+	return pretty
+}
+
+// Envelope wraps a successful response body with metadata for clients that opt in
+type Envelope struct {
+	Data interface{}  `json:"data"`
+	Meta EnvelopeMeta `json:"meta"`
+}
+
+// EnvelopeMeta describes the request an enveloped response answers
+type EnvelopeMeta struct {
+	RequestId string `json:"requestId"`
+	Timestamp string `json:"timestamp"`
+}
+
+// wantsEnvelope reports whether the client asked for enveloped responses, either with ?envelope=true
+// or with an Accept media type carrying profile="envelope" (e.g. application/json; profile="envelope")
+func wantsEnvelope(r *http.Request) bool {
+	if enveloped, err := strconv.ParseBool(r.URL.Query().Get("envelope")); err == nil {
+		return enveloped
+	}
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		_, params, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err == nil && params["profile"] == "envelope" {
+			return true
+		}
+	}
+	return false
+}
+
+// writeData writes a response body like writeJSON, wrapping 2xx bodies in an Envelope when the client
+// asked for one. Error bodies are never enveloped so clients parse them the same way either way.
+func writeData(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	if status < 200 || status > 299 || !wantsEnvelope(r) {
+		writeJSON(w, r, status, v)
+		return
+	}
+	id := requestIDFrom(r.Context())
+	if id == "" {
+		// Outside requestIDMiddleware; still give the client an id it can quote
+		id = randomID()
+		w.Header().Set("X-Request-Id", id)
+	}
+	writeJSON(w, r, status, Envelope{
+		Data: v,
+		Meta: EnvelopeMeta{RequestId: id, Timestamp: time.Now().UTC().Format(time.RFC3339)},
+	})
+}
+
+// writeError responds with an error body and the given status code: the JSON envelope by default,
+// or the bare message for clients such as monitoring probes whose Accept header prefers text/plain
+func writeError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	if prefersPlainText(r.Header.Get("Accept")) {
+		writePlainError(w, status, message)
+		return
+	}
+	writeJSON(w, r, status, ErrorResponse{Error: message})
+}
+
+// writePlainError responds with message as a text/plain body
+func writePlainError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	fmt.Fprintln(w, message)
+}
+
+// prefersPlainText reports whether an Accept header ranks text/plain above application/json. Each
+// type takes the q-value of its most specific matching range, so ties, a missing header and "*/*"
+// all keep the JSON default.
+func prefersPlainText(accept string) bool {
+	if strings.TrimSpace(accept) == "" {
+		return false
+	}
+	return acceptQuality(accept, "text", "plain") > acceptQuality(accept, "application", "json")
+}
+
+// acceptQuality returns the q-value an Accept header gives type/subtype, or 0 if it isn't acceptable
+func acceptQuality(accept, typ, subtype string) float64 {
+	bestQ, bestSpecificity := 0.0, 0
+	for _, part := range strings.Split(accept, ",") {
+		mediaRange, q, ok := parseCoding(part) // Media ranges take the same ";q=" parameter as codings
+		if !ok {
+			continue
+		}
+		rangeType, rangeSubtype, _ := strings.Cut(mediaRange, "/")
+		specificity := 0
+		switch {
+		case rangeType == typ && rangeSubtype == subtype:
+			specificity = 3
+		case rangeType == typ && rangeSubtype == "*":
+			specificity = 2
+		case rangeType == "*" && rangeSubtype == "*":
+			specificity = 1
+		}
+		if specificity > bestSpecificity {
+			bestQ, bestSpecificity = q, specificity
+		}
+	}
+	return bestQ
+}
+
+// methodNotAllowed responds with a 405 error and an Allow header listing the supported methods
+func methodNotAllowed(w http.ResponseWriter, r *http.Request, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+}
+
+// writeRequestError responds to an error from a request helper, defaulting to 400 when it carries no status.
+// A validationError is answered with its field errors, which plaintext clients get as one line.
+func writeRequestError(w http.ResponseWriter, r *http.Request, err error) {
+	if valErr, ok := err.(*validationError); ok && !prefersPlainText(r.Header.Get("Accept")) {
+		writeJSON(w, r, http.StatusBadRequest, ValidationErrorResponse{Error: "Invalid order", Fields: valErr.Fields})
+		return
+	}
+	status := http.StatusBadRequest
+	if reqErr, ok := err.(*requestError); ok {
+		status = reqErr.Status
+	}
+	writeError(w, r, status, err.Error())
+}
+
+// decodeJSON requires an application/json Content-Type (a charset parameter is allowed)
+// and decodes the request body into v. Decoding errors are reported as invalidMessage followed by
+// what was wrong, e.g. "Invalid order request body: invalid JSON at offset 24".
+func decodeJSON(r *http.Request, v interface{}, invalidMessage string) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return &requestError{Status: http.StatusUnsupportedMediaType, Message: "Content-Type must be application/json"}
+	}
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return &requestError{Status: http.StatusBadRequest, Message: invalidMessage + ": " + describeJSONError(err)}
+	}
+	return nil
+}
+
+// describeJSONError explains a decoding error in terms of the JSON the client sent
+func describeJSONError(err error) string {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return fmt.Sprintf("invalid JSON at offset %d", syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return fmt.Sprintf("expected %s, got %s", jsonTypeName(typeErr.Type.Kind()), typeErr.Value)
+		}
+		return fmt.Sprintf("field %q expected %s, got %s", typeErr.Field, jsonTypeName(typeErr.Type.Kind()), typeErr.Value)
+	case errors.Is(err, io.EOF):
+		return "request body is empty"
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "unexpected end of JSON input"
+	default:
+		return err.Error() // e.g. a Price that isn't a number
+	}
+}
+
+// jsonTypeName names the JSON type a Go value of the given kind is decoded from
+func jsonTypeName(kind reflect.Kind) string {
+	switch kind {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// writeUpstreamError responds to a failure loading data from the Dotnet service
+func writeUpstreamError(w http.ResponseWriter, r *http.Request, err error) {
+	var upErr *upstreamError
+	if errors.As(err, &upErr) {
+		writeError(w, r, upErr.Status, upErr.Message)
+		return
+	}
+	writeError(w, r, http.StatusBadGateway, "Failed to fetch products from backend service")
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestPrefersPlainText tests Accept negotiation between the JSON and plaintext error bodies
+func TestPrefersPlainText(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"*/*", false},
+		{"application/json", false},
+		{"text/plain", true},
+		{"text/*", true},
+		{"text/plain, application/json", false}, // Ties keep JSON
+		{"application/json;q=0.5, text/plain", true},
+		{"text/plain;q=0.5, */*", false},
+		{"text/html, */*;q=0.1", false},
+		{"text/plain;q=0, */*", false},
+		{"text/plain;q=bogus", false},
+	}
+	for _, tt := range tests {
+		if got := prefersPlainText(tt.accept); got != tt.want {
+			t.Errorf("prefersPlainText(%q) = %v, want %v", tt.accept, got, tt.want)
+		}
+	}
+}
+
+// TestWriteError_ContentNegotiation tests that errors are JSON by default and plaintext on request
+func TestWriteError_ContentNegotiation(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/products", nil)
+	rr := httptest.NewRecorder()
+	writeError(rr, req, http.StatusBadGateway, "Upstream failed")
+	var response ErrorResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil || response.Error != "Upstream failed" {
+		t.Errorf("default error body = %q, want the JSON envelope", rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("default error Content-Type = %q", ct)
+	}
+
+	req.Header.Set("Accept", "text/plain")
+	rr = httptest.NewRecorder()
+	writeError(rr, req, http.StatusBadGateway, "Upstream failed")
+	if status := rr.Code; status != http.StatusBadGateway {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadGateway)
+	}
+	if body := rr.Body.String(); body != "Upstream failed\n" {
+		t.Errorf("plaintext error body = %q, want %q", body, "Upstream failed\n")
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Errorf("plaintext error Content-Type = %q", ct)
+	}
+}
+
+// TestOrderHandler_PlainTextValidationErrors tests that field errors are flattened for plaintext clients
+func TestOrderHandler_PlainTextValidationErrors(t *testing.T) {
+	req := postJSON(t, "/order", PlaceOrderRequest{DeliveryAddress: "1 Main St"})
+	req.Header.Set("Accept", "text/plain")
+	rr := httptest.NewRecorder()
+
+	orderHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Errorf("plaintext validation error Content-Type = %q, body %q", ct, rr.Body.String())
+	}
+}
+
+// TestOrderHandler_UpstreamErrorNegotiation tests that a failure placing the order upstream is JSON by
+// default and plaintext on request, like other errors
+func TestOrderHandler_UpstreamErrorNegotiation(t *testing.T) {
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	os.Setenv("DOTNET_PRODUCTS_API_URL", closed.URL)
+	defer os.Unsetenv("DOTNET_PRODUCTS_API_URL")
+	order := PlaceOrderRequest{
+		Items:           []OrderItemRequest{{Id: "p1", Quantity: 1, Price: 10}},
+		TotalAmount:     pricePtr(10),
+		DeliveryAddress: "1 Main St",
+	}
+
+	rr := httptest.NewRecorder()
+	orderHandler(rr, postJSON(t, "/order", order))
+	if status := rr.Code; status != http.StatusBadGateway {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadGateway)
+	}
+	var response ErrorResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil || response.Error != "Failed to place order with backend service" {
+		t.Errorf("default order error body = %q, want the JSON envelope", rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("default order error Content-Type = %q", ct)
+	}
+
+	req := postJSON(t, "/order", order)
+	req.Header.Set("Accept", "text/plain")
+	rr = httptest.NewRecorder()
+	orderHandler(rr, req)
+	if status := rr.Code; status != http.StatusBadGateway {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadGateway)
+	}
+	if body := rr.Body.String(); body != "Failed to place order with backend service\n" {
+		t.Errorf("plaintext order error body = %q", body)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Errorf("plaintext order error Content-Type = %q", ct)
+	}
+}
+
+// TestWantsEnvelope tests the query parameter and Accept profile opt-ins
+func TestWantsEnvelope(t *testing.T) {
+	tests := []struct {
+		target, accept string
+		want           bool
+	}{
+		{"/products", "", false},
+		{"/products?envelope=true", "", true},
+		{"/products?envelope=false", `application/json; profile="envelope"`, false},
+		{"/products", `application/json; profile="envelope"`, true},
+		{"/products", "text/html, application/json;profile=envelope", true},
+		{"/products", "application/json; profile=other", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		req.Header.Set("Accept", tt.accept)
+		if got := wantsEnvelope(req); got != tt.want {
+			t.Errorf("wantsEnvelope(%s, Accept %q) = %v, want %v", tt.target, tt.accept, got, tt.want)
+		}
+	}
+}
+
+// TestEnvelope_ProductsAndOrders tests that products and order responses are enveloped only on request
+func TestEnvelope_ProductsAndOrders(t *testing.T) {
+	newFakeDotnet(t, []Product{{Id: "p1", Name: "Widget", Price: 5, Stock: 10}})
+
+	// Raw products stay a bare array
+	rr := httptest.NewRecorder()
+	productsHandler(rr, httptest.NewRequest(http.MethodGet, "/products", nil))
+	var raw []Product
+	if err := json.Unmarshal(rr.Body.Bytes(), &raw); err != nil || len(raw) != 1 {
+		t.Errorf("raw products = %s, want a one-element array", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/products?envelope=true", nil)
+	requestIDMiddleware(http.HandlerFunc(productsHandler)).ServeHTTP(rr, req)
+	var products struct {
+		Data []Product    `json:"data"`
+		Meta EnvelopeMeta `json:"meta"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &products); err != nil || len(products.Data) != 1 {
+		t.Fatalf("enveloped products = %s, want data with one product", rr.Body.String())
+	}
+	if products.Meta.RequestId == "" || products.Meta.RequestId != rr.Header().Get("X-Request-Id") || products.Meta.Timestamp == "" {
+		t.Errorf("envelope meta = %+v, want the request id %q and a timestamp", products.Meta, rr.Header().Get("X-Request-Id"))
+	}
+
+	order := PlaceOrderRequest{Items: []OrderItemRequest{{Id: "p1", Quantity: 1, Price: 5}}, DeliveryAddress: "1 Main St", TotalAmount: pricePtr(5)}
+	rr = httptest.NewRecorder()
+	orderHandler(rr, postJSON(t, "/order", order))
+	var placed PlaceOrderResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &placed); err != nil || !placed.Success {
+		t.Errorf("raw order response = %s, want a bare success response", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	req = postJSON(t, "/order", order)
+	req.Header.Set("Accept", `application/json; profile="envelope"`)
+	orderHandler(rr, req)
+	var enveloped struct {
+		Data PlaceOrderResponse `json:"data"`
+		Meta EnvelopeMeta       `json:"meta"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &enveloped); err != nil || !enveloped.Data.Success || enveloped.Meta.RequestId == "" {
+		t.Errorf("enveloped order response = %s, want data and meta", rr.Body.String())
+	}
+}
+
+// TestWriteData_ErrorsNotEnveloped tests that non-2xx bodies are written as-is
+func TestWriteData_ErrorsNotEnveloped(t *testing.T) {
+	rr := httptest.NewRecorder()
+	writeData(rr, httptest.NewRequest(http.MethodPost, "/order?envelope=true", nil), http.StatusConflict, ErrorResponse{Error: "out of stock"})
+	if body := rr.Body.String(); body != "{\"error\":\"out of stock\"}\n" {
+		t.Errorf("writeData returned %q, want the bare error", body)
+	}
+}
+
+// TestWriteJSON_Pretty tests that ?pretty=true indents responses and compact output stays the default
+func TestWriteJSON_Pretty(t *testing.T) {
+	newFakeDotnet(t, []Product{{Id: "p1", Name: "Mug", Price: 5, Stock: 2}})
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", "[{\"id\":\"p1\""},
+		{"?pretty=false", "[{\"id\":\"p1\""},
+		{"?pretty=true", "[\n  {\n    \"id\": \"p1\""},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		productsHandler(rr, httptest.NewRequest(http.MethodGet, "/products"+tt.query, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("handler returned wrong status code for %q: got %v want %v", tt.query, rr.Code, http.StatusOK)
+		}
+		if !strings.HasPrefix(rr.Body.String(), tt.want) {
+			t.Errorf("products%s body = %q, want it to start with %q", tt.query, rr.Body.String(), tt.want)
+		}
+	}
+
+	// Error bodies go through the same helper
+	rr := httptest.NewRecorder()
+	writeError(rr, httptest.NewRequest(http.MethodGet, "/order?pretty=true", nil), http.StatusBadRequest, "bad")
+	if got, want := rr.Body.String(), "{\n  \"error\": \"bad\"\n}\n"; got != want {
+		t.Errorf("error body = %q, want %q", got, want)
+	}
+}
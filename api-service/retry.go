@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// retryPolicy controls how idempotent upstream requests are retried
+type retryPolicy struct {
+	MaxAttempts   int           // Total attempts, including the first
+	Backoff       time.Duration // Base delay between attempts, doubled after each failure
+	MaxRetryAfter time.Duration // Longest Retry-After we are willing to wait on a 429
+	MinAttempt    time.Duration // Least time an attempt needs; no retry starts with less left before the deadline
+}
+
+// retryPolicyFromEnv builds the retry policy from UPSTREAM_MAX_ATTEMPTS, UPSTREAM_RETRY_BACKOFF,
+// UPSTREAM_RETRY_AFTER_MAX and UPSTREAM_MIN_ATTEMPT_TIME
+func retryPolicyFromEnv() retryPolicy {
+	return retryPolicy{
+		MaxAttempts:   envInt("UPSTREAM_MAX_ATTEMPTS", 3),
+		Backoff:       envDuration("UPSTREAM_RETRY_BACKOFF", 200*time.Millisecond),
+		MaxRetryAfter: envDuration("UPSTREAM_RETRY_AFTER_MAX", 5*time.Second),
+		MinAttempt:    envDuration("UPSTREAM_MIN_ATTEMPT_TIME", 100*time.Millisecond),
+	}
+}
+
+// sleep is swapped out in tests so retries don't actually wait
+var sleep = sleepContext
+
+// sleepContext waits for d or until ctx is done, returning ctx.Err() in the latter case
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// doWithRetry performs the request built by newReq, retrying on connection errors, 5xx responses and
+// 429s. A 429 waits for the upstream's Retry-After; if that exceeds MaxRetryAfter the 429 is returned as-is.
+// Retrying stops once ctx is done, and the last result is returned when ctx's deadline leaves too little
+// time for the wait plus another attempt. Only use this for idempotent requests.
+func doWithRetry(ctx context.Context, client *http.Client, newReq func() (*http.Request, error), policy retryPolicy) (*http.Response, error) {
+	attempts := policy.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	backoff := policy.Backoff
+
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		req, err := newReq()
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		last := attempt >= attempts
+
+		var wait time.Duration
+		switch {
+		case err != nil:
+			if last {
+				return nil, err
+			}
+			log.Printf("Upstream request to %s failed (attempt %d/%d): %v", req.URL, attempt, attempts, err)
+			wait = backoff
+		case resp.StatusCode == http.StatusTooManyRequests:
+			retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+			if !ok {
+				retryAfter = backoff
+			}
+			if last || retryAfter > policy.MaxRetryAfter {
+				return resp, nil
+			}
+			log.Printf("Upstream %s rate limited us (attempt %d/%d), retrying in %s", req.URL, attempt, attempts, retryAfter)
+			wait = retryAfter
+		case resp.StatusCode >= 500:
+			if last {
+				return resp, nil
+			}
+			log.Printf("Upstream %s returned status %d (attempt %d/%d)", req.URL, resp.StatusCode, attempt, attempts)
+			wait = backoff
+		default:
+			return resp, nil
+		}
+
+		// Don't start a retry that the caller's deadline leaves no time to finish
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait+policy.MinAttempt {
+			log.Printf("Not retrying %s, only %s left before the deadline", req.URL, time.Until(deadline).Round(time.Millisecond))
+			return resp, err
+		}
+
+		// Drain the discarded response so the connection can be reused
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+		backoff *= 2
+	}
+}
+
+// parseRetryAfter parses a Retry-After header given either as delay-seconds or an HTTP date
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	if d := t.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
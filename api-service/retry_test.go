@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stubSleep replaces sleep with a recorder for the duration of the test
+func stubSleep(t *testing.T) *[]time.Duration {
+	t.Helper()
+	var slept []time.Duration
+	orig := sleep
+	sleep = func(ctx context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return ctx.Err()
+	}
+	t.Cleanup(func() { sleep = orig })
+	return &slept
+}
+
+// newRateLimitedUpstream returns 429 with the given Retry-After on the first request and 200 afterwards
+func newRateLimitedUpstream(t *testing.T, retryAfter string) (*httptest.Server, *int32) {
+	t.Helper()
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			w.Header().Set("Retry-After", retryAfter)
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	return server, &hits
+}
+
+func getRequest(url string) func() (*http.Request, error) {
+	return func() (*http.Request, error) { return http.NewRequest(http.MethodGet, url, nil) }
+}
+
+// TestDoWithRetry_RetryAfterSeconds tests that a 429 with integer Retry-After waits and retries
+func TestDoWithRetry_RetryAfterSeconds(t *testing.T) {
+	slept := stubSleep(t)
+	server, hits := newRateLimitedUpstream(t, "2")
+
+	policy := retryPolicy{MaxAttempts: 3, Backoff: 10 * time.Millisecond, MaxRetryAfter: 5 * time.Second}
+	resp, err := doWithRetry(context.Background(), http.DefaultClient, getRequest(server.URL), policy)
+	if err != nil {
+		t.Fatalf("doWithRetry returned error: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("got status %v want %v", resp.StatusCode, http.StatusOK)
+	}
+	if got := atomic.LoadInt32(hits); got != 2 {
+		t.Errorf("upstream hit %d times, want 2", got)
+	}
+	if len(*slept) != 1 || (*slept)[0] != 2*time.Second {
+		t.Errorf("slept %v, want [2s]", *slept)
+	}
+}
+
+// TestDoWithRetry_RetryAfterHTTPDate tests that a 429 with an HTTP-date Retry-After waits until that date
+func TestDoWithRetry_RetryAfterHTTPDate(t *testing.T) {
+	slept := stubSleep(t)
+	server, hits := newRateLimitedUpstream(t, time.Now().Add(3*time.Second).UTC().Format(http.TimeFormat))
+
+	policy := retryPolicy{MaxAttempts: 3, Backoff: 10 * time.Millisecond, MaxRetryAfter: 5 * time.Second}
+	resp, err := doWithRetry(context.Background(), http.DefaultClient, getRequest(server.URL), policy)
+	if err != nil {
+		t.Fatalf("doWithRetry returned error: %v", err)
+	}
+	resp.Body.Close()
+
+	if got := atomic.LoadInt32(hits); got != 2 {
+		t.Errorf("upstream hit %d times, want 2", got)
+	}
+	// HTTP dates have one-second resolution, so allow for truncation
+	if len(*slept) != 1 || (*slept)[0] < time.Second || (*slept)[0] > 3*time.Second {
+		t.Errorf("slept %v, want roughly 3s", *slept)
+	}
+}
+
+// TestDoWithRetry_RetryAfterExceedsMax tests that an overly long Retry-After fails fast with the 429
+func TestDoWithRetry_RetryAfterExceedsMax(t *testing.T) {
+	slept := stubSleep(t)
+	server, hits := newRateLimitedUpstream(t, "120")
+
+	policy := retryPolicy{MaxAttempts: 3, Backoff: 10 * time.Millisecond, MaxRetryAfter: 5 * time.Second}
+	resp, err := doWithRetry(context.Background(), http.DefaultClient, getRequest(server.URL), policy)
+	if err != nil {
+		t.Fatalf("doWithRetry returned error: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("got status %v want %v", resp.StatusCode, http.StatusTooManyRequests)
+	}
+	if got := atomic.LoadInt32(hits); got != 1 {
+		t.Errorf("upstream hit %d times, want 1", got)
+	}
+	if len(*slept) != 0 {
+		t.Errorf("expected no sleep, slept %v", *slept)
+	}
+}
+
+// TestParseRetryAfter tests both Retry-After forms and malformed values
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"0", 0, true},
+		{"30", 30 * time.Second, true},
+		{"-1", 0, false},
+		{"Mon, 01 Jan 2024 12:00:10 GMT", 10 * time.Second, true},
+		{"Mon, 01 Jan 2024 11:59:00 GMT", 0, true},
+		{"soon", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("parseRetryAfter(%q) = %v, %v; want %v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+// newFailingUpstream always returns 503 and counts requests
+func newFailingUpstream(t *testing.T) (*httptest.Server, *int32) {
+	t.Helper()
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+	return server, &hits
+}
+
+// TestDoWithRetry_CanceledContext tests that a canceled context stops retries before the next attempt
+func TestDoWithRetry_CanceledContext(t *testing.T) {
+	server, hits := newFailingUpstream(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Cancel while waiting to retry, as if the client disconnected during the backoff
+	orig := sleep
+	sleep = func(ctx context.Context, d time.Duration) error {
+		cancel()
+		return ctx.Err()
+	}
+	defer func() { sleep = orig }()
+
+	_, err := doWithRetry(ctx, http.DefaultClient, getRequest(server.URL), retryPolicy{MaxAttempts: 5, Backoff: time.Millisecond})
+	if err != context.Canceled {
+		t.Errorf("doWithRetry returned %v, want context.Canceled", err)
+	}
+	if got := atomic.LoadInt32(hits); got != 1 {
+		t.Errorf("upstream hit %d times, want 1", got)
+	}
+}
+
+// TestDoWithRetry_AlreadyCanceled tests that no attempt is made with a context that is already done
+func TestDoWithRetry_AlreadyCanceled(t *testing.T) {
+	server, hits := newFailingUpstream(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := doWithRetry(ctx, http.DefaultClient, getRequest(server.URL), retryPolicy{MaxAttempts: 3}); err != context.Canceled {
+		t.Errorf("doWithRetry returned %v, want context.Canceled", err)
+	}
+	if got := atomic.LoadInt32(hits); got != 0 {
+		t.Errorf("upstream hit %d times, want 0", got)
+	}
+}
+
+// TestDoWithRetry_DeadlineBudget tests that no retry starts when the deadline can't fit the backoff and another attempt
+func TestDoWithRetry_DeadlineBudget(t *testing.T) {
+	slept := stubSleep(t)
+	server, hits := newFailingUpstream(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	policy := retryPolicy{MaxAttempts: 5, Backoff: 2 * time.Second, MinAttempt: 100 * time.Millisecond}
+	resp, err := doWithRetry(ctx, http.DefaultClient, getRequest(server.URL), policy)
+	if err != nil {
+		t.Fatalf("doWithRetry returned error: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("got status %d, want the last upstream status 503", resp.StatusCode)
+	}
+	if got := atomic.LoadInt32(hits); got != 1 {
+		t.Errorf("upstream hit %d times, want 1", got)
+	}
+	if len(*slept) != 0 {
+		t.Errorf("expected no sleep, slept %v", *slept)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Middleware wraps a handler with behaviour shared across endpoints
+type Middleware func(http.Handler) http.Handler
+
+// chain wraps h in mws so they run in the order given: the first middleware is the outermost and
+// sees the request first and the response last
+func chain(h http.Handler, mws ...Middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// routes registers every endpoint on a new mux and wraps it in the server middleware
+func routes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/auth", authHandler)
+	// With AUTH_REQUIRED=true the shop endpoints need a login token or API key. Authentication comes
+	// first so the tenant list isn't probed without credentials; with TENANT_UPSTREAMS_FILE set each
+	// request is then routed to its tenant's Dotnet service.
+	shop := func(h http.HandlerFunc) http.Handler {
+		if authRequired() {
+			return requireAuth(requireTenant(h))
+		}
+		return requireTenant(h)
+	}
+	mux.Handle("/products", shop(productsHandler))
+	// With GUEST_CHECKOUT=true orders without credentials are taken too, as rate-limited guest orders
+	checkout := func(h http.HandlerFunc) http.Handler {
+		if guestCheckoutEnabled() {
+			return allowGuests(shop(h), requireTenant(limitGuestOrders(h)))
+		}
+		return shop(h)
+	}
+	mux.Handle("/order", recordRequests(checkout(orderHandler))) // New endpoint for order processing
+	mux.Handle("/orders/batch", shop(batchOrderHandler))
+	mux.Handle("/cart/reserve", shop(reserveHandler))
+	mux.Handle("/categories", shop(categoriesHandler))
+	mux.Handle("/products/export", shop(exportHandler))
+	mux.Handle("/products/stream", shop(productsStreamHandler))
+	// Images stay outside auth because <img> tags can't send a bearer token or API key; they only
+	// serve image bytes for catalog products, from origins on IMAGE_PROXY_ALLOWED_ORIGINS
+	mux.Handle("/products/{id}/image", requireTenant(http.HandlerFunc(productImageHandler)))
+	mux.HandleFunc("/healthz", healthzHandler)
+	mux.HandleFunc("/livez", livezHandler)
+	mux.HandleFunc("/ping", pingHandler) // Outside auth so load balancers can probe it
+	mux.HandleFunc("/features", featuresHandler)
+	// With ADMIN_PORT set these move to the admin listener so the public port never serves them
+	if adminPort() == "" {
+		registerAdminRoutes(mux)
+	}
+	mux.HandleFunc(notFoundPattern, notFoundHandler)
+
+	handler := http.Handler(mux)
+	// Inject faults innermost so they are logged, traced and counted under their route like real
+	// failures; an invalid chaos configuration has already stopped startup
+	if cfg, err := chaosConfigFromEnv(); err == nil && cfg.Enabled {
+		log.Printf("WARNING: CHAOS MODE ENABLED. Injecting 500s into %.0f%% and %s delays into %.0f%% of requests to %s (CHAOS_SEED=%d). Never enable this in production.",
+			cfg.ErrorRate*100, cfg.Delay, cfg.DelayRate*100, strings.Join(slices.Sorted(maps.Keys(cfg.Paths)), ", "), cfg.Seed)
+		handler = newChaosInjector(cfg).middleware(handler)
+	}
+	return withServerMiddleware(handler)
+}
+
+// registerAdminRoutes registers the operator endpoints: metrics, /admin/*, /status and, when enabled, pprof
+func registerAdminRoutes(mux *http.ServeMux) {
+	mux.Handle("/metrics", metricsHandler)
+	mux.Handle("/admin/maintenance", requireAdmin(http.HandlerFunc(maintenanceHandler)))
+	mux.Handle("/admin/features/reload", requireAdmin(http.HandlerFunc(featuresReloadHandler)))
+	mux.Handle("/admin/products/refresh", requireAdmin(http.HandlerFunc(productsRefreshHandler)))
+	mux.Handle("/admin/config", requireAdmin(http.HandlerFunc(configHandler)))
+	mux.Handle("/admin/cache", requireAdmin(http.HandlerFunc(cacheHandler)))
+	mux.Handle("/admin/cache/{key...}", requireAdmin(http.HandlerFunc(cacheEntryHandler)))
+	mux.Handle("/status", requireAdmin(http.HandlerFunc(summaryHandler)))
+	if pprofEnabled() {
+		registerPprof(mux)
+	}
+}
+
+// adminRoutes serves the operator endpoints on the ADMIN_PORT listener, without fault injection
+func adminRoutes() http.Handler {
+	mux := http.NewServeMux()
+	registerAdminRoutes(mux)
+	mux.HandleFunc(notFoundPattern, notFoundHandler)
+	return withServerMiddleware(mux)
+}
+
+// withServerMiddleware wraps a mux in the middleware every listener shares
+func withServerMiddleware(handler http.Handler) http.Handler {
+	sampler := newLogSampler(envFloat("LOG_SAMPLE_RATE", 1.0), time.Now().UnixNano())
+	return chain(handler,
+		// Start the server span first so everything below, including logging, runs inside it
+		tracingMiddleware,
+		// Assign the request id before anything logs it
+		requestIDMiddleware,
+		// Keep the FORWARD_HEADERS the Dotnet service should see for this request
+		forwardHeadersMiddleware,
+		// Write every request to the access log, with response bytes as sent after compression
+		func(next http.Handler) http.Handler { return accessLogMiddleware(next, accessLog) },
+		// Log completed requests, sampling successful ones to keep production log volume down
+		func(next http.Handler) http.Handler { return loggingMiddleware(next, sampler) },
+		// Record per-handler metrics; it reads the pattern the mux matched, so nothing between it and
+		// the mux may replace the request, and it counts response bytes as sent after compression
+		serverMetrics.middleware,
+		// Compress responses for clients that accept br or gzip
+		compressionMiddleware,
+	)
+}
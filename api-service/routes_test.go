@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestChain_Order tests that middleware run outermost-first on the way in and in reverse on the way out
+func TestChain_Order(t *testing.T) {
+	var trace []string
+	mw := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				trace = append(trace, name+" in")
+				next.ServeHTTP(w, r)
+				trace = append(trace, name+" out")
+			})
+		}
+	}
+	handler := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		trace = append(trace, "handler")
+	}), mw("a"), mw("b"), mw("c"))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	want := "a in,b in,c in,handler,c out,b out,a out"
+	if got := strings.Join(trace, ","); got != want {
+		t.Errorf("middleware order = %s, want %s", got, want)
+	}
+}
+
+// TestChain_NoMiddleware tests that chaining nothing returns the handler itself
+func TestChain_NoMiddleware(t *testing.T) {
+	rr := httptest.NewRecorder()
+	chain(statusHandler(http.StatusTeapot)).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if status := rr.Code; status != http.StatusTeapot {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusTeapot)
+	}
+}
+
+// TestRoutes tests that registered endpoints are served through the middleware and unknown paths 404
+func TestRoutes(t *testing.T) {
+	handler := routes()
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/features", nil))
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code for /features: got %v want %v", status, http.StatusOK)
+	}
+	if vary := rr.Header().Get("Vary"); vary != "Accept-Encoding" {
+		t.Errorf("Vary = %q, want the compression middleware's Accept-Encoding", vary)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/no-such-endpoint", nil))
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code for unknown path: got %v want %v", status, http.StatusNotFound)
+	}
+}
+
+// TestRoutes_NotFound tests that unknown paths get a JSON not_found error naming the path on both muxes
+func TestRoutes_NotFound(t *testing.T) {
+	for name, handler := range map[string]http.Handler{"public": routes(), "admin": adminRoutes()} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/no/such/endpoint", nil))
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", name, status, http.StatusNotFound)
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s: Content-Type = %q, want application/json", name, ct)
+		}
+		var body NotFoundResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: decoding body %q: %v", name, rr.Body.String(), err)
+		}
+		want := NotFoundError{Code: "not_found", Message: "No endpoint at /no/such/endpoint", Path: "/no/such/endpoint"}
+		if body.Error != want {
+			t.Errorf("%s: error = %+v, want %+v", name, body.Error, want)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/missing", nil)
+	req.Header.Set("Accept", "text/plain")
+	rr := httptest.NewRecorder()
+	routes().ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound || strings.TrimSpace(rr.Body.String()) != "No endpoint at /missing" {
+		t.Errorf("plain text not found = %d %q, want 404 with the bare message", rr.Code, rr.Body.String())
+	}
+}
+
+// TestRoutes_AdminPort tests that with ADMIN_PORT set the operator endpoints leave the public mux for the admin one
+func TestRoutes_AdminPort(t *testing.T) {
+	withAdminToken(t, "secret")
+	os.Setenv("ENABLE_PPROF", "true")
+	defer os.Unsetenv("ENABLE_PPROF")
+	os.Setenv("ADMIN_PORT", "9090")
+	defer os.Unsetenv("ADMIN_PORT")
+
+	public, admin := routes(), adminRoutes()
+	get := func(handler http.Handler, path string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	for _, path := range []string{"/metrics", "/admin/maintenance", "/admin/config", "/debug/pprof/", "/debug/pprof/heap"} {
+		if status := get(public, path); status != http.StatusNotFound {
+			t.Errorf("public handler returned wrong status code for %s: got %v want %v", path, status, http.StatusNotFound)
+		}
+		if status := get(admin, path); status != http.StatusOK {
+			t.Errorf("admin handler returned wrong status code for %s: got %v want %v", path, status, http.StatusOK)
+		}
+	}
+	for _, path := range []string{"/features", "/ping"} {
+		if status := get(public, path); status != http.StatusOK {
+			t.Errorf("public handler returned wrong status code for %s: got %v want %v", path, status, http.StatusOK)
+		}
+		if status := get(admin, path); status != http.StatusNotFound {
+			t.Errorf("admin handler returned wrong status code for %s: got %v want %v", path, status, http.StatusNotFound)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"html"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// htmlTag matches an HTML tag, comment or doctype for the "strip" sanitization policy; a "<" not
+// followed by a name, as in "5 < 6", is left for escaping
+var htmlTag = regexp.MustCompile(`<[/!?]?[a-zA-Z-][^<>]*>`)
+
+// outputSanitizer returns the function applied to product names and descriptions before they are
+// sent, or nil when SANITIZE_OUTPUT is off. SANITIZE_POLICY chooses "escape" (the default), which
+// HTML-escapes the text, or "strip", which removes tags and escapes any leftover angle brackets.
+func outputSanitizer() func(string) string {
+	if !envBool("SANITIZE_OUTPUT", false) {
+		return nil
+	}
+	switch policy := os.Getenv("SANITIZE_POLICY"); policy {
+	case "", "escape":
+		return html.EscapeString
+	case "strip":
+		return stripTags
+	default:
+		log.Printf("Invalid SANITIZE_POLICY value %q. Using default 'escape'.", policy)
+		return html.EscapeString
+	}
+}
+
+// stripTags removes HTML tags from s, repeating until none are left so nested fragments such as
+// "<scr<b>ipt>" can't reassemble into a tag, then escapes any stray angle brackets
+func stripTags(s string) string {
+	for {
+		stripped := htmlTag.ReplaceAllString(s, "")
+		if stripped == s {
+			break
+		}
+		s = stripped
+	}
+	return strings.NewReplacer("<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// sanitizeProducts returns a copy of products with sanitize applied to each name and description
+func sanitizeProducts(products []Product, sanitize func(string) string) []Product {
+	sanitized := make([]Product, len(products))
+	for i, p := range products {
+		p.Name = sanitize(p.Name)
+		p.Description = sanitize(p.Description)
+		sanitized[i] = p
+	}
+	return sanitized
+}
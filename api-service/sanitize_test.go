@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestStripTags tests tag removal, including fragments that reassemble into tags
+func TestStripTags(t *testing.T) {
+	tests := map[string]string{
+		"Plain name":                         "Plain name",
+		"<b>Bold</b> deal":                   "Bold deal",
+		`<img src=x onerror="alert(1)">Shoe`: "Shoe",
+		"<scr<b>ipt>alert(1)</script>":       "alert(1)",
+		"5 < 6 and 7 > 3":                    "5 &lt; 6 and 7 &gt; 3",
+		"<<script>script>x":                  "x",
+		"<!-- hidden -->Visible":             "Visible",
+	}
+	for in, want := range tests {
+		if got := stripTags(in); got != want {
+			t.Errorf("stripTags(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// TestProductsHandler_SanitizeOutput tests that malicious product text is escaped or stripped only when enabled
+func TestProductsHandler_SanitizeOutput(t *testing.T) {
+	newFakeDotnet(t, []Product{{
+		Id:          "p1",
+		Name:        `<script>alert("xss")</script>Widget`,
+		Description: `<img src=x onerror=alert(1)>Great & cheap`,
+	}})
+
+	_, products := getProducts(t, "?q=script")
+	if len(products) != 1 || products[0].Name != `<script>alert("xss")</script>Widget` {
+		t.Fatalf("unsanitized products = %+v, want the raw name", products)
+	}
+
+	os.Setenv("SANITIZE_OUTPUT", "true")
+	defer os.Unsetenv("SANITIZE_OUTPUT")
+	_, products = getProducts(t, "?q=script") // Filters still match the raw text
+	if len(products) != 1 {
+		t.Fatalf("got %d products, want 1", len(products))
+	}
+	if got, want := products[0].Name, "&lt;script&gt;alert(&#34;xss&#34;)&lt;/script&gt;Widget"; got != want {
+		t.Errorf("escaped name = %q, want %q", got, want)
+	}
+	if got, want := products[0].Description, "&lt;img src=x onerror=alert(1)&gt;Great &amp; cheap"; got != want {
+		t.Errorf("escaped description = %q, want %q", got, want)
+	}
+
+	os.Setenv("SANITIZE_POLICY", "strip")
+	defer os.Unsetenv("SANITIZE_POLICY")
+	_, products = getProducts(t, "")
+	if products[0].Name != `alert("xss")Widget` || products[0].Description != "Great & cheap" {
+		t.Errorf("stripped product = %+v", products[0])
+	}
+}
+
+// TestProductsHandler_SanitizedETag tests that sanitized responses don't share an ETag with raw ones
+func TestProductsHandler_SanitizedETag(t *testing.T) {
+	newFakeDotnet(t, []Product{{Id: "p1", Name: "<b>Widget</b>"}})
+
+	rr := httptest.NewRecorder()
+	productsHandler(rr, httptest.NewRequest(http.MethodGet, "/products", nil))
+	raw := rr.Header().Get("ETag")
+
+	os.Setenv("SANITIZE_OUTPUT", "true")
+	defer os.Unsetenv("SANITIZE_OUTPUT")
+	rr = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/products", nil)
+	req.Header.Set("If-None-Match", raw)
+	productsHandler(rr, req)
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") == raw || !strings.Contains(rr.Body.String(), "\\u0026lt;b\\u0026gt;") {
+		t.Errorf("sanitized response = %d with ETag %s (raw %s): %s", rr.Code, rr.Header().Get("ETag"), raw, rr.Body.String())
+	}
+}
@@ -0,0 +1,355 @@
+package main
+
+// A small JSON Schema validator for the order contract. It covers the keywords an order schema needs
+// (type, properties, required, additionalProperties, items, enum, minimum/maximum and their exclusive
+// forms, minLength/maxLength, pattern, minItems/maxItems) and refuses to compile schemas using any
+// other validation keyword, so a schema never silently checks less than its author expects.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// orderSchema is the schema orderHandler checks request bodies against, compiled at startup from
+// ORDER_SCHEMA_FILE; nil means orders are only checked by validateOrder
+var orderSchema *jsonSchema
+
+// jsonSchema is a compiled schema; unset constraints are nil
+type jsonSchema struct {
+	Types                []string
+	Properties           map[string]*jsonSchema
+	Required             []string
+	AdditionalProperties *jsonSchema // Schema for properties not listed in Properties
+	NoAdditional         bool        // "additionalProperties": false
+	Items                *jsonSchema
+	Enum                 []interface{}
+	Minimum              *float64
+	Maximum              *float64
+	ExclusiveMinimum     *float64
+	ExclusiveMaximum     *float64
+	MinLength            *int
+	MaxLength            *int
+	MinItems             *int
+	MaxItems             *int
+	Pattern              *regexp.Regexp
+}
+
+// schemaAnnotations are keywords that describe a schema without constraining values
+var schemaAnnotations = map[string]bool{
+	"$schema": true, "$id": true, "$comment": true, "title": true, "description": true,
+	"default": true, "examples": true,
+}
+
+// loadSchemaFile reads and compiles the schema at path
+func loadSchemaFile(path string) (*jsonSchema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := compileSchema(data)
+	if err != nil {
+		return nil, fmt.Errorf("compiling %s: %w", path, err)
+	}
+	return schema, nil
+}
+
+// compileSchema parses a schema document
+func compileSchema(data []byte) (*jsonSchema, error) {
+	return compileSchemaAt(json.RawMessage(data), "#")
+}
+
+// compileSchemaAt compiles the schema at the given JSON pointer, which is used in error messages
+func compileSchemaAt(data json.RawMessage, at string) (*jsonSchema, error) {
+	var keywords map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keywords); err != nil {
+		return nil, fmt.Errorf("%s: schema must be an object", at)
+	}
+	s := &jsonSchema{}
+	names := make([]string, 0, len(keywords))
+	for name := range keywords {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		raw := keywords[name]
+		var err error
+		switch name {
+		case "type":
+			var single string
+			if json.Unmarshal(raw, &single) == nil {
+				s.Types = []string{single}
+			} else {
+				err = json.Unmarshal(raw, &s.Types)
+			}
+			for _, t := range s.Types {
+				switch t {
+				case "object", "array", "string", "number", "integer", "boolean", "null":
+				default:
+					err = fmt.Errorf("unknown type %q", t)
+				}
+			}
+		case "properties":
+			var props map[string]json.RawMessage
+			if err = json.Unmarshal(raw, &props); err == nil {
+				s.Properties = make(map[string]*jsonSchema, len(props))
+				for prop, sub := range props {
+					if s.Properties[prop], err = compileSchemaAt(sub, at+"/properties/"+prop); err != nil {
+						return nil, err
+					}
+				}
+			}
+		case "required":
+			err = json.Unmarshal(raw, &s.Required)
+		case "additionalProperties":
+			var allowed bool
+			if json.Unmarshal(raw, &allowed) == nil {
+				s.NoAdditional = !allowed
+			} else if s.AdditionalProperties, err = compileSchemaAt(raw, at+"/additionalProperties"); err != nil {
+				return nil, err
+			}
+		case "items":
+			if s.Items, err = compileSchemaAt(raw, at+"/items"); err != nil {
+				return nil, err
+			}
+		case "enum":
+			err = json.Unmarshal(raw, &s.Enum)
+		case "minimum":
+			err = json.Unmarshal(raw, &s.Minimum)
+		case "maximum":
+			err = json.Unmarshal(raw, &s.Maximum)
+		case "exclusiveMinimum":
+			err = json.Unmarshal(raw, &s.ExclusiveMinimum)
+		case "exclusiveMaximum":
+			err = json.Unmarshal(raw, &s.ExclusiveMaximum)
+		case "minLength":
+			err = json.Unmarshal(raw, &s.MinLength)
+		case "maxLength":
+			err = json.Unmarshal(raw, &s.MaxLength)
+		case "minItems":
+			err = json.Unmarshal(raw, &s.MinItems)
+		case "maxItems":
+			err = json.Unmarshal(raw, &s.MaxItems)
+		case "pattern":
+			var pattern string
+			if err = json.Unmarshal(raw, &pattern); err == nil {
+				s.Pattern, err = regexp.Compile(pattern)
+			}
+		default:
+			if !schemaAnnotations[name] {
+				err = fmt.Errorf("unsupported keyword")
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s/%s: %v", at, name, err)
+		}
+	}
+	return s, nil
+}
+
+// validateJSON checks a JSON document against the schema, returning every violation found
+func (s *jsonSchema) validateJSON(data []byte) ([]FieldError, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return s.validate(v, ""), nil
+}
+
+// validate checks v, decoded with UseNumber, addressing violations by path in FieldError style
+func (s *jsonSchema) validate(v interface{}, path string) []FieldError {
+	field := path
+	if field == "" {
+		field = "body"
+	}
+	fail := func(format string, args ...interface{}) []FieldError {
+		return []FieldError{{Field: field, Message: fmt.Sprintf(format, args...)}}
+	}
+
+	if len(s.Types) > 0 && !schemaTypeMatches(s.Types, v) {
+		return fail("must be of type %s, got %s", strings.Join(s.Types, " or "), schemaTypeOf(v))
+	}
+
+	var fields []FieldError
+	if len(s.Enum) > 0 {
+		found := false
+		plain := plainJSON(v)
+		for _, allowed := range s.Enum {
+			if reflect.DeepEqual(plain, allowed) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fields = append(fields, fail("must be one of %s", enumList(s.Enum))...)
+		}
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				fields = append(fields, FieldError{Field: joinSchemaPath(path, name), Message: "is required"})
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if sub, ok := s.Properties[name]; ok {
+				fields = append(fields, sub.validate(v[name], joinSchemaPath(path, name))...)
+			} else if s.NoAdditional {
+				fields = append(fields, FieldError{Field: joinSchemaPath(path, name), Message: "is not allowed"})
+			} else if s.AdditionalProperties != nil {
+				fields = append(fields, s.AdditionalProperties.validate(v[name], joinSchemaPath(path, name))...)
+			}
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			fields = append(fields, fail("must contain at least %d items", *s.MinItems)...)
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			fields = append(fields, fail("must contain at most %d items", *s.MaxItems)...)
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				fields = append(fields, s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case string:
+		length := utf8.RuneCountInString(v)
+		if s.MinLength != nil && length < *s.MinLength {
+			fields = append(fields, fail("must be at least %d characters", *s.MinLength)...)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			fields = append(fields, fail("must be at most %d characters", *s.MaxLength)...)
+		}
+		if s.Pattern != nil && !s.Pattern.MatchString(v) {
+			fields = append(fields, fail("must match %s", s.Pattern)...)
+		}
+	case json.Number:
+		n, _ := v.Float64()
+		if s.Minimum != nil && n < *s.Minimum {
+			fields = append(fields, fail("must be >= %v", *s.Minimum)...)
+		}
+		if s.Maximum != nil && n > *s.Maximum {
+			fields = append(fields, fail("must be <= %v", *s.Maximum)...)
+		}
+		if s.ExclusiveMinimum != nil && n <= *s.ExclusiveMinimum {
+			fields = append(fields, fail("must be > %v", *s.ExclusiveMinimum)...)
+		}
+		if s.ExclusiveMaximum != nil && n >= *s.ExclusiveMaximum {
+			fields = append(fields, fail("must be < %v", *s.ExclusiveMaximum)...)
+		}
+	}
+	return fields
+}
+
+// joinSchemaPath extends a FieldError path with a property name, e.g. "items[0]" and "id" to "items[0].id"
+func joinSchemaPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// schemaTypeMatches reports whether v has one of the JSON Schema types; an integer is a number without a fraction
+func schemaTypeMatches(types []string, v interface{}) bool {
+	got := schemaTypeOf(v)
+	for _, t := range types {
+		if t == got || (t == "number" && got == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// schemaTypeOf names the JSON Schema type of a value decoded with UseNumber
+func schemaTypeOf(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	case json.Number:
+		if n, err := v.Float64(); err == nil && n == math.Trunc(n) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// plainJSON converts json.Number values to float64 so a value compares equal to enum entries
+func plainJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		n, _ := v.Float64()
+		return n
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = plainJSON(item)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			out[k] = plainJSON(item)
+		}
+		return out
+	}
+	return v
+}
+
+// enumList formats the allowed values of an enum for a violation message
+func enumList(values []interface{}) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		b, _ := json.Marshal(v)
+		parts[i] = string(b)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// decodeOrderRequest decodes an order body, first checking it against orderSchema when one is configured.
+// Schema violations are returned as a *validationError; validateOrder still runs in placeOrder, as it
+// normalizes the order and enforces the server-side caps.
+func decodeOrderRequest(r *http.Request, order *PlaceOrderRequest) error {
+	if orderSchema == nil {
+		return decodeJSON(r, order, "Invalid order request body")
+	}
+	var raw json.RawMessage
+	if err := decodeJSON(r, &raw, "Invalid order request body"); err != nil {
+		return err
+	}
+	fields, err := orderSchema.validateJSON(raw)
+	if err != nil {
+		return &requestError{Status: http.StatusBadRequest, Message: "Invalid order request body: " + describeJSONError(err)}
+	}
+	if len(fields) > 0 {
+		return &validationError{Fields: fields}
+	}
+	if err := json.Unmarshal(raw, order); err != nil {
+		return &requestError{Status: http.StatusBadRequest, Message: "Invalid order request body: " + describeJSONError(err)}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testOrderSchema requires a known region and caps quantities below validateOrder's default
+const testOrderSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"required": ["items", "deliveryAddress", "region"],
+	"properties": {
+		"items": {
+			"type": "array",
+			"minItems": 1,
+			"items": {
+				"type": "object",
+				"required": ["id", "quantity"],
+				"properties": {
+					"id": {"type": "string", "pattern": "^p[0-9]+$"},
+					"quantity": {"type": "integer", "minimum": 1, "maximum": 10},
+					"price": {"type": "number", "minimum": 0}
+				}
+			}
+		},
+		"deliveryAddress": {"type": "string", "minLength": 1, "maxLength": 200},
+		"region": {"enum": ["US-CA", "US-NY"]},
+		"totalAmount": {"type": "number"}
+	}
+}`
+
+// useOrderSchema compiles schema into orderSchema for the duration of the test
+func useOrderSchema(t *testing.T, schema string) {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "order.schema.json")
+	if err := os.WriteFile(path, []byte(schema), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	compiled, err := loadSchemaFile(path)
+	if err != nil {
+		t.Fatalf("loadSchemaFile() error = %v", err)
+	}
+	orderSchema = compiled
+	t.Cleanup(func() { orderSchema = nil })
+}
+
+// TestOrderHandler_SchemaAcceptsValidOrder tests that an order matching the schema is placed
+func TestOrderHandler_SchemaAcceptsValidOrder(t *testing.T) {
+	fake := newFakeDotnet(t, nil)
+	useOrderSchema(t, testOrderSchema)
+
+	order := PlaceOrderRequest{Items: []OrderItemRequest{{Id: "p1", Quantity: 2, Price: 5}}, DeliveryAddress: "1 Main St", Region: "US-CA", TotalAmount: pricePtr(10)}
+	rr := httptest.NewRecorder()
+	orderHandler(rr, postJSON(t, "/order", order))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v (%s)", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	if len(fake.Orders) != 1 {
+		t.Errorf("upstream received %d orders, want 1", len(fake.Orders))
+	}
+}
+
+// TestOrderHandler_SchemaRejectsInvalidOrder tests that every violation is reported and nothing is forwarded
+func TestOrderHandler_SchemaRejectsInvalidOrder(t *testing.T) {
+	fake := newFakeDotnet(t, nil)
+	useOrderSchema(t, testOrderSchema)
+
+	body := `{"items": [{"id": "sku-1", "quantity": 20}, {"quantity": 1.5}], "deliveryAddress": "1 Main St", "region": "EU"}`
+	req := httptest.NewRequest(http.MethodPost, "/order", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	orderHandler(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+	var resp ValidationErrorResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	want := []FieldError{
+		{Field: "items[0].id", Message: "must match ^p[0-9]+$"},
+		{Field: "items[0].quantity", Message: "must be <= 10"},
+		{Field: "items[1].id", Message: "is required"},
+		{Field: "items[1].quantity", Message: "must be of type integer, got number"},
+		{Field: "region", Message: `must be one of "US-CA", "US-NY"`},
+	}
+	if !reflect.DeepEqual(resp.Fields, want) {
+		t.Errorf("fields = %+v, want %+v", resp.Fields, want)
+	}
+	if len(fake.Orders) != 0 {
+		t.Errorf("upstream received %d orders, want none", len(fake.Orders))
+	}
+}
+
+// TestCompileSchema_RejectsUnsupportedKeywords tests that a schema can't silently check less than it says
+func TestCompileSchema_RejectsUnsupportedKeywords(t *testing.T) {
+	for _, schema := range []string{
+		`{"type": "object", "properties": {"items": {"$ref": "#/$defs/items"}}}`,
+		`{"type": "decimal"}`,
+		`{"pattern": "("}`,
+		`[]`,
+	} {
+		if _, err := compileSchema([]byte(schema)); err == nil {
+			t.Errorf("compileSchema(%s) succeeded, want an error", schema)
+		}
+	}
+}
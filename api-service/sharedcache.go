@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// sharedCatalog is a catalog as stored in sharedStore, so replicas reuse each other's fetches
+type sharedCatalog struct {
+	Products        []Product `json:"products"`
+	FetchedAt       time.Time `json:"fetchedAt"`
+	LastModified    time.Time `json:"lastModified"`
+	ContentLanguage string    `json:"contentLanguage,omitempty"`
+	DeltaSince      time.Time `json:"deltaSince,omitzero"` // Zero in catalogs stored before it was recorded
+}
+
+// sharedKey is the sharedStore key of this cache's catalog; tenants' catalogs are kept apart
+func (c *productCache) sharedKey() string {
+	key := "products:catalog"
+	if c.tenant != "" {
+		key = "products:tenant:" + c.tenant + ":catalog"
+	}
+	if c.lang == "" {
+		return key
+	}
+	return key + ":" + c.lang
+}
+
+// loadShared adopts the catalog another replica stored in sharedStore if it is younger than ttl,
+// returning nil when there is none or sharedStore fails, so the caller fetches from the Dotnet service
+func (c *productCache) loadShared(ctx context.Context, ttl time.Duration, generation int) *catalogEntry {
+	if sharedStore == nil {
+		return nil
+	}
+	data, ok, err := sharedStore.Get(ctx, c.sharedKey())
+	if err != nil {
+		log.Printf("Error reading shared products cache, fetching from the backend: %v", err)
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+	var shared sharedCatalog
+	if err := json.Unmarshal(data, &shared); err != nil {
+		log.Printf("Error decoding shared products cache, fetching from the backend: %v", err)
+		return nil
+	}
+	if time.Since(shared.FetchedAt) >= ttl {
+		return nil
+	}
+	entry, err := newCatalogEntry(shared.Products)
+	if err != nil {
+		return nil
+	}
+	entry.FetchedAt, entry.LastModified, entry.ContentLanguage = shared.FetchedAt, shared.LastModified, shared.ContentLanguage
+	entry.DeltaSince = shared.DeltaSince
+	if entry.DeltaSince.IsZero() {
+		entry.DeltaSince = deltaWatermark(time.Time{}, shared.FetchedAt)
+	}
+
+	// The replica that fetched the catalog announced any price changes, so they aren't repeated here
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation != generation {
+		return entry
+	}
+	c.entry = entry
+	c.previous = shared.Products
+	return entry
+}
+
+// saveShared stores entry in sharedStore for PRODUCTS_CACHE_TTL; failures only cost other replicas a fetch
+func (c *productCache) saveShared(ctx context.Context, entry *catalogEntry) {
+	ttl := envDuration("PRODUCTS_CACHE_TTL", defaultProductsCacheTTL)
+	if sharedStore == nil || ttl <= 0 {
+		return
+	}
+	data, err := json.Marshal(sharedCatalog{
+		Products:        entry.Products,
+		FetchedAt:       entry.FetchedAt,
+		LastModified:    entry.LastModified,
+		ContentLanguage: entry.ContentLanguage,
+		DeltaSince:      entry.DeltaSince,
+	})
+	if err == nil {
+		err = sharedStore.Set(ctx, c.sharedKey(), data, ttl)
+	}
+	if err != nil {
+		log.Printf("Error writing shared products cache: %v", err)
+	}
+}
+
+// deleteShared removes the catalog from sharedStore, so invalidating it reaches every replica's next refresh
+func (c *productCache) deleteShared() {
+	if sharedStore == nil {
+		return
+	}
+	if err := sharedStore.Delete(context.Background(), c.sharedKey()); err != nil {
+		log.Printf("Error invalidating shared products cache: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Defaults for graceful shutdown when SHUTDOWN_DRAIN_DELAY / SHUTDOWN_TIMEOUT are not set
+const (
+	defaultShutdownDrainDelay = 10 * time.Second
+	defaultShutdownTimeout    = 20 * time.Second
+)
+
+// draining is set once a shutdown signal arrives. /healthz then fails readiness so load balancers
+// stop sending traffic while the server keeps serving what still arrives.
+var draining atomic.Bool
+
+// shutdownKey is the request context key for the channel closed when the request's server starts
+// shutting down
+type shutdownKey struct{}
+
+// shuttingDown returns a channel closed once the server handling ctx's request starts shutting down,
+// so long-lived responses such as the products stream can end rather than hold up Shutdown. Request
+// contexts themselves aren't canceled, which would also abort in-flight orders. The channel is nil,
+// so never ready, for requests not served by serve.
+func shuttingDown(ctx context.Context) <-chan struct{} {
+	done, _ := ctx.Value(shutdownKey{}).(<-chan struct{})
+	return done
+}
+
+// boundServer is a server with the listener it serves on
+type boundServer struct {
+	Server   *http.Server
+	Listener net.Listener
+}
+
+// serve runs each server on its listener until a signal arrives on signals, then drains: /healthz
+// reports 503 for SHUTDOWN_DRAIN_DELAY so load balancers take us out of rotation, after which every
+// server stops accepting connections and they share SHUTDOWN_TIMEOUT to finish in-flight requests.
+// Streams are told to end when shutdown starts, and connections still open at the timeout are closed.
+// If any server fails first its error is returned straight away.
+func serve(signals <-chan os.Signal, servers ...boundServer) error {
+	errs := make(chan error, len(servers))
+	for _, s := range servers {
+		stopping := make(chan struct{})
+		base := context.WithValue(context.Background(), shutdownKey{}, (<-chan struct{})(stopping))
+		s.Server.BaseContext = func(net.Listener) context.Context { return base }
+		s.Server.RegisterOnShutdown(func() { close(stopping) })
+		go func() { errs <- s.Server.Serve(s.Listener) }()
+	}
+
+	select {
+	case err := <-errs:
+		return err
+	case <-signals:
+	}
+
+	draining.Store(true)
+	delay := envDuration("SHUTDOWN_DRAIN_DELAY", defaultShutdownDrainDelay)
+	log.Printf("Shutdown signal received, failing readiness for %s before stopping", delay)
+	time.Sleep(delay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), envDuration("SHUTDOWN_TIMEOUT", defaultShutdownTimeout))
+	defer cancel()
+	var g errgroup.Group
+	for _, s := range servers {
+		g.Go(func() error {
+			err := s.Server.Shutdown(ctx)
+			if errors.Is(err, context.DeadlineExceeded) {
+				log.Printf("Requests still in progress after SHUTDOWN_TIMEOUT, closing their connections")
+				return s.Server.Close()
+			}
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	for range servers {
+		if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+	}
+	log.Println("Server stopped")
+	return nil
+}
+
+// livezHandler reports liveness: the process is up and serving, even while draining
+func livezHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		methodNotAllowed(w, r, http.MethodGet, http.MethodHead)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, HealthResponse{Status: "ok"})
+}
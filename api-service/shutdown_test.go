@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestHealthz_Draining tests that readiness fails while draining and liveness stays green
+func TestHealthz_Draining(t *testing.T) {
+	c, _, calls, _ := newCountingHealthCache()
+	orig := upstreamHealth
+	upstreamHealth = c
+	defer func() { upstreamHealth = orig }()
+	draining.Store(true)
+	defer draining.Store(false)
+
+	rr := httptest.NewRecorder()
+	healthzHandler(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusServiceUnavailable)
+	}
+	if *calls != 0 {
+		t.Errorf("upstream checked %d times while draining, want 0", *calls)
+	}
+
+	rr = httptest.NewRecorder()
+	livezHandler(rr, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("livez returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+}
+
+// TestServe_DrainsOnSignal tests that a signal flips readiness, keeps serving for the drain delay, then stops
+func TestServe_DrainsOnSignal(t *testing.T) {
+	os.Setenv("SHUTDOWN_DRAIN_DELAY", "200ms")
+	defer os.Unsetenv("SHUTDOWN_DRAIN_DELAY")
+	defer draining.Store(false)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "http://" + ln.Addr().String()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", healthzHandler)
+	signals := make(chan os.Signal, 1)
+	done := make(chan error, 1)
+	go func() { done <- serve(signals, boundServer{Server: &http.Server{Handler: mux}, Listener: ln}) }()
+
+	signals <- syscall.SIGTERM
+	deadline := time.Now().Add(time.Second)
+	for !draining.Load() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	resp, err := http.Get(url + "/healthz")
+	if err != nil {
+		t.Fatalf("request during drain failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("healthz during drain returned %v, want %v", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("serve returned %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not return after the drain delay")
+	}
+}
+
+// TestServe_StopsEveryServer tests that one signal shuts down the public and admin servers together
+func TestServe_StopsEveryServer(t *testing.T) {
+	os.Setenv("SHUTDOWN_DRAIN_DELAY", "1ms")
+	defer os.Unsetenv("SHUTDOWN_DRAIN_DELAY")
+	defer draining.Store(false)
+
+	var servers []boundServer
+	for i := 0; i < 2; i++ {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		servers = append(servers, boundServer{Server: &http.Server{Handler: http.NotFoundHandler()}, Listener: ln})
+	}
+	signals := make(chan os.Signal, 1)
+	done := make(chan error, 1)
+	go func() { done <- serve(signals, servers...) }()
+
+	signals <- syscall.SIGTERM
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("serve returned %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not return after the signal")
+	}
+	for i, s := range servers {
+		if conn, err := net.Dial("tcp", s.Listener.Addr().String()); err == nil {
+			conn.Close()
+			t.Errorf("server %d still accepting connections after shutdown", i)
+		}
+	}
+}
+
+// TestServe_EndsStreams tests that an open products stream ends on shutdown rather than holding it
+// up, and that a request still running at SHUTDOWN_TIMEOUT has its connection closed
+func TestServe_EndsStreams(t *testing.T) {
+	os.Setenv("SHUTDOWN_DRAIN_DELAY", "1ms")
+	defer os.Unsetenv("SHUTDOWN_DRAIN_DELAY")
+	os.Setenv("SHUTDOWN_TIMEOUT", "500ms")
+	defer os.Unsetenv("SHUTDOWN_TIMEOUT")
+	os.Setenv("PRODUCTS_STREAM_INTERVAL", "1h")
+	defer os.Unsetenv("PRODUCTS_STREAM_INTERVAL")
+	defer draining.Store(false)
+	newFakeDotnet(t, []Product{{Id: "p1", Name: "Lamp", Price: 19.99, Stock: 10}})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "http://" + ln.Addr().String()
+	stuck := make(chan struct{})
+	defer close(stuck)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/products/stream", productsStreamHandler)
+	mux.HandleFunc("/stuck", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		http.NewResponseController(w).Flush()
+		<-stuck
+	})
+	signals := make(chan os.Signal, 1)
+	done := make(chan error, 1)
+	go func() { done <- serve(signals, boundServer{Server: &http.Server{Handler: mux}, Listener: ln}) }()
+
+	// Wait for the stream's first update so it is open and idle
+	stream, err := http.Get(url + "/products/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Body.Close()
+	if _, err := bufio.NewReader(stream.Body).ReadString('\n'); err != nil {
+		t.Fatalf("reading the stream: %v", err)
+	}
+
+	start := time.Now()
+	signals <- syscall.SIGTERM
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("serve returned %v, want nil", err)
+		}
+		if elapsed := time.Since(start); elapsed >= 400*time.Millisecond {
+			t.Errorf("serve took %v, an open stream held up shutdown", elapsed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("an open stream held up shutdown")
+	}
+	if _, err := io.ReadAll(stream.Body); err != nil {
+		t.Errorf("stream did not end cleanly: %v", err)
+	}
+
+	// A handler that ignores shutdown is cut off at SHUTDOWN_TIMEOUT
+	ln, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { done <- serve(signals, boundServer{Server: &http.Server{Handler: mux}, Listener: ln}) }()
+	resp, err := http.Get("http://" + ln.Addr().String() + "/stuck")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	start = time.Now()
+	signals <- syscall.SIGTERM
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("serve with a stuck request returned %v, want nil", err)
+		}
+		if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+			t.Errorf("serve returned after %v, before SHUTDOWN_TIMEOUT", elapsed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not close a stuck request's connection")
+	}
+}
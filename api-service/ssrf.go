@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// errPrivateUpstream is returned when an upstream address resolves to a private range and
+// ALLOW_PRIVATE_UPSTREAM is not set
+var errPrivateUpstream = errors.New("upstream address is in a private range")
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), not covered by netip.Addr.IsPrivate
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// isPrivateIP reports whether ip is loopback, private, link-local, unspecified or otherwise not
+// publicly routable, treating IPv4-mapped IPv6 addresses as their IPv4 form
+func isPrivateIP(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip) ||
+		(ip.Is4() && ip.As4()[0] == 0) // 0.0.0.0/8 reaches the local host on some systems
+}
+
+// allowPrivateUpstream reports whether ALLOW_PRIVATE_UPSTREAM permits private addresses, e.g. for local dev
+func allowPrivateUpstream() bool {
+	allow, _ := strconv.ParseBool(os.Getenv("ALLOW_PRIVATE_UPSTREAM"))
+	return allow
+}
+
+// guardDial refuses connections to private addresses. It runs after DNS resolution, on the address
+// actually being dialed, so a hostname can't be rebound to an internal IP between check and use.
+func guardDial(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("unexpected dial address %q: %w", address, err)
+	}
+	if isPrivateIP(ip) && !allowPrivateUpstream() {
+		return fmt.Errorf("%w: %s", errPrivateUpstream, ip)
+	}
+	return nil
+}
+
+// upstreamTransport is shared by every upstream client so connections are reused across requests
+var upstreamTransport = newGuardedTransport()
+
+// newGuardedTransport returns a copy of the default transport whose dialer applies guardDial
+func newGuardedTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   guardDial,
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, address)
+	}
+	return transport
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"testing"
+)
+
+// TestIsPrivateIP tests the IP range classification used by the upstream dial guard
+func TestIsPrivateIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"127.0.0.1", true},
+		{"127.255.0.3", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"172.31.255.255", true},
+		{"172.32.0.1", false},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true}, // Cloud metadata endpoint
+		{"100.64.0.1", true},
+		{"0.0.0.0", true},
+		{"0.1.2.3", true},
+		{"8.8.8.8", false},
+		{"93.184.216.34", false},
+		{"::1", true},
+		{"::", true},
+		{"fe80::1", true},
+		{"fd00::1", true},
+		{"ff02::1", true},
+		{"::ffff:127.0.0.1", true}, // IPv4-mapped loopback
+		{"::ffff:10.0.0.1", true},
+		{"2606:4700:4700::1111", false},
+	}
+	for _, tt := range tests {
+		if got := isPrivateIP(netip.MustParseAddr(tt.ip)); got != tt.want {
+			t.Errorf("isPrivateIP(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+// TestUpstreamClient_BlocksPrivateAddresses tests that loopback upstreams are refused unless allowed
+func TestUpstreamClient_BlocksPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	os.Setenv("ALLOW_PRIVATE_UPSTREAM", "false")
+	defer os.Setenv("ALLOW_PRIVATE_UPSTREAM", "true")
+
+	_, err := newUpstreamClient().Get(server.URL)
+	if !errors.Is(err, errPrivateUpstream) {
+		t.Errorf("request to loopback returned %v, want errPrivateUpstream", err)
+	}
+
+	os.Setenv("ALLOW_PRIVATE_UPSTREAM", "true")
+	resp, err := newUpstreamClient().Get(server.URL)
+	if err != nil {
+		t.Fatalf("request with ALLOW_PRIVATE_UPSTREAM=true returned %v", err)
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// processStart is when the service started, for the uptime in /status
+var processStart = time.Now()
+
+// StatusResponse is the body returned by GET /status, an on-call summary of every subsystem
+type StatusResponse struct {
+	Status        string              `json:"status"` // Same as /healthz: ok, degraded, unavailable or draining
+	StartedAt     string              `json:"startedAt"`
+	UptimeSeconds int64               `json:"uptimeSeconds"`
+	Maintenance   bool                `json:"maintenance"`
+	Upstream      UpstreamStatus      `json:"upstream"`
+	ProductsCache ProductsCacheStatus `json:"productsCache"`
+	Orders        OrdersStatus        `json:"orders"`
+	Warnings      []string            `json:"warnings,omitempty"`
+}
+
+// UpstreamStatus is the Dotnet service reachability from the shared /healthz check
+type UpstreamStatus struct {
+	Reachable bool   `json:"reachable"`
+	CheckedAt string `json:"checkedAt,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ProductsCacheStatus describes the cached catalog; the other fields are omitted while nothing is cached
+type ProductsCacheStatus struct {
+	Cached     bool   `json:"cached"`
+	Products   int    `json:"products,omitempty"`
+	Bytes      int    `json:"bytes,omitempty"` // Size of the serialized catalog
+	FetchedAt  string `json:"fetchedAt,omitempty"`
+	AgeSeconds int64  `json:"ageSeconds,omitempty"`
+	Fresh      bool   `json:"fresh"` // Younger than PRODUCTS_CACHE_TTL, so served without refetching
+}
+
+// OrdersStatus is the current order concurrency against MAX_CONCURRENT_ORDERS (0 means unlimited) and the orders queued for a slot
+type OrdersStatus struct {
+	InFlight      int `json:"inFlight"`
+	MaxConcurrent int `json:"maxConcurrent"`
+	Queued        int `json:"queued"`
+}
+
+// summaryHandler serves GET /status, summarizing upstream reachability, the products cache, order
+// concurrency and uptime. It reveals internals, so it is registered behind requireAdmin. It always
+// answers 200: unlike /healthz it describes problems rather than failing a probe over them.
+func summaryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+	now := time.Now()
+	resp := StatusResponse{
+		StartedAt:     processStart.UTC().Format(time.RFC3339),
+		UptimeSeconds: int64(now.Sub(processStart).Seconds()),
+		Maintenance:   maintenanceMode.Load(),
+		Warnings:      configWarnings(),
+		Orders: OrdersStatus{
+			InFlight:      ordersInFlightTotal(),
+			MaxConcurrent: max(envInt("MAX_CONCURRENT_ORDERS", defaultMaxConcurrentOrders), 0),
+			Queued:        ordersQueuedTotal(),
+		},
+	}
+
+	// Reuse the cached /healthz result so polling this doesn't add upstream load
+	err := upstreamHealth.get(context.WithoutCancel(r.Context()), envDuration("HEALTH_CACHE_TTL", defaultHealthCacheTTL))
+	resp.Upstream.Reachable = err == nil
+	if err != nil {
+		resp.Upstream.Error = err.Error()
+	}
+	if checked := upstreamHealth.lastChecked(); !checked.IsZero() {
+		resp.Upstream.CheckedAt = checked.UTC().Format(time.RFC3339)
+	}
+
+	if entry := productsCache.cached(); entry != nil {
+		age := now.Sub(entry.FetchedAt)
+		resp.ProductsCache = ProductsCacheStatus{
+			Cached:     true,
+			Products:   len(entry.Products),
+			Bytes:      len(entry.Body),
+			FetchedAt:  entry.FetchedAt.UTC().Format(time.RFC3339),
+			AgeSeconds: int64(age.Seconds()),
+			Fresh:      age < envDuration("PRODUCTS_CACHE_TTL", defaultProductsCacheTTL),
+		}
+	}
+
+	switch {
+	case draining.Load():
+		resp.Status = "draining"
+	case err != nil:
+		resp.Status = "unavailable"
+	case len(resp.Warnings) > 0:
+		resp.Status = "degraded"
+	default:
+		resp.Status = "ok"
+	}
+	writeJSON(w, r, http.StatusOK, resp)
+}
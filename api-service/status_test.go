@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// getStatus fetches /status through requireAdmin and decodes it into a generic map to check its shape
+func getStatus(t *testing.T) map[string]interface{} {
+	t.Helper()
+	rr := adminRequest(summaryHandler, httptest.NewRequest(http.MethodGet, "/status", nil), "secret")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	return body
+}
+
+// useHealthCache swaps in c as the upstream health cache for the duration of the test
+func useHealthCache(t *testing.T, c *healthCache) {
+	t.Helper()
+	orig := upstreamHealth
+	upstreamHealth = c
+	t.Cleanup(func() { upstreamHealth = orig })
+}
+
+// TestSummaryHandler_Shape tests the summary once a catalog is cached and the upstream is reachable
+func TestSummaryHandler_Shape(t *testing.T) {
+	withAdminToken(t, "secret")
+	c, _, _, _ := newCountingHealthCache()
+	useHealthCache(t, c)
+	newFakeDotnet(t, []Product{{Id: "p1", Stock: 1}, {Id: "p2", Stock: 2}})
+	getProducts(t, "")
+
+	body := getStatus(t)
+	if want := []string{"maintenance", "orders", "productsCache", "startedAt", "status", "upstream", "uptimeSeconds"}; !reflect.DeepEqual(slices.Sorted(maps.Keys(body)), want) {
+		t.Errorf("status keys = %v, want %v", slices.Sorted(maps.Keys(body)), want)
+	}
+	if body["status"] != "ok" {
+		t.Errorf("status = %v, want ok", body["status"])
+	}
+	upstream := body["upstream"].(map[string]interface{})
+	if upstream["reachable"] != true || upstream["checkedAt"] == nil {
+		t.Errorf("upstream = %v, want reachable with a checkedAt", upstream)
+	}
+	cache := body["productsCache"].(map[string]interface{})
+	if cache["cached"] != true || cache["fresh"] != true || cache["products"] != 2.0 || cache["bytes"].(float64) <= 0 || cache["fetchedAt"] == nil {
+		t.Errorf("productsCache = %v, want a fresh cache of 2 products", cache)
+	}
+	orders := body["orders"].(map[string]interface{})
+	if orders["inFlight"] != 0.0 || orders["maxConcurrent"] != float64(defaultMaxConcurrentOrders) {
+		t.Errorf("orders = %v, want 0 in flight of %d", orders, defaultMaxConcurrentOrders)
+	}
+}
+
+// TestSummaryHandler_Degrades tests that an unreachable upstream and an empty cache are reported, not failed on
+func TestSummaryHandler_Degrades(t *testing.T) {
+	withAdminToken(t, "secret")
+	c, _, _, result := newCountingHealthCache()
+	*result = errors.New("connection refused")
+	useHealthCache(t, c)
+	productsCache.invalidate()
+
+	body := getStatus(t)
+	if body["status"] != "unavailable" {
+		t.Errorf("status = %v, want unavailable", body["status"])
+	}
+	upstream := body["upstream"].(map[string]interface{})
+	if upstream["reachable"] != false || upstream["error"] != "connection refused" {
+		t.Errorf("upstream = %v, want unreachable with the error", upstream)
+	}
+	if cache := body["productsCache"].(map[string]interface{}); !reflect.DeepEqual(cache, map[string]interface{}{"cached": false, "fresh": false}) {
+		t.Errorf("productsCache = %v, want only cached and fresh set to false", cache)
+	}
+}
+
+// TestSummaryHandler_AdminOnly tests that the summary needs the admin token
+func TestSummaryHandler_AdminOnly(t *testing.T) {
+	withAdminToken(t, "secret")
+	get := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/status", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		routes().ServeHTTP(rr, req)
+		return rr.Code
+	}
+	if status := get(""); status != http.StatusUnauthorized {
+		t.Errorf("handler returned wrong status code without a token: got %v want %v", status, http.StatusUnauthorized)
+	}
+	if status := get("wrong"); status != http.StatusForbidden {
+		t.Errorf("handler returned wrong status code with a wrong token: got %v want %v", status, http.StatusForbidden)
+	}
+}
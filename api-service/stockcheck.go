@@ -0,0 +1,25 @@
+package main
+
+// outOfStockItems returns the ids of items whose requested quantity, summed over duplicate line items,
+// exceeds the stock in products less the quantities held (product id to quantity, may be nil). Items
+// missing from products are left for the Dotnet service to judge.
+func outOfStockItems(items []OrderItemRequest, products []Product, held map[string]int) []string {
+	stock := make(map[string]int, len(products))
+	for _, p := range products {
+		stock[p.Id] = p.Stock
+	}
+	wanted := make(map[string]int)
+	for _, item := range items {
+		wanted[item.Id] += item.Quantity
+	}
+	var outOfStock []string
+	for _, item := range items {
+		available, known := stock[item.Id]
+		if !known || wanted[item.Id] <= available-held[item.Id] {
+			continue
+		}
+		outOfStock = append(outOfStock, item.Id)
+		delete(wanted, item.Id) // Report each id once
+	}
+	return outOfStock
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// TestOutOfStockItems tests summing duplicate lines and leaving unknown products to Dotnet
+func TestOutOfStockItems(t *testing.T) {
+	products := []Product{{Id: "p1", Stock: 3}, {Id: "p2", Stock: 0}}
+	items := []OrderItemRequest{
+		{Id: "p1", Quantity: 2},
+		{Id: "p1", Quantity: 2},
+		{Id: "p2", Quantity: 1},
+		{Id: "unknown", Quantity: 99},
+	}
+	if got, want := outOfStockItems(items, products, nil), []string{"p1", "p2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("outOfStockItems = %v, want %v", got, want)
+	}
+	if got := outOfStockItems(items[:1], products, nil); got != nil {
+		t.Errorf("outOfStockItems within stock = %v, want none", got)
+	}
+	if got, want := outOfStockItems(items[:1], products, map[string]int{"p1": 2}), []string{"p1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("outOfStockItems with held stock = %v, want %v", got, want)
+	}
+}
+
+// TestOrderHandler_StockPrecheckHeldStock tests that an unreserved order can't take stock held by a reservation
+func TestOrderHandler_StockPrecheckHeldStock(t *testing.T) {
+	useFakeReservationClock(t)
+	dotnet := newFakeDotnet(t, []Product{{Id: "p1", Name: "Widget", Price: 5, Stock: 5}})
+
+	items := []OrderItemRequest{{Id: "p1", Quantity: 5, Price: 5}}
+	if status, resp := reserve(t, items); status != http.StatusOK || !resp.Success {
+		t.Fatalf("reservation failed: status %v, response %+v", status, resp)
+	}
+
+	rr := httptest.NewRecorder()
+	orderHandler(rr, postJSON(t, "/order", PlaceOrderRequest{Items: items, DeliveryAddress: "1 Main St", TotalAmount: pricePtr(25)}))
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusConflict)
+	}
+	var resp PlaceOrderResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.Success || !reflect.DeepEqual(resp.OutOfStockItems, []string{"p1"}) {
+		t.Errorf("response = %+v, want failure listing p1", resp)
+	}
+	if len(dotnet.Orders) != 0 {
+		t.Errorf("Dotnet received %d orders, want 0", len(dotnet.Orders))
+	}
+}
+
+// TestOrderHandler_StockPrecheck tests that orders exceeding cached stock are rejected without reaching Dotnet,
+// and proxied when the pre-check is disabled
+func TestOrderHandler_StockPrecheck(t *testing.T) {
+	dotnet := newFakeDotnet(t, []Product{{Id: "p1", Name: "Widget", Price: 5, Stock: 1}})
+	productsHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/products", nil)) // Warm the cache
+
+	order := PlaceOrderRequest{Items: []OrderItemRequest{{Id: "p1", Quantity: 2, Price: 5}}, DeliveryAddress: "1 Main St", TotalAmount: pricePtr(10)}
+	rr := httptest.NewRecorder()
+	orderHandler(rr, postJSON(t, "/order", order))
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusConflict)
+	}
+	var resp PlaceOrderResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.Success || !reflect.DeepEqual(resp.OutOfStockItems, []string{"p1"}) {
+		t.Errorf("response = %+v, want failure listing p1", resp)
+	}
+	if len(dotnet.Orders) != 0 {
+		t.Errorf("Dotnet received %d orders, want 0", len(dotnet.Orders))
+	}
+
+	os.Setenv("STOCK_PRECHECK", "false")
+	defer os.Unsetenv("STOCK_PRECHECK")
+	rr = httptest.NewRecorder()
+	orderHandler(rr, postJSON(t, "/order", order))
+	if rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if len(dotnet.Orders) != 1 {
+		t.Errorf("Dotnet received %d orders with the pre-check off, want 1", len(dotnet.Orders))
+	}
+}
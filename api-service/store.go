@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Store holds short-lived shared state, such as claimed order nonces, as byte values under string
+// keys that expire after a TTL. The in-memory implementation keeps state per process; an external
+// implementation (e.g. Redis) lets it survive restarts and be shared between replicas, which is why
+// every method takes a context and can fail.
+type Store interface {
+	// Get returns the value stored under key, and false if there is none or it has expired
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value under key for ttl, replacing any existing value
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// SetIfAbsent stores value under key for ttl only if no live value is stored there, and reports
+	// whether it did. It is atomic, so of concurrent callers with the same key exactly one succeeds.
+	SetIfAbsent(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	// Delete removes key; deleting a missing key is not an error
+	Delete(ctx context.Context, key string) error
+	// List returns the live keys, for inspecting the store rather than for use on a request path
+	List(ctx context.Context) ([]StoreEntry, error)
+}
+
+// StoreEntry describes one key returned by Store.List
+type StoreEntry struct {
+	Key       string
+	WrittenAt time.Time // Zero when the store doesn't track it
+	ExpiresAt time.Time
+}
+
+// memoryEntry is one value in a memoryStore
+type memoryEntry struct {
+	value     []byte
+	writtenAt time.Time
+	expiresAt time.Time
+	seq       uint64 // Matches the entry's place in memoryStore.order
+}
+
+// memoryKey is a key in memoryStore.order; it is stale once its entry was replaced or removed
+type memoryKey struct {
+	key string
+	seq uint64
+}
+
+// memoryStore is the in-process Store. When maxEntries is set it bounds the number of entries,
+// forgetting the oldest written ones early to make room.
+type memoryStore struct {
+	mu         sync.Mutex
+	entries    map[string]memoryEntry
+	order      []memoryKey // Writes, oldest first, for pruning and eviction
+	seq        uint64
+	now        func() time.Time
+	maxEntries func() int // Read on every write so the limit can come from the environment; nil is unbounded
+}
+
+// newMemoryStore returns an empty in-memory Store holding at most maxEntries() entries, or any
+// number when maxEntries is nil or returns 0 or less
+func newMemoryStore(maxEntries func() int) *memoryStore {
+	return &memoryStore{
+		entries:    make(map[string]memoryEntry),
+		now:        time.Now,
+		maxEntries: maxEntries,
+	}
+}
+
+func (s *memoryStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok || !s.now().Before(e.expiresAt) {
+		return nil, false, nil
+	}
+	return append([]byte(nil), e.value...), true, nil
+}
+
+func (s *memoryStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setLocked(key, value, ttl)
+	return nil
+}
+
+func (s *memoryStore) SetIfAbsent(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok && s.now().Before(e.expiresAt) {
+		return false, nil
+	}
+	s.setLocked(key, value, ttl)
+	return true, nil
+}
+
+func (s *memoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	delete(s.entries, key) // Its entry in order is skipped as stale
+	s.mu.Unlock()
+	return nil
+}
+
+// List returns the live entries, oldest written first
+func (s *memoryStore) List(ctx context.Context) ([]StoreEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	var list []StoreEntry
+	for _, k := range s.order {
+		if e, ok := s.entries[k.key]; ok && e.seq == k.seq && now.Before(e.expiresAt) {
+			list = append(list, StoreEntry{Key: k.key, WrittenAt: e.writtenAt, ExpiresAt: e.expiresAt})
+		}
+	}
+	return list, nil
+}
+
+// setLocked stores value under key after pruning and, if the store is full, evicting; s.mu must be held
+func (s *memoryStore) setLocked(key string, value []byte, ttl time.Duration) {
+	now := s.now()
+	s.pruneLocked(now)
+	delete(s.entries, key)
+	if s.maxEntries != nil {
+		for limit := s.maxEntries(); limit > 0 && len(s.entries) >= limit; {
+			s.evictOldestLocked()
+		}
+	}
+	s.seq++
+	s.entries[key] = memoryEntry{value: append([]byte(nil), value...), writtenAt: now, expiresAt: now.Add(ttl), seq: s.seq}
+	s.order = append(s.order, memoryKey{key: key, seq: s.seq})
+}
+
+// pruneLocked drops stale and expired keys from the front of order, and compacts order once it is
+// mostly stale keys, e.g. after many overwrites behind a long-lived entry; s.mu must be held
+func (s *memoryStore) pruneLocked(now time.Time) {
+	for len(s.order) > 0 {
+		e, ok := s.entries[s.order[0].key]
+		if ok && e.seq == s.order[0].seq && now.Before(e.expiresAt) {
+			break
+		}
+		s.evictOldestLocked()
+	}
+	if len(s.order) > 2*len(s.entries)+16 {
+		live := make([]memoryKey, 0, len(s.entries))
+		for _, k := range s.order {
+			if e, ok := s.entries[k.key]; ok && e.seq == k.seq {
+				live = append(live, k)
+			}
+		}
+		s.order = live
+	}
+}
+
+// evictOldestLocked drops the oldest key in order, and its entry unless that was written since; s.mu must be held
+func (s *memoryStore) evictOldestLocked() {
+	oldest := s.order[0]
+	s.order = s.order[1:]
+	if e, ok := s.entries[oldest.key]; ok && e.seq == oldest.seq {
+		delete(s.entries, oldest.key)
+	}
+}
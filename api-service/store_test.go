@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+// newTestMemoryStore returns a memoryStore bounded to limit entries whose clock the test controls
+func newTestMemoryStore(limit int) (*memoryStore, *time.Time) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := newMemoryStore(func() int { return limit })
+	s.now = func() time.Time { return now }
+	return s, &now
+}
+
+// TestMemoryStore tests Get, Set, Delete and expiry
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	s, now := newTestMemoryStore(0)
+
+	if _, ok, err := s.Get(ctx, "k"); ok || err != nil {
+		t.Errorf("Get on an empty store = %v, %v, want not found", ok, err)
+	}
+	value := []byte("v1")
+	s.Set(ctx, "k", value, time.Minute)
+	value[0] = 'x' // The store keeps its own copy
+	if got, ok, _ := s.Get(ctx, "k"); !ok || string(got) != "v1" {
+		t.Errorf("Get after Set = %q, %v, want v1", got, ok)
+	}
+	s.Set(ctx, "k", []byte("v2"), time.Minute)
+	if got, _, _ := s.Get(ctx, "k"); string(got) != "v2" {
+		t.Errorf("Get after overwrite = %q, want v2", got)
+	}
+
+	*now = now.Add(time.Minute)
+	if _, ok, _ := s.Get(ctx, "k"); ok {
+		t.Errorf("Get returned a value past its TTL")
+	}
+
+	s.Set(ctx, "k", []byte("v3"), time.Minute)
+	if err := s.Delete(ctx, "k"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, ok, _ := s.Get(ctx, "k"); ok {
+		t.Errorf("Get returned a deleted value")
+	}
+	if err := s.Delete(ctx, "missing"); err != nil {
+		t.Errorf("Delete of a missing key = %v, want nil", err)
+	}
+}
+
+// TestMemoryStore_List tests that List returns live entries, oldest written first, with their write and expiry times
+func TestMemoryStore_List(t *testing.T) {
+	ctx := context.Background()
+	s, now := newTestMemoryStore(0)
+	start := *now
+	s.Set(ctx, "a", nil, time.Minute)
+	s.Set(ctx, "b", nil, time.Hour)
+	*now = now.Add(time.Second)
+	s.Set(ctx, "a", nil, time.Minute)
+	s.Set(ctx, "c", nil, time.Minute)
+	s.Delete(ctx, "c")
+
+	list, err := s.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	want := []StoreEntry{
+		{Key: "b", WrittenAt: start, ExpiresAt: start.Add(time.Hour)},
+		{Key: "a", WrittenAt: start.Add(time.Second), ExpiresAt: start.Add(time.Second + time.Minute)},
+	}
+	if !slices.Equal(list, want) {
+		t.Errorf("List() = %v, want %v", list, want)
+	}
+	*now = now.Add(time.Minute)
+	if list, _ := s.List(ctx); len(list) != 1 || list[0].Key != "b" {
+		t.Errorf("List() after a's expiry = %v, want only b", list)
+	}
+}
+
+// TestMemoryStore_SetIfAbsent tests that SetIfAbsent only writes missing or expired keys
+func TestMemoryStore_SetIfAbsent(t *testing.T) {
+	ctx := context.Background()
+	s, now := newTestMemoryStore(0)
+
+	if ok, _ := s.SetIfAbsent(ctx, "k", []byte("first"), time.Minute); !ok {
+		t.Errorf("SetIfAbsent on a missing key = false, want true")
+	}
+	if ok, _ := s.SetIfAbsent(ctx, "k", []byte("second"), time.Minute); ok {
+		t.Errorf("SetIfAbsent on a live key = true, want false")
+	}
+	if got, _, _ := s.Get(ctx, "k"); string(got) != "first" {
+		t.Errorf("value = %q, want the first write kept", got)
+	}
+	*now = now.Add(time.Minute)
+	if ok, _ := s.SetIfAbsent(ctx, "k", []byte("third"), time.Minute); !ok {
+		t.Errorf("SetIfAbsent on an expired key = false, want true")
+	}
+}
+
+// TestMemoryStore_Bounded tests that the oldest written entries are evicted once the store is full
+func TestMemoryStore_Bounded(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestMemoryStore(2)
+
+	for _, k := range []string{"a", "b", "c"} {
+		s.Set(ctx, k, []byte(k), time.Hour)
+	}
+	if len(s.entries) != 2 {
+		t.Errorf("store holds %d entries, want 2", len(s.entries))
+	}
+	if _, ok, _ := s.Get(ctx, "a"); ok {
+		t.Errorf("oldest entry was kept in a full store")
+	}
+
+	// Rewriting b makes c the oldest write, so c is evicted next rather than the new b
+	s.Set(ctx, "b", []byte("b2"), time.Hour)
+	s.Set(ctx, "d", []byte("d"), time.Hour)
+	if _, ok, _ := s.Get(ctx, "c"); ok {
+		t.Errorf("entry c survived eviction, want it evicted as the oldest write")
+	}
+	if got, ok, _ := s.Get(ctx, "b"); !ok || string(got) != "b2" {
+		t.Errorf("rewritten entry = %q, %v, want b2 kept", got, ok)
+	}
+}
+
+// TestMemoryStore_Compacts tests that repeated overwrites behind a long-lived entry don't grow the store without bound
+func TestMemoryStore_Compacts(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestMemoryStore(0)
+
+	s.Set(ctx, "long-lived", nil, time.Hour)
+	for i := 0; i < 1000; i++ {
+		s.Set(ctx, "hot", nil, time.Hour)
+	}
+	if len(s.order) > 2*len(s.entries)+16 {
+		t.Errorf("order holds %d keys for %d entries, want it compacted", len(s.order), len(s.entries))
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// defaultProductsStreamInterval is how often /products/stream polls the catalog when PRODUCTS_STREAM_INTERVAL is not set
+const defaultProductsStreamInterval = 5 * time.Second
+
+// defaultProductsStreamMaxBackoff caps the wait between failed polls when PRODUCTS_STREAM_MAX_BACKOFF is not set
+const defaultProductsStreamMaxBackoff = time.Minute
+
+// streamBackoff is the wait before the next poll after failures consecutive failed polls: twice the
+// interval after the first failure, doubling with each further one, up to maxBackoff
+func streamBackoff(interval, maxBackoff time.Duration, failures int) time.Duration {
+	wait := interval
+	for i := 0; i < failures && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	return max(min(wait, maxBackoff), interval)
+}
+
+// StockUpdate is one product's stock level in a stockUpdate event
+type StockUpdate struct {
+	Id    string `json:"id"`
+	Stock int    `json:"stock"`
+}
+
+// stockChanges returns the products whose stock differs from last, updating last in place.
+// Products not in last yet, as on the first poll, count as changed.
+func stockChanges(last map[string]int, products []Product) []StockUpdate {
+	var changed []StockUpdate
+	for _, p := range products {
+		if stock, ok := last[p.Id]; ok && stock == p.Stock {
+			continue
+		}
+		last[p.Id] = p.Stock
+		changed = append(changed, StockUpdate{Id: p.Id, Stock: p.Stock})
+	}
+	return changed
+}
+
+// productsStreamHandler pushes stock changes as Server-Sent Events. The first event carries every
+// product's stock; later ones only the products whose stock changed since the previous poll.
+func productsStreamHandler(w http.ResponseWriter, r *http.Request) {
+	// Set CORS headers for any origin
+	applyCORS(w, r, "GET, OPTIONS", "Content-Type, Authorization, X-API-Key, X-Tenant-ID")
+
+	// Handle preflight OPTIONS request
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet, http.MethodOptions)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Stop proxies such as nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		log.Printf("Products stream not supported by the response writer: %v", err)
+		return
+	}
+
+	// Each poll goes through the shared cache, so many open streams cost one upstream fetch per interval.
+	// While the Dotnet service is failing, polls back off so streams don't hammer it, and the client
+	// gets a keepalive comment every interval so proxies don't time out the idle connection.
+	interval := envDuration("PRODUCTS_STREAM_INTERVAL", defaultProductsStreamInterval)
+	maxBackoff := envDuration("PRODUCTS_STREAM_MAX_BACKOFF", defaultProductsStreamMaxBackoff)
+	heartbeat := time.NewTicker(interval)
+	defer heartbeat.Stop()
+	keepalive := func() bool {
+		if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+	last := make(map[string]int)
+	failures := 0
+	for {
+		entry, err := tenantProductCache(r.Context()).get(r.Context(), interval)
+		switch {
+		case r.Context().Err() != nil:
+			return
+		case err != nil:
+			failures++
+			log.Printf("An error occured polling products for stream (%d in a row): %v", failures, err)
+			if !keepalive() {
+				return
+			}
+		default:
+			if failures > 0 {
+				log.Printf("Products stream polling recovered after %d failed poll(s)", failures)
+				failures = 0
+			}
+			if changed := stockChanges(last, entry.Products); len(changed) > 0 {
+				data, err := marshalResponse(changed)
+				if err != nil {
+					log.Printf("Error encoding stock update: %v", err)
+					return
+				}
+				if _, err := fmt.Fprintf(w, "event: stockUpdate\ndata: %s\n\n", data); err != nil {
+					return
+				}
+				if err := rc.Flush(); err != nil {
+					return
+				}
+			} else if !keepalive() {
+				return
+			}
+		}
+
+		// Wait for the next poll, sending heartbeats in between while backing off
+		wait := interval
+		var beats <-chan time.Time
+		if failures > 0 {
+			wait = streamBackoff(interval, maxBackoff, failures)
+			heartbeat.Reset(interval)
+			beats = heartbeat.C
+		}
+		next := time.NewTimer(wait)
+		for waiting := true; waiting; {
+			select {
+			case <-r.Context().Done():
+				next.Stop()
+				return // The client disconnected
+			case <-shuttingDown(r.Context()):
+				next.Stop()
+				return // Clients reconnect, to another instance
+			case <-beats:
+				if !keepalive() {
+					next.Stop()
+					return
+				}
+			case <-next.C:
+				waiting = false
+			}
+		}
+	}
+}
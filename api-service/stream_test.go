@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// readEvent reads the next named SSE event from r, skipping comments, and returns its name and data
+func readEvent(t *testing.T, r *bufio.Reader) (string, string) {
+	t.Helper()
+	var name, data string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading event: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "" && name != "":
+			return name, data
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+// TestProductsStreamHandler tests that the stream sends every product first and then only changed stock
+func TestProductsStreamHandler(t *testing.T) {
+	var mu sync.Mutex
+	catalog := []Product{{Id: "p1", Stock: 5}, {Id: "p2", Stock: 3}}
+	newProductsUpstream(t, func() []Product {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]Product(nil), catalog...)
+	})
+	os.Setenv("PRODUCTS_STREAM_INTERVAL", "10ms")
+	defer os.Unsetenv("PRODUCTS_STREAM_INTERVAL")
+
+	server := httptest.NewServer(http.HandlerFunc(productsStreamHandler))
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET /products/stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", got)
+	}
+	events := bufio.NewReader(resp.Body)
+
+	name, data := readEvent(t, events)
+	var updates []StockUpdate
+	json.Unmarshal([]byte(data), &updates)
+	if want := []StockUpdate{{"p1", 5}, {"p2", 3}}; name != "stockUpdate" || !reflect.DeepEqual(updates, want) {
+		t.Errorf("first event = %s %s, want stockUpdate %v", name, data, want)
+	}
+
+	mu.Lock()
+	catalog[1].Stock = 2
+	mu.Unlock()
+
+	name, data = readEvent(t, events)
+	updates = nil
+	json.Unmarshal([]byte(data), &updates)
+	if want := []StockUpdate{{"p2", 2}}; name != "stockUpdate" || !reflect.DeepEqual(updates, want) {
+		t.Errorf("second event = %s %s, want stockUpdate %v", name, data, want)
+	}
+}
+
+// TestProductsStreamHandler_ClientDisconnect tests that the handler returns once the client goes away
+func TestProductsStreamHandler_ClientDisconnect(t *testing.T) {
+	newProductsUpstream(t, func() []Product { return []Product{{Id: "p1", Stock: 1}} })
+	os.Setenv("PRODUCTS_STREAM_INTERVAL", "10ms")
+	defer os.Unsetenv("PRODUCTS_STREAM_INTERVAL")
+
+	returned := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		productsStreamHandler(w, r)
+		close(returned)
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET /products/stream: %v", err)
+	}
+	readEvent(t, bufio.NewReader(resp.Body))
+	resp.Body.Close()
+
+	select {
+	case <-returned:
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler kept streaming after the client disconnected")
+	}
+}
+
+// TestStockChanges tests diffing stock levels between polls
+func TestStockChanges(t *testing.T) {
+	last := map[string]int{}
+	stockChanges(last, []Product{{Id: "a", Stock: 1}, {Id: "b", Stock: 2}})
+
+	got := stockChanges(last, []Product{{Id: "a", Stock: 1}, {Id: "b", Stock: 0}, {Id: "c", Stock: 4}})
+	if want := []StockUpdate{{"b", 0}, {"c", 4}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("stockChanges() = %v, want %v", got, want)
+	}
+	if got := stockChanges(last, []Product{{Id: "a", Stock: 1}}); got != nil {
+		t.Errorf("stockChanges() without changes = %v, want nil", got)
+	}
+}
+
+// TestStreamBackoff tests that waits double from the interval after each failed poll up to the cap
+func TestStreamBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 5 * time.Second},
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{4, time.Minute},
+		{50, time.Minute},
+	}
+	for _, tt := range tests {
+		if got := streamBackoff(5*time.Second, time.Minute, tt.failures); got != tt.want {
+			t.Errorf("streamBackoff(5s, 1m, %d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+	if got := streamBackoff(5*time.Second, time.Second, 3); got != 5*time.Second {
+		t.Errorf("streamBackoff with a cap below the interval = %v, want the interval", got)
+	}
+}
+
+// TestProductsStreamHandler_TransientFailures tests that failed polls back off and keep the client
+// alive with heartbeats, and that the stream resumes with stock updates once the upstream recovers
+func TestProductsStreamHandler_TransientFailures(t *testing.T) {
+	var hits atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) <= 3 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode([]Product{{Id: "p1", Stock: 5}})
+	}))
+	defer upstream.Close()
+	os.Setenv("DOTNET_PRODUCTS_API_URL", upstream.URL)
+	defer os.Unsetenv("DOTNET_PRODUCTS_API_URL")
+	os.Setenv("UPSTREAM_MAX_ATTEMPTS", "1") // One upstream request per poll
+	defer os.Unsetenv("UPSTREAM_MAX_ATTEMPTS")
+	os.Setenv("PRODUCTS_STREAM_INTERVAL", "10ms")
+	defer os.Unsetenv("PRODUCTS_STREAM_INTERVAL")
+	os.Setenv("PRODUCTS_STREAM_MAX_BACKOFF", "40ms")
+	defer os.Unsetenv("PRODUCTS_STREAM_MAX_BACKOFF")
+	productsCache.invalidate()
+	defer productsCache.invalidate()
+
+	server := httptest.NewServer(http.HandlerFunc(productsStreamHandler))
+	defer server.Close()
+	start := time.Now()
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET /products/stream: %v", err)
+	}
+	defer resp.Body.Close()
+	events := bufio.NewReader(resp.Body)
+
+	keepalives := 0
+	for {
+		line, err := events.ReadString('\n')
+		if err != nil {
+			t.Fatalf("stream closed during upstream failures: %v", err)
+		}
+		if line == ": keepalive\n" {
+			keepalives++
+		}
+		if strings.HasPrefix(line, "event: stockUpdate") {
+			break
+		}
+	}
+	// Three failures wait 20ms, 40ms and 40ms instead of three 10ms intervals
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("stream recovered after %v, want the failed polls to back off for at least 100ms", elapsed)
+	}
+	if keepalives < 3 {
+		t.Errorf("got %d keepalives before recovery, want at least one per failed poll", keepalives)
+	}
+	if got := hits.Load(); got < 4 {
+		t.Errorf("upstream polled %d times, want 3 failures and a success", got)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// regionPattern matches ISO 3166 country and subdivision codes such as "US", "US-CA" or "GB-LND"
+var regionPattern = regexp.MustCompile(`^[A-Z]{2}(-[A-Z0-9]{1,3})?$`)
+
+// normalizeRegion uppercases and validates an order's region code; an empty region is allowed
+func normalizeRegion(region string) (string, error) {
+	region = strings.ToUpper(strings.TrimSpace(region))
+	if region != "" && !regionPattern.MatchString(region) {
+		return "", fmt.Errorf("must be a region code like US or US-CA")
+	}
+	return region, nil
+}
+
+// loadTaxRates reads the region to tax rate mapping from a JSON file like {"US-CA": 0.0725, "US-NY": 0.08}.
+// Regions are case-insensitive.
+func loadTaxRates(path string) (map[string]float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]float64
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	rates := make(map[string]float64, len(raw))
+	for region, rate := range raw {
+		normalized, err := normalizeRegion(region)
+		if err != nil || normalized == "" {
+			return nil, fmt.Errorf("region %q: must be a region code like US or US-CA", region)
+		}
+		if rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("region %q: rate must be between 0 and 1", region)
+		}
+		rates[normalized] = rate
+	}
+	return rates, nil
+}
+
+// taxRateFor returns the tax rate for a normalized region from TAX_RATES_FILE, falling back to the
+// base TAX_RATE for orders without a region, regions not in the file, or when no file is configured
+func taxRateFor(region string) (float64, error) {
+	path := os.Getenv("TAX_RATES_FILE")
+	if path == "" || region == "" {
+		return taxRate(), nil
+	}
+	rates, err := loadTaxRates(path)
+	if err != nil {
+		return 0, fmt.Errorf("loading tax rates: %w", err)
+	}
+	if rate, ok := rates[region]; ok {
+		return rate, nil
+	}
+	return taxRate(), nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTaxRatesFile writes a tax rates file and points TAX_RATES_FILE at it
+func writeTaxRatesFile(t *testing.T, contents string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tax-rates.json")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("Could not write tax rates file: %v", err)
+	}
+	os.Setenv("TAX_RATES_FILE", path)
+	t.Cleanup(func() { os.Unsetenv("TAX_RATES_FILE") })
+}
+
+// TestNormalizeRegion tests region code validation
+func TestNormalizeRegion(t *testing.T) {
+	for _, region := range []string{"", "US", "us-ca", " GB-LND "} {
+		if _, err := normalizeRegion(region); err != nil {
+			t.Errorf("normalizeRegion(%q) returned error: %v", region, err)
+		}
+	}
+	for _, region := range []string{"USA", "U", "US-", "US-CALI", "US_CA", "12"} {
+		if _, err := normalizeRegion(region); err == nil {
+			t.Errorf("normalizeRegion(%q) returned no error", region)
+		}
+	}
+}
+
+// TestTaxRateFor tests known regions, unknown regions falling back to TAX_RATE, and invalid files
+func TestTaxRateFor(t *testing.T) {
+	os.Setenv("TAX_RATE", "0.05")
+	defer os.Unsetenv("TAX_RATE")
+	writeTaxRatesFile(t, `{"us-ca": 0.0725, "US-NY": 0.08}`)
+
+	for region, want := range map[string]float64{"US-CA": 0.0725, "US-NY": 0.08, "US-TX": 0.05, "": 0.05} {
+		if got, err := taxRateFor(region); err != nil || got != want {
+			t.Errorf("taxRateFor(%q) = %v, %v; want %v", region, got, err, want)
+		}
+	}
+
+	for _, contents := range []string{`{"US-CA": 1.5}`, `{"California": 0.07}`, `not json`} {
+		writeTaxRatesFile(t, contents)
+		if _, err := taxRateFor("US-CA"); err == nil {
+			t.Errorf("taxRateFor with file %s returned no error", contents)
+		}
+	}
+}
+
+// TestOrderHandler_RegionalTax tests that the breakdown uses the order region's rate and invalid regions are rejected
+func TestOrderHandler_RegionalTax(t *testing.T) {
+	newFakeDotnet(t, nil)
+	writeTaxRatesFile(t, `{"US-CA": 0.0725}`)
+
+	place := func(region string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		orderHandler(rr, postJSON(t, "/order", PlaceOrderRequest{
+			Items:           []OrderItemRequest{{Id: "prod1", Quantity: 1, Price: 100}},
+			TotalAmount:     pricePtr(100),
+			DeliveryAddress: "1 Main St",
+			Region:          region,
+		}))
+		return rr
+	}
+
+	var resp PlaceOrderResponse
+	json.Unmarshal(place("us-ca").Body.Bytes(), &resp)
+	if resp.Breakdown == nil || resp.Breakdown.Tax != 7.25 || resp.Breakdown.Total != 107.25 {
+		t.Errorf("US-CA breakdown = %+v, want tax 7.25 and total 107.25", resp.Breakdown)
+	}
+
+	resp = PlaceOrderResponse{}
+	json.Unmarshal(place("US-OR").Body.Bytes(), &resp)
+	if resp.Breakdown == nil || resp.Breakdown.Tax != 0 {
+		t.Errorf("unknown region breakdown = %+v, want the base rate of no tax", resp.Breakdown)
+	}
+
+	rr := place("California")
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code for invalid region: got %v want %v", status, http.StatusBadRequest)
+	}
+	var valResp ValidationErrorResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &valResp); err != nil || len(valResp.Fields) != 1 || valResp.Fields[0].Field != "region" {
+		t.Errorf("invalid region response = %q, want a region field error", rr.Body.String())
+	}
+}
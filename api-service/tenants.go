@@ -0,0 +1,220 @@
+package main
+
+// Multi-tenant routing. TENANT_UPSTREAMS_FILE maps tenant ids to the Dotnet service each tenant's
+// shop endpoints call, as JSON like
+//
+//	{"acme": "http://acme-dotnet:8080", "globex": "http://globex-1:8080,http://globex-2:8080"}
+//
+// where each value takes the same instance list as DOTNET_PRODUCTS_API_URLS. A request names its
+// tenant with the X-Tenant-ID header or, without one, by the first label of a host like
+// acme.shop.example.com. Requests for a tenant not in the file, or naming none, get 400. The file is
+// loaded at startup and reread when its modification time or size changes, so tenants can be added
+// without a restart; an edit that doesn't load is logged and the tenants loaded before are kept.
+//
+// State that would otherwise leak between tenants is kept per tenant: catalog caches (and their
+// shared store keys), the MAX_CONCURRENT_ORDERS limit, order nonces and stock reservations.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tenantIDPattern matches tenant ids, which are DNS labels so they can also be used as subdomains
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// tenantRoute is the tenant a request was routed to
+type tenantRoute struct {
+	id        string
+	instances []upstreamInstance
+}
+
+// tenantKey is the request context key for the tenant requireTenant resolved
+type tenantKey struct{}
+
+// loadTenantUpstreams reads the tenant to Dotnet instances mapping from TENANT_UPSTREAMS_FILE's JSON.
+// Tenant ids are case-insensitive.
+func loadTenantUpstreams(path string) (map[string][]upstreamInstance, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	tenants := make(map[string][]upstreamInstance, len(raw))
+	for id, value := range raw {
+		normalized := strings.ToLower(strings.TrimSpace(id))
+		if !tenantIDPattern.MatchString(normalized) {
+			return nil, fmt.Errorf("tenant %q: must be letters, digits and dashes, like a DNS label", id)
+		}
+		instances := parseUpstreamInstances(value)
+		if len(instances) == 0 {
+			return nil, fmt.Errorf("tenant %q: no upstream URL", id)
+		}
+		for _, instance := range instances {
+			if u, err := url.Parse(instance.base); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return nil, fmt.Errorf("tenant %q: upstream %q must be an http or https URL", id, instance.base)
+			}
+		}
+		tenants[normalized] = instances
+	}
+	return tenants, nil
+}
+
+// tenantUpstreamsFile caches the mapping loaded from TENANT_UPSTREAMS_FILE
+type tenantUpstreamsFile struct {
+	mu      sync.Mutex
+	path    string
+	modTime time.Time // Of the version of the file last tried, loaded or not
+	size    int64
+	tried   bool
+	tenants map[string][]upstreamInstance // Last mapping loaded from path, nil if none has
+	err     error                         // Why nothing has loaded from path, while tenants is nil
+}
+
+// tenantUpstreams is the process-wide TENANT_UPSTREAMS_FILE cache used by requireTenant
+var tenantUpstreams = &tenantUpstreamsFile{}
+
+// get returns the mapping in the file at path, only rereading it when its modification time or size
+// has changed since it was last tried. A reread that fails keeps the last mapping loaded, so a broken
+// edit is logged once rather than failing every request; it is only an error before any has loaded.
+func (f *tenantUpstreamsFile) get(path string) (map[string][]upstreamInstance, error) {
+	modTime, size := time.Time{}, int64(-1)
+	if info, err := os.Stat(path); err == nil {
+		modTime, size = info.ModTime(), info.Size()
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.path != path {
+		f.path, f.tried, f.tenants, f.err = path, false, nil, nil
+	}
+	if f.tried && modTime.Equal(f.modTime) && size == f.size {
+		return f.tenants, f.err
+	}
+	f.modTime, f.size, f.tried = modTime, size, true
+	tenants, err := loadTenantUpstreams(path)
+	switch {
+	case err == nil:
+		f.tenants, f.err = tenants, nil
+	case f.tenants == nil:
+		f.err = err
+	default:
+		log.Printf("Error reloading tenant upstreams, keeping the %d tenants loaded before: %v", len(f.tenants), err)
+	}
+	return f.tenants, f.err
+}
+
+// requestTenantID returns the tenant a request names with X-Tenant-ID, or else with the first label
+// of its host when that is a known tenant. The bool result reports whether the header was used.
+func requestTenantID(r *http.Request, tenants map[string][]upstreamInstance) (string, bool) {
+	if id := r.Header.Get("X-Tenant-ID"); id != "" {
+		return strings.ToLower(strings.TrimSpace(id)), true
+	}
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if net.ParseIP(host) != nil {
+		return "", false
+	}
+	// Only hosts with a subdomain, so tenant.example.com but not example.com
+	if labels := strings.Split(host, "."); len(labels) >= 3 {
+		if id := strings.ToLower(labels[0]); tenants[id] != nil {
+			return id, false
+		}
+	}
+	return "", false
+}
+
+// requireTenant routes requests to their tenant's Dotnet service when TENANT_UPSTREAMS_FILE is set,
+// rejecting those for an unknown tenant or none. Preflight requests pass through, since browsers
+// don't send custom headers on them.
+func requireTenant(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := os.Getenv("TENANT_UPSTREAMS_FILE")
+		if path == "" || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		tenants, err := tenantUpstreams.get(path)
+		if err != nil {
+			log.Printf("Error loading tenant upstreams: %v", err)
+			writeError(w, r, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+		id, fromHeader := requestTenantID(r, tenants)
+		instances, ok := tenants[id]
+		if !ok {
+			if !fromHeader {
+				writeError(w, r, http.StatusBadRequest, "A tenant is required: set X-Tenant-ID or use the tenant's subdomain")
+				return
+			}
+			log.Printf("Rejected request to %s for unknown tenant %q", r.URL.Path, truncate(id, 64))
+			writeError(w, r, http.StatusBadRequest, "Unknown tenant")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tenantKey{}, tenantRoute{id: id, instances: instances})))
+	})
+}
+
+// tenantFrom returns the id of the tenant requireTenant routed ctx's request to, or "" without tenants
+func tenantFrom(ctx context.Context) string {
+	t, _ := ctx.Value(tenantKey{}).(tenantRoute)
+	return t.id
+}
+
+// tenantInstances returns the Dotnet instances of ctx's tenant, or nil without tenants
+func tenantInstances(ctx context.Context) []upstreamInstance {
+	t, _ := ctx.Value(tenantKey{}).(tenantRoute)
+	return t.instances
+}
+
+// tenantProductCache returns the default-language catalog cache of ctx's tenant
+func tenantProductCache(ctx context.Context) *productCache {
+	return productCacheFor(tenantFrom(ctx), "")
+}
+
+// perTenant holds one T per tenant, created on first use; the "" tenant is the one used without
+// tenants. Keys are only ids from TENANT_UPSTREAMS_FILE, so the map stays small.
+type perTenant[T any] struct {
+	mu       sync.Mutex
+	byTenant map[string]T
+	create   func(tenant string) T
+}
+
+func newPerTenant[T any](create func(tenant string) T) *perTenant[T] {
+	return &perTenant[T]{byTenant: make(map[string]T), create: create}
+}
+
+// get returns tenant's T, creating it if needed
+func (p *perTenant[T]) get(tenant string) T {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	v, ok := p.byTenant[tenant]
+	if !ok {
+		v = p.create(tenant)
+		p.byTenant[tenant] = v
+	}
+	return v
+}
+
+// each calls f with every tenant's T created so far
+func (p *perTenant[T]) each(f func(tenant string, v T)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for tenant, v := range p.byTenant {
+		f(tenant, v)
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// useTenants writes tenants to a TENANT_UPSTREAMS_FILE for the test, dropping tenant catalogs after it
+func useTenants(t *testing.T, tenants map[string]string) {
+	t.Helper()
+	data, err := json.Marshal(tenants)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("TENANT_UPSTREAMS_FILE", path)
+	t.Cleanup(func() {
+		os.Unsetenv("TENANT_UPSTREAMS_FILE")
+		invalidateLocalizedCaches()
+	})
+}
+
+// withTenant returns ctx as requireTenant leaves it for a request routed to tenant id
+func withTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantRoute{id: id})
+}
+
+// TestLoadTenantUpstreams tests parsing of the tenant file, including instance lists, and what it refuses
+func TestLoadTenantUpstreams(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string][]upstreamInstance
+		wantErr bool
+	}{
+		{"single", `{"acme": "http://acme:8080"}`, map[string][]upstreamInstance{"acme": {{base: "http://acme:8080"}}}, false},
+		{"case-insensitive id", `{" Acme ": "http://acme:8080"}`, map[string][]upstreamInstance{"acme": {{base: "http://acme:8080"}}}, false},
+		{"instance list", `{"acme": "http://a:8080|3, https://b:8443"}`, map[string][]upstreamInstance{"acme": {{base: "http://a:8080", weight: 3}, {base: "https://b:8443", weight: 1}}}, false},
+		{"empty", `{}`, map[string][]upstreamInstance{}, false},
+		{"invalid json", `{"acme": `, nil, true},
+		{"invalid id", `{"acme_corp": "http://acme:8080"}`, nil, true},
+		{"no url", `{"acme": " , "}`, nil, true},
+		{"not http", `{"acme": "ftp://acme"}`, nil, true},
+		{"no host", `{"acme": "acme:8080"}`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tenants.json")
+			os.WriteFile(path, []byte(tt.content), 0o600)
+			got, err := loadTenantUpstreams(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadTenantUpstreams() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadTenantUpstreams() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if _, err := loadTenantUpstreams(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("loadTenantUpstreams accepted a missing file")
+	}
+}
+
+// TestTenantUpstreamsFile_Reload tests that the tenant file is only reread when it changes, and that a
+// broken edit keeps the tenants loaded before
+func TestTenantUpstreamsFile_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	stamp := time.Now()
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		stamp = stamp.Add(time.Second) // Distinct modification times however fast the writes
+		if err := os.Chtimes(path, stamp, stamp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	acme := map[string][]upstreamInstance{"acme": {{base: "http://acme:8080"}}}
+	file := &tenantUpstreamsFile{}
+
+	if _, err := file.get(path); err == nil {
+		t.Error("get of a missing file succeeded")
+	}
+	write(`{"acme": "http://acme:8080"}`)
+	if got, err := file.get(path); err != nil || !reflect.DeepEqual(got, acme) {
+		t.Fatalf("get = %v, %v, want acme", got, err)
+	}
+
+	write(`{"acme": `)
+	if got, err := file.get(path); err != nil || !reflect.DeepEqual(got, acme) {
+		t.Errorf("get after a broken edit = %v, %v, want acme kept", got, err)
+	}
+	os.Remove(path)
+	if got, err := file.get(path); err != nil || !reflect.DeepEqual(got, acme) {
+		t.Errorf("get after the file was removed = %v, %v, want acme kept", got, err)
+	}
+
+	write(`{"acme": "http://acme:8080", "globex": "http://globex:8080"}`)
+	if got, err := file.get(path); err != nil || len(got) != 2 {
+		t.Errorf("get after a fixed edit = %v, %v, want both tenants", got, err)
+	}
+}
+
+// TestRequireTenant tests resolution by header and subdomain, and the requests it rejects
+func TestRequireTenant(t *testing.T) {
+	useTenants(t, map[string]string{"acme": "http://acme:8080", "globex": "http://globex:8080"})
+	tests := []struct {
+		name       string
+		method     string
+		host       string
+		header     string
+		wantStatus int
+		wantTenant string
+	}{
+		{"header", http.MethodGet, "shop.example.com", "acme", http.StatusOK, "acme"},
+		{"header case-insensitive", http.MethodGet, "shop.example.com", "GLOBEX", http.StatusOK, "globex"},
+		{"header unknown", http.MethodGet, "shop.example.com", "initech", http.StatusBadRequest, ""},
+		{"header over subdomain", http.MethodGet, "acme.example.com", "globex", http.StatusOK, "globex"},
+		{"unknown header not saved by subdomain", http.MethodGet, "acme.example.com", "initech", http.StatusBadRequest, ""},
+		{"subdomain", http.MethodGet, "acme.example.com", "", http.StatusOK, "acme"},
+		{"subdomain with port", http.MethodGet, "Globex.shop.example.com:8080", "", http.StatusOK, "globex"},
+		{"unknown subdomain", http.MethodGet, "www.example.com", "", http.StatusBadRequest, ""},
+		{"no subdomain", http.MethodGet, "acme.com", "", http.StatusBadRequest, ""},
+		{"ip host", http.MethodGet, "10.0.0.1:8080", "", http.StatusBadRequest, ""},
+		{"preflight", http.MethodOptions, "shop.example.com", "", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := requireTenant(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = tenantFrom(r.Context())
+			}))
+			req := httptest.NewRequest(tt.method, "/products", nil)
+			req.Host = tt.host
+			if tt.header != "" {
+				req.Header.Set("X-Tenant-ID", tt.header)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if status := rr.Code; status != tt.wantStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tt.wantStatus)
+			}
+			if got != tt.wantTenant {
+				t.Errorf("tenant = %q, want %q", got, tt.wantTenant)
+			}
+		})
+	}
+}
+
+// TestRequireTenant_Disabled tests that without TENANT_UPSTREAMS_FILE requests pass without a tenant
+func TestRequireTenant_Disabled(t *testing.T) {
+	called := false
+	handler := requireTenant(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		if got := tenantFrom(r.Context()); got != "" {
+			t.Errorf("tenant = %q, want none", got)
+		}
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/products", nil)
+	req.Header.Set("X-Tenant-ID", "acme")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if !called {
+		t.Error("handler was not called")
+	}
+}
+
+// TestTenantRouting_Isolation tests that each tenant's catalog comes from its own Dotnet service and is cached apart
+func TestTenantRouting_Isolation(t *testing.T) {
+	acme, acmeHits := newProductsUpstream(t, func() []Product { return []Product{{Id: "anvil", Price: 10, Stock: 1}} })
+	globex, globexHits := newProductsUpstream(t, func() []Product { return []Product{{Id: "laser", Price: 99, Stock: 1}} })
+	useTenants(t, map[string]string{"acme": acme.URL, "globex": globex.URL})
+	handler := routes()
+
+	get := func(tenant string) (int, []Product) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/products", nil)
+		if tenant != "" {
+			req.Header.Set("X-Tenant-ID", tenant)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		var products []Product
+		json.Unmarshal(rr.Body.Bytes(), &products)
+		return rr.Code, products
+	}
+	for i := 0; i < 2; i++ {
+		if status, products := get("acme"); status != http.StatusOK || len(products) != 1 || products[0].Id != "anvil" {
+			t.Errorf("acme got status %v, products %v, want its own catalog", status, products)
+		}
+		if status, products := get("globex"); status != http.StatusOK || len(products) != 1 || products[0].Id != "laser" {
+			t.Errorf("globex got status %v, products %v, want its own catalog", status, products)
+		}
+	}
+	if a, g := atomic.LoadInt32(acmeHits), atomic.LoadInt32(globexHits); a != 1 || g != 1 {
+		t.Errorf("got %d acme and %d globex fetches, want one each", a, g)
+	}
+	for _, tenant := range []string{"", "initech"} {
+		if status, _ := get(tenant); status != http.StatusBadRequest {
+			t.Errorf("tenant %q: handler returned wrong status code: got %v want %v", tenant, status, http.StatusBadRequest)
+		}
+	}
+	if entry := productsCache.cached(); entry != nil {
+		t.Errorf("default catalog was cached for tenant requests: %v", entry.Products)
+	}
+}
+
+// TestTenantState_Isolation tests that shared keys, order nonces, order slots and reservations are kept per tenant
+func TestTenantState_Isolation(t *testing.T) {
+	if got := productCacheFor("acme", "").sharedKey(); got != "products:tenant:acme:catalog" {
+		t.Errorf("tenant sharedKey = %q", got)
+	}
+	if got := productCacheFor("acme", "de").sharedKey(); got != "products:tenant:acme:catalog:de" {
+		t.Errorf("localized tenant sharedKey = %q", got)
+	}
+	if productCacheFor("", "") != productsCache || productCacheFor("acme", "") == productCacheFor("globex", "") {
+		t.Error("productCacheFor should return productsCache without a tenant and separate caches per tenant")
+	}
+
+	nonces := newNonceStore(newMemoryStore(nil))
+	acme, globex := withTenant(context.Background(), "acme"), withTenant(context.Background(), "globex")
+	for _, ctx := range []context.Context{acme, globex, context.Background()} {
+		if claimed, err := nonces.claim(ctx, "n1", time.Minute); err != nil || !claimed {
+			t.Errorf("claim for tenant %q = %v, %v, want the nonce unused", tenantFrom(ctx), claimed, err)
+		}
+	}
+	if claimed, _ := nonces.claim(acme, "n1", time.Minute); claimed {
+		t.Error("nonce reused within a tenant was claimed")
+	}
+
+	if !orderLimiters.get("acme").acquire(context.Background(), 1, 0) {
+		t.Fatal("acme could not take an order slot")
+	}
+	defer orderLimiters.get("acme").release()
+	if !orderLimiters.get("globex").acquire(context.Background(), 1, time.Millisecond) {
+		t.Error("acme's order in flight took globex's slot")
+	} else {
+		orderLimiters.get("globex").release()
+	}
+
+	t.Cleanup(func() {
+		tenantReservations = newPerTenant(func(string) *reservationStore { return newReservationStore() })
+	})
+	items := []OrderItemRequest{{Id: "p1", Quantity: 1}}
+	stock := map[string]int{"p1": 1}
+	id, _, _ := reservationsFor("acme").reserve(items, stock, time.Minute)
+	if id == "" {
+		t.Fatal("acme could not reserve")
+	}
+	if _, _, outOfStock := reservationsFor("globex").reserve(items, stock, time.Minute); len(outOfStock) > 0 {
+		t.Error("acme's hold counted against globex's stock")
+	}
+	if reservationsFor("globex").active(id) || reservationsFor("").active(id) {
+		t.Error("acme's reservation is visible to other tenants")
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Token defaults when JWT_SECRET / JWT_TTL are not set
+const (
+	devJWTSecret    = "dev-insecure-jwt-secret"
+	defaultTokenTTL = time.Hour
+)
+
+// errInvalidToken is returned by parseToken for malformed, tampered or expired tokens
+var errInvalidToken = errors.New("invalid token")
+
+// tokenClaims are the JWT claims issued on login
+type tokenClaims struct {
+	Subject   string `json:"sub"`
+	Role      string `json:"role"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// jwtHeader is the only header we issue or accept, so tokens can't pick their own algorithm
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// tokensEnabled reports whether AUTH_ISSUE_TOKENS=true makes successful logins return a JWT
+func tokensEnabled() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("AUTH_ISSUE_TOKENS"))
+	return enabled
+}
+
+// jwtSecret returns JWT_SECRET, falling back to an insecure development secret
+func jwtSecret() []byte {
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	return []byte(devJWTSecret)
+}
+
+// bearerSecret returns the secret bearer tokens are verified with, and false when none are accepted:
+// unless AUTH_ISSUE_TOKENS=true and JWT_SECRET is set, a token could only have been signed with the
+// public development secret, which anyone can forge one with
+func bearerSecret() ([]byte, bool) {
+	secret := os.Getenv("JWT_SECRET")
+	if !tokensEnabled() || secret == "" {
+		return nil, false
+	}
+	return []byte(secret), true
+}
+
+// checkTokenConfig warns at startup when JWT_SECRET is missing but tokens are issued or
+// AUTH_REQUIRED is on, or refuses to start under STRICT_CONFIG
+func checkTokenConfig() error {
+	if os.Getenv("JWT_SECRET") != "" || !tokensEnabled() && !authRequired() {
+		return nil
+	}
+	if strictConfig() {
+		return errors.New("STRICT_CONFIG=true requires JWT_SECRET when AUTH_ISSUE_TOKENS or AUTH_REQUIRED is true")
+	}
+	log.Println("WARNING: JWT_SECRET is not set, so login tokens are signed with a development secret and " +
+		"bearer tokens are rejected. Set JWT_SECRET, or STRICT_CONFIG=true to refuse to start without it.")
+	return nil
+}
+
+// issueToken signs an HS256 JWT for id that expires after ttl
+func issueToken(id Identity, secret []byte, ttl time.Duration, now time.Time) (string, error) {
+	claims, err := json.Marshal(tokenClaims{
+		Subject:   id.Username,
+		Role:      id.Role,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	signingInput := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(claims)
+	return signingInput + "." + sign(signingInput, secret), nil
+}
+
+// parseToken verifies a token from issueToken and returns the identity it was issued for
+func parseToken(token string, secret []byte, now time.Time) (Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != jwtHeader {
+		return Identity{}, errInvalidToken
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(sign(parts[0]+"."+parts[1], secret))) {
+		return Identity{}, errInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return Identity{}, errInvalidToken
+	}
+	var claims tokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return Identity{}, errInvalidToken
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return Identity{}, errInvalidToken
+	}
+	return Identity{Username: claims.Subject, Role: claims.Role}, nil
+}
+
+// sign returns the base64url HMAC-SHA256 of signingInput
+func sign(signingInput string, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signingInput))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestIssueToken_RoundTrip tests that issued tokens parse back to the same identity until they expire
+func TestIssueToken_RoundTrip(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	token, err := issueToken(Identity{Username: "alice", Role: "admin"}, secret, time.Hour, now)
+	if err != nil {
+		t.Fatalf("issueToken returned error: %v", err)
+	}
+
+	id, err := parseToken(token, secret, now.Add(59*time.Minute))
+	if err != nil || id.Username != "alice" || id.Role != "admin" {
+		t.Errorf("parseToken = %+v, %v; want alice/admin", id, err)
+	}
+	if _, err := parseToken(token, secret, now.Add(time.Hour)); !errors.Is(err, errInvalidToken) {
+		t.Errorf("expired token returned %v, want errInvalidToken", err)
+	}
+	if _, err := parseToken(token, []byte("other"), now); !errors.Is(err, errInvalidToken) {
+		t.Errorf("token with wrong secret returned %v, want errInvalidToken", err)
+	}
+}
+
+// TestParseToken_Tampered tests that altered claims and foreign algorithms are rejected
+func TestParseToken_Tampered(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Now()
+	token, _ := issueToken(Identity{Username: "bob", Role: "customer"}, secret, time.Hour, now)
+	parts := strings.Split(token, ".")
+
+	admin, _ := issueToken(Identity{Username: "bob", Role: "admin"}, []byte("guess"), time.Hour, now)
+	tampered := []string{
+		parts[0] + "." + strings.Split(admin, ".")[1] + "." + parts[2], // Claims swapped in
+		"eyJhbGciOiJub25lIn0." + parts[1] + ".",                        // {"alg":"none"}
+		parts[0] + "." + parts[1],
+		"",
+	}
+	for _, tok := range tampered {
+		if _, err := parseToken(tok, secret, now); !errors.Is(err, errInvalidToken) {
+			t.Errorf("parseToken(%q) returned %v, want errInvalidToken", tok, err)
+		}
+	}
+}
+
+// TestAuthHandler_IssuesToken tests that logins only return a token when AUTH_ISSUE_TOKENS is set
+func TestAuthHandler_IssuesToken(t *testing.T) {
+	useFakeLockoutClock(t)
+	os.Setenv("AUTH_PASSKEY", "testpasskey")
+	defer os.Unsetenv("AUTH_PASSKEY")
+
+	login := func() LoginResponse {
+		rr := httptest.NewRecorder()
+		authHandler(rr, postJSON(t, "/auth", LoginRequest{Username: "alice", Passkey: "testpasskey"}))
+		var resp LoginResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decoding login response: %v", err)
+		}
+		return resp
+	}
+
+	if resp := login(); resp.Token != "" {
+		t.Errorf("token issued without AUTH_ISSUE_TOKENS: %q", resp.Token)
+	}
+
+	os.Setenv("AUTH_ISSUE_TOKENS", "true")
+	defer os.Unsetenv("AUTH_ISSUE_TOKENS")
+	os.Setenv("JWT_SECRET", "jwtsecret")
+	defer os.Unsetenv("JWT_SECRET")
+	resp := login()
+	id, err := parseToken(resp.Token, []byte("jwtsecret"), time.Now())
+	if err != nil || id.Username != "alice" || id.Role != "customer" {
+		t.Errorf("issued token parsed to %+v, %v; want alice/customer", id, err)
+	}
+}
+
+// TestCheckTokenConfig tests the startup check for a missing JWT_SECRET
+func TestCheckTokenConfig(t *testing.T) {
+	os.Setenv("AUTH_ISSUE_TOKENS", "true")
+	defer os.Unsetenv("AUTH_ISSUE_TOKENS")
+	if err := checkTokenConfig(); err != nil {
+		t.Errorf("checkTokenConfig without STRICT_CONFIG returned error: %v", err)
+	}
+
+	os.Setenv("STRICT_CONFIG", "true")
+	defer os.Unsetenv("STRICT_CONFIG")
+	if err := checkTokenConfig(); err == nil {
+		t.Error("checkTokenConfig under STRICT_CONFIG without JWT_SECRET returned nil")
+	}
+	os.Unsetenv("AUTH_ISSUE_TOKENS")
+	os.Setenv("AUTH_REQUIRED", "true")
+	defer os.Unsetenv("AUTH_REQUIRED")
+	if err := checkTokenConfig(); err == nil {
+		t.Error("checkTokenConfig under STRICT_CONFIG with AUTH_REQUIRED and no JWT_SECRET returned nil")
+	}
+	if warnings := configWarnings(); len(warnings) != 1 || !strings.Contains(warnings[0], "AUTH_REQUIRED") {
+		t.Errorf("configWarnings() = %q, want a warning about AUTH_REQUIRED without JWT_SECRET", warnings)
+	}
+	os.Setenv("JWT_SECRET", "jwtsecret")
+	defer os.Unsetenv("JWT_SECRET")
+	if err := checkTokenConfig(); err != nil {
+		t.Errorf("checkTokenConfig with JWT_SECRET returned error: %v", err)
+	}
+}
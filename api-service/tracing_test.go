@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// useSpanRecorder installs an in-memory tracer provider for the duration of the test
+func useSpanRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	origProvider, origPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(origProvider)
+		otel.SetTextMapPropagator(origPropagator)
+	})
+	return recorder
+}
+
+// TestTracing_PropagatesToUpstream tests the server span, the child upstream span and traceparent propagation
+func TestTracing_PropagatesToUpstream(t *testing.T) {
+	recorder := useSpanRecorder(t)
+
+	var upstreamTraceparent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamTraceparent = r.Header.Get("traceparent")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"success":true,"orderId":"order-1"}`))
+	}))
+	defer upstream.Close()
+	os.Setenv("DOTNET_PRODUCTS_API_URL", upstream.URL)
+	defer os.Unsetenv("DOTNET_PRODUCTS_API_URL")
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := postJSON(t, "/order", PlaceOrderRequest{
+		Items:           []OrderItemRequest{{Id: "prod1", Quantity: 1, Price: 5}},
+		TotalAmount:     pricePtr(5),
+		DeliveryAddress: "1 Main St",
+	})
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	rr := httptest.NewRecorder()
+	tracingMiddleware(http.HandlerFunc(orderHandler)).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	if !strings.Contains(upstreamTraceparent, traceID) {
+		t.Errorf("upstream traceparent %q does not continue trace %s", upstreamTraceparent, traceID)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("recorded %d spans, want 2", len(spans))
+	}
+	client, server := spans[0], spans[1]
+	if server.Name() != "POST /order" || client.Name() != "upstream POST /place-order" {
+		t.Errorf("got spans %q and %q", server.Name(), client.Name())
+	}
+	if server.SpanContext().TraceID().String() != traceID {
+		t.Errorf("server span trace id = %s, want %s", server.SpanContext().TraceID(), traceID)
+	}
+	if client.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Error("upstream span is not a child of the handler span")
+	}
+}
+
+// TestTracing_RecordsUpstreamError tests that a failed upstream call marks the client span as an error
+func TestTracing_RecordsUpstreamError(t *testing.T) {
+	recorder := useSpanRecorder(t)
+	upstream := httptest.NewServer(http.NotFoundHandler())
+	upstream.Close() // Connections are refused
+
+	req, _ := http.NewRequest(http.MethodGet, upstream.URL+"/all-products", nil)
+	if _, err := newUpstreamClient().Do(req); err == nil {
+		t.Fatal("expected an error calling a closed upstream")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Status().Code != codes.Error {
+		t.Fatalf("expected one errored span, got %d spans", len(spans))
+	}
+}
+
+// TestSetupTracing_NoopWithoutEndpoint tests that tracing is a no-op when no exporter is configured
+func TestSetupTracing_NoopWithoutEndpoint(t *testing.T) {
+	shutdown, err := setupTracing(t.Context())
+	if err != nil {
+		t.Fatalf("setupTracing returned error: %v", err)
+	}
+	if err := shutdown(t.Context()); err != nil {
+		t.Errorf("shutdown returned error: %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/salus-templates/shopping-cart-backend/api-service/dotnetclient"
+)
+
+// upstreamPathURL builds the full URL for a Dotnet endpoint on the instance at base, with the optional
+// DOTNET_API_PREFIX (e.g. "/api/v1") in between, normalizing slashes between the parts
+func upstreamPathURL(base, path string) string {
+	return dotnetclient.EndpointURL(base, os.Getenv("DOTNET_API_PREFIX"), path)
+}
+
+// defaultUpstreamTimeout bounds calls to the Dotnet service when UPSTREAM_TIMEOUT is not set
+const defaultUpstreamTimeout = 10 * time.Second
+
+// upstreamTimeout reads the timeout for one kind of upstream call from key (e.g. PRODUCTS_TIMEOUT),
+// falling back to the shared UPSTREAM_TIMEOUT
+func upstreamTimeout(key string) time.Duration {
+	return envDuration(key, envDuration("UPSTREAM_TIMEOUT", defaultUpstreamTimeout))
+}
+
+// newUpstreamClient returns an HTTP client for calling the Dotnet service with tracing enabled and
+// identifying itself with upstreamUserAgent.
+// Connections to private addresses are refused unless ALLOW_PRIVATE_UPSTREAM is set. The client has
+// no overall timeout; callers bound each call with a context deadline from upstreamTimeout.
+func newUpstreamClient() *http.Client {
+	return &http.Client{
+		Transport: tracingTransport{base: userAgentTransport{base: upstreamTransport}},
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestUpstreamPathURL tests slash normalization between the base URL, prefix and path
+func TestUpstreamPathURL(t *testing.T) {
+	tests := []struct {
+		base   string
+		prefix string
+		path   string
+		want   string
+	}{
+		{"http://dotnet:8080", "", "/all-products", "http://dotnet:8080/all-products"},
+		{"http://dotnet:8080/", "", "/all-products", "http://dotnet:8080/all-products"},
+		{"http://dotnet:8080", "/api/v1", "/all-products", "http://dotnet:8080/api/v1/all-products"},
+		{"http://dotnet:8080/", "/api/v1/", "/place-order", "http://dotnet:8080/api/v1/place-order"},
+		{"http://dotnet:8080", "api/v1", "place-order", "http://dotnet:8080/api/v1/place-order"},
+		{"http://dotnet:8080//", "//api//", "//all-products", "http://dotnet:8080/api/all-products"},
+		{"http://dotnet:8080", "/", "/all-products", "http://dotnet:8080/all-products"},
+	}
+	defer os.Unsetenv("DOTNET_API_PREFIX")
+	for _, tt := range tests {
+		os.Setenv("DOTNET_API_PREFIX", tt.prefix)
+		if got := upstreamPathURL(tt.base, tt.path); got != tt.want {
+			t.Errorf("upstreamPathURL(%q, %q) with prefix %q = %q, want %q", tt.base, tt.path, tt.prefix, got, tt.want)
+		}
+	}
+}
+
+// fakeDotnet is a fake Dotnet products service serving a fixed catalog and recording placed orders
+type fakeDotnet struct {
+	Server   *httptest.Server
+	Products []Product
+	Orders   []PlaceOrderRequest
+	Delay    time.Duration // How long /place-order takes to answer
+	mu       sync.Mutex
+}
+
+// newFakeDotnet starts a fake Dotnet service and points DOTNET_PRODUCTS_API_URL at it
+func newFakeDotnet(t *testing.T, products []Product) *fakeDotnet {
+	t.Helper()
+	f := &fakeDotnet{Products: products}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/all-products", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(f.Products)
+	})
+	mux.HandleFunc("/place-order", func(w http.ResponseWriter, r *http.Request) {
+		var order PlaceOrderRequest
+		json.NewDecoder(r.Body).Decode(&order)
+		time.Sleep(f.Delay)
+		f.mu.Lock()
+		f.Orders = append(f.Orders, order)
+		n := len(f.Orders)
+		f.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(PlaceOrderResponse{Success: true, Message: "Order placed", OrderId: fmt.Sprintf("order-%d", n)})
+	})
+	f.Server = httptest.NewServer(mux)
+	os.Setenv("DOTNET_PRODUCTS_API_URL", f.Server.URL)
+	productsCache.invalidate()
+	t.Cleanup(func() {
+		f.Server.Close()
+		os.Unsetenv("DOTNET_PRODUCTS_API_URL")
+		productsCache.invalidate()
+	})
+	return f
+}
+
+// lastOrder returns the most recent order received by the fake service
+func (f *fakeDotnet) lastOrder(t *testing.T) PlaceOrderRequest {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.Orders) == 0 {
+		t.Fatal("fake Dotnet service received no orders")
+	}
+	return f.Orders[len(f.Orders)-1]
+}
+
+// postJSON builds a JSON POST request for handler tests
+func postJSON(t *testing.T, path string, v interface{}) *http.Request {
+	t.Helper()
+	body, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Could not encode request: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+// TestUpstreamTimeout tests the per-endpoint timeout variables and their shared fallback
+func TestUpstreamTimeout(t *testing.T) {
+	if got := upstreamTimeout("PRODUCTS_TIMEOUT"); got != defaultUpstreamTimeout {
+		t.Errorf("default timeout = %v, want %v", got, defaultUpstreamTimeout)
+	}
+	os.Setenv("UPSTREAM_TIMEOUT", "3s")
+	defer os.Unsetenv("UPSTREAM_TIMEOUT")
+	os.Setenv("ORDER_TIMEOUT", "20s")
+	defer os.Unsetenv("ORDER_TIMEOUT")
+	if got := upstreamTimeout("PRODUCTS_TIMEOUT"); got != 3*time.Second {
+		t.Errorf("PRODUCTS_TIMEOUT fallback = %v, want UPSTREAM_TIMEOUT's 3s", got)
+	}
+	if got := upstreamTimeout("ORDER_TIMEOUT"); got != 20*time.Second {
+		t.Errorf("ORDER_TIMEOUT = %v, want 20s", got)
+	}
+}
+
+// TestUpstreamTimeout_PerPath tests that a slow Dotnet service times out only the call whose timeout is short
+func TestUpstreamTimeout_PerPath(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/place-order" {
+			json.NewEncoder(w).Encode(PlaceOrderResponse{Success: true, OrderId: "order-1"})
+			return
+		}
+		json.NewEncoder(w).Encode([]Product{{Id: "prod1", Name: "Headphones", Price: 99.99, Stock: 1}})
+	}))
+	defer server.Close()
+	os.Setenv("DOTNET_PRODUCTS_API_URL", server.URL)
+	defer os.Unsetenv("DOTNET_PRODUCTS_API_URL")
+	os.Setenv("UPSTREAM_MAX_ATTEMPTS", "1")
+	defer os.Unsetenv("UPSTREAM_MAX_ATTEMPTS")
+	productsCache.invalidate()
+	defer productsCache.invalidate()
+
+	order := PlaceOrderRequest{Items: []OrderItemRequest{{Id: "prod1", Quantity: 1, Price: 99.99}}, TotalAmount: pricePtr(99.99), DeliveryAddress: "1 Main St"}
+	place := func() int {
+		rr := httptest.NewRecorder()
+		orderHandler(rr, postJSON(t, "/order", order))
+		return rr.Code
+	}
+
+	os.Setenv("PRODUCTS_TIMEOUT", "20ms")
+	os.Setenv("ORDER_TIMEOUT", "2s")
+	if status, _ := getProducts(t, ""); status != http.StatusBadGateway {
+		t.Errorf("products with a 20ms timeout: got %v want %v", status, http.StatusBadGateway)
+	}
+	if status := place(); status != http.StatusOK {
+		t.Errorf("order with a 2s timeout: got %v want %v", status, http.StatusOK)
+	}
+
+	os.Setenv("PRODUCTS_TIMEOUT", "2s")
+	os.Setenv("ORDER_TIMEOUT", "20ms")
+	defer os.Unsetenv("PRODUCTS_TIMEOUT")
+	defer os.Unsetenv("ORDER_TIMEOUT")
+	if status, _ := getProducts(t, ""); status != http.StatusOK {
+		t.Errorf("products with a 2s timeout: got %v want %v", status, http.StatusOK)
+	}
+	if status := place(); status != http.StatusBadGateway {
+		t.Errorf("order with a 20ms timeout: got %v want %v", status, http.StatusBadGateway)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"runtime/debug"
+	"sync"
+)
+
+// buildVersion is this binary's version from its build info: the module version when built from a
+// tagged release, else the VCS revision it was built from, else "dev"
+var buildVersion = sync.OnceValue(func() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "dev"
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" && setting.Value != "" {
+			if len(setting.Value) > 12 {
+				return setting.Value[:12]
+			}
+			return setting.Value
+		}
+	}
+	return "dev"
+})
+
+// upstreamUserAgent is the User-Agent sent on outbound requests: UPSTREAM_USER_AGENT, defaulting to
+// shopping-cart-go/<version> so the calls are easy to pick out in the Dotnet service's logs
+func upstreamUserAgent() string {
+	if ua := os.Getenv("UPSTREAM_USER_AGENT"); ua != "" {
+		return ua
+	}
+	return "shopping-cart-go/" + buildVersion()
+}
+
+// userAgentTransport sets upstreamUserAgent on every request it sends, along with any client headers
+// the request's context carries from FORWARD_HEADERS
+type userAgentTransport struct {
+	base http.RoundTripper
+}
+
+func (t userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the caller's request, so set them on a clone
+	req = req.Clone(req.Context())
+	for name, values := range forwardedHeaders(req.Context()) {
+		req.Header[name] = values
+	}
+	req.Header.Set("User-Agent", upstreamUserAgent())
+
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestUpstreamUserAgent tests that the default and configured User-Agent reach the Dotnet service and the price webhook
+func TestUpstreamUserAgent(t *testing.T) {
+	var got atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.Store(r.Header.Get("User-Agent"))
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+	client := HTTPDotnetClient{BaseURL: server.URL}
+
+	if _, err := client.GetProducts(context.Background()); err != nil {
+		t.Fatalf("GetProducts: %v", err)
+	}
+	if ua, _ := got.Load().(string); !strings.HasPrefix(ua, "shopping-cart-go/") || ua == "shopping-cart-go/" {
+		t.Errorf("default User-Agent = %q, want shopping-cart-go/<version>", ua)
+	}
+
+	os.Setenv("UPSTREAM_USER_AGENT", "cart-api/1.2 (+ops@example.com)")
+	defer os.Unsetenv("UPSTREAM_USER_AGENT")
+	if _, err := client.GetProducts(context.Background()); err != nil {
+		t.Fatalf("GetProducts: %v", err)
+	}
+	if ua, _ := got.Load().(string); ua != "cart-api/1.2 (+ops@example.com)" {
+		t.Errorf("User-Agent = %q, want UPSTREAM_USER_AGENT", ua)
+	}
+	got.Store("")
+	sendPriceChangeWebhook(server.URL, time.Second, "", nil, time.Now())
+	if ua, _ := got.Load().(string); ua != "cart-api/1.2 (+ops@example.com)" {
+		t.Errorf("webhook User-Agent = %q, want UPSTREAM_USER_AGENT", ua)
+	}
+}
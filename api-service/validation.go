@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// defaultAddressMaxLength caps delivery addresses when ADDRESS_MAX_LENGTH is not set
+const defaultAddressMaxLength = 500
+
+// Order size caps when MAX_ITEM_QUANTITY / MAX_ORDER_ITEMS are not set
+const (
+	defaultMaxItemQuantity = 100 // Units of one line item
+	defaultMaxOrderItems   = 50  // Line items in one order
+)
+
+// normalizeAddress trims the delivery address and collapses internal whitespace (including newlines)
+// to single spaces, rejecting empty addresses, control characters and addresses over ADDRESS_MAX_LENGTH
+func normalizeAddress(address string) (string, error) {
+	normalized := strings.Join(strings.Fields(address), " ")
+	if normalized == "" {
+		return "", errors.New("Delivery address is required")
+	}
+	for _, r := range normalized {
+		if unicode.IsControl(r) {
+			return "", errors.New("Delivery address contains invalid characters")
+		}
+	}
+	if maxLen := envInt("ADDRESS_MAX_LENGTH", defaultAddressMaxLength); utf8.RuneCountInString(normalized) > maxLen {
+		return "", fmt.Errorf("Delivery address must be at most %d characters", maxLen)
+	}
+	return normalized, nil
+}
+
+// FieldError describes one invalid field of a request, addressed by its JSON path (e.g. "items[2].quantity")
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrorResponse is the 400 body for a request with invalid fields
+type ValidationErrorResponse struct {
+	Error  string       `json:"error"`
+	Fields []FieldError `json:"fields"`
+}
+
+// validationError carries the field errors of an invalid request
+type validationError struct {
+	Fields []FieldError
+}
+
+func (e *validationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Field + " " + f.Message
+	}
+	return "Invalid order: " + strings.Join(msgs, "; ")
+}
+
+// validateOrder checks the order's items, total, delivery address, region and any email, normalizing
+// the items, address, region and email in place.
+// It returns every problem found rather than stopping at the first, so the form can flag them all.
+func validateOrder(order *PlaceOrderRequest) []FieldError {
+	var fields []FieldError
+	maxItems := envInt("MAX_ORDER_ITEMS", defaultMaxOrderItems)
+	maxQuantity := envInt("MAX_ITEM_QUANTITY", defaultMaxItemQuantity)
+	if len(order.Items) == 0 {
+		fields = append(fields, FieldError{Field: "items", Message: "must contain at least one item"})
+	} else if maxItems > 0 && len(order.Items) > maxItems {
+		fields = append(fields, FieldError{Field: "items", Message: fmt.Sprintf("must contain at most %d items, got %d", maxItems, len(order.Items))})
+	}
+	for i, item := range order.Items {
+		path := fmt.Sprintf("items[%d]", i)
+		if strings.TrimSpace(item.Id) == "" {
+			fields = append(fields, FieldError{Field: path + ".id", Message: "is required"})
+		}
+		if item.Quantity <= 0 {
+			fields = append(fields, FieldError{Field: path + ".quantity", Message: "must be > 0"})
+		} else if maxQuantity > 0 && item.Quantity > maxQuantity {
+			fields = append(fields, FieldError{Field: path + ".quantity", Message: fmt.Sprintf("must be <= %d for item %q", maxQuantity, item.Id)})
+		}
+		if item.Price < 0 {
+			fields = append(fields, FieldError{Field: path + ".price", Message: "must be >= 0"})
+		}
+	}
+	if order.TotalAmount != nil && *order.TotalAmount < 0 {
+		fields = append(fields, FieldError{Field: "totalAmount", Message: "must be >= 0"})
+	}
+	if order.MaxTotal != nil && *order.MaxTotal < 0 {
+		fields = append(fields, FieldError{Field: "maxTotal", Message: "must be >= 0"})
+	}
+
+	// Repeated product ids are merged into one line item unless MERGE_DUPLICATE_ITEMS=false; the
+	// merged quantity is held to the same cap as a single line item
+	merge := envBool("MERGE_DUPLICATE_ITEMS", true)
+	fields = append(fields, duplicateItemErrors(order.Items, merge)...)
+	if merge && len(fields) == 0 {
+		order.Items = mergeItems(order.Items)
+		for _, item := range order.Items {
+			if maxQuantity > 0 && item.Quantity > maxQuantity {
+				fields = append(fields, FieldError{Field: "items", Message: fmt.Sprintf("total quantity of item %q must be <= %d", item.Id, maxQuantity)})
+			}
+		}
+	}
+
+	address, err := normalizeAddress(order.DeliveryAddress)
+	if err != nil {
+		fields = append(fields, FieldError{Field: "deliveryAddress", Message: err.Error()})
+	} else {
+		order.DeliveryAddress = address
+	}
+
+	region, err := normalizeRegion(order.Region)
+	if err != nil {
+		fields = append(fields, FieldError{Field: "region", Message: err.Error()})
+	} else {
+		order.Region = region
+	}
+
+	// A contact email is forwarded lowercased, so the Dotnet service sees one form per address
+	if email := strings.TrimSpace(order.Email); email != "" {
+		if err := validateEmail(email); err != nil {
+			fields = append(fields, FieldError{Field: "email", Message: err.Error()})
+		} else {
+			order.Email = normalizeEmail(email)
+		}
+	}
+	return fields
+}
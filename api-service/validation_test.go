@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestNormalizeAddress tests trimming, whitespace collapsing, length and character rejection
+func TestNormalizeAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		want    string
+		wantErr bool
+	}{
+		{"already clean", "1 Main St, Springfield", "1 Main St, Springfield", false},
+		{"trims ends", "  1 Main St  ", "1 Main St", false},
+		{"collapses internal whitespace", "1  Main\tSt,\n Springfield", "1 Main St, Springfield", false},
+		{"unicode", "  Königstraße 1,  Berlin ", "Königstraße 1, Berlin", false},
+		{"empty", "", "", true},
+		{"whitespace only", " \t\n ", "", true},
+		{"control character", "1 Main St\x00", "", true},
+		{"escape sequence", "1 Main St\x1b[31m", "", true},
+		{"at max length", strings.Repeat("a", defaultAddressMaxLength), strings.Repeat("a", defaultAddressMaxLength), false},
+		{"over max length", strings.Repeat("a", defaultAddressMaxLength+1), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeAddress(tt.address)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("normalizeAddress(%q) error = %v, wantErr %v", tt.address, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("normalizeAddress(%q) = %q, want %q", tt.address, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestNormalizeAddress_ConfiguredMaxLength tests that ADDRESS_MAX_LENGTH overrides the default
+func TestNormalizeAddress_ConfiguredMaxLength(t *testing.T) {
+	os.Setenv("ADDRESS_MAX_LENGTH", "10")
+	defer os.Unsetenv("ADDRESS_MAX_LENGTH")
+
+	if _, err := normalizeAddress("1 Main Street"); err == nil {
+		t.Error("expected an error for an address over the configured max length")
+	}
+}
+
+// TestOrderHandler_NormalizesAddress tests that the normalized address is forwarded and blank ones rejected
+func TestOrderHandler_NormalizesAddress(t *testing.T) {
+	dotnet := newFakeDotnet(t, nil)
+	order := PlaceOrderRequest{Items: []OrderItemRequest{{Id: "prod1", Quantity: 1, Price: 5}}, TotalAmount: pricePtr(5)}
+
+	order.DeliveryAddress = "  1  Main St \n Springfield "
+	rr := httptest.NewRecorder()
+	orderHandler(rr, postJSON(t, "/order", order))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if got := dotnet.lastOrder(t).DeliveryAddress; got != "1 Main St Springfield" {
+		t.Errorf("forwarded address = %q, want %q", got, "1 Main St Springfield")
+	}
+
+	order.DeliveryAddress = "   "
+	rr = httptest.NewRecorder()
+	orderHandler(rr, postJSON(t, "/order", order))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code for blank address: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+// TestValidateOrder tests field paths, including item indices, for invalid orders
+func TestValidateOrder(t *testing.T) {
+	order := PlaceOrderRequest{
+		Items: []OrderItemRequest{
+			{Id: "p1", Quantity: 1, Price: 10},
+			{Id: "", Quantity: 2, Price: 10},
+			{Id: "p3", Quantity: 0, Price: -1},
+		},
+		TotalAmount: pricePtr(-5),
+	}
+
+	want := []FieldError{
+		{Field: "items[1].id", Message: "is required"},
+		{Field: "items[2].quantity", Message: "must be > 0"},
+		{Field: "items[2].price", Message: "must be >= 0"},
+		{Field: "totalAmount", Message: "must be >= 0"},
+		{Field: "deliveryAddress", Message: "Delivery address is required"},
+	}
+	if got := validateOrder(&order); !reflect.DeepEqual(got, want) {
+		t.Errorf("validateOrder() = %+v, want %+v", got, want)
+	}
+
+	empty := PlaceOrderRequest{DeliveryAddress: "1 Main St"}
+	if got := validateOrder(&empty); len(got) != 1 || got[0].Field != "items" {
+		t.Errorf("validateOrder() for no items = %+v, want an items error", got)
+	}
+}
+
+// TestValidateOrder_SizeCaps tests MAX_ITEM_QUANTITY and MAX_ORDER_ITEMS at and above their limits
+func TestValidateOrder_SizeCaps(t *testing.T) {
+	items := func(n, quantity int) []OrderItemRequest {
+		list := make([]OrderItemRequest, n)
+		for i := range list {
+			list[i] = OrderItemRequest{Id: fmt.Sprintf("p%d", i), Quantity: quantity, Price: 1}
+		}
+		return list
+	}
+	tests := []struct {
+		name  string
+		items []OrderItemRequest
+		want  []FieldError
+	}{
+		{"quantity at cap", items(1, 100), nil},
+		{"quantity above cap", items(1, 101), []FieldError{{Field: "items[0].quantity", Message: `must be <= 100 for item "p0"`}}},
+		{"items at cap", items(50, 1), nil},
+		{"items above cap", items(51, 1), []FieldError{{Field: "items", Message: "must contain at most 50 items, got 51"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := PlaceOrderRequest{Items: tt.items, DeliveryAddress: "1 Main St"}
+			if got := validateOrder(&order); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateOrder() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	os.Setenv("MAX_ITEM_QUANTITY", "5")
+	defer os.Unsetenv("MAX_ITEM_QUANTITY")
+	os.Setenv("MAX_ORDER_ITEMS", "2")
+	defer os.Unsetenv("MAX_ORDER_ITEMS")
+	order := PlaceOrderRequest{Items: items(3, 6), DeliveryAddress: "1 Main St"}
+	if got := validateOrder(&order); len(got) != 4 {
+		t.Errorf("validateOrder() with configured caps = %+v, want an items error and three quantity errors", got)
+	}
+}
+
+// TestOrderHandler_FieldErrors tests that invalid orders are rejected with a 400 listing the fields
+func TestOrderHandler_FieldErrors(t *testing.T) {
+	newFakeDotnet(t, nil)
+	order := PlaceOrderRequest{
+		Items:           []OrderItemRequest{{Id: "p1", Quantity: 1, Price: 5}, {Id: "p2", Quantity: -1, Price: 5}},
+		TotalAmount:     pricePtr(5),
+		DeliveryAddress: "1 Main St",
+	}
+
+	rr := httptest.NewRecorder()
+	orderHandler(rr, postJSON(t, "/order", order))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+	var resp ValidationErrorResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("could not decode validation response: %v", err)
+	}
+	want := []FieldError{{Field: "items[1].quantity", Message: "must be > 0"}}
+	if !reflect.DeepEqual(resp.Fields, want) {
+		t.Errorf("fields = %+v, want %+v", resp.Fields, want)
+	}
+}